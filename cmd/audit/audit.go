@@ -1,16 +1,29 @@
 package audit
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
 	"slices"
+	"strings"
+	"syscall"
 	"time"
 
+	goversion "github.com/caarlos0/go-version"
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/attest/info"
 	goutils "github.com/loicsikidi/go-utils"
 	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
 	"github.com/loicsikidi/tpm-trust/internal"
+	"github.com/loicsikidi/tpm-trust/internal/ekfile"
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
 	"github.com/loicsikidi/tpm-trust/internal/logutil"
+	"github.com/loicsikidi/tpm-trust/internal/output"
 	"github.com/loicsikidi/tpm-trust/internal/privilege"
 	"github.com/loicsikidi/tpm-trust/internal/tpm"
 	"github.com/loicsikidi/tpm-trust/internal/validate"
@@ -20,12 +33,364 @@ import (
 )
 
 type options struct {
-	keyType             string
-	skipRevocationCheck bool
-	verbose             bool
+	keyType                string
+	skipRevocationCheck    bool
+	revocationCheck        string
+	requireRevocationCheck bool
+	verbose                bool
+	explain                bool
+	userAgent              string
+	ipFamily               string
+	directHosts            []string
+	allowedManufacturers   []string
+	disallowSHA1           bool
+	minRSABits             int
+	allowedCurves          []string
+	ekCert                 string
+	ekDir                  string
+	intermediatesDir       string
+	trustAnchor            string
+	includeSystemRoots     bool
+	akCert                 string
+	platformCert           string
+	platformCA             string
+	format                 string
+	jsonPretty             bool
+	tree                   bool
+	logFormat              string
+	strictManufacturer     bool
+	useCache               bool
+	cacheDir               string
+	timeout                time.Duration
+	downloadTimeout        time.Duration
+	maxBundleAge           time.Duration
+	bundleAgeWarnOnly      bool
+	embeddedBundle         bool
+	outputFile             string
+	signWithAK             bool
+	ekSource               string
+	waitForTPM             time.Duration
+	tpmDevice              string
+	allTPMs                bool
+	nvReadSize             int
+	endorsementAuth        string
+	strictExtensions       bool
+	strictKeyUsage         bool
+	requireAIA             bool
+	ctLogList              string
+	requireSCT             bool
+	failFast               bool
+	noColor                bool
+	selectCert             string
+	watch                  time.Duration
+	listen                 string
+	deadline               time.Duration
+	redact                 bool
+	downloadManifest       string
+	cpuProfile             string
+	memProfile             string
+
+	// redactor masks the identifiers of the audited certificates (set by run with --redact).
+	redactor *redactor
+}
+
+// eccKeyTypes lists the key types accepted by --allowed-curves.
+var eccKeyTypes = []tpm.KeyType{
+	tpm.KeyTypeECCNistP256,
+	tpm.KeyTypeECCNistP384,
+	tpm.KeyTypeECCNistP521,
+	tpm.KeyTypeECCSM2P256,
+}
+
+// Revocation check modes (--revocation-check).
+const (
+	revocationOff     = "off"
+	revocationSoft    = "soft"
+	revocationEnforce = "enforce"
+)
+
+// revocationMode returns the revocation check mode, --skip-revocation-check meaning off.
+func (o *options) revocationMode() string {
+	switch {
+	case o.skipRevocationCheck:
+		return revocationOff
+	case o.revocationCheck == "":
+		return revocationEnforce
+	default:
+		return o.revocationCheck
+	}
+}
+
+// Check validates the options.
+func (o *options) Check() error {
+	switch o.revocationCheck {
+	case "", revocationOff, revocationSoft, revocationEnforce:
+	default:
+		return fmt.Errorf("unsupported --revocation-check %q (supported: off, soft, enforce)", o.revocationCheck)
+	}
+	if o.skipRevocationCheck && o.revocationCheck == revocationSoft {
+		return fmt.Errorf("--skip-revocation-check and --revocation-check=soft are mutually exclusive")
+	}
+	if o.revocationMode() == revocationOff && o.requireRevocationCheck {
+		return fmt.Errorf("--require-revocation cannot be used when the revocation check is off")
+	}
+	switch o.format {
+	case "text", "json", "jsonl", "in-toto", "csv":
+	default:
+		return fmt.Errorf("unsupported format %q (supported: text, json, jsonl, in-toto, csv)", o.format)
+	}
+	if o.tree && o.format != "text" {
+		return fmt.Errorf("--tree requires --format text")
+	}
+	if o.tree && (o.ekDir != "" || o.allTPMs) {
+		return fmt.Errorf("--tree cannot be used with --ek-dir or --all-tpms")
+	}
+	if o.logFormat != "text" && o.logFormat != "json" {
+		return fmt.Errorf("unsupported log format %q (supported: text, json)", o.logFormat)
+	}
+	if o.ipFamily != "" && !slices.Contains(httpclient.IPFamilies, httpclient.IPFamily(o.ipFamily)) {
+		return fmt.Errorf("unsupported --ip-family %q (supported: auto, v4, v6)", o.ipFamily)
+	}
+	if len(o.directHosts) > 0 {
+		transport := httpclient.TransportConfig{DirectHosts: o.directHosts}
+		if err := transport.CheckAndSetDefaults(); err != nil {
+			return fmt.Errorf("invalid --direct-hosts: %w", err)
+		}
+	}
+	if o.ekCert != "" && o.ekDir != "" {
+		return fmt.Errorf("--ek-cert and --ek-dir are mutually exclusive")
+	}
+	if o.akCert != "" && o.ekDir != "" {
+		return fmt.Errorf("--ak-cert cannot be used with --ek-dir")
+	}
+	if o.trustAnchor != "" && o.maxBundleAge > 0 {
+		return fmt.Errorf("--max-bundle-age cannot be used with --trust-anchor")
+	}
+	if o.trustAnchor != "" && o.embeddedBundle {
+		return fmt.Errorf("--embedded-bundle cannot be used with --trust-anchor")
+	}
+	if o.trustAnchor != "" && o.includeSystemRoots {
+		return fmt.Errorf("--include-system-roots cannot be used with --trust-anchor")
+	}
+	if o.requireSCT && o.ctLogList == "" {
+		return fmt.Errorf("--require-sct requires --ct-log-list")
+	}
+	if (o.platformCert == "") != (o.platformCA == "") {
+		return fmt.Errorf("--platform-cert and --platform-ca must be set together")
+	}
+	if o.platformCert != "" && o.ekDir != "" {
+		return fmt.Errorf("--platform-cert cannot be used with --ek-dir")
+	}
+	if o.platformCertFromNV() && o.fromFile() {
+		return fmt.Errorf("reading the platform certificate from an NV index requires reading the EK certificate from the TPM")
+	}
+	if o.signWithAK && o.outputFile == "" && !o.machineOutput() {
+		return fmt.Errorf("--sign-with-ak requires --output-file or a machine-readable --format")
+	}
+	if o.signWithAK && (o.fromFile() || o.allTPMs) {
+		return fmt.Errorf("--sign-with-ak requires reading the EK certificate from a single TPM")
+	}
+	if o.fromFile() && o.endorsementAuth != "" {
+		return fmt.Errorf("--endorsement-auth requires reading the EK certificate from the TPM")
+	}
+	if o.fromFile() && o.keyType != "" {
+		return fmt.Errorf("key type cannot be set when auditing EK certificate files")
+	}
+	if o.fromFile() && len(o.allowedManufacturers) > 0 {
+		return fmt.Errorf("--allow-manufacturer requires reading the EK certificate from the TPM")
+	}
+	if o.fromFile() && o.strictManufacturer {
+		return fmt.Errorf("--strict-manufacturer requires reading the EK certificate from the TPM")
+	}
+	if o.selectCert != "" {
+		if _, err := o.certSelector(); err != nil {
+			return err
+		}
+		if o.fromFile() || o.keyType != "" || o.ekSource == tpm.SourcePCP.String() {
+			return fmt.Errorf("--select-cert cannot be combined with a key type, --source pcp or EK certificate files")
+		}
+	}
+	if o.watch < 0 {
+		return fmt.Errorf("invalid --watch: %s (must be positive)", o.watch)
+	}
+	if o.watch > 0 && o.ekCert == ekfile.Stdin {
+		return fmt.Errorf("--watch cannot be used when reading the EK certificate from the standard input")
+	}
+	if o.redact && o.format == "in-toto" {
+		return fmt.Errorf("--redact cannot be used with --format in-toto (its subject is the EK public key digest)")
+	}
+	if o.format == "in-toto" && o.ekDir != "" {
+		return fmt.Errorf("--format in-toto cannot be used with --ek-dir")
+	}
+	if o.watch > 0 && o.ekDir != "" {
+		return fmt.Errorf("--watch cannot be used with --ek-dir")
+	}
+	if o.watch > 0 && o.format == "csv" {
+		return fmt.Errorf("--format csv cannot be used with --watch (see --format jsonl)")
+	}
+	if o.downloadManifest != "" && o.watch > 0 {
+		return fmt.Errorf("--download-manifest cannot be used with --watch")
+	}
+	if o.listen != "" && o.watch == 0 {
+		return fmt.Errorf("--listen requires --watch")
+	}
+	if o.waitForTPM < 0 {
+		return fmt.Errorf("invalid --wait-for-tpm: %s (must be positive)", o.waitForTPM)
+	}
+	if o.fromFile() && o.waitForTPM > 0 {
+		return fmt.Errorf("--wait-for-tpm requires reading the EK certificate from the TPM")
+	}
+	if o.tpmDevice != "" || o.allTPMs {
+		if o.tpmDevice != "" && o.allTPMs {
+			return fmt.Errorf("--tpm-device and --all-tpms are mutually exclusive")
+		}
+		if o.fromFile() {
+			return fmt.Errorf("--tpm-device and --all-tpms require reading the EK certificate from the TPM")
+		}
+		if !tpm.DevicesSupported() {
+			return tpm.ErrDevicesNotSupported
+		}
+	}
+	if o.nvReadSize < 0 {
+		return fmt.Errorf("invalid --nv-read-size: %d (must be positive)", o.nvReadSize)
+	}
+	if o.nvReadSize > 0 && (o.fromFile() || o.ekSource == tpm.SourcePCP.String()) {
+		return fmt.Errorf("--nv-read-size requires reading the EK certificate from the TPM NV storage")
+	}
+	if o.allTPMs && (o.watch > 0 || o.akCert != "" || o.platformCert != "" || o.format == "in-toto") {
+		return fmt.Errorf("--all-tpms cannot be used with --watch, --ak-cert, --platform-cert or --format in-toto")
+	}
+	switch tpm.Source(o.ekSource) {
+	case "", tpm.SourceNV:
+	case tpm.SourcePCP:
+		if o.fromFile() {
+			return fmt.Errorf("--source cannot be used when auditing EK certificate files")
+		}
+		if !tpm.PCPSupported() {
+			return fmt.Errorf("unsupported source %q: %w", o.ekSource, tpm.ErrPCPNotSupported)
+		}
+	default:
+		return fmt.Errorf("unsupported source %q (supported: nv, pcp)", o.ekSource)
+	}
+	if o.timeout <= 0 || o.downloadTimeout <= 0 {
+		return fmt.Errorf("--timeout and --download-timeout must be positive")
+	}
+	if o.downloadTimeout > o.timeout {
+		return fmt.Errorf("--download-timeout (%s) cannot exceed --timeout (%s)", o.downloadTimeout, o.timeout)
+	}
+	if o.maxBundleAge < 0 {
+		return fmt.Errorf("invalid --max-bundle-age: %s (must be positive)", o.maxBundleAge)
+	}
+	if o.deadline < 0 {
+		return fmt.Errorf("invalid --deadline: %s (must be positive)", o.deadline)
+	}
+	if o.bundleAgeWarnOnly && o.maxBundleAge == 0 {
+		return fmt.Errorf("--bundle-age-warn-only requires --max-bundle-age")
+	}
+	if o.minRSABits < 0 {
+		return fmt.Errorf("invalid --min-rsa-bits: %d (must be positive)", o.minRSABits)
+	}
+	for _, curve := range o.allowedCurves {
+		if !slices.Contains(eccKeyTypes, tpm.KeyType(curve)) {
+			return fmt.Errorf("invalid curve: %s (must be one of: %s)", curve, strings.Join(goutils.Map(eccKeyTypes, tpm.KeyType.String), ", "))
+		}
+	}
+	return nil
+}
+
+// isManufacturerAllowed reports whether the manufacturer matches the allowlist
+// (by ASCII identifier or name, case-insensitive). An empty allowlist allows everything.
+func (o *options) isManufacturerAllowed(m info.Manufacturer) bool {
+	if len(o.allowedManufacturers) == 0 {
+		return true
+	}
+	id := strings.TrimRight(m.ASCII, " \x00")
+	return slices.ContainsFunc(o.allowedManufacturers, func(allowed string) bool {
+		allowed = strings.TrimSpace(allowed)
+		return strings.EqualFold(allowed, id) || strings.EqualFold(allowed, m.Name)
+	})
+}
+
+// certSelector returns the parsed --select-cert, if set.
+func (o *options) certSelector() (*tpm.CertSelector, error) {
+	if o.selectCert == "" {
+		return nil, nil
+	}
+	return tpm.ParseCertSelector(o.selectCert)
+}
+
+// jsonOutput reports whether the result is written as JSON on stdout (see --format).
+func (o *options) jsonOutput() bool {
+	return o.format == "json" || o.format == "jsonl" || o.format == "in-toto"
+}
+
+// machineOutput reports whether the result is written on stdout in a
+// machine-readable format (JSON or CSV), which logs must not contaminate.
+func (o *options) machineOutput() bool {
+	return o.jsonOutput() || o.format == "csv"
+}
+
+// prettyJSON reports whether the JSON written on stdout is indented: JSON
+// lines are always compact, one result per line.
+func (o *options) prettyJSON() bool {
+	return o.jsonPretty && o.format != "jsonl"
+}
+
+// fromFile reports whether EK certificates are read from files instead of the TPM.
+func (o *options) fromFile() bool {
+	return o.ekCert != "" || o.ekDir != ""
+}
+
+// source describes where the EK certificate is read from.
+func (o *options) source() string {
+	if o.ekCert == ekfile.Stdin {
+		return sourceStdin
+	}
+	if o.ekCert != "" {
+		return o.ekCert
+	}
+	if o.tpmDevice != "" {
+		return o.tpmDevice
+	}
+	return sourceTPM
+}
+
+// httpConfig builds the config of the client downloading the trusted bundle,
+// issuers and CRLs.
+func (o *options) httpConfig() httpclient.Config {
+	return httpclient.Config{
+		UserAgent: o.userAgent,
+		Transport: httpclient.TransportConfig{IPFamily: httpclient.IPFamily(o.ipFamily), DirectHosts: o.directHosts},
+	}
 }
 
-func NewCommand() *cobra.Command {
+// checkConfig builds the validation config of ek from the options.
+// manufacturer is the one reported by the TPM, if any.
+func (o *options) checkConfig(ek endorsement.EK, manufacturer *info.Manufacturer) validate.CheckConfig {
+	cfg := validate.CheckConfig{
+		EK:                     ek,
+		Manufacturer:           manufacturer,
+		StrictManufacturer:     o.strictManufacturer,
+		StrictExtensions:       o.strictExtensions,
+		StrictKeyUsage:         o.strictKeyUsage,
+		RequireAIA:             o.requireAIA,
+		RequireSCT:             o.requireSCT,
+		CollectErrors:          !o.failFast,
+		SkipRevocationCheck:    o.revocationMode() == revocationOff,
+		SoftRevocationCheck:    o.revocationMode() == revocationSoft,
+		RequireRevocationCheck: o.requireRevocationCheck,
+		KeyPolicy: validate.KeyPolicy{
+			MinRSABits:    o.minRSABits,
+			AllowedCurves: goutils.Map(o.allowedCurves, func(c string) tpm.KeyType { return tpm.KeyType(c) }),
+		},
+	}
+	if o.disallowSHA1 {
+		cfg.DisallowedSignatureAlgorithms = validate.SHA1SignatureAlgorithms
+	}
+	return cfg
+}
+
+func NewCommand(info goversion.Info) *cobra.Command {
 	opts := &options{}
 
 	cmd := &cobra.Command{
@@ -39,21 +404,165 @@ Available key types (KTY):
   - ecc-nist-p256, ecc-nist-p384, ecc-nist-p521
   - ecc-sm2-p256
 
+The EK certificate can also be read from a PEM or DER file (--ek-cert),
+or from every certificate file of a directory (--ek-dir). In the latter case,
+a verdict is reported per file followed by a summary. A PEM file may also
+hold the chain of the EK certificate, which is then used to verify it.
+
+On hosts with several TPMs (eg. a hardware and a virtual one), the TPM device
+can be selected (--tpm-device) or every device audited independently
+(--all-tpms), in which case a verdict is reported per device followed by
+a summary.
+
 Exit codes:
-  0 - TPM is trusted
-  1 - TPM is not trusted or validation failed`,
+  0 - TPM is trusted (all files or devices in batch mode)
+  1 - TPM is not trusted or validation failed
+  3 - TPM manufacturer is not part of the trusted bundle`,
 		Example: `  # Audit the TPM
   tpm-trust audit
   
   ## Audit without revocation check
   tpm-trust audit --skip-revocation-check
 
+  ## Only warn if the EK certificate is revoked or its CRL cannot be downloaded
+  tpm-trust audit --revocation-check soft
+
+  ## Fail if the revocation status cannot be checked
+  tpm-trust audit --require-revocation
+
   ## Audit with verbose logging
   tpm-trust audit --verbose
+
+  ## Explain why the certificate is trusted (or not)
+  tpm-trust audit --explain
+
+  ## Emit structured JSON logs (eg. for journald or container log collectors)
+  tpm-trust audit --log-format json
+
+  ## Disable colors in logs (eg. in CI, where they are forced by default)
+  tpm-trust audit --no-color
   
+  ## Fail if the EK certificate was issued for another manufacturer than the TPM's
+  tpm-trust audit --strict-manufacturer
+
+  ## Reject EK certificates with unrecognized critical extensions (RFC 5280)
+  tpm-trust audit --strict-extensions
+
+  ## Reject EK certificates whose key usage doesn't conform to the TCG EK profile
+  tpm-trust audit --strict-key-usage
+
+  ## Reject EK certificates without AIA issuing certificate URL
+  tpm-trust audit --require-aia
+
+  ## Reject EK certificates without a valid SCT from a known CT log
+  tpm-trust audit --ct-log-list log_list.json --require-sct
+
+  ## Report every failure of the EK certificate instead of the first one
+  tpm-trust audit --fail-fast=false
+
+  ## Only accept TPMs from Infineon or Nuvoton
+  tpm-trust audit --allow-manufacturer IFX --allow-manufacturer NTC
+
+  ## Give slow CRL endpoints more time
+  tpm-trust audit --timeout 30s --download-timeout 10s
+
+  ## Show the verified chain as a tree
+  tpm-trust audit --tree
+
+  ## Record the URL, HTTP status, size and SHA-256 of every download
+  tpm-trust audit --download-manifest downloads.json
+
+  ## Mask the identifiers of the EK certificate (eg. to share the output in a public issue)
+  tpm-trust audit --verbose --format json --redact
+
+  ## Only download over IPv6 (eg. IPv6-only network)
+  tpm-trust audit --ip-family v6
+
+  ## Reach the manufacturer CDNs directly, everything else through HTTPS_PROXY
+  tpm-trust audit --direct-hosts pki.infineon.com,tsci.intel.com
+
+  ## Abort the audit if it takes longer than 1 minute overall
+  tpm-trust audit --deadline 1m
+
+  ## Fail if the trusted bundle is older than 30 days
+  tpm-trust audit --max-bundle-age 720h
+
+  ## Use the trusted bundle embedded at build time (eg. on an offline machine)
+  tpm-trust audit --embedded-bundle
+
+  ## Only trust a specific root instead of the trusted bundle
+  tpm-trust audit --trust-anchor ./root.pem
+
+  ## Also accept roots of the system store (eg. a private CA of a lab)
+  tpm-trust audit --include-system-roots
+
   ## Audit a specific key type
-  tpm-trust audit rsa-2048`,
+  tpm-trust audit rsa-2048
+
+  ## Wait up to 1 minute for the TPM to be available (eg. early in the boot sequence)
+  tpm-trust audit --wait-for-tpm 1m
+
+  ## Read the EK certificate through the Platform Crypto Provider first (Windows only)
+  tpm-trust audit --source pcp
+
+  ## Audit a specific TPM device (Linux only)
+  tpm-trust audit --tpm-device /dev/tpmrm1
+
+  ## Audit every TPM device of the host (Linux only)
+  tpm-trust audit --all-tpms
+
+  ## Generate the EK under an endorsement hierarchy protected by an authorization value
+  tpm-trust audit --endorsement-auth file:/etc/tpm/endorsement-auth
+
+  ## Read 1234 bytes from the EK certificate NV index (TPM reporting a wrong size)
+  tpm-trust audit --nv-read-size 1234
+
+  ## Audit the EK certificate stored at a specific NV index
+  tpm-trust audit --select-cert 0x1C0000A
+
+  ## Audit an EK certificate file
+  tpm-trust audit --ek-cert ek.pem
+
+  ## Audit offline a PEM bundle holding the EK certificate along with its chain
+  tpm-trust audit --ek-cert ek-chain.pem --embedded-bundle --skip-revocation-check
+
+  ## Audit an EK certificate piped by another tool
+  cat ek.der | tpm-trust audit --ek-cert -
+
+  ## Audit the TPM along with its IAK certificate
+  tpm-trust audit --ak-cert iak.pem
+
+  ## Reuse the chain and CRLs cached by a previous audit (no network call while they are fresh)
+  tpm-trust audit --use-cache
+
+  ## Write the verdict to a file polled by a monitoring agent
+  tpm-trust audit --output-file /var/lib/tpm-trust/result.json
+
+  ## Sign the verdict with an attestation key of the TPM (result.json.sig)
+  tpm-trust audit --output-file result.json --sign-with-ak
+
+  ## Sign the JSON result written to stdout (the signature goes to stderr)
+  tpm-trust audit --format json --sign-with-ak > result.json 2> result.json.sig
+
+  ## Audit every hour, keeping the latest verdict in a file
+  tpm-trust audit --watch 1h --output-file /var/lib/tpm-trust/result.json
+
+  ## Output an in-toto statement to sign (eg. with cosign attest-blob)
+  tpm-trust audit --format in-toto --json-pretty=false > statement.json
+
+  ## Audit all EK certificate files of a directory and output JSON
+  tpm-trust audit --ek-dir ./certs --format json
+
+  ## Stream one JSON result per line (eg. to a log shipper)
+  tpm-trust audit --ek-dir ./certs --format jsonl
+  tpm-trust audit --watch 1h --format jsonl
+
+  ## Export the verdicts of a fleet for spreadsheet review
+  tpm-trust audit --ek-dir ./certs --format csv > audit.csv`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed(output.JSONPrettyFlag) {
+				opts.jsonPretty = output.IsTerminal(os.Stdout)
+			}
 			opts.keyType = goutils.OptionalArg(args)
 			return run(cmd.Context(), opts)
 		},
@@ -62,47 +571,603 @@ Exit codes:
 		SilenceErrors: true,
 	}
 
-	cmd.Flags().BoolVar(&opts.skipRevocationCheck, "skip-revocation-check", false, "Skip CRL revocation check")
-	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "Enable verbose logging")
+	cmd.Flags().BoolVar(&opts.skipRevocationCheck, "skip-revocation-check", false, "Skip CRL revocation check (same as --revocation-check=off)")
+	cmd.Flags().StringVar(&opts.revocationCheck, "revocation-check", revocationEnforce, "Revocation check mode: off, soft (only warn on revoked certificate or CRL download failure) or enforce")
+	cmd.Flags().BoolVar(&opts.requireRevocationCheck, "require-revocation", false, "Fail if the EK certificate has no CRL distribution point")
+	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "Enable verbose logging (streamed to stderr with --format json)")
+	cmd.Flags().BoolVar(&opts.explain, "explain", false, "Explain step by step how the certificate chain was built and verified (streamed to stderr with --format json)")
+	cmd.Flags().StringVar(&opts.logFormat, "log-format", "text", "Log format (text or json)")
+	cmd.Flags().BoolVar(&opts.noColor, "no-color", false, "Disable colors in logs (already disabled when the output is not a terminal)")
+	cmd.Flags().BoolVar(&opts.disallowSHA1, "disallow-sha1", false, "Fail if a certificate of the chain is signed with SHA-1 (or weaker)")
+	cmd.Flags().IntVar(&opts.minRSABits, "min-rsa-bits", 0, "Reject RSA EK keys below this size (eg. 2048)")
+	cmd.Flags().StringSliceVar(&opts.allowedCurves, "allowed-curves", nil, "Only accept ECC EK keys on these curves (eg. ecc-nist-p256,ecc-nist-p384)")
+	cmd.Flags().StringSliceVar(&opts.allowedManufacturers, "allow-manufacturer", nil, "Only accept TPMs from this manufacturer (ID or name, repeatable)")
+	cmd.Flags().BoolVar(&opts.strictManufacturer, "strict-manufacturer", false, "Fail if the EK certificate manufacturer attribute does not match the TPM manufacturer")
+	cmd.Flags().BoolVar(&opts.strictExtensions, "strict-extensions", false, "Fail if the EK certificate has critical extensions which are not processed (RFC 5280)")
+	cmd.Flags().BoolVar(&opts.strictKeyUsage, "strict-key-usage", false, "Fail if the EK certificate key usage does not conform to the TCG EK Credential Profile (keyEncipherment for RSA, keyAgreement for ECC)")
+	cmd.Flags().BoolVar(&opts.requireAIA, "require-aia", false, "Fail if the EK certificate has no AIA issuing certificate URL, even if its issuers are available locally")
+	cmd.Flags().StringVar(&opts.ctLogList, "ct-log-list", "", "Verify the SCTs embedded in the EK certificate against the Certificate Transparency logs of this JSON log list (v3 format)")
+	cmd.Flags().BoolVar(&opts.requireSCT, "require-sct", false, "Fail if the EK certificate has no valid SCT from a log of --ct-log-list")
+	cmd.Flags().BoolVar(&opts.failFast, "fail-fast", true, "Stop at the first failure of the EK certificate; use --fail-fast=false to report every failure (eg. revoked and untrusted) at once")
+	cmd.Flags().StringVar(&opts.selectCert, "select-cert", "", "Audit this EK certificate of NV storage instead of the automatic choice: NV index (eg. 0x1C0000A), position in the list of available certificates (eg. 2) or serial:<number>")
+	cmd.Flags().StringVar(&opts.ekSource, "source", tpm.SourceNV.String(), "Where EK certificates are read from first: nv (TPM NV storage) or pcp (Windows Platform Crypto Provider); the other one is used as a fallback")
+	cmd.Flags().StringVar(&opts.tpmDevice, "tpm-device", "", "Open this TPM device (eg. /dev/tpmrm1) instead of the first available one (Linux only)")
+	cmd.Flags().BoolVar(&opts.allTPMs, "all-tpms", false, "Audit every TPM device of the host and report a verdict per device (Linux only)")
+	cmd.Flags().StringVar(&opts.endorsementAuth, "endorsement-auth", "", "Authorization value of the endorsement hierarchy, needed to generate the EK if not empty: file:<path>, env:<variable>, hex:<value>, str:<value> or the value itself (visible in the process list); defaults to $"+endorsementAuthEnv)
+	cmd.Flags().IntVar(&opts.nvReadSize, "nv-read-size", 0, "Advanced: read this number of bytes from the EK certificate NV index instead of its reported size (eg. when the TPM reports a wrong size)")
+	cmd.Flags().DurationVar(&opts.waitForTPM, "wait-for-tpm", 0, "Keep trying to open the TPM for up to this duration if it is not ready yet (eg. early at boot)")
+	cmd.Flags().StringVar(&opts.ekCert, "ek-cert", "", "Audit the EK certificate from this PEM or DER file instead of the TPM ('-' for the standard input)")
+	cmd.Flags().StringVar(&opts.ekDir, "ek-dir", "", "Audit every EK certificate file (.pem, .crt, .cer, .der) of this directory")
+	cmd.Flags().StringVar(&opts.intermediatesDir, "intermediates", "", "Directory of intermediate CA certificates used before downloading issuers via AIA")
+	cmd.Flags().StringVar(&opts.trustAnchor, "trust-anchor", "", "Only trust the root of this PEM or DER file instead of the manufacturers trusted bundle")
+	cmd.Flags().BoolVar(&opts.includeSystemRoots, "include-system-roots", false, "Also trust the roots of the system certificate store; weakens the audit as any CA of the store may then vouch for the EK")
+	cmd.Flags().StringVar(&opts.akCert, "ak-cert", "", "Also audit this AK (eg. IAK) certificate file, which must chain to the same root as the EK")
+	cmd.Flags().StringVar(&opts.platformCert, "platform-cert", "", "Also audit this TCG platform certificate, which must reference the EK certificate: file or NV index (eg. 0x1C90000)")
+	cmd.Flags().StringVar(&opts.platformCA, "platform-ca", "", "File of the trusted platform CA certificates (PEM), required by --platform-cert")
+	cmd.Flags().BoolVar(&opts.useCache, "use-cache", false, "Verify the EK certificate against the chain and CRLs cached by a previous audit, only reaching out if they are stale")
+	cmd.Flags().StringVar(&opts.cacheDir, "cache-dir", "", "Directory of the --use-cache entries (default: user cache directory)")
+	cmd.Flags().StringVarP(&opts.format, "format", "f", "text", "Output format (text, json, jsonl, in-toto or csv); jsonl writes one compact JSON result per line, as soon as it is available")
+	cmd.Flags().DurationVar(&opts.watch, "watch", 0, "Keep running and audit again at this interval (eg. 1h) until interrupted")
+	cmd.Flags().StringVar(&opts.listen, "listen", "", "Serve the latest result over HTTP on this address (eg. :8080), requires --watch")
+	cmd.Flags().StringVar(&opts.outputFile, "output-file", "", "Also write the JSON result to this file (atomically replaced)")
+	cmd.Flags().BoolVar(&opts.signWithAK, "sign-with-ak", false, "Sign the result with an attestation key generated in the TPM, writing the detached signature to <output-file>.sig (stderr without --output-file)")
+	cmd.Flags().BoolVar(&opts.jsonPretty, output.JSONPrettyFlag, false, "Indent JSON output (default: true when stdout is a terminal)")
+	cmd.Flags().StringVar(&opts.downloadManifest, "download-manifest", "", "Write to this file a JSON manifest of every download (CRLs, issuer and EK certificates) with its URL, HTTP status, size and SHA-256")
+	cmd.Flags().BoolVar(&opts.redact, "redact", false, "Mask the identifiers of the EK certificate (serial number, subject, SANs, fingerprints) in logs and output, keeping the manufacturer, key type, issuers and verdict")
+	cmd.Flags().BoolVar(&opts.tree, "tree", false, "Print the verified chain as a tree (root, intermediates, EK) with the subject, validity and fingerprint of each certificate")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", validate.DefaultTimeout, "Overall deadline of the certificate chain verification (issuers and CRLs downloads)")
+	cmd.Flags().DurationVar(&opts.downloadTimeout, "download-timeout", validate.DefaultDownloadTimeout, "Timeout of a single issuer, CRL or EK certificate download (cannot exceed --timeout)")
+	cmd.Flags().DurationVar(&opts.deadline, "deadline", 0, "Abort the whole audit (TPM read, trusted bundle fetch and verification) if it takes longer than this duration (eg. 1m)")
+	cmd.Flags().DurationVar(&opts.maxBundleAge, "max-bundle-age", 0, "Fail if the trusted bundle was released longer ago than this duration (eg. 720h)")
+	cmd.Flags().BoolVar(&opts.bundleAgeWarnOnly, "bundle-age-warn-only", false, "Only warn when the trusted bundle exceeds --max-bundle-age")
+	cmd.Flags().BoolVar(&opts.embeddedBundle, "embedded-bundle", false, "Use the trusted bundle embedded at build time instead of fetching it")
+	cmd.Flags().StringVar(&opts.userAgent, "user-agent", httpclient.UserAgent(info.GitVersion), "User-Agent header sent with HTTP requests")
+	cmd.Flags().StringVar(&opts.ipFamily, "ip-family", string(httpclient.IPFamilyAuto), "Address family of the downloads: auto, v4 (IPv4 only) or v6 (IPv6 only)")
+	cmd.Flags().StringSliceVar(&opts.directHosts, "direct-hosts", nil, "Hosts (and their subdomains) downloaded from directly, bypassing the proxy of the environment (in addition to NO_PROXY)")
+	// Performance investigation only
+	cmd.Flags().StringVar(&opts.cpuProfile, "cpuprofile", "", "Write a pprof CPU profile of the command to this file")
+	cmd.Flags().StringVar(&opts.memProfile, "memprofile", "", "Write a pprof heap profile to this file when the command ends")
+	_ = cmd.Flags().MarkHidden("cpuprofile")
+	_ = cmd.Flags().MarkHidden("memprofile")
 	return cmd
 }
 
 func run(ctx context.Context, opts *options) error {
-	logger := log.New(log.WithVerbose(opts.verbose))
+	if err := opts.Check(); err != nil {
+		return err
+	}
+
+	logger := newLogger(opts, os.Stderr)
+	if opts.redact {
+		opts.redactor = &redactor{}
+		logger = log.NewRedactLogger(logger, opts.redactor.redact)
+	}
+	err := opts.redactor.redactError(execute(ctx, logger, opts))
+	if errors.Is(err, ErrUnsupportedManufacturer) {
+		err = internal.WithExitCode(err, exitUnsupportedManufacturer)
+	}
+	if err != nil && opts.logFormat == "json" && !errors.Is(err, internal.ErrSilence) {
+		// Keep every log entry structured, including the final error
+		entry := logger.WithError(err)
+		if code := errorCode(err); code != "" {
+			entry = entry.WithField("code", code)
+		}
+		entry.Error("command failed")
+		return internal.Silence(err)
+	}
+	return err
+}
+
+// newLogger creates the logger of the command.
+//
+// In JSON mode, logs are suppressed to keep stdout clean, unless --verbose (or
+// --explain) is set: they are then streamed to stderr so that the JSON result
+// (on stdout and in --output-file) is never contaminated.
+func newLogger(opts *options, stderr io.Writer) log.Logger {
+	if !opts.machineOutput() {
+		return log.New(log.WithVerbose(opts.verbose), log.WithJSON(opts.logFormat == "json"), log.WithNoColor(opts.noColor))
+	}
+	if !opts.verbose && !opts.explain {
+		return log.New(log.WithNoop())
+	}
+	return log.New(log.WithOutput(stderr), log.WithVerbose(opts.verbose), log.WithJSON(opts.logFormat == "json"), log.WithNoColor(opts.noColor))
+}
+
+func execute(ctx context.Context, logger log.Logger, opts *options) (err error) {
+	if !opts.fromFile() {
+		if err := privilege.Elevate(); err != nil {
+			return fmt.Errorf("failed to elevate privileges: %w", err)
+		}
+	}
+
+	// Started once the process is re-executed with elevated privileges, if need be
+	stopProfiling, err := startProfiling(opts.cpuProfile, opts.memProfile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if profileErr := stopProfiling(); profileErr != nil && err == nil {
+			err = profileErr
+		}
+	}()
+
+	if opts.useCache && opts.cacheDir == "" {
+		dir, err := defaultCacheDir()
+		if err != nil {
+			return fmt.Errorf("failed to get cache directory: %w", err)
+		}
+		opts.cacheDir = dir
+	}
+
+	cfg := opts.httpConfig()
+	if opts.downloadManifest != "" {
+		cfg.Recorder = &httpclient.Recorder{}
+		// Written whatever the outcome, failed downloads included
+		defer func() {
+			if manifestErr := writeDownloadManifest(opts.downloadManifest, cfg.Recorder, opts.redactor); manifestErr != nil && err == nil {
+				err = manifestErr
+			}
+		}()
+	}
+	client, err := httpclient.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	if opts.ekDir != "" {
+		return runBatch(ctx, logger, client, opts)
+	}
+	if opts.allTPMs {
+		return runDevices(ctx, logger, client, opts)
+	}
+	if opts.watch > 0 {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		st := &status{}
+		if opts.listen != "" {
+			shutdown, err := serve(logger, opts.listen, st.handler())
+			if err != nil {
+				return err
+			}
+			defer shutdown()
+		}
+		return watch(ctx, logger, opts.watch, func(ctx context.Context) (*result, error) {
+			res, err := runOnce(ctx, logger, client, opts)
+			st.set(res)
+			return res, err
+		})
+	}
+
+	_, err = runOnce(ctx, logger, client, opts)
+	return err
+}
+
+// runOnce audits the EK certificate and reports the result (stdout in JSON
+// mode and --output-file). The returned error is nil only if the TPM is trusted.
+func runOnce(ctx context.Context, logger log.Logger, client *httpclient.Client, opts *options) (*result, error) {
+	start := time.Now()
+	res := &result{Source: opts.source(), AuditedAt: start.UTC(), Durations: &durationsResult{}}
+	ctx, cancel := withDeadline(ctx, opts.deadline)
+	defer cancel()
+	err := checkDeadline(ctx, opts.deadline, audit(ctx, logger, client, opts, res))
+	res.setError(err)
+	opts.redactor.redactResult(res)
+	elapsed := time.Since(start)
+	res.Durations.Total = elapsed.Milliseconds()
+	logger.WithField("ms", res.Durations.Total).
+		Infof("total audit took: %s", elapsed.Round(time.Millisecond))
+	var out any = res
+	if opts.format == "in-toto" {
+		statement, statementErr := newInTotoStatement(res)
+		if statementErr != nil {
+			// Nothing to attest: the EK could not even be read
+			if err == nil {
+				err = statementErr
+			}
+			return res, err
+		}
+		out = statement
+	}
+	data, writeErr := writeOutputFile(opts, out)
+	if writeErr != nil {
+		return res, writeErr
+	}
+	sign := func(data []byte) error {
+		signErr := signResult(ctx, logger, opts, data, res.publicKey)
+		if signErr == nil || err == nil {
+			return signErr
+		}
+		logger.WithError(signErr).Error("failed to sign the result with an AK")
+		return nil
+	}
+	if opts.signWithAK && opts.outputFile != "" {
+		if signErr := sign(data); signErr != nil {
+			return res, signErr
+		}
+	}
+	if opts.tree && res.chain != nil {
+		var tree strings.Builder
+		if err := writeTree(&tree, res.chain); err != nil {
+			return res, err
+		}
+		if _, err := io.WriteString(os.Stdout, opts.redactor.redact(tree.String())); err != nil {
+			return res, err
+		}
+	}
+	if opts.machineOutput() {
+		var buf bytes.Buffer
+		var writeErr error
+		if opts.format == "csv" {
+			writeErr = outputCSV(&buf, opts.revocationMode(), res)
+		} else {
+			writeErr = output.WriteJSON(&buf, out, opts.prettyJSON())
+		}
+		if writeErr == nil {
+			_, writeErr = os.Stdout.Write(buf.Bytes())
+		}
+		if writeErr != nil {
+			return res, writeErr
+		}
+		if opts.signWithAK && opts.outputFile == "" {
+			if signErr := sign(buf.Bytes()); signErr != nil {
+				return res, signErr
+			}
+		}
+		if err != nil {
+			// Keep the cause for the exit code
+			return res, internal.Silence(err)
+		}
+		return res, nil
+	}
+	return res, err
+}
+
+// withDeadline bounds ctx with the --deadline of the audit, if any.
+func withDeadline(ctx context.Context, deadline time.Duration) (context.Context, context.CancelFunc) {
+	if deadline == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, deadline)
+}
+
+// checkDeadline reports err as [errDeadlineExceeded] if the --deadline of ctx
+// expired: whatever failed afterwards (eg. a download) was aborted by it.
+// A verdict reached before (eg. revoked) is kept.
+func checkDeadline(ctx context.Context, deadline time.Duration, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	if !errors.Is(err, context.DeadlineExceeded) && verdictOf(err) != verdictError {
+		return err
+	}
+	return fmt.Errorf("%w (%s): %v", errDeadlineExceeded, deadline, err)
+}
+
+// watch runs auditFn every interval until ctx is canceled (eg. SIGTERM),
+// logging every change of verdict. Failed audits do not stop the loop.
+func watch(ctx context.Context, logger log.Logger, interval time.Duration, auditFn func(context.Context) (*result, error)) error {
+	logger.Infof("watching TPM trust every %s", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last verdict
+	for {
+		res, err := auditFn(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil && !errors.Is(err, internal.ErrSilence) {
+			logger.WithError(err).Error("audit failed")
+		}
+		if res != nil && res.Verdict != last {
+			if last != "" {
+				logger.WithField("previous", last).
+					WithField("current", res.Verdict).
+					Warn("verdict changed")
+			}
+			last = res.Verdict
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+	logger.Info("stop watching TPM trust")
+	return nil
+}
+
+// writeOutputFile writes v as JSON to opts.outputFile, if set, and returns
+// the written bytes. The file is replaced atomically so that readers never
+// see a partial result.
+func writeOutputFile(opts *options, v any) ([]byte, error) {
+	if opts.outputFile == "" {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := output.WriteJSON(&buf, v, opts.jsonPretty); err != nil {
+		return nil, err
+	}
+	if err := output.WriteFileAtomic(opts.outputFile, buf.Bytes(), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// runBatch audits every EK certificate file of opts.ekDir against
+// the same trusted bundle and reports a verdict per file.
+func runBatch(ctx context.Context, logger log.Logger, client *httpclient.Client, opts *options) error {
+	paths, err := ekfile.List(opts.ekDir)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no certificate file found in %s (supported extensions: %s)", opts.ekDir, strings.Join(ekfile.Extensions, ", "))
+	}
 
-	if err := privilege.Elevate(); err != nil {
-		return fmt.Errorf("failed to elevate privileges: %w", err)
+	// The deadline bounds the whole batch
+	ctx, cancel := withDeadline(ctx, opts.deadline)
+	defer cancel()
+	trustedBundle, bundle, err := loadTrust(ctx, logger, client, opts)
+	if err != nil {
+		return checkDeadline(ctx, opts.deadline, err)
+	}
+	checker, err := newChecker(logger, trustedBundle, client, opts)
+	if err != nil {
+		return err
 	}
 
+	results := make([]*result, 0, len(paths))
+	for _, path := range paths {
+		res := &result{Source: path, AuditedAt: time.Now().UTC(), Bundle: bundle}
+		logger.WithField("file", path).Info("Auditing EK certificate")
+		ek, err := ekfile.ReadBundle(path)
+		if err == nil {
+			opts.redactor.addCertificate(ek.Certificate)
+			res.setCertificate(ek.Certificate)
+			var chains [][]*x509.Certificate
+			chains, err = validateEK(ctx, logger, checker, opts, ek, nil, &res.Warnings, nil)
+			res.Root = newRootResult(chains)
+		}
+		res.setError(checkDeadline(ctx, opts.deadline, err))
+		opts.redactor.redactResult(res)
+		results = append(results, res)
+		if err := streamResult(opts, res); err != nil {
+			return err
+		}
+	}
+	return reportResults(opts, "file", results)
+}
+
+// runDevices audits the EK certificate of every TPM device of the host
+// (--all-tpms) independently and reports a verdict per device.
+func runDevices(ctx context.Context, logger log.Logger, client *httpclient.Client, opts *options) error {
+	devices, err := tpm.ListDevices()
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return errors.New("no TPM device found")
+	}
+
+	// The deadline bounds the audit of every device
+	ctx, cancel := withDeadline(ctx, opts.deadline)
+	defer cancel()
+	results := make([]*result, 0, len(devices))
+	for _, device := range devices {
+		start := time.Now()
+		deviceOpts := *opts
+		deviceOpts.tpmDevice = device
+		res := &result{Source: device, AuditedAt: start.UTC(), Durations: &durationsResult{}}
+		logger.WithField("device", device).Info("Auditing TPM device")
+		res.setError(checkDeadline(ctx, opts.deadline, audit(ctx, logger, client, &deviceOpts, res)))
+		opts.redactor.redactResult(res)
+		res.Durations.Total = time.Since(start).Milliseconds()
+		results = append(results, res)
+		if err := streamResult(opts, res); err != nil {
+			return err
+		}
+	}
+	return reportResults(opts, "device", results)
+}
+
+// streamResult writes res on its own line as soon as it is available with
+// --format jsonl, so that consumers may process the results of a batch
+// incrementally (stdout is not buffered).
+func streamResult(opts *options, res *result) error {
+	if opts.format != "jsonl" {
+		return nil
+	}
+	return output.WriteJSON(os.Stdout, res, false)
+}
+
+// reportResults writes the results of a batch (stdout and --output-file).
+// kind is what the results are about (eg. "file"). [internal.ErrSilence] is
+// returned unless every result is trusted.
+func reportResults(opts *options, kind string, results []*result) error {
+	if _, err := writeOutputFile(opts, results); err != nil {
+		return err
+	}
+
+	var err error
+	switch opts.format {
+	case "jsonl":
+		// Already streamed (see streamResult)
+	case "json":
+		err = output.WriteJSON(os.Stdout, results, opts.jsonPretty)
+	case "csv":
+		err = outputCSV(os.Stdout, opts.revocationMode(), results...)
+	default:
+		err = outputTable(os.Stdout, kind, results)
+	}
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(results, func(r *result) bool { return r.Verdict != verdictTrusted }) {
+		return internal.ErrSilence
+	}
+	return nil
+}
+
+// audit reads the EK certificate (from the TPM or a file) and validates it
+// against the trusted bundle. res is filled as information becomes available.
+func audit(ctx context.Context, logger log.Logger, client *httpclient.Client, opts *options, res *result) error {
+	var (
+		ek           endorsement.EK
+		manufacturer *info.Manufacturer
+	)
+	startRead := time.Now()
+	if opts.ekCert != "" {
+		if opts.ekCert == ekfile.Stdin {
+			logger.Info("Reading EK certificate from the standard input")
+		} else {
+			logger.WithField("file", opts.ekCert).Info("Reading EK certificate from file")
+		}
+		fileEK, err := ekfile.ReadBundle(opts.ekCert)
+		if err != nil {
+			return err
+		}
+		ek = fileEK
+		if len(ek.Chain) > 0 {
+			logger.WithField("count", len(ek.Chain)).Debug("chain certificates found along with the EK certificate")
+		}
+	} else {
+		ekResponse, err := readEK(ctx, logger, client, opts)
+		if err != nil {
+			return err
+		}
+		ek = ekResponse.EK
+		manufacturer = &ekResponse.Manufacturer
+		res.Manufacturer = ekResponse.Manufacturer.ASCII
+		res.TPMType = ekResponse.Type.String()
+		res.AvailableCertificates = newAvailableCertificates(ekResponse.Available)
+
+		if !opts.isManufacturerAllowed(ekResponse.Manufacturer) {
+			logger.WithField("id", ekResponse.Manufacturer.ASCII).
+				WithField("allowed", opts.allowedManufacturers).
+				Error("manufacturer not allowed")
+			return internal.Silence(fmt.Errorf("%w: %s", errManufacturerNotAllowed, ekResponse.Manufacturer.ASCII))
+		}
+	}
+	opts.redactor.addCertificate(ek.Certificate)
+	res.Durations.ReadEK = time.Since(startRead).Milliseconds()
+	res.setCertificate(ek.Certificate)
+	res.publicKey = ek.Certificate.RawSubjectPublicKeyInfo
+
+	startLoad := time.Now()
+	trustedBundle, bundle, err := loadTrust(ctx, logger, client, opts)
+	if err != nil {
+		return err
+	}
+	res.Durations.LoadBundle = time.Since(startLoad).Milliseconds()
+	res.Bundle = bundle
+
+	// A trust anchor is not tied to the manufacturers of the bundle
+	if manufacturer != nil && trustedBundle != nil {
+		if err := checkManufacturer(logger, trustedBundle, *manufacturer); err != nil {
+			return err
+		}
+	}
+
+	checker, err := newChecker(logger, trustedBundle, client, opts)
+	if err != nil {
+		return err
+	}
+	startValidate := time.Now()
+	chains, err := validateEK(ctx, logger, checker, opts, ek, manufacturer, &res.Warnings, nil)
+	res.Durations.ValidateEK = time.Since(startValidate).Milliseconds()
+	res.Root = newRootResult(chains)
+	if len(chains) > 0 {
+		res.chain = chains[0]
+	}
+	if err == nil && opts.akCert != "" {
+		err = validateAK(ctx, logger, checker, opts, chains, &res.Warnings)
+	}
+	if err == nil && opts.platformCert != "" {
+		err = validatePlatform(ctx, logger, opts, ek.Certificate, res)
+	}
+	if err != nil {
+		if verdictOf(err) == verdictError || ctx.Err() != nil {
+			return err
+		}
+		logger.Error("TPM is not genuine ✋")
+		return internal.Silence(err)
+	}
+	logger.Info("TPM is genuine 🔒")
+	return nil
+}
+
+func readEK(ctx context.Context, logger log.Logger, client *httpclient.Client, opts *options) (*tpm.EKResponse, error) {
 	startRead := time.Now()
 	logger.Info("Reading EK certificate from TPM")
 	var (
 		result    *tpm.EKResponse
 		searchErr error
 	)
+	auth, err := parseEndorsementAuth(opts.endorsementAuth, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
 	if opts.keyType == "" {
-		result, searchErr = tpm.SearchEKCertificate(tpm.TPMConfig{Logger: logger})
-		if searchErr != nil {
-			return fmt.Errorf("failed to read EK certificate: %w", searchErr)
+		selector, err := opts.certSelector()
+		if err != nil {
+			return nil, err
 		}
+		result, searchErr = tpm.SearchEKCertificate(ctx, tpm.TPMConfig{Logger: logger, HttpClient: client, DownloadTimeout: opts.downloadTimeout, Source: tpm.Source(opts.ekSource), WaitForTPM: opts.waitForTPM, Device: opts.tpmDevice, NVReadSize: opts.nvReadSize, Selector: selector, EndorsementAuth: auth, OnCertificateURL: opts.redactor.addCertificateURL})
 	} else {
-		result, searchErr = tpm.GetEKCertificate(tpm.TPMConfig{Logger: logger, KeyType: tpm.KeyType(opts.keyType)})
-		if searchErr != nil {
-			return fmt.Errorf("failed to read EK certificate: %w", searchErr)
-		}
+		result, searchErr = tpm.GetEKCertificate(ctx, tpm.TPMConfig{Logger: logger, KeyType: tpm.KeyType(opts.keyType), HttpClient: client, DownloadTimeout: opts.downloadTimeout, Source: tpm.Source(opts.ekSource), WaitForTPM: opts.waitForTPM, Device: opts.tpmDevice, NVReadSize: opts.nvReadSize, EndorsementAuth: auth, OnCertificateURL: opts.redactor.addCertificateURL})
+	}
+	if errors.Is(searchErr, tpm.ErrEndorsementAuth) && auth == nil {
+		return nil, fmt.Errorf("failed to read EK certificate: %w (set it with --endorsement-auth or $%s)", searchErr, endorsementAuthEnv)
+	}
+	if searchErr != nil {
+		return nil, fmt.Errorf("failed to read EK certificate: %w", searchErr)
 	}
 	logutil.LogDurationWithPadding(logger, startRead)
+	return result, nil
+}
+
+// loadTrust loads the trusted bundle and reports its release. With
+// --trust-anchor, the bundle is not needed: nil is returned and the
+// checker only trusts the anchor (see [newChecker]).
+func loadTrust(ctx context.Context, logger log.Logger, client *httpclient.Client, opts *options) (apiv1beta.TrustedBundle, *bundleResult, error) {
+	if opts.trustAnchor != "" {
+		return nil, nil, nil
+	}
+	trustedBundle, err := loadTrustedBundle(ctx, logger, client, opts.embeddedBundle)
+	if err != nil {
+		return nil, nil, err
+	}
+	bundle, err := checkBundle(logger, opts, trustedBundle)
+	if err != nil {
+		return nil, nil, err
+	}
+	return trustedBundle, bundle, nil
+}
 
+func loadTrustedBundle(ctx context.Context, logger log.Logger, client *httpclient.Client, embedded bool) (apiv1beta.TrustedBundle, error) {
 	startLoad := time.Now()
 	logger.Info("Loading manufacturers trusted bundle")
-	cfg := apiv1beta.GetConfig{
-		AutoUpdate: apiv1beta.AutoUpdateConfig{
-			Disabled: true,
-		},
+	var trustedBundle apiv1beta.TrustedBundle
+	var err error
+	if embedded {
+		logger.Debug("using the embedded trusted bundle")
+		trustedBundle, err = validate.LoadEmbeddedBundle(ctx)
+	} else {
+		trustedBundle, err = validate.FetchTrustedBundle(ctx, validate.FetchBundleConfig{
+			GetConfig: apiv1beta.GetConfig{
+				AutoUpdate: apiv1beta.AutoUpdateConfig{
+					Disabled: true,
+				},
+				HTTPClient: client,
+			},
+			Logger: logger,
+		})
 	}
-	trustedBundle, err := apiv1beta.GetTrustedBundle(ctx, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to get trusted bundle: %w", err)
+		return nil, fmt.Errorf("failed to get trusted bundle: %w", err)
 	}
 	logutil.LogWithPadding(logger, func() {
 		logger.Info("download and verify integrity")
@@ -122,51 +1187,177 @@ func run(ctx context.Context, opts *options) error {
 			}
 		})
 	})
+	return trustedBundle, nil
+}
 
-	if !slices.Contains(trustedBundle.GetVendors(), apiv1beta.VendorID(result.Manufacturer.ASCII)) {
-		logger.Debugf("raw manufacturer: %s", result.Manufacturer.String())
-		logger.Debugf("manufacturer's ASCII: %q", result.Manufacturer.ASCII)
-		logger.Debugf("manufacturer's ASCII (bytes): %v", []byte(result.Manufacturer.ASCII))
-		logger.WithField("id", result.Manufacturer.ASCII).
+// checkBundle reports the release of the trusted bundle and,
+// if --max-bundle-age is set, ensures that it is not too old.
+func checkBundle(logger log.Logger, opts *options, trustedBundle apiv1beta.TrustedBundle) (*bundleResult, error) {
+	info, err := validate.GetBundleInfo(trustedBundle)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	res := newBundleResult(info, now)
+	logutil.LogWithPadding(logger, func() {
+		logger.WithField("date", res.Date).
+			WithField("age", fmt.Sprintf("%d day(s)", res.AgeDays)).
+			Info("bundle release")
+	})
+	if opts.maxBundleAge == 0 {
+		return res, nil
+	}
+	if err := info.CheckAge(now, opts.maxBundleAge); err != nil {
+		if !opts.bundleAgeWarnOnly {
+			return res, err
+		}
+		logutil.LogWithPadding(logger, func() {
+			logger.WithError(err).Warn("stale trusted bundle")
+		})
+	}
+	return res, nil
+}
+
+// checkManufacturer ensures that the manufacturer is part of the trusted bundle.
+func checkManufacturer(logger log.Logger, trustedBundle apiv1beta.TrustedBundle, manufacturer info.Manufacturer) error {
+	if !slices.Contains(trustedBundle.GetVendors(), apiv1beta.VendorID(manufacturer.ASCII)) {
+		logger.Debugf("raw manufacturer: %s", manufacturer.String())
+		logger.Debugf("manufacturer's ASCII: %q", manufacturer.ASCII)
+		logger.Debugf("manufacturer's ASCII (bytes): %v", []byte(manufacturer.ASCII))
+		logger.WithField("id", manufacturer.ASCII).
 			WithField("reason", `unfortunately, this manufacturer
 is not included yet in 'tpm-ca-certificates' 🥹
 Please open an issue to request its inclusion:
 https://github.com/loicsikidi/tpm-ca-certificates/issues/new`).
 			Error("unsupported manufacturer")
-		return internal.ErrSilence
+		return internal.Silence(&UnsupportedManufacturerError{ID: manufacturer.ASCII, Name: manufacturer.Name})
 	}
 	logutil.LogWithPadding(logger, func() {
-		logger.WithField("id", result.Manufacturer.ASCII).Info("manufacturer supported")
+		logger.WithField("id", manufacturer.ASCII).Info("manufacturer supported")
 	})
+	return nil
+}
 
-	startValidate := time.Now()
-	logger.Info("Validating EK certificate")
+func newChecker(logger log.Logger, trustedBundle apiv1beta.TrustedBundle, client *httpclient.Client, opts *options) (validate.Checker, error) {
+	var intermediates []*x509.Certificate
+	if opts.intermediatesDir != "" {
+		var err error
+		if intermediates, err = loadIntermediates(opts.intermediatesDir); err != nil {
+			return nil, err
+		}
+		logger.Debugf("loaded %d local intermediate certificate(s)", len(intermediates))
+	}
+	var anchor *x509.Certificate
+	if opts.trustAnchor != "" {
+		var err error
+		if anchor, err = loadTrustAnchor(opts.trustAnchor); err != nil {
+			return nil, err
+		}
+		logger.WithField("subject", anchor.Subject.String()).
+			Info("Trusting only the root of --trust-anchor")
+	}
+	if opts.includeSystemRoots {
+		logger.Warn("Trusting the system roots along with the trusted bundle")
+	}
+	var ctLogs []validate.CTLog
+	if opts.ctLogList != "" {
+		data, err := os.ReadFile(opts.ctLogList)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CT log list: %w", err)
+		}
+		if ctLogs, err = validate.ParseCTLogList(data); err != nil {
+			return nil, err
+		}
+		logger.Debugf("loaded %d CT log(s)", len(ctLogs))
+	}
 	checker, err := validate.NewEKChecker(validate.EKCheckerConfig{
-		TrustedBundle: trustedBundle,
-		Logger:        logger,
+		TrustedBundle:   trustedBundle,
+		HttpClient:      client,
+		Timeout:         opts.timeout,
+		DownloadTimeout: opts.downloadTimeout,
+		Logger:          logger,
+		Intermediates:   intermediates,
+		Explain:         opts.explain,
+		TrustAnchor:     anchor,
+		SystemRoots:     opts.includeSystemRoots,
+		CTLogs:          ctLogs,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create EK checker: %w", err)
+		return nil, fmt.Errorf("failed to create EK checker: %w", err)
 	}
+	return checker, nil
+}
+
+// validateEK validates the EK certificate, logs its status and returns the verified chains.
+// If set, collected is filled with the issuers and CRLs downloaded to verify it
+// (ignored with --use-cache, which loads them from the cache directory).
+func validateEK(ctx context.Context, logger log.Logger, checker validate.Checker, opts *options, ek endorsement.EK, manufacturer *info.Manufacturer, warnings *[]validate.Warning, collected *validate.Cache) ([][]*x509.Certificate, error) {
+	startValidate := time.Now()
+	logger.Info("Validating EK certificate")
+	cfg := opts.checkConfig(ek, manufacturer)
+	cfg.Context = ctx
+	cfg.Warnings = warnings
+	cfg.Cache = collected
+	if opts.useCache {
+		cache, err := loadCache(opts.cacheDir, ek.Certificate)
+		if err != nil {
+			logger.WithError(err).Warn("ignoring unreadable cache")
+			cache = &validate.Cache{}
+		}
+		cfg.Cache = cache
+	}
+	chains, err := checker.CheckWithChains(cfg)
+	logStatus(logger, startValidate, err)
+	if root := newRootResult(chains); root != nil {
+		logutil.LogWithPadding(logger, func() {
+			logger.WithField("subject", root.Subject).
+				WithField("ski", root.SKI).
+				WithField("sha256", root.Fingerprint).
+				Info("anchored by root")
+		})
+	}
+	if err == nil && opts.useCache {
+		if err := saveCache(opts.cacheDir, ek.Certificate, cfg.Cache); err != nil {
+			logger.WithError(err).Warn("failed to update cache")
+		}
+	}
+	return chains, err
+}
 
-	checkCfg := validate.CheckConfig{
-		EK:                  result.EK,
-		SkipRevocationCheck: opts.skipRevocationCheck,
+// validateAK validates the AK certificate of opts.akCert, which must chain
+// to the same root as the EK certificate (ekChains), and logs its status.
+func validateAK(ctx context.Context, logger log.Logger, checker validate.Checker, opts *options, ekChains [][]*x509.Certificate, warnings *[]validate.Warning) error {
+	startValidate := time.Now()
+	logger.WithField("file", opts.akCert).Info("Validating AK certificate")
+	cert, err := ekfile.Read(opts.akCert)
+	if err != nil {
+		return err
 	}
-	if err := checker.Check(checkCfg); err != nil {
-		if errors.Is(err, validate.ErrUntrustedCertificate) {
+	_, err = checker.CheckAK(validate.AKCheckConfig{
+		Certificate:            cert,
+		EKChains:               ekChains,
+		SkipRevocationCheck:    opts.revocationMode() == revocationOff,
+		SoftRevocationCheck:    opts.revocationMode() == revocationSoft,
+		RequireRevocationCheck: opts.requireRevocationCheck,
+		Context:                ctx,
+		Warnings:               warnings,
+	})
+	logStatus(logger, startValidate, err)
+	return err
+}
+
+// logStatus logs the outcome of a certificate validation.
+func logStatus(logger log.Logger, start time.Time, err error) {
+	if err != nil {
+		if v := verdictOf(err); v != verdictError {
 			logutil.LogWithPadding(logger, func() {
-				logger.Error("status: untrusted")
+				logger.WithError(err).WithField("code", errorCode(err)).Errorf("status: %s", v)
 			})
-			logger.Error("TPM is not genuine ✋")
-			return internal.ErrSilence
 		}
-		return err
+		return
 	}
 	logutil.LogWithPadding(logger, func() {
 		logger.Info("status: trusted")
-		logutil.LogDuration(logger, startValidate)
+		logutil.LogDuration(logger, start)
 	})
-	logger.Info("TPM is genuine 🔒")
-	return nil
 }
@@ -0,0 +1,623 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/attest/info"
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+
+	"github.com/loicsikidi/tpm-trust/internal"
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/tpm"
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+func TestOptions_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    options
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			opts: options{format: "text", logFormat: "text"},
+		},
+		{
+			name:    "mutually exclusive revocation flags",
+			opts:    options{format: "text", logFormat: "text", skipRevocationCheck: true, requireRevocationCheck: true},
+			wantErr: true,
+		},
+		{
+			name: "trust anchor",
+			opts: options{format: "text", logFormat: "text", trustAnchor: "root.pem"},
+		},
+		{
+			name:    "trust anchor with max bundle age",
+			opts:    options{format: "text", logFormat: "text", trustAnchor: "root.pem", maxBundleAge: time.Hour},
+			wantErr: true,
+		},
+		{
+			name: "system roots",
+			opts: options{format: "text", logFormat: "text", includeSystemRoots: true},
+		},
+		{
+			name: "ek cert from stdin",
+			opts: options{format: "text", logFormat: "text", ekCert: "-"},
+		},
+		{
+			name: "in-toto format",
+			opts: options{format: "in-toto", logFormat: "text"},
+		},
+		{
+			name:    "in-toto format with ek dir",
+			opts:    options{format: "in-toto", logFormat: "text", ekDir: "certs"},
+			wantErr: true,
+		},
+		{
+			name:    "ek cert from stdin with watch",
+			opts:    options{format: "text", logFormat: "text", ekCert: "-", watch: time.Hour},
+			wantErr: true,
+		},
+		{
+			name:    "trust anchor with embedded bundle",
+			opts:    options{format: "text", logFormat: "text", trustAnchor: "root.pem", embeddedBundle: true},
+			wantErr: true,
+		},
+		{
+			name:    "trust anchor with system roots",
+			opts:    options{format: "text", logFormat: "text", trustAnchor: "root.pem", includeSystemRoots: true},
+			wantErr: true,
+		},
+		{
+			name: "require sct with ct log list",
+			opts: options{format: "text", logFormat: "text", ctLogList: "log_list.json", requireSCT: true},
+		},
+		{
+			name:    "download manifest with watch",
+			opts:    options{format: "text", logFormat: "text", downloadManifest: "downloads.json", watch: time.Minute},
+			wantErr: true,
+		},
+		{
+			name:    "redact with in-toto format",
+			opts:    options{format: "in-toto", logFormat: "text", redact: true},
+			wantErr: true,
+		},
+		{
+			name:    "endorsement auth with ek file",
+			opts:    options{format: "text", logFormat: "text", ekCert: "ek.pem", endorsementAuth: "env:EK_AUTH"},
+			wantErr: true,
+		},
+		{
+			name:    "require sct without ct log list",
+			opts:    options{format: "text", logFormat: "text", requireSCT: true},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported format",
+			opts:    options{format: "yaml", logFormat: "text"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported log format",
+			opts:    options{format: "text", logFormat: "logfmt"},
+			wantErr: true,
+		},
+		{
+			name: "EK directory with JSON output",
+			opts: options{format: "json", logFormat: "text", ekDir: "certs"},
+		},
+		{
+			name: "EK directory with CSV output",
+			opts: options{format: "csv", logFormat: "text", ekDir: "certs"},
+		},
+		{
+			name:    "CSV output with watch",
+			opts:    options{format: "csv", logFormat: "text", watch: time.Hour},
+			wantErr: true,
+		},
+		{
+			name:    "EK file and directory",
+			opts:    options{format: "text", logFormat: "text", ekCert: "ek.pem", ekDir: "certs"},
+			wantErr: true,
+		},
+		{
+			name:    "AK file with EK directory",
+			opts:    options{format: "text", logFormat: "text", ekDir: "certs", akCert: "iak.pem"},
+			wantErr: true,
+		},
+		{
+			name: "platform certificate file with EK file",
+			opts: options{format: "text", logFormat: "text", ekCert: "ek.pem", platformCert: "platform.pem", platformCA: "platform-ca.pem"},
+		},
+		{
+			name:    "platform certificate without CA",
+			opts:    options{format: "text", logFormat: "text", platformCert: "platform.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "platform certificate NV index with EK file",
+			opts:    options{format: "text", logFormat: "text", ekCert: "ek.pem", platformCert: "0x1C90000", platformCA: "platform-ca.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "EK file with key type",
+			opts:    options{format: "text", logFormat: "text", ekCert: "ek.pem", keyType: "rsa-2048"},
+			wantErr: true,
+		},
+		{
+			name:    "EK directory with manufacturer allowlist",
+			opts:    options{format: "text", logFormat: "text", ekDir: "certs", allowedManufacturers: []string{"IFX"}},
+			wantErr: true,
+		},
+		{
+			name:    "EK file with strict manufacturer",
+			opts:    options{format: "text", logFormat: "text", ekCert: "ek.pem", strictManufacturer: true},
+			wantErr: true,
+		},
+		{
+			name: "select certificate by NV index",
+			opts: options{format: "text", logFormat: "text", selectCert: "0x1C0000A"},
+		},
+		{
+			name:    "invalid certificate selector",
+			opts:    options{format: "text", logFormat: "text", selectCert: "second"},
+			wantErr: true,
+		},
+		{
+			name:    "certificate selector with key type",
+			opts:    options{format: "text", logFormat: "text", selectCert: "2", keyType: "rsa-2048"},
+			wantErr: true,
+		},
+		{
+			name: "wait for TPM",
+			opts: options{format: "text", logFormat: "text", waitForTPM: time.Minute},
+		},
+		{
+			name:    "EK file with wait for TPM",
+			opts:    options{format: "text", logFormat: "text", ekCert: "ek.pem", waitForTPM: time.Minute},
+			wantErr: true,
+		},
+		{
+			name: "soft revocation check",
+			opts: options{format: "text", logFormat: "text", revocationCheck: "soft"},
+		},
+		{
+			name:    "unknown revocation check mode",
+			opts:    options{format: "text", logFormat: "text", revocationCheck: "lenient"},
+			wantErr: true,
+		},
+		{
+			name:    "skip with soft revocation check",
+			opts:    options{format: "text", logFormat: "text", skipRevocationCheck: true, revocationCheck: "soft"},
+			wantErr: true,
+		},
+		{
+			name:    "required with revocation check off",
+			opts:    options{format: "text", logFormat: "text", revocationCheck: "off", requireRevocationCheck: true},
+			wantErr: true,
+		},
+		{
+			name: "watch",
+			opts: options{format: "text", logFormat: "text", watch: time.Hour},
+		},
+		{
+			name:    "negative watch interval",
+			opts:    options{format: "text", logFormat: "text", watch: -time.Hour},
+			wantErr: true,
+		},
+		{
+			name:    "watch with EK directory",
+			opts:    options{format: "text", logFormat: "text", ekDir: "certs", watch: time.Hour},
+			wantErr: true,
+		},
+		{
+			name: "listen",
+			opts: options{format: "text", logFormat: "text", watch: time.Hour, listen: ":8080"},
+		},
+		{
+			name:    "listen without watch",
+			opts:    options{format: "text", logFormat: "text", listen: ":8080"},
+			wantErr: true,
+		},
+		{
+			name: "NV source",
+			opts: options{format: "text", logFormat: "text", ekSource: "nv"},
+		},
+		{
+			name:    "unknown source",
+			opts:    options{format: "text", logFormat: "text", ekSource: "registry"},
+			wantErr: true,
+		},
+		{
+			name:    "EK file with PCP source",
+			opts:    options{format: "text", logFormat: "text", ekCert: "ek.pem", ekSource: "pcp"},
+			wantErr: true,
+		},
+		{
+			name: "custom timeouts",
+			opts: options{format: "text", logFormat: "text", timeout: time.Minute, downloadTimeout: time.Minute},
+		},
+		{
+			name:    "download timeout exceeding overall timeout",
+			opts:    options{format: "text", logFormat: "text", timeout: time.Second, downloadTimeout: 2 * time.Second},
+			wantErr: true,
+		},
+		{
+			name: "max bundle age",
+			opts: options{format: "text", logFormat: "text", maxBundleAge: 720 * time.Hour, bundleAgeWarnOnly: true},
+		},
+		{
+			name:    "bundle age warn only without max age",
+			opts:    options{format: "text", logFormat: "text", bundleAgeWarnOnly: true},
+			wantErr: true,
+		},
+		{
+			name:    "negative timeout",
+			opts:    options{format: "text", logFormat: "text", timeout: -time.Second},
+			wantErr: true,
+		},
+		{
+			name: "deadline",
+			opts: options{format: "text", logFormat: "text", deadline: time.Minute},
+		},
+		{
+			name:    "negative deadline",
+			opts:    options{format: "text", logFormat: "text", deadline: -time.Minute},
+			wantErr: true,
+		},
+		{
+			name: "jsonl format",
+			opts: options{format: "jsonl", logFormat: "text", ekDir: "certs"},
+		},
+		{
+			name: "jsonl format with watch",
+			opts: options{format: "jsonl", logFormat: "text", watch: time.Hour},
+		},
+		{
+			name:    "unsupported format",
+			opts:    options{format: "yaml", logFormat: "text"},
+			wantErr: true,
+		},
+		{
+			name: "tree",
+			opts: options{format: "text", logFormat: "text", tree: true},
+		},
+		{
+			name:    "tree with json format",
+			opts:    options{format: "json", logFormat: "text", tree: true},
+			wantErr: true,
+		},
+		{
+			name:    "tree with ek dir",
+			opts:    options{format: "text", logFormat: "text", tree: true, ekDir: "certs"},
+			wantErr: true,
+		},
+		{
+			name: "ip family",
+			opts: options{format: "text", logFormat: "text", ipFamily: "v6"},
+		},
+		{
+			name:    "invalid ip family",
+			opts:    options{format: "text", logFormat: "text", ipFamily: "ipv6"},
+			wantErr: true,
+		},
+		{
+			name: "direct hosts",
+			opts: options{format: "text", logFormat: "text", directHosts: []string{"pki.infineon.com", ".intel.com"}},
+		},
+		{
+			name: "sign with AK",
+			opts: options{format: "json", logFormat: "text", signWithAK: true, outputFile: "result.json"},
+		},
+		{
+			name: "sign with AK on stdout",
+			opts: options{format: "json", logFormat: "text", signWithAK: true},
+		},
+		{
+			name:    "sign with AK without output file in text format",
+			opts:    options{format: "text", logFormat: "text", signWithAK: true},
+			wantErr: true,
+		},
+		{
+			name:    "sign with AK and EK file",
+			opts:    options{format: "json", logFormat: "text", signWithAK: true, outputFile: "result.json", ekCert: "ek.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid direct host",
+			opts:    options{format: "text", logFormat: "text", directHosts: []string{"http://pki.infineon.com/crl"}},
+			wantErr: true,
+		},
+		{
+			name: "nv read size",
+			opts: options{format: "text", logFormat: "text", nvReadSize: 1234},
+		},
+		{
+			name:    "negative nv read size",
+			opts:    options{format: "text", logFormat: "text", nvReadSize: -1},
+			wantErr: true,
+		},
+		{
+			name:    "nv read size with ek cert",
+			opts:    options{format: "text", logFormat: "text", nvReadSize: 1234, ekCert: "ek.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "tpm device",
+			opts:    options{format: "text", logFormat: "text", tpmDevice: "/dev/tpmrm1"},
+			wantErr: !tpm.DevicesSupported(),
+		},
+		{
+			name:    "all tpms",
+			opts:    options{format: "json", logFormat: "text", allTPMs: true},
+			wantErr: !tpm.DevicesSupported(),
+		},
+		{
+			name:    "tpm device with all tpms",
+			opts:    options{format: "text", logFormat: "text", tpmDevice: "/dev/tpmrm1", allTPMs: true},
+			wantErr: true,
+		},
+		{
+			name:    "tpm device with ek cert",
+			opts:    options{format: "text", logFormat: "text", tpmDevice: "/dev/tpmrm1", ekCert: "ek.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "all tpms with ak cert",
+			opts:    options{format: "text", logFormat: "text", allTPMs: true, akCert: "iak.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "all tpms with watch",
+			opts:    options{format: "text", logFormat: "text", allTPMs: true, watch: time.Hour},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			// Use flags default values unless overridden
+			opts := tc.opts
+			if opts.timeout == 0 {
+				opts.timeout = validate.DefaultTimeout
+			}
+			if opts.downloadTimeout == 0 {
+				opts.downloadTimeout = validate.DefaultDownloadTimeout
+			}
+			if err := opts.Check(); (err != nil) != tc.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestOptions_IsManufacturerAllowed(t *testing.T) {
+	manufacturer := info.Manufacturer{ASCII: "IFX", Name: "Infineon"}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		want    bool
+	}{
+		{name: "empty allowlist", want: true},
+		{name: "match by ID", allowed: []string{"NTC", "IFX"}, want: true},
+		{name: "match by name (case-insensitive)", allowed: []string{"infineon"}, want: true},
+		{name: "no match", allowed: []string{"NTC", "STM"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &options{allowedManufacturers: tt.allowed}
+			if got := opts.isManufacturerAllowed(manufacturer); got != tt.want {
+				t.Errorf("isManufacturerAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// vendorsBundle is a trusted bundle only listing vendors.
+type vendorsBundle struct {
+	apiv1beta.TrustedBundle
+	vendors []apiv1beta.VendorID
+}
+
+func (b *vendorsBundle) GetVendors() []apiv1beta.VendorID { return b.vendors }
+
+func TestCheckManufacturer(t *testing.T) {
+	bundle := &vendorsBundle{vendors: []apiv1beta.VendorID{"IFX", "NTC"}}
+	logger := log.New(log.WithNoop())
+
+	if err := checkManufacturer(logger, bundle, info.Manufacturer{ASCII: "IFX", Name: "Infineon"}); err != nil {
+		t.Fatalf("checkManufacturer() error = %v", err)
+	}
+
+	err := checkManufacturer(logger, bundle, info.Manufacturer{ASCII: "XYZ", Name: "Unknown Corp"})
+	if !errors.Is(err, ErrUnsupportedManufacturer) || !errors.Is(err, internal.ErrSilence) {
+		t.Fatalf("checkManufacturer() error = %v, want silenced %v", err, ErrUnsupportedManufacturer)
+	}
+	var unsupported *UnsupportedManufacturerError
+	if !errors.As(err, &unsupported) || unsupported.ID != "XYZ" || unsupported.Name != "Unknown Corp" {
+		t.Errorf("checkManufacturer() error = %#v, want the manufacturer", err)
+	}
+	if want := "unsupported manufacturer: XYZ (Unknown Corp)"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if got := verdictOf(err); got != verdictUnsupported {
+		t.Errorf("verdictOf() = %v, want %v", got, verdictUnsupported)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		opts       options
+		wantStderr bool
+	}{
+		{
+			name: "JSON format suppresses logs",
+			opts: options{format: "json"},
+		},
+		{
+			name:       "JSON format with verbose logs to stderr",
+			opts:       options{format: "json", verbose: true},
+			wantStderr: true,
+		},
+		{
+			name:       "JSON format with verbose JSON logs to stderr",
+			opts:       options{format: "json", verbose: true, logFormat: "json"},
+			wantStderr: true,
+		},
+		{
+			name: "in-toto format suppresses logs",
+			opts: options{format: "in-toto"},
+		},
+		{
+			name:       "JSON format with explain logs to stderr",
+			opts:       options{format: "json", explain: true},
+			wantStderr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stderr bytes.Buffer
+			newLogger(&tc.opts, &stderr).Info("reading EK certificate")
+			if got := stderr.Len() > 0; got != tc.wantStderr {
+				t.Errorf("logs written to stderr = %t, want %t (got %q)", got, tc.wantStderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	verdicts := []verdict{verdictTrusted, verdictTrusted, verdictRevoked, verdictRevoked}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var logs bytes.Buffer
+	calls := 0
+	err := watch(ctx, log.NewJSONLogger(&logs, false), time.Millisecond, func(context.Context) (*result, error) {
+		res := &result{Verdict: verdicts[calls]}
+		calls++
+		if calls == len(verdicts) {
+			cancel()
+		}
+		if res.Verdict != verdictTrusted {
+			return res, internal.ErrSilence
+		}
+		return res, nil
+	})
+	if err != nil {
+		t.Fatalf("watch() error = %v", err)
+	}
+	if calls != len(verdicts) {
+		t.Errorf("audits = %d, want %d", calls, len(verdicts))
+	}
+	if got := strings.Count(logs.String(), "verdict changed"); got != 1 {
+		t.Errorf("verdict changes logged = %d, want 1 (logs: %s)", got, logs.String())
+	}
+}
+
+func TestWatch_AuditError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var logs bytes.Buffer
+	calls := 0
+	err := watch(ctx, log.NewJSONLogger(&logs, false), time.Millisecond, func(context.Context) (*result, error) {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return &result{Verdict: verdictError}, errors.New("failed to open TPM")
+	})
+	if err != nil {
+		t.Fatalf("watch() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("audits = %d, want 2", calls)
+	}
+	if !strings.Contains(logs.String(), "failed to open TPM") {
+		t.Errorf("audit error not logged (logs: %s)", logs.String())
+	}
+}
+
+func TestRunOnce_Durations(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	opts := &options{format: "text", ekCert: t.TempDir() + "/missing.pem"}
+	res, err := runOnce(context.Background(), log.NewJSONLogger(&logs, false), nil, opts)
+	if err == nil {
+		t.Fatal("runOnce() error = nil, want an error")
+	}
+	if res.Durations == nil {
+		t.Fatal("runOnce() did not report the durations")
+	}
+	if res.Durations.LoadBundle != 0 || res.Durations.ValidateEK != 0 {
+		t.Errorf("durations = %+v, want unreached phases to be 0", res.Durations)
+	}
+	if !strings.Contains(logs.String(), "total audit took") {
+		t.Errorf("total duration not logged (logs: %s)", logs.String())
+	}
+}
+
+func TestCheckDeadline(t *testing.T) {
+	t.Parallel()
+
+	expired, cancel := context.WithTimeout(context.Background(), 0)
+	t.Cleanup(cancel)
+	<-expired.Done()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	failure := errors.New("failed to download CRL")
+	revoked := fmt.Errorf("%w: CN=Test EK", x509util.ErrCertificateRevoked)
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		err         error
+		wantErr     error
+		wantVerdict verdict
+	}{
+		{name: "success", ctx: expired},
+		{name: "failure", ctx: context.Background(), err: failure, wantErr: failure, wantVerdict: verdictError},
+		{name: "expired", ctx: expired, err: failure, wantErr: errDeadlineExceeded, wantVerdict: verdictError},
+		{name: "expired/download", ctx: expired, err: fmt.Errorf("failed to download CRL: %w", context.DeadlineExceeded), wantErr: errDeadlineExceeded, wantVerdict: verdictError},
+		{name: "expired/revoked", ctx: expired, err: revoked, wantErr: x509util.ErrCertificateRevoked, wantVerdict: verdictRevoked},
+		{name: "canceled", ctx: canceled, err: failure, wantErr: failure, wantVerdict: verdictError},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkDeadline(tc.ctx, time.Second, tc.err)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("checkDeadline() error = %v, want %v", err, tc.wantErr)
+			}
+			if err != nil && verdictOf(err) != tc.wantVerdict {
+				t.Errorf("verdictOf() = %s, want %s", verdictOf(err), tc.wantVerdict)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// endorsementAuthEnv is the environment variable holding the authorization
+// value of the endorsement hierarchy when --endorsement-auth is not set.
+const endorsementAuthEnv = "TPM_TRUST_ENDORSEMENT_AUTH"
+
+// parseEndorsementAuth returns the authorization value of the endorsement
+// hierarchy described by value (see --endorsement-auth), or by the
+// environment variable endorsementAuthEnv if value is empty. Unlike a plain
+// value, which is visible in the process list, the "file:" and "env:" forms
+// keep the secret off the command line. nil means the empty authorization value.
+func parseEndorsementAuth(value string, lookupEnv func(string) (string, bool)) ([]byte, error) {
+	if value == "" {
+		value, _ = lookupEnv(endorsementAuthEnv)
+		if value == "" {
+			return nil, nil
+		}
+	}
+	kind, rest, _ := strings.Cut(value, ":")
+	switch kind {
+	case "file":
+		data, err := os.ReadFile(rest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read endorsement hierarchy authorization: %w", err)
+		}
+		return []byte(strings.TrimRight(string(data), "\r\n")), nil
+	case "env":
+		auth, ok := lookupEnv(rest)
+		if !ok {
+			return nil, fmt.Errorf("endorsement hierarchy authorization: environment variable %s is not set", rest)
+		}
+		return []byte(auth), nil
+	case "hex":
+		auth, err := hex.DecodeString(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endorsement hierarchy authorization: %w", err)
+		}
+		return auth, nil
+	case "str":
+		return []byte(rest), nil
+	default:
+		return []byte(value), nil
+	}
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEndorsementAuth(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "auth")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{endorsementAuthEnv: "default", "EK_AUTH": "from-env"}
+	lookupEnv := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	noEnv := func(string) (string, bool) { return "", false }
+
+	tests := []struct {
+		name      string
+		value     string
+		lookupEnv func(string) (string, bool)
+		want      []byte
+		wantErr   bool
+	}{
+		{name: "empty auth", lookupEnv: noEnv},
+		{name: "default env", lookupEnv: lookupEnv, want: []byte("default")},
+		{name: "value", value: "secret", lookupEnv: lookupEnv, want: []byte("secret")},
+		{name: "str", value: "str:file:secret", lookupEnv: lookupEnv, want: []byte("file:secret")},
+		{name: "hex", value: "hex:00ff", lookupEnv: lookupEnv, want: []byte{0x00, 0xFF}},
+		{name: "file", value: "file:" + path, lookupEnv: lookupEnv, want: []byte("from-file")},
+		{name: "env", value: "env:EK_AUTH", lookupEnv: lookupEnv, want: []byte("from-env")},
+		{name: "missing file", value: "file:" + filepath.Join(t.TempDir(), "missing"), lookupEnv: lookupEnv, wantErr: true},
+		{name: "missing env", value: "env:MISSING", lookupEnv: lookupEnv, wantErr: true},
+		{name: "invalid hex", value: "hex:zz", lookupEnv: lookupEnv, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseEndorsementAuth(tc.value, tc.lookupEnv)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseEndorsementAuth() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if string(got) != string(tc.want) || (got == nil) != (tc.want == nil) {
+				t.Errorf("parseEndorsementAuth() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+// defaultCacheDir returns the directory where verified chains and CRLs are cached.
+func defaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tpm-trust", "chains"), nil
+}
+
+// cachePath returns the path of the cache entry of the EK certificate.
+// Entries are keyed by the SHA-256 fingerprint of the certificate.
+func cachePath(dir string, ek *x509.Certificate) string {
+	sum := sha256.Sum256(ek.Raw)
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".pem")
+}
+
+// loadCache reads the cache entry of the EK certificate.
+// An empty cache is returned if there is no entry yet.
+func loadCache(dir string, ek *x509.Certificate) (*validate.Cache, error) {
+	data, err := os.ReadFile(cachePath(dir, ek))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &validate.Cache{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	cache := &validate.Cache{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse cached certificate: %w", err)
+			}
+			cache.Chain = append(cache.Chain, cert)
+		case "X509 CRL":
+			rl, err := x509.ParseRevocationList(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse cached CRL: %w", err)
+			}
+			cache.CRLs = append(cache.CRLs, rl)
+		}
+	}
+	return cache, nil
+}
+
+// saveCache writes the cache entry of the EK certificate as a PEM bundle
+// holding the issuers followed by the CRLs.
+func saveCache(dir string, ek *x509.Certificate, cache *validate.Cache) error {
+	var buf bytes.Buffer
+	for _, cert := range cache.Chain {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return err
+		}
+	}
+	for _, rl := range cache.CRLs {
+		if err := pem.Encode(&buf, &pem.Block{Type: "X509 CRL", Bytes: rl.Raw}); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := os.WriteFile(cachePath(dir, ek), buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
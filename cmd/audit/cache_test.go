@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, cert, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := loadCache(dir, cert)
+	if err != nil {
+		t.Fatalf("loadCache() error = %v", err)
+	}
+	if len(cache.Chain) != 0 || len(cache.CRLs) != 0 {
+		t.Fatal("expected an empty cache")
+	}
+
+	want := &validate.Cache{Chain: []*x509.Certificate{cert}, CRLs: []*x509.RevocationList{crl}}
+	if err := saveCache(dir, cert, want); err != nil {
+		t.Fatalf("saveCache() error = %v", err)
+	}
+	info, err := os.Stat(cachePath(dir, cert))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("cache mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	got, err := loadCache(dir, cert)
+	if err != nil {
+		t.Fatalf("loadCache() error = %v", err)
+	}
+	if len(got.Chain) != 1 || !got.Chain[0].Equal(cert) {
+		t.Error("cached chain mismatch")
+	}
+	if len(got.CRLs) != 1 || got.CRLs[0].Number.Cmp(crl.Number) != 0 {
+		t.Error("cached CRLs mismatch")
+	}
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/loicsikidi/go-utils/system/fsutil"
+
+	"github.com/loicsikidi/tpm-trust/internal/ekfile"
+)
+
+// loadIntermediates reads the CA certificates of the files found in dir.
+// PEM files may hold several certificates.
+func loadIntermediates(dir string) ([]*x509.Certificate, error) {
+	paths, err := ekfile.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for _, path := range paths {
+		found, err := readCertificates(path)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, found...)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s (supported extensions: %s)", dir, strings.Join(ekfile.Extensions, ", "))
+	}
+	return certs, nil
+}
+
+// loadTrustAnchor reads the single CA certificate of the file at path.
+func loadTrustAnchor(path string) (*x509.Certificate, error) {
+	certs, err := readCertificates(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) != 1 {
+		return nil, fmt.Errorf("%s: expected a single trust anchor, found %d certificates", path, len(certs))
+	}
+	return certs[0], nil
+}
+
+// readCertificates reads the CA certificates of a PEM or DER file.
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := fsutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var ders [][]byte
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			if block.Type == "CERTIFICATE" {
+				ders = append(ders, block.Bytes)
+			}
+		}
+	} else {
+		ders = append(ders, data)
+	}
+
+	certs := make([]*x509.Certificate, 0, len(ders))
+	for _, der := range ders {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if !cert.IsCA {
+			return nil, fmt.Errorf("%s: %q is not a CA certificate", path, cert.Subject.String())
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
@@ -0,0 +1,151 @@
+package audit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadIntermediates(t *testing.T) {
+	t.Parallel()
+
+	ca1 := createTestCert(t, "Test CA 1", true)
+	ca2 := createTestCert(t, "Test CA 2", true)
+	ca3 := createTestCert(t, "Test CA 3", true)
+	leaf := createTestCert(t, "Test EK", false)
+	bundle := slices.Concat(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca1.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca2.Raw}),
+	)
+
+	tests := []struct {
+		name    string
+		files   map[string][]byte
+		want    []*x509.Certificate
+		wantErr bool
+	}{
+		{
+			name: "PEM bundle and DER",
+			files: map[string][]byte{
+				"bundle.pem": bundle,
+				"ca3.cer":    ca3.Raw,
+				"README.md":  []byte("ignored"),
+			},
+			want: []*x509.Certificate{ca1, ca2, ca3},
+		},
+		{
+			name:    "not a CA certificate",
+			files:   map[string][]byte{"ek.der": leaf.Raw},
+			wantErr: true,
+		},
+		{
+			name:    "invalid DER",
+			files:   map[string][]byte{"ca.der": []byte("not a certificate")},
+			wantErr: true,
+		},
+		{
+			name:    "no certificate",
+			files:   map[string][]byte{"README.md": []byte("ignored")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for name, data := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := loadIntermediates(dir)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("loadIntermediates() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !slices.EqualFunc(got, tc.want, (*x509.Certificate).Equal) {
+				t.Errorf("loadIntermediates() = %d certificate(s), want %d", len(got), len(tc.want))
+			}
+		})
+	}
+}
+
+func TestLoadTrustAnchor(t *testing.T) {
+	t.Parallel()
+
+	ca1 := createTestCert(t, "Test CA 1", true)
+	ca2 := createTestCert(t, "Test CA 2", true)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "PEM", data: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca1.Raw})},
+		{name: "DER", data: ca1.Raw},
+		{
+			name: "several certificates",
+			data: slices.Concat(
+				pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca1.Raw}),
+				pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca2.Raw}),
+			),
+			wantErr: true,
+		},
+		{name: "not a CA certificate", data: createTestCert(t, "Test EK", false).Raw, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "anchor.crt")
+			if err := os.WriteFile(path, tc.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadTrustAnchor(path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("loadTrustAnchor() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && !got.Equal(ca1) {
+				t.Errorf("loadTrustAnchor() = %q, want %q", got.Subject, ca1.Subject)
+			}
+		})
+	}
+}
+
+func createTestCert(t *testing.T, cn string, isCA bool) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+const (
+	// inTotoStatementType is the type of in-toto v1 statements.
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	// auditPredicateType identifies an audit result used as in-toto predicate.
+	auditPredicateType = "https://github.com/loicsikidi/tpm-trust/audit/v1"
+	// ekPublicKeySubject is the name of the subject of the statement.
+	ekPublicKeySubject = "ek-public-key"
+)
+
+// inTotoStatement is an in-toto v1 statement attesting the audit result of
+// an EK, ready to be wrapped in a DSSE envelope (payload type
+// application/vnd.in-toto+json) by a signing tool.
+//
+// Fields are encoded in a fixed order and the digest set has a single entry,
+// so the encoding of a given result is always the same.
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     *result         `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// newInTotoStatement wraps res in an in-toto statement whose subject is the
+// SHA-256 digest of the EK public key (DER encoded SubjectPublicKeyInfo).
+func newInTotoStatement(res *result) (*inTotoStatement, error) {
+	if len(res.publicKey) == 0 {
+		return nil, errors.New("no EK public key to use as in-toto subject")
+	}
+	sum := sha256.Sum256(res.publicKey)
+	return &inTotoStatement{
+		Type: inTotoStatementType,
+		Subject: []inTotoSubject{{
+			Name:   ekPublicKeySubject,
+			Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])},
+		}},
+		PredicateType: auditPredicateType,
+		Predicate:     res,
+	}, nil
+}
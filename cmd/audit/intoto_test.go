@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/loicsikidi/tpm-trust/internal/output"
+)
+
+func TestNewInTotoStatement(t *testing.T) {
+	t.Parallel()
+
+	cert := createTestCert(t, "EK", false)
+	res := &result{Source: sourceTPM, KeyType: "ecc-nist-p256", Verdict: verdictTrusted, publicKey: cert.RawSubjectPublicKeyInfo}
+
+	statement, err := newInTotoStatement(res)
+	if err != nil {
+		t.Fatalf("newInTotoStatement() error = %v", err)
+	}
+	var first, second bytes.Buffer
+	if err := output.WriteJSON(&first, statement, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.WriteJSON(&second, statement, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("encoding is not deterministic:\n%s\n%s", first.Bytes(), second.Bytes())
+	}
+
+	var got struct {
+		Type    string `json:"_type"`
+		Subject []struct {
+			Name   string            `json:"name"`
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+		PredicateType string         `json:"predicateType"`
+		Predicate     map[string]any `json:"predicate"`
+	}
+	if err := json.Unmarshal(first.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	if got.Type != inTotoStatementType || got.PredicateType != auditPredicateType {
+		t.Errorf("_type = %q, predicateType = %q", got.Type, got.PredicateType)
+	}
+	if len(got.Subject) != 1 || got.Subject[0].Name != ekPublicKeySubject || got.Subject[0].Digest["sha256"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("subject = %+v, want the SHA-256 digest of the EK public key", got.Subject)
+	}
+	if got.Predicate["verdict"] != string(verdictTrusted) {
+		t.Errorf("predicate = %v, want the audit result", got.Predicate)
+	}
+}
+
+func TestNewInTotoStatementWithoutEK(t *testing.T) {
+	t.Parallel()
+
+	if _, err := newInTotoStatement(&result{Source: sourceTPM, Verdict: verdictError}); err == nil {
+		t.Error("newInTotoStatement() expected an error")
+	}
+}
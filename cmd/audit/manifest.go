@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
+	"github.com/loicsikidi/tpm-trust/internal/output"
+)
+
+// downloadManifest lists what an audit downloaded (see --download-manifest),
+// so that it can be reproduced and changes of what a URL serves spotted.
+type downloadManifest struct {
+	CreatedAt time.Time             `json:"created_at"`
+	Downloads []httpclient.Download `json:"downloads"`
+}
+
+// writeDownloadManifest writes the downloads recorded by recorder to path
+// (replaced atomically), with the identifiers registered in redactor masked:
+// the URL (eg. of an EK certificate) and the digest of what was downloaded.
+func writeDownloadManifest(path string, recorder *httpclient.Recorder, redactor *redactor) error {
+	manifest := downloadManifest{
+		CreatedAt: time.Now().UTC(),
+		Downloads: recorder.Downloads(),
+	}
+	if manifest.Downloads == nil {
+		manifest.Downloads = []httpclient.Download{}
+	}
+	for i := range manifest.Downloads {
+		d := &manifest.Downloads[i]
+		d.URL = redactor.redact(d.URL)
+		d.SHA256 = redactor.redact(d.SHA256)
+		d.Error = redactor.redact(d.Error)
+	}
+	var buf bytes.Buffer
+	if err := output.WriteJSON(&buf, manifest, true); err != nil {
+		return err
+	}
+	if err := output.WriteFileAtomic(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write download manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
+)
+
+type stubHTTPClient struct{}
+
+func (stubHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader([]byte("crl")))}, nil
+}
+
+func TestWriteDownloadManifest(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "downloads.json")
+	recorder := &httpclient.Recorder{}
+	client, err := httpclient.New(httpclient.Config{Client: stubHTTPClient{}, Recorder: recorder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDownloadManifest(path, recorder, nil); err != nil {
+		t.Fatal(err)
+	}
+	var manifest downloadManifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Downloads == nil || len(manifest.Downloads) != 0 {
+		t.Errorf("Downloads = %v, want an empty list", manifest.Downloads)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/ca.crl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if err := writeDownloadManifest(path, recorder, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Downloads) != 1 || manifest.Downloads[0].URL != "http://example.com/ca.crl" || manifest.Downloads[0].Length != 3 {
+		t.Errorf("Downloads = %+v, want the download of ca.crl", manifest.Downloads)
+	}
+
+	// --redact masks the URL and the digest of an identifying download
+	r := &redactor{}
+	r.add("http://example.com/ca.crl", manifest.Downloads[0].SHA256)
+	if err := writeDownloadManifest(path, recorder, r); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if got := manifest.Downloads[0]; got.URL != redactedPlaceholder || got.SHA256 != redactedPlaceholder {
+		t.Errorf("download = %+v, want its URL and SHA-256 redacted", got)
+	}
+}
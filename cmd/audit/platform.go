@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/loicsikidi/go-utils/system/fsutil"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/tpm"
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+// platformCertFromNV reports whether the platform certificate is read from
+// an NV index (eg. 0x1C90000) rather than a file.
+func (o *options) platformCertFromNV() bool {
+	return strings.HasPrefix(strings.ToLower(o.platformCert), "0x")
+}
+
+// readPlatformCert reads the platform certificate from the NV index or the file of opts.platformCert.
+func readPlatformCert(ctx context.Context, logger log.Logger, opts *options) (*validate.PlatformCertificate, error) {
+	var data []byte
+	if opts.platformCertFromNV() {
+		index, err := strconv.ParseUint(opts.platformCert[2:], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid platform certificate NV index %q", opts.platformCert)
+		}
+		nv, err := tpm.ReadNV(ctx, tpm.TPMConfig{Logger: logger, WaitForTPM: opts.waitForTPM, Device: opts.tpmDevice}, tpm2.TPMHandle(index))
+		if err != nil {
+			return nil, err
+		}
+		if nv.Data == nil {
+			return nil, fmt.Errorf("NV index %s holds no platform certificate", opts.platformCert)
+		}
+		data = nv.Data
+	} else {
+		var err error
+		if data, err = fsutil.ReadFile(opts.platformCert); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", opts.platformCert, err)
+		}
+	}
+	pc, err := validate.ParsePlatformCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse platform certificate %s: %w", opts.platformCert, err)
+	}
+	return pc, nil
+}
+
+// validatePlatform validates the platform certificate of opts.platformCert,
+// which must reference ek and chain to the CAs of opts.platformCA.
+// Its description is reported in res.
+func validatePlatform(ctx context.Context, logger log.Logger, opts *options, ek *x509.Certificate, res *result) error {
+	startValidate := time.Now()
+	logger.WithField("source", opts.platformCert).Info("Validating platform certificate")
+	pc, err := readPlatformCert(ctx, logger, opts)
+	if err != nil {
+		return err
+	}
+	cas, err := readCertificates(opts.platformCA)
+	if err != nil {
+		return err
+	}
+
+	opts.redactor.add(pc.SerialNumber.String())
+	res.Platform = &platformResult{Serial: pc.SerialNumber.String(), Issuer: pc.Issuer.String()}
+	_, err = validate.CheckPlatformCertificate(validate.PlatformCheckConfig{
+		Certificate: pc,
+		EK:          ek,
+		CAs:         cas,
+	})
+	res.Platform.Trusted = err == nil
+	if err != nil {
+		res.Platform.Error = err.Error()
+	}
+	logStatus(logger, startValidate, err)
+	return err
+}
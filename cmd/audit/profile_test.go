@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartProfiling(t *testing.T) {
+	dir := t.TempDir()
+	cpuProfile := filepath.Join(dir, "cpu.pprof")
+	memProfile := filepath.Join(dir, "mem.pprof")
+
+	stop, err := startProfiling(cpuProfile, memProfile)
+	if err != nil {
+		t.Fatalf("startProfiling() error = %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("stop() error = %v", err)
+	}
+	for _, path := range []string{cpuProfile, memProfile} {
+		if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+			t.Errorf("profile %s not written: %v", filepath.Base(path), err)
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		stop, err := startProfiling("", "")
+		if err != nil {
+			t.Fatalf("startProfiling() error = %v", err)
+		}
+		if err := stop(); err != nil {
+			t.Errorf("stop() error = %v", err)
+		}
+	})
+
+	t.Run("invalid-path", func(t *testing.T) {
+		missing := filepath.Join(dir, "missing", "profile.pprof")
+		if _, err := startProfiling(missing, ""); err == nil {
+			t.Error("startProfiling() expected error for CPU profile in a missing directory")
+		}
+		stop, err := startProfiling("", missing)
+		if err != nil {
+			t.Fatalf("startProfiling() error = %v", err)
+		}
+		if err := stop(); err == nil {
+			t.Error("stop() expected error for memory profile in a missing directory")
+		}
+	})
+}
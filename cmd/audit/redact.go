@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// redactedPlaceholder replaces the identifiers masked by --redact.
+const redactedPlaceholder = "[REDACTED]"
+
+// minRedactedLength is the length below which a value is not worth
+// masking, and masking it would garble unrelated output (eg. a duration).
+const minRedactedLength = 4
+
+var (
+	oidCommonName   = asn1.ObjectIdentifier{2, 5, 4, 3}
+	oidSerialNumber = asn1.ObjectIdentifier{2, 5, 4, 5}
+)
+
+// redactor masks the identifiers of the audited certificates (--redact) in
+// the logs and the result, so that they can be shared publicly (eg. in an
+// issue). What is needed to debug an audit is preserved: manufacturer, TPM
+// model, key type, issuers, verdict and error codes.
+//
+// A nil redactor masks nothing.
+type redactor struct {
+	mu     sync.RWMutex
+	values []string // longest first
+}
+
+// add registers identifiers to mask.
+func (r *redactor) add(values ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range values {
+		if len(v) >= minRedactedLength && !slices.Contains(r.values, v) {
+			r.values = append(r.values, v)
+		}
+	}
+	// Longest first, so that a value containing another one is fully masked
+	slices.SortStableFunc(r.values, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+}
+
+// addCertificate registers the identifiers of cert: serial number, subject,
+// subject alternative names (the TPM device attributes are not identifiers)
+// and SHA-256 fingerprints of the certificate and of its public key.
+func (r *redactor) addCertificate(cert *x509.Certificate) {
+	if r == nil {
+		return
+	}
+	values := []string{
+		cert.SerialNumber.String(),
+		cert.SerialNumber.Text(16),
+		strings.ToUpper(cert.SerialNumber.Text(16)),
+		cert.Subject.String(),
+	}
+	values = append(values, subjectIdentifiers(cert.Subject)...)
+	values = append(values, cert.DNSNames...)
+	values = append(values, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		values = append(values, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		values = append(values, uri.String())
+	}
+	sum := sha256.Sum256(cert.Raw)
+	keySum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	values = append(values, hex.EncodeToString(sum[:]), hex.EncodeToString(keySum[:]))
+	r.add(values...)
+}
+
+// addCertificateURL registers the URL of an EK certificate derived from the
+// EK public key (AMD and Intel fTPMs) and its last path segment, which is a
+// digest of the EK public key (eg. base64 encoded for Intel).
+func (r *redactor) addCertificateURL(certURL string) {
+	if r == nil {
+		return
+	}
+	values := []string{certURL}
+	if u, err := url.Parse(certURL); err == nil {
+		values = append(values, path.Base(u.EscapedPath()), path.Base(u.Path))
+	}
+	r.add(values...)
+}
+
+// subjectIdentifiers returns the attributes of name identifying a device
+// (common name and serial number).
+func subjectIdentifiers(name pkix.Name) []string {
+	var values []string
+	for _, atv := range name.Names {
+		if v, ok := atv.Value.(string); ok && (atv.Type.Equal(oidCommonName) || atv.Type.Equal(oidSerialNumber)) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// redact returns s with the registered identifiers masked.
+func (r *redactor) redact(s string) string {
+	if r == nil {
+		return s
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, redactedPlaceholder)
+	}
+	return s
+}
+
+// redactResult masks the identifiers of res.
+func (r *redactor) redactResult(res *result) {
+	if r == nil {
+		return
+	}
+	res.Error = r.redact(res.Error)
+	res.fingerprint = r.redact(res.fingerprint)
+	for i := range res.Warnings {
+		res.Warnings[i].Message = r.redact(res.Warnings[i].Message)
+	}
+	if res.Platform != nil {
+		res.Platform.Serial = redactedPlaceholder
+		res.Platform.Error = r.redact(res.Platform.Error)
+	}
+}
+
+// redactError masks the identifiers of the message of err, which
+// still matches the errors it wraps.
+func (r *redactor) redactError(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+	msg := r.redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{err: err, msg: msg}
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
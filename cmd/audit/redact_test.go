@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal"
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+func TestRedactor(t *testing.T) {
+	t.Parallel()
+
+	ca, caKey := createTestCA(t, "Example EK CA")
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(0x1234567890),
+		Subject:      pkix.Name{CommonName: "device-0042", SerialNumber: "SN-98765"},
+		DNSNames:     []string{"host.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &redactor{}
+	r.addCertificate(ek)
+	// Intel EK certificate URLs embed the base64 encoded digest of the EK public key
+	r.addCertificateURL("https://ekop.intel.com/ekcertservice/aGVsbG8td29ybGQtZWstcHVia2V5LWRpZ2VzdA%3D%3D")
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "serial", in: "serial 78187493520 revoked", want: "serial [REDACTED] revoked"},
+		{name: "hex serial", in: "serial 1234567890", want: "serial [REDACTED]"},
+		{name: "common name", in: `"device-0042" is untrusted`, want: `"[REDACTED]" is untrusted`},
+		{name: "subject", in: ek.Subject.String() + " is untrusted", want: "[REDACTED] is untrusted"},
+		{name: "san", in: "SAN host.example.com", want: "SAN [REDACTED]"},
+		{name: "issuer", in: ca.Subject.String(), want: ca.Subject.String()},
+		{name: "short", in: "took 42ms", want: "took 42ms"},
+		{
+			name: "ek certificate url",
+			in:   "GET https://ekop.intel.com/ekcertservice/aGVsbG8td29ybGQtZWstcHVia2V5LWRpZ2VzdA%3D%3D: 404",
+			want: "GET [REDACTED]: 404",
+		},
+		{name: "ek public key digest", in: "pubhash aGVsbG8td29ybGQtZWstcHVia2V5LWRpZ2VzdA==", want: "pubhash [REDACTED]"},
+		{name: "ek certificate service", in: "https://ekop.intel.com/ekcertservice/", want: "https://ekop.intel.com/ekcertservice/"},
+	}
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := r.redact(tc.in); got != tc.want {
+				t.Errorf("redact(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRedactorResult(t *testing.T) {
+	t.Parallel()
+
+	r := &redactor{}
+	r.add("SN-98765")
+	res := &result{
+		Manufacturer: "IFX",
+		Warnings:     []validate.Warning{{Message: "missing CRL DP: CN=SN-98765"}},
+		Platform:     &platformResult{Serial: "1234", Issuer: "CN=Example Platform CA"},
+	}
+	res.setError(fmt.Errorf("%w: SN-98765", validate.ErrUntrustedCertificate))
+	r.redactResult(res)
+
+	if res.Error != validate.ErrUntrustedCertificate.Error()+": [REDACTED]" {
+		t.Errorf("Error = %q, want it redacted", res.Error)
+	}
+	if res.Verdict != verdictUntrusted || res.Manufacturer != "IFX" {
+		t.Errorf("verdict and manufacturer must be preserved, got %q and %q", res.Verdict, res.Manufacturer)
+	}
+	if strings.Contains(res.Warnings[0].Message, "SN-98765") {
+		t.Errorf("Warnings[0].Message = %q, want it redacted", res.Warnings[0].Message)
+	}
+	if res.Platform.Serial != redactedPlaceholder || res.Platform.Issuer != "CN=Example Platform CA" {
+		t.Errorf("Platform = %+v, want only its serial redacted", res.Platform)
+	}
+}
+
+func TestRedactorError(t *testing.T) {
+	t.Parallel()
+
+	r := &redactor{}
+	r.add("SN-98765")
+	err := r.redactError(internal.Silence(fmt.Errorf("%w: SN-98765", validate.ErrUntrustedCertificate)))
+	if strings.Contains(err.Error(), "SN-98765") {
+		t.Errorf("redactError() = %q, want it redacted", err)
+	}
+	if !errors.Is(err, validate.ErrUntrustedCertificate) || !errors.Is(err, internal.ErrSilence) {
+		t.Errorf("redactError() = %v, want it to wrap the original error", err)
+	}
+
+	var nilRedactor *redactor
+	if got := nilRedactor.redact("SN-98765"); got != "SN-98765" {
+		t.Errorf("nil redactor redact() = %q, want it unchanged", got)
+	}
+}
@@ -0,0 +1,364 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+	"github.com/loicsikidi/tpm-trust/internal/certinfo"
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/output"
+	"github.com/loicsikidi/tpm-trust/internal/tpm"
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+var (
+	errManufacturerNotAllowed = codes.New(codes.E050ManufacturerNotAllowed, "manufacturer not allowed")
+	// errDeadlineExceeded is returned when the audit is aborted by --deadline.
+	errDeadlineExceeded = codes.New(codes.E052DeadlineExceeded, "audit deadline exceeded")
+	// ErrUnsupportedManufacturer is matched by the error returned when the
+	// manufacturer of the TPM is not part of the trusted bundle
+	// (see [UnsupportedManufacturerError]).
+	ErrUnsupportedManufacturer = codes.New(codes.E051UnsupportedManufacturer, "unsupported manufacturer")
+)
+
+// exitUnsupportedManufacturer is the exit code of an audit which fails
+// because the manufacturer of the TPM is not part of the trusted bundle.
+const exitUnsupportedManufacturer = 3
+
+// UnsupportedManufacturerError reports a manufacturer of TPM which is not
+// part of the trusted bundle. It matches [ErrUnsupportedManufacturer].
+type UnsupportedManufacturerError struct {
+	// ID is the ASCII manufacturer ID (eg. "IFX").
+	ID string
+	// Name is the manufacturer name, if known (eg. "Infineon").
+	Name string
+}
+
+func (e *UnsupportedManufacturerError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("%s: %s", ErrUnsupportedManufacturer, e.ID)
+	}
+	return fmt.Sprintf("%s: %s (%s)", ErrUnsupportedManufacturer, e.ID, e.Name)
+}
+
+func (e *UnsupportedManufacturerError) Is(target error) bool {
+	return target == ErrUnsupportedManufacturer
+}
+
+// Code returns the code of [ErrUnsupportedManufacturer] (see [codes.Of]).
+func (e *UnsupportedManufacturerError) Code() codes.Code {
+	return codes.E051UnsupportedManufacturer
+}
+
+// untrustedErrors lists the errors meaning that the EK certificate
+// was evaluated and rejected (as opposed to an operational failure).
+var untrustedErrors = []error{
+	validate.ErrUntrustedCertificate,
+	validate.ErrEKCannotBeCA,
+	validate.ErrSelfSignedEK,
+	validate.ErrMissingAIA,
+	validate.ErrMissingSCT,
+	validate.ErrMissingRevocationDP,
+	validate.ErrDisallowedSignatureAlgorithm,
+	validate.ErrDisallowedKey,
+	validate.ErrUntrustedAKCertificate,
+	validate.ErrAKCannotBeCA,
+	validate.ErrAKRootMismatch,
+	validate.ErrManufacturerMismatch,
+	validate.ErrUnhandledCriticalExtension,
+	validate.ErrUntrustedPlatformCertificate,
+	validate.ErrPlatformEKMismatch,
+	errManufacturerNotAllowed,
+}
+
+type verdict string
+
+const (
+	verdictTrusted   verdict = "trusted"
+	verdictUntrusted verdict = "untrusted"
+	verdictRevoked   verdict = "revoked"
+	// verdictUnsupported means that the EK certificate could not be evaluated
+	// because the manufacturer of the TPM is not part of the trusted bundle.
+	verdictUnsupported verdict = "unsupported"
+	verdictError       verdict = "error"
+)
+
+// verdictOf maps the outcome of an audit to a verdict.
+func verdictOf(err error) verdict {
+	switch {
+	case err == nil:
+		return verdictTrusted
+	case errors.Is(err, x509util.ErrCertificateRevoked):
+		return verdictRevoked
+	case errors.Is(err, ErrUnsupportedManufacturer):
+		return verdictUnsupported
+	case slices.ContainsFunc(untrustedErrors, func(target error) bool { return errors.Is(err, target) }):
+		return verdictUntrusted
+	default:
+		return verdictError
+	}
+}
+
+// errorCode returns the code of err (see [codes.Of]). When err holds several
+// failures (see --fail-fast), it is the code of the first failure of the
+// verdict of err, ie. of the most severe one.
+func errorCode(err error) codes.Code {
+	var failures validate.CheckErrors
+	if errors.As(err, &failures) {
+		v := verdictOf(err)
+		for _, failure := range failures {
+			if verdictOf(failure) == v {
+				return codes.Of(failure)
+			}
+		}
+	}
+	return codes.Of(err)
+}
+
+const (
+	// sourceTPM is the source of a result when the EK certificate is read from the TPM.
+	sourceTPM = "tpm"
+	// sourceStdin is the source of a result when the EK certificate is read from the standard input.
+	sourceStdin = "stdin"
+	// sourceRequest is the source of a result when the EK certificate is submitted to the serve command.
+	sourceRequest = "request"
+)
+
+// result is the outcome of the audit of a single EK certificate.
+type result struct {
+	Source       string `json:"source"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	// TPMType is the kind of TPM (discrete, firmware, virtual or unknown).
+	TPMType string `json:"tpm_type,omitempty"`
+	KeyType string `json:"key_type,omitempty"`
+	// Policies lists the certificate policies of the EK certificate, if any.
+	Policies []certinfo.Policy `json:"policies,omitempty"`
+	// TPMSpecification is the TPM specification declared by the EK certificate, if any.
+	TPMSpecification *certinfo.TPMSpecification `json:"tpm_specification,omitempty"`
+	Verdict          verdict                    `json:"verdict"`
+	Error            string                     `json:"error,omitempty"`
+	// ErrorCode is the stable code of the error (eg. E010_UNTRUSTED), if known.
+	ErrorCode codes.Code `json:"error_code,omitempty"`
+	// AuditedAt is when the audit was performed.
+	AuditedAt time.Time `json:"audited_at,omitzero"`
+	// Bundle describes the trusted bundle the certificate was audited against.
+	Bundle *bundleResult `json:"bundle,omitempty"`
+	// AvailableCertificates lists every EK certificate found in the TPM,
+	// even though only the one of KeyType is audited.
+	AvailableCertificates []availableCertificate `json:"available_certificates,omitempty"`
+	// Platform describes the platform certificate, if audited (see --platform-cert).
+	Platform *platformResult `json:"platform,omitempty"`
+	// Root describes the root CA the EK certificate chains to, once verified.
+	Root *rootResult `json:"root,omitempty"`
+	// Warnings lists the soft issues found by the verification (eg. missing
+	// CRL distribution point), which don't change the verdict.
+	Warnings []validate.Warning `json:"warnings,omitempty"`
+	// Durations reports how long the audit took (unset in batch mode).
+	Durations *durationsResult `json:"durations,omitempty"`
+
+	// publicKey is the DER encoded public key of the EK, once read (see --format in-toto).
+	publicKey []byte
+	// fingerprint is the SHA-256 fingerprint of the EK certificate (hex), once read (see --format csv).
+	fingerprint string
+	// chain is the first verified chain (EK, intermediates, root), if any (see --tree).
+	chain []*x509.Certificate
+}
+
+// rootResult describes the root CA which anchored the trust in the EK certificate.
+type rootResult struct {
+	Subject string `json:"subject"`
+	// SKI is the subject key identifier (hex), if any.
+	SKI string `json:"ski,omitempty"`
+	// Fingerprint is the SHA-256 fingerprint of the certificate (hex).
+	Fingerprint string `json:"sha256_fingerprint"`
+}
+
+// newRootResult describes the root of the first verified chain (nil if there is none).
+func newRootResult(chains [][]*x509.Certificate) *rootResult {
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+	root := chains[0][len(chains[0])-1]
+	sum := sha256.Sum256(root.Raw)
+	return &rootResult{
+		Subject:     root.Subject.String(),
+		SKI:         hex.EncodeToString(root.SubjectKeyId),
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+}
+
+// durationsResult reports how long each phase of the audit took, and the
+// whole audit, in milliseconds. A phase which was not reached reports 0.
+type durationsResult struct {
+	ReadEK     int64 `json:"read_ek_ms"`
+	LoadBundle int64 `json:"load_bundle_ms"`
+	ValidateEK int64 `json:"validate_ek_ms"`
+	Total      int64 `json:"total_ms"`
+}
+
+// platformResult describes the platform certificate audited along with the EK certificate.
+type platformResult struct {
+	Serial  string `json:"serial"`
+	Issuer  string `json:"issuer"`
+	Trusted bool   `json:"trusted"`
+	Error   string `json:"error,omitempty"`
+}
+
+// bundleResult describes the release of the trusted bundle.
+type bundleResult struct {
+	Date    string `json:"date"`
+	Commit  string `json:"commit"`
+	AgeDays int    `json:"age_days"`
+}
+
+func newBundleResult(info validate.BundleInfo, now time.Time) *bundleResult {
+	return &bundleResult{
+		Date:    info.Date.Format(time.DateOnly),
+		Commit:  info.Commit,
+		AgeDays: int(info.Age(now).Hours() / 24),
+	}
+}
+
+// availableCertificate describes an EK certificate stored in the TPM NV storage.
+type availableCertificate struct {
+	KeyType string `json:"key_type"`
+	NVIndex string `json:"nv_index"`
+}
+
+func newAvailableCertificates(locs []tpm.CertificateLocation) []availableCertificate {
+	var certs []availableCertificate
+	for _, loc := range locs {
+		certs = append(certs, availableCertificate{
+			KeyType: loc.KeyType.String(),
+			NVIndex: fmt.Sprintf("0x%X", uint32(loc.Index)),
+		})
+	}
+	return certs
+}
+
+// setCertificate describes the EK certificate: its key type, certificate
+// policies and TPM specification. Malformed extensions are not reported.
+func (r *result) setCertificate(cert *x509.Certificate) {
+	r.KeyType = tpm.KeyTypeFromCert(cert).String()
+	sum := sha256.Sum256(cert.Raw)
+	r.fingerprint = hex.EncodeToString(sum[:])
+	r.Policies, _ = certinfo.ParsePolicies(cert)
+	r.TPMSpecification, _ = certinfo.ParseTPMSpecification(cert)
+}
+
+func (r *result) setError(err error) {
+	r.Verdict = verdictOf(err)
+	if err != nil {
+		r.Error = err.Error()
+		r.ErrorCode = errorCode(err)
+	}
+}
+
+// outputTable writes one line per result followed by a summary. kind is
+// what the results are about (eg. "file" or "device").
+func outputTable(w io.Writer, kind string, results []*result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tKTY\tVERDICT\tERROR\n", strings.ToUpper(kind))
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Source, r.KeyType, r.Verdict, r.Error)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	s := summarize(results)
+	_, err := fmt.Fprintf(w, "\n%d %s(s): %d trusted, %d untrusted, %d revoked, %d error(s)\n",
+		len(results), kind, s[verdictTrusted], s[verdictUntrusted], s[verdictRevoked], s[verdictError])
+	return err
+}
+
+// Revocation statuses of the CSV output.
+const (
+	revocationStatusGood       = "good"
+	revocationStatusRevoked    = "revoked"
+	revocationStatusUnknown    = "unknown"
+	revocationStatusNotChecked = "not_checked"
+)
+
+// csvHeader lists the columns of the CSV output, one row per result.
+var csvHeader = []string{
+	"timestamp",
+	"source",
+	"manufacturer",
+	"key_type",
+	"ek_fingerprint",
+	"verdict",
+	"error_code",
+	"root_ca",
+	"revocation_status",
+	"warnings",
+}
+
+// outputCSV writes one CSV row per result, after a header (see csvHeader).
+// revocation is the revocation check mode of the audit (eg. "soft").
+func outputCSV(w io.Writer, revocation string, results ...*result) error {
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		var timestamp, root string
+		if !r.AuditedAt.IsZero() {
+			timestamp = r.AuditedAt.Format(time.RFC3339)
+		}
+		if r.Root != nil {
+			root = r.Root.Subject
+		}
+		warnings := make([]string, 0, len(r.Warnings))
+		for _, w := range r.Warnings {
+			warnings = append(warnings, string(w.Code))
+		}
+		rows = append(rows, []string{
+			timestamp,
+			r.Source,
+			r.Manufacturer,
+			r.KeyType,
+			r.fingerprint,
+			string(r.Verdict),
+			string(r.ErrorCode),
+			root,
+			r.revocationStatus(revocation),
+			strings.Join(warnings, "; "),
+		})
+	}
+	return output.WriteCSV(w, csvHeader, rows)
+}
+
+// revocationStatus summarizes the revocation status of the EK certificate
+// given the revocation check mode: unknown when the check was inconclusive
+// (eg. soft failure), empty when the audit stopped before it.
+func (r *result) revocationStatus(revocation string) string {
+	switch {
+	case r.Verdict == verdictRevoked:
+		return revocationStatusRevoked
+	case revocation == revocationOff:
+		return revocationStatusNotChecked
+	case slices.ContainsFunc(r.Warnings, func(w validate.Warning) bool {
+		return w.Code == codes.W001MissingCRLDP || w.Code == codes.W004RevocationCheckFailed
+	}):
+		return revocationStatusUnknown
+	case r.Verdict == verdictTrusted:
+		return revocationStatusGood
+	default:
+		return ""
+	}
+}
+
+// summarize counts the results per verdict.
+func summarize(results []*result) map[verdict]int {
+	summary := make(map[verdict]int)
+	for _, r := range results {
+		summary[r.Verdict]++
+	}
+	return summary
+}
@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+	"github.com/loicsikidi/tpm-trust/internal"
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/tpm"
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+func TestVerdictOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		want     verdict
+		wantCode codes.Code
+	}{
+		{name: "no error", want: verdictTrusted},
+		{name: "revoked", err: x509util.ErrCertificateRevoked, want: verdictRevoked, wantCode: codes.E011Revoked},
+		{name: "untrusted", err: fmt.Errorf("%w: unknown authority", validate.ErrUntrustedCertificate), want: verdictUntrusted, wantCode: codes.E010Untrusted},
+		{name: "silenced untrusted", err: internal.Silence(validate.ErrDisallowedKey), want: verdictUntrusted, wantCode: codes.E015DisallowedKey},
+		{name: "unhandled critical extension", err: fmt.Errorf("%w: 1.2.3.4", validate.ErrUnhandledCriticalExtension), want: verdictUntrusted, wantCode: codes.E017UnhandledCriticalExtension},
+		{name: "self-signed", err: validate.ErrSelfSignedEK, want: verdictUntrusted, wantCode: codes.E027EKSelfSigned},
+		{name: "missing aia", err: fmt.Errorf("%w: issuer not found", validate.ErrMissingAIA), want: verdictUntrusted, wantCode: codes.E028MissingAIA},
+		{name: "missing sct", err: fmt.Errorf("%w: no embedded SCT", validate.ErrMissingSCT), want: verdictUntrusted, wantCode: codes.E029MissingSCT},
+		{name: "unsupported manufacturer", err: internal.Silence(&UnsupportedManufacturerError{ID: "XYZ"}), want: verdictUnsupported, wantCode: codes.E051UnsupportedManufacturer},
+		{name: "deadline exceeded", err: fmt.Errorf("%w (1s): context deadline exceeded", errDeadlineExceeded), want: verdictError, wantCode: codes.E052DeadlineExceeded},
+		{name: "crl rollback", err: fmt.Errorf("%w: CRL number 3 is lower than 5 cached", validate.ErrCRLRollback), want: verdictError, wantCode: codes.E060CRLRollback},
+		{name: "operational error", err: errors.New("connection refused"), want: verdictError},
+		{
+			name:     "several failures",
+			err:      validate.CheckErrors{validate.ErrDisallowedKey, fmt.Errorf("failed to check revocation: %w", x509util.ErrCertificateRevoked)},
+			want:     verdictRevoked,
+			wantCode: codes.E011Revoked,
+		},
+		{
+			name:     "several failures keep the code of the most severe",
+			err:      validate.CheckErrors{errors.New("connection refused"), validate.ErrDisallowedKey, validate.ErrMissingSCT},
+			want:     verdictUntrusted,
+			wantCode: codes.E015DisallowedKey,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := verdictOf(tc.err); got != tc.want {
+				t.Errorf("verdictOf() = %v, want %v", got, tc.want)
+			}
+			var res result
+			res.setError(tc.err)
+			if res.ErrorCode != tc.wantCode {
+				t.Errorf("setError() code = %q, want %q", res.ErrorCode, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestOutputTable(t *testing.T) {
+	results := []*result{
+		{Source: "a.pem", KeyType: "rsa-2048", Verdict: verdictTrusted},
+		{Source: "b.der", KeyType: "ecc-nist-p256", Verdict: verdictRevoked, Error: "certificate is revoked"},
+		{Source: "c.pem", Verdict: verdictError, Error: "failed to parse"},
+	}
+
+	var buf bytes.Buffer
+	if err := outputTable(&buf, "file", results); err != nil {
+		t.Fatalf("outputTable() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"FILE", "a.pem", "certificate is revoked", "3 file(s): 1 trusted, 0 untrusted, 1 revoked, 1 error(s)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestOutputCSV(t *testing.T) {
+	auditedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	results := []*result{
+		{
+			Source: "a.pem", AuditedAt: auditedAt, KeyType: "rsa-2048", fingerprint: "ab12", Verdict: verdictTrusted,
+			Root: &rootResult{Subject: "CN=Root CA,O=Acme"},
+		},
+		{Source: "b.der", AuditedAt: auditedAt, Verdict: verdictRevoked, ErrorCode: codes.E011Revoked},
+		{
+			Source: "c.pem", Verdict: verdictTrusted,
+			Warnings: []validate.Warning{{Code: codes.W004RevocationCheckFailed}, {Code: codes.W006MissingEKU}},
+		},
+		{Source: "d.pem", Verdict: verdictError},
+	}
+
+	var buf bytes.Buffer
+	if err := outputCSV(&buf, revocationSoft, results...); err != nil {
+		t.Fatalf("outputCSV() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"timestamp,source,manufacturer,key_type,ek_fingerprint,verdict,error_code,root_ca,revocation_status,warnings",
+		`2025-03-01T12:00:00Z,a.pem,,rsa-2048,ab12,trusted,,"CN=Root CA,O=Acme",good,`,
+		"2025-03-01T12:00:00Z,b.der,,,,revoked,E011_REVOKED,,revoked,",
+		",c.pem,,,,trusted,,,unknown,W004_REVOCATION_CHECK_FAILED; W006_MISSING_EKU",
+		",d.pem,,,,error,,,,",
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("outputCSV() = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := outputCSV(&buf, revocationOff, results[0]); err != nil {
+		t.Fatalf("outputCSV() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), ",not_checked,") {
+		t.Errorf("outputCSV() = %q, want revocation status not_checked", buf.String())
+	}
+}
+
+func TestNewAvailableCertificates(t *testing.T) {
+	locs := []tpm.CertificateLocation{
+		{KeyType: tpm.KeyTypeRSA2048, Index: 0x01C00002},
+		{KeyType: tpm.KeyTypeECCNistP256, Index: 0x01C0000A},
+	}
+
+	got, err := json.Marshal(&result{
+		Source:                sourceTPM,
+		Verdict:               verdictTrusted,
+		AvailableCertificates: newAvailableCertificates(locs),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"source":"tpm","verdict":"trusted","available_certificates":[{"key_type":"rsa-2048","nv_index":"0x1C00002"},{"key_type":"ecc-nist-p256","nv_index":"0x1C0000A"}]}`
+	if string(got) != want {
+		t.Errorf("json = %s, want %s", got, want)
+	}
+}
+
+func TestNewBundleResult(t *testing.T) {
+	info := validate.BundleInfo{Date: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC), Commit: "abc123"}
+	now := time.Date(2025, 12, 11, 12, 0, 0, 0, time.UTC)
+
+	got := newBundleResult(info, now)
+	want := &bundleResult{Date: "2025-12-01", Commit: "abc123", AgeDays: 10}
+	if *got != *want {
+		t.Errorf("newBundleResult() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewRootResult(t *testing.T) {
+	root := createTestCert(t, "Test Root CA", true)
+	leaf := createTestCert(t, "Test EK", false)
+
+	if got := newRootResult(nil); got != nil {
+		t.Errorf("newRootResult(nil) = %+v, want nil", got)
+	}
+
+	sum := sha256.Sum256(root.Raw)
+	got := newRootResult([][]*x509.Certificate{{leaf, root}})
+	want := &rootResult{
+		Subject:     "CN=Test Root CA",
+		SKI:         hex.EncodeToString(root.SubjectKeyId),
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+	if got == nil || *got != *want {
+		t.Errorf("newRootResult() = %+v, want %+v", got, want)
+	}
+	if want.SKI == "" {
+		t.Error("expected the test root to have a subject key identifier")
+	}
+}
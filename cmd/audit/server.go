@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/output"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+// status holds the result of the most recent audit.
+type status struct {
+	mu   sync.RWMutex
+	last *result
+}
+
+func (s *status) set(res *result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = res
+}
+
+func (s *status) get() *result {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.last
+}
+
+// handler serves the result of the most recent audit:
+//   - /healthz answers 200 if the TPM is trusted, 503 otherwise (or before the first audit);
+//   - /result answers the JSON result (503 before the first audit).
+func (s *status) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		res := s.get()
+		switch {
+		case res == nil:
+			http.Error(w, "no audit yet", http.StatusServiceUnavailable)
+		case res.Verdict != verdictTrusted:
+			http.Error(w, string(res.Verdict), http.StatusServiceUnavailable)
+		default:
+			fmt.Fprintln(w, res.Verdict)
+		}
+	})
+	mux.HandleFunc("GET /result", func(w http.ResponseWriter, r *http.Request) {
+		res := s.get()
+		if res == nil {
+			http.Error(w, "no audit yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = output.WriteJSON(w, res, false)
+	})
+	return mux
+}
+
+// serve starts serving handler on addr in the background.
+// The returned function gracefully shuts down the server.
+func serve(logger log.Logger, addr string, handler http.Handler) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Error("HTTP server failed")
+		}
+	}()
+	logger.Infof("serving HTTP on %s", ln.Addr())
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.WithError(err).Warn("failed to shut down HTTP server")
+		}
+	}, nil
+}
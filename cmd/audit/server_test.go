@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusHandler(t *testing.T) {
+	t.Parallel()
+
+	auditedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		last     *result
+		path     string
+		wantCode int
+	}{
+		{
+			name:     "healthz before first audit",
+			path:     "/healthz",
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "healthz trusted",
+			last:     &result{Verdict: verdictTrusted, AuditedAt: auditedAt},
+			path:     "/healthz",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "healthz revoked",
+			last:     &result{Verdict: verdictRevoked, AuditedAt: auditedAt},
+			path:     "/healthz",
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "result before first audit",
+			path:     "/result",
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "result untrusted",
+			last:     &result{Verdict: verdictUntrusted, AuditedAt: auditedAt},
+			path:     "/result",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "unknown path",
+			last:     &result{Verdict: verdictTrusted, AuditedAt: auditedAt},
+			path:     "/",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			st := &status{}
+			if tc.last != nil {
+				st.set(tc.last)
+			}
+			rec := httptest.NewRecorder()
+			st.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.wantCode {
+				t.Fatalf("status code = %d, want %d", rec.Code, tc.wantCode)
+			}
+			if tc.path != "/result" || tc.last == nil {
+				return
+			}
+			var got result
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON result: %v", err)
+			}
+			if got.Verdict != tc.last.Verdict || !got.AuditedAt.Equal(auditedAt) {
+				t.Errorf("result = %+v, want %+v", got, *tc.last)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package audit
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	goversion "github.com/caarlos0/go-version"
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/attest/info"
+	"github.com/loicsikidi/go-tpm-kit/manufacturer"
+	"github.com/loicsikidi/go-utils/system/fsutil"
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+	"github.com/spf13/cobra"
+
+	"github.com/loicsikidi/tpm-trust/internal/ekfile"
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/output"
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+// defaultServeAddr is the default listening address of the serve command:
+// the loopback interface, so that the service is only exposed on purpose.
+const defaultServeAddr = "127.0.0.1:8080"
+
+// serveOptions are the validation options of the audit command
+// along with the listening address of the service.
+type serveOptions struct {
+	options
+	addr string
+	// cacheSize is the number of results kept in the verdict cache (0 disables it).
+	cacheSize int
+	cacheTTL  time.Duration
+}
+
+// Check validates the options.
+func (o *serveOptions) Check() error {
+	if o.addr == "" {
+		return fmt.Errorf("--listen cannot be empty")
+	}
+	if o.cacheSize < 0 {
+		return fmt.Errorf("--verdict-cache-size cannot be negative")
+	}
+	if o.cacheSize > 0 && o.cacheTTL <= 0 {
+		return fmt.Errorf("--verdict-cache-ttl must be positive")
+	}
+	return o.options.Check()
+}
+
+// NewServeCommand creates the serve command, which audits the EK
+// certificates submitted over HTTP (eg. collected by remote agents).
+func NewServeCommand(info goversion.Info) *cobra.Command {
+	opts := &serveOptions{options: options{format: "text"}}
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "audit EK certificates submitted over HTTP",
+		Long: `Run an HTTP service auditing the EK certificates submitted by remote agents.
+
+The trusted bundle is loaded once at startup and shared by every request:
+restart the service to pick up a new release.
+
+Endpoints:
+  POST /v1/audit  - audit the EK certificate of the body (PEM or DER); the TPM
+                    manufacturer ID may be passed as 'manufacturer' query parameter
+                    (eg. ?manufacturer=IFX). Answers the JSON result, with status
+                    200 once evaluated (whatever the verdict), 400 if the request
+                    is invalid or 500 if the audit failed (eg. network failure).
+  GET  /v1/cache  - answers the size, TTL, number of entries, hits and misses of
+                    the verdict cache (404 if disabled)
+  GET  /healthz   - answers 200 while the service is running
+
+Results are cached in memory by EK public key (and manufacturer), so that
+repeated audits of the same device are fast: a result expires after
+--verdict-cache-ttl, or earlier when a CRL used to check its revocation
+status passes its next update. Failed audits (verdict error) are not cached.`,
+		Example: `  # Serve on the default address (127.0.0.1:8080)
+  tpm-trust serve
+
+  # Accept the EK certificates of remote agents on every interface
+  tpm-trust serve --listen :8080
+
+  # Audit an EK certificate collected on a host with an Infineon TPM
+  curl --data-binary @ek.pem 'http://localhost:8080/v1/audit?manufacturer=IFX'
+
+  # Keep up to 10000 results for 1 hour
+  tpm-trust serve --verdict-cache-size 10000 --verdict-cache-ttl 1h`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(cmd.Context(), opts)
+		},
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().StringVar(&opts.addr, "listen", defaultServeAddr, "Address to listen on (eg. :8080 for every interface)")
+	cmd.Flags().IntVar(&opts.cacheSize, "verdict-cache-size", defaultVerdictCacheSize, "Number of results cached in memory by EK public key (0 disables the cache)")
+	cmd.Flags().DurationVar(&opts.cacheTTL, "verdict-cache-ttl", defaultVerdictCacheTTL, "Lifetime of a cached result (shortened to the next update of the CRLs used)")
+	cmd.Flags().BoolVar(&opts.skipRevocationCheck, "skip-revocation-check", false, "Skip CRL revocation check (same as --revocation-check=off)")
+	cmd.Flags().StringVar(&opts.revocationCheck, "revocation-check", revocationEnforce, "Revocation check mode: off, soft (only warn on revoked certificate or CRL download failure) or enforce")
+	cmd.Flags().BoolVar(&opts.requireRevocationCheck, "require-revocation", false, "Fail if the EK certificate has no CRL distribution point")
+	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "Enable verbose logging")
+	cmd.Flags().StringVar(&opts.logFormat, "log-format", "text", "Log format (text or json)")
+	cmd.Flags().BoolVar(&opts.noColor, "no-color", false, "Disable colors in logs (already disabled when the output is not a terminal)")
+	cmd.Flags().BoolVar(&opts.disallowSHA1, "disallow-sha1", false, "Fail if a certificate of the chain is signed with SHA-1 (or weaker)")
+	cmd.Flags().IntVar(&opts.minRSABits, "min-rsa-bits", 0, "Reject RSA EK keys below this size (eg. 2048)")
+	cmd.Flags().StringSliceVar(&opts.allowedCurves, "allowed-curves", nil, "Only accept ECC EK keys on these curves (eg. ecc-nist-p256,ecc-nist-p384)")
+	cmd.Flags().BoolVar(&opts.strictManufacturer, "strict-manufacturer", false, "Fail if the EK certificate manufacturer attribute does not match the submitted manufacturer")
+	cmd.Flags().BoolVar(&opts.strictExtensions, "strict-extensions", false, "Fail if the EK certificate has critical extensions which are not processed (RFC 5280)")
+	cmd.Flags().BoolVar(&opts.strictKeyUsage, "strict-key-usage", false, "Fail if the EK certificate key usage does not conform to the TCG EK Credential Profile (keyEncipherment for RSA, keyAgreement for ECC)")
+	cmd.Flags().BoolVar(&opts.requireAIA, "require-aia", false, "Fail if the EK certificate has no AIA issuing certificate URL, even if its issuers are available locally")
+	cmd.Flags().StringVar(&opts.ctLogList, "ct-log-list", "", "Verify the SCTs embedded in the EK certificate against the Certificate Transparency logs of this JSON log list (v3 format)")
+	cmd.Flags().BoolVar(&opts.requireSCT, "require-sct", false, "Fail if the EK certificate has no valid SCT from a log of --ct-log-list")
+	cmd.Flags().BoolVar(&opts.failFast, "fail-fast", true, "Stop at the first failure of the EK certificate; use --fail-fast=false to report every failure (eg. revoked and untrusted) at once")
+	cmd.Flags().StringVar(&opts.intermediatesDir, "intermediates", "", "Directory of intermediate CA certificates used before downloading issuers via AIA")
+	cmd.Flags().StringVar(&opts.trustAnchor, "trust-anchor", "", "Only trust the root of this PEM or DER file instead of the manufacturers trusted bundle")
+	cmd.Flags().BoolVar(&opts.includeSystemRoots, "include-system-roots", false, "Also trust the roots of the system certificate store; weakens the audit as any CA of the store may then vouch for the EK")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", validate.DefaultTimeout, "Overall deadline of the certificate chain verification of a request (issuers and CRLs downloads)")
+	cmd.Flags().DurationVar(&opts.downloadTimeout, "download-timeout", validate.DefaultDownloadTimeout, "Timeout of a single issuer or CRL download (cannot exceed --timeout)")
+	cmd.Flags().DurationVar(&opts.maxBundleAge, "max-bundle-age", 0, "Fail at startup if the trusted bundle was released longer ago than this duration (eg. 720h)")
+	cmd.Flags().BoolVar(&opts.bundleAgeWarnOnly, "bundle-age-warn-only", false, "Only warn when the trusted bundle exceeds --max-bundle-age")
+	cmd.Flags().BoolVar(&opts.embeddedBundle, "embedded-bundle", false, "Use the trusted bundle embedded at build time instead of fetching it")
+	cmd.Flags().StringVar(&opts.userAgent, "user-agent", httpclient.UserAgent(info.GitVersion), "User-Agent header sent with HTTP requests")
+	cmd.Flags().StringVar(&opts.ipFamily, "ip-family", string(httpclient.IPFamilyAuto), "Address family of the downloads: auto, v4 (IPv4 only) or v6 (IPv6 only)")
+	cmd.Flags().StringSliceVar(&opts.directHosts, "direct-hosts", nil, "Hosts (and their subdomains) downloaded from directly, bypassing the proxy of the environment (in addition to NO_PROXY)")
+	return cmd
+}
+
+func runServe(ctx context.Context, opts *serveOptions) error {
+	if err := opts.Check(); err != nil {
+		return err
+	}
+
+	logger := newLogger(&opts.options, os.Stderr)
+	client, err := httpclient.New(opts.httpConfig())
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	svc, err := newService(ctx, logger, client, &opts.options)
+	if err != nil {
+		return err
+	}
+	if opts.cacheSize > 0 {
+		svc.cache = newVerdictCache(opts.cacheSize, opts.cacheTTL)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdown, err := serve(logger, opts.addr, svc.handler())
+	if err != nil {
+		return err
+	}
+	<-ctx.Done()
+	logger.Info("shutting down")
+	shutdown()
+	return nil
+}
+
+// service audits the EK certificates submitted over HTTP against
+// a checker (and trusted bundle) shared by every request.
+type service struct {
+	logger        log.Logger
+	opts          *options
+	trustedBundle apiv1beta.TrustedBundle
+	bundle        *bundleResult
+	checker       validate.Checker
+	// cache, if set, holds the latest results by EK public key.
+	cache *verdictCache
+}
+
+// newService loads the trusted bundle and creates the checker shared by every request.
+func newService(ctx context.Context, logger log.Logger, client *httpclient.Client, opts *options) (*service, error) {
+	trustedBundle, bundle, err := loadTrust(ctx, logger, client, opts)
+	if err != nil {
+		return nil, err
+	}
+	checker, err := newChecker(logger, trustedBundle, client, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &service{
+		logger:        logger,
+		opts:          opts,
+		trustedBundle: trustedBundle,
+		bundle:        bundle,
+		checker:       checker,
+	}, nil
+}
+
+// handler serves:
+//   - POST /v1/audit, which audits the EK certificate of the body;
+//   - GET /v1/cache, which answers the statistics of the verdict cache;
+//   - GET /healthz, which answers 200 while the service is running.
+func (s *service) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("POST /v1/audit", s.handleAudit)
+	mux.HandleFunc("GET /v1/cache", func(w http.ResponseWriter, r *http.Request) {
+		if s.cache == nil {
+			http.Error(w, "verdict cache is disabled", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = output.WriteJSON(w, s.cache.stats(), false)
+	})
+	return mux
+}
+
+func (s *service) handleAudit(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, fsutil.DefaultMaxFileSize))
+	if err != nil {
+		if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf("EK certificate too large: exceeds %d bytes", maxErr.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, "no EK certificate in the request body", http.StatusBadRequest)
+		return
+	}
+	cert, err := ekfile.Parse(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid EK certificate: %v", err), http.StatusBadRequest)
+		return
+	}
+	var m *info.Manufacturer
+	if id := r.URL.Query().Get("manufacturer"); id != "" {
+		if m, err = parseManufacturer(id); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	res := s.audit(r.Context(), cert, m)
+	status := http.StatusOK
+	if res.Verdict == verdictError {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = output.WriteJSON(w, res, false)
+}
+
+// audit validates cert, whose TPM manufacturer m may be unknown (nil).
+// The downloads are aborted if ctx is canceled (eg. the client disconnected).
+func (s *service) audit(ctx context.Context, cert *x509.Certificate, m *info.Manufacturer) *result {
+	if s.cache != nil {
+		if res := s.cache.get(cert, m); res != nil {
+			s.logger.WithField("subject", cert.Subject.String()).Debug("using cached result")
+			return res
+		}
+	}
+
+	start := time.Now()
+	res := &result{
+		Source:    sourceRequest,
+		AuditedAt: start.UTC(),
+		Bundle:    s.bundle,
+		Durations: &durationsResult{},
+	}
+	res.setCertificate(cert)
+	logger := s.logger.WithField("subject", cert.Subject.String())
+	if m != nil {
+		res.Manufacturer = m.ASCII
+		logger = logger.WithField("manufacturer", m.ASCII)
+	}
+	logger.Info("Auditing submitted EK certificate")
+
+	var err error
+	var collected validate.Cache
+	// A trust anchor is not tied to the manufacturers of the bundle
+	if m != nil && s.trustedBundle != nil {
+		err = checkManufacturer(s.logger, s.trustedBundle, *m)
+	}
+	if err == nil {
+		startValidate := time.Now()
+		var chains [][]*x509.Certificate
+		chains, err = validateEK(ctx, s.logger, s.checker, s.opts, endorsement.EK{Certificate: cert}, m, &res.Warnings, &collected)
+		res.Durations.ValidateEK = time.Since(startValidate).Milliseconds()
+		res.Root = newRootResult(chains)
+	}
+	res.setError(err)
+	res.Durations.Total = time.Since(start).Milliseconds()
+	logger.WithField("verdict", res.Verdict).Info("EK certificate audited")
+	if s.cache != nil && res.Verdict != verdictError {
+		s.cache.put(cert, m, res, collected.NextUpdate())
+	}
+	return res
+}
+
+// parseManufacturer returns the TPM manufacturer of the ASCII ID (eg. "IFX").
+func parseManufacturer(id string) (*info.Manufacturer, error) {
+	attr := manufacturer.GetTPMManufacturerAttrFromASCII(strings.TrimSpace(id))
+	if attr == "" {
+		return nil, fmt.Errorf("unknown manufacturer %q", id)
+	}
+	code, err := strconv.ParseUint(strings.TrimPrefix(attr, "id:"), 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manufacturer %q: %w", id, err)
+	}
+	m := info.GetManufacturerByID(manufacturer.ID(code))
+	return &m, nil
+}
@@ -0,0 +1,319 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+func TestServiceHandler(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t, "Test Root CA")
+	other, otherKey := createTestCA(t, "Other Root CA")
+	ek := createTestIssuedCert(t, "Test EK", root, rootKey)
+	untrustedEK := createTestIssuedCert(t, "Untrusted EK", other, otherKey)
+
+	anchor := filepath.Join(t.TempDir(), "anchor.pem")
+	if err := os.WriteFile(anchor, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// The chain of the untrusted EK is complete, but leads to another root
+	intermediates := t.TempDir()
+	if err := os.WriteFile(filepath.Join(intermediates, "other.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: other.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := httpclient.New(httpclient.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &options{
+		format:           "text",
+		trustAnchor:      anchor,
+		intermediatesDir: intermediates,
+		revocationCheck:  revocationOff,
+		timeout:          validate.DefaultTimeout,
+		downloadTimeout:  validate.DefaultDownloadTimeout,
+	}
+	svc, err := newService(t.Context(), log.New(log.WithNoop()), client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(svc.handler())
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name             string
+		query            string
+		body             []byte
+		wantCode         int
+		wantVerdict      verdict
+		wantManufacturer string
+	}{
+		{name: "trusted DER", body: ek.Raw, wantCode: http.StatusOK, wantVerdict: verdictTrusted},
+		{
+			name:        "trusted PEM",
+			body:        pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ek.Raw}),
+			wantCode:    http.StatusOK,
+			wantVerdict: verdictTrusted,
+		},
+		{
+			name:             "with manufacturer",
+			query:            "?manufacturer=IFX",
+			body:             ek.Raw,
+			wantCode:         http.StatusOK,
+			wantVerdict:      verdictTrusted,
+			wantManufacturer: "IFX",
+		},
+		{name: "untrusted", body: untrustedEK.Raw, wantCode: http.StatusOK, wantVerdict: verdictUntrusted},
+		{name: "unknown manufacturer", query: "?manufacturer=ZZZZ", body: ek.Raw, wantCode: http.StatusBadRequest},
+		{name: "not a certificate", body: []byte("not a certificate"), wantCode: http.StatusBadRequest},
+		{name: "empty body", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, err := http.Post(srv.URL+"/v1/audit"+tc.query, "application/octet-stream", bytes.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.wantCode {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("status = %d, want %d (%s)", resp.StatusCode, tc.wantCode, body)
+			}
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			var res result
+			if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Verdict != tc.wantVerdict {
+				t.Errorf("verdict = %q, want %q (error: %s)", res.Verdict, tc.wantVerdict, res.Error)
+			}
+			if res.Source != sourceRequest {
+				t.Errorf("source = %q, want %q", res.Source, sourceRequest)
+			}
+			if res.Manufacturer != tc.wantManufacturer {
+				t.Errorf("manufacturer = %q, want %q", res.Manufacturer, tc.wantManufacturer)
+			}
+			// The test EK certificates have no EK Extended Key Usage
+			if tc.wantVerdict == verdictTrusted && !slices.ContainsFunc(res.Warnings, func(w validate.Warning) bool {
+				return w.Code == codes.W006MissingEKU
+			}) {
+				t.Errorf("warnings = %v, want %s", res.Warnings, codes.W006MissingEKU)
+			}
+		})
+	}
+}
+
+func TestServeOptions_Check(t *testing.T) {
+	t.Parallel()
+
+	opts := &serveOptions{
+		options: options{
+			format:          "text",
+			logFormat:       "text",
+			timeout:         validate.DefaultTimeout,
+			downloadTimeout: validate.DefaultDownloadTimeout,
+		},
+		addr: defaultServeAddr,
+	}
+	if err := opts.Check(); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	opts.addr = ""
+	if err := opts.Check(); err == nil {
+		t.Error("Check() error = nil, want an error without listening address")
+	}
+	opts.addr = defaultServeAddr
+	opts.cacheSize = -1
+	if err := opts.Check(); err == nil {
+		t.Error("Check() error = nil, want an error with a negative cache size")
+	}
+	opts.cacheSize = defaultVerdictCacheSize
+	if err := opts.Check(); err == nil {
+		t.Error("Check() error = nil, want an error without cache TTL")
+	}
+}
+
+func TestServiceVerdictCache(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t, "Test Root CA")
+	ek := createTestIssuedCert(t, "Test EK", root, rootKey)
+	anchor := filepath.Join(t.TempDir(), "anchor.pem")
+	if err := os.WriteFile(anchor, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := httpclient.New(httpclient.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := newService(t.Context(), log.New(log.WithNoop()), client, &options{
+		format:          "text",
+		trustAnchor:     anchor,
+		revocationCheck: revocationOff,
+		timeout:         validate.DefaultTimeout,
+		downloadTimeout: validate.DefaultDownloadTimeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(svc.handler())
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/v1/cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d when the cache is disabled", resp.StatusCode, http.StatusNotFound)
+	}
+
+	svc.cache = newVerdictCache(defaultVerdictCacheSize, defaultVerdictCacheTTL)
+	for range 2 {
+		resp, err := http.Post(srv.URL+"/v1/audit", "application/octet-stream", bytes.NewReader(ek.Raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res result
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if res.Verdict != verdictTrusted {
+			t.Errorf("verdict = %q, want %q (error: %s)", res.Verdict, verdictTrusted, res.Error)
+		}
+	}
+
+	resp, err = http.Get(srv.URL + "/v1/cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var stats verdictCacheStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 1 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 1 entry, 1 hit and 1 miss", stats)
+	}
+}
+
+func TestServiceConcurrentAudits(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t, "Test Root CA")
+	anchor := filepath.Join(t.TempDir(), "anchor.pem")
+	if err := os.WriteFile(anchor, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := httpclient.New(httpclient.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := newService(t.Context(), log.New(log.WithNoop()), client, &options{
+		format:          "text",
+		trustAnchor:     anchor,
+		revocationCheck: revocationOff,
+		timeout:         validate.DefaultTimeout,
+		downloadTimeout: validate.DefaultDownloadTimeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests share the checker instead of waiting for each other
+	results := make([]*result, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		ek := createTestIssuedCert(t, fmt.Sprintf("Test EK %d", i), root, rootKey)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = svc.audit(t.Context(), ek, nil)
+		}()
+	}
+	wg.Wait()
+	for i, res := range results {
+		if res.Verdict != verdictTrusted {
+			t.Errorf("audit %d: verdict = %q, want %q (error: %s)", i, res.Verdict, verdictTrusted, res.Error)
+		}
+	}
+}
+
+func createTestCA(t *testing.T, cn string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func createTestIssuedCert(t *testing.T, cn string, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
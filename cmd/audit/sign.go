@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/output"
+	"github.com/loicsikidi/tpm-trust/internal/tpm"
+)
+
+// akSignatureSuffix is appended to --output-file to name its detached
+// signature (see --sign-with-ak).
+const akSignatureSuffix = ".sig"
+
+// akSignature is the detached signature of the result (--output-file or
+// stdout) made by an attestation key (AK) of the audited TPM (see --sign-with-ak).
+//
+// The signature alone only proves that the result was signed by the AK: a
+// verifier binds the AK to the TPM of the audited EK by credential activation
+// against the EK, which the TPM answers by loading back AKBlob.
+type akSignature struct {
+	// Hash is the hash algorithm of the signed bytes (eg. SHA-256).
+	Hash string `json:"hash"`
+	// Signature is the signature of the result: PKCS #1 v1.5 for an RSA AK,
+	// ASN.1 DER encoded for an ECC AK.
+	Signature []byte `json:"signature"`
+	// AKPublicKey is the PEM encoded public key of the AK.
+	AKPublicKey string `json:"ak_public_key"`
+	// AKAttestation holds the TPM structures certifying the creation of the AK.
+	AKAttestation akAttestation `json:"ak_attestation"`
+	// AKBlob is the AK encrypted by the TPM, which only this TPM can load.
+	AKBlob []byte `json:"ak_blob"`
+	// EKPublicKeyDigest is the SHA-256 digest (hex) of the DER encoded public
+	// key of the audited EK, if read.
+	EKPublicKeyDigest string `json:"ek_public_key_sha256,omitempty"`
+}
+
+// akAttestation holds the attestation parameters of an AK, in the TPM
+// wire format (TPM 2.0 Part 2, Structures).
+type akAttestation struct {
+	// Public is the TPMT_PUBLIC of the AK.
+	Public []byte `json:"public"`
+	// CreateData is the TPMS_CREATION_DATA of the AK.
+	CreateData []byte `json:"create_data"`
+	// CreateAttestation is the TPMS_ATTEST certifying the creation of the AK.
+	CreateAttestation []byte `json:"create_attestation"`
+	// CreateSignature is the TPMT_SIGNATURE of CreateAttestation by the AK.
+	CreateSignature []byte `json:"create_signature"`
+}
+
+// newAKSignature describes sig, made by an AK of the TPM whose EK public key
+// (DER encoded SubjectPublicKeyInfo) is ekPublicKey.
+func newAKSignature(sig *tpm.AKSignature, ekPublicKey []byte) (*akSignature, error) {
+	der, err := x509.MarshalPKIXPublicKey(sig.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode AK public key: %w", err)
+	}
+	if sig.Attestation.Public == nil {
+		return nil, fmt.Errorf("AK has no public area")
+	}
+	if len(sig.Blob) == 0 {
+		return nil, fmt.Errorf("AK has no blob")
+	}
+	res := &akSignature{
+		Hash:        sig.Hash.String(),
+		Signature:   sig.Signature,
+		AKPublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		AKAttestation: akAttestation{
+			Public:            tpm2.Marshal(sig.Attestation.Public),
+			CreateData:        tpm2.Marshal(&sig.Attestation.CreateData),
+			CreateAttestation: tpm2.Marshal(&sig.Attestation.CreateAttestation),
+			CreateSignature:   tpm2.Marshal(&sig.Attestation.CreateSignature),
+		},
+		AKBlob: sig.Blob,
+	}
+	if len(ekPublicKey) > 0 {
+		sum := sha256.Sum256(ekPublicKey)
+		res.EKPublicKeyDigest = hex.EncodeToString(sum[:])
+	}
+	return res, nil
+}
+
+// signResult signs data, the result as written to --output-file or stdout,
+// with an AK generated in the TPM. The signature is written next to
+// --output-file, or as a single JSON line on stderr when the result is
+// written to stdout.
+func signResult(ctx context.Context, logger log.Logger, opts *options, data, ekPublicKey []byte) error {
+	logger.Info("Signing the result with an AK of the TPM")
+	sig, err := tpm.SignWithAK(ctx, tpm.TPMConfig{Logger: logger, WaitForTPM: opts.waitForTPM, Device: opts.tpmDevice}, data)
+	if err != nil {
+		return err
+	}
+	signature, err := newAKSignature(sig, ekPublicKey)
+	if err != nil {
+		return err
+	}
+	signature.EKPublicKeyDigest = opts.redactor.redact(signature.EKPublicKeyDigest)
+	var buf bytes.Buffer
+	if opts.outputFile == "" {
+		if err := output.WriteJSON(&buf, signature, false); err != nil {
+			return err
+		}
+		if _, err := os.Stderr.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write AK signature: %w", err)
+		}
+		return nil
+	}
+	if err := output.WriteJSON(&buf, signature, opts.jsonPretty); err != nil {
+		return err
+	}
+	path := opts.outputFile + akSignatureSuffix
+	if err := output.WriteFileAtomic(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write AK signature: %w", err)
+	}
+	logger.WithField("file", path).Info("result signed with AK")
+	return nil
+}
@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/loicsikidi/attest"
+	"github.com/loicsikidi/go-tpm-kit/tpmtest"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/tpm"
+)
+
+func TestNewAKSignature(t *testing.T) {
+	t.Parallel()
+
+	// The simulator outlives SignWithAK to load the AK back
+	sim := keepOpen{tpmtest.OpenSimulator(t, tpmtest.OpenConfig{SkipProvisioning: true})}
+	data := []byte(`{"source":"tpm","verdict":"trusted"}`)
+	sig, err := tpm.SignWithAK(context.Background(), tpm.TPMConfig{TPM: sim, Logger: log.New(log.WithNoop())}, data)
+	if err != nil {
+		t.Fatalf("SignWithAK() error = %v", err)
+	}
+	ek := createTestCert(t, "EK", false)
+
+	got, err := newAKSignature(sig, ek.RawSubjectPublicKeyInfo)
+	if err != nil {
+		t.Fatalf("newAKSignature() error = %v", err)
+	}
+	if got.Hash != "SHA-256" {
+		t.Errorf("hash = %q, want SHA-256", got.Hash)
+	}
+	sum := sha256.Sum256(ek.RawSubjectPublicKeyInfo)
+	if got.EKPublicKeyDigest != hex.EncodeToString(sum[:]) {
+		t.Errorf("EK public key digest = %q, want %x", got.EKPublicKeyDigest, sum)
+	}
+	if _, err := tpm2.Unmarshal[tpm2.TPMTPublic](got.AKAttestation.Public); err != nil {
+		t.Errorf("AK attestation public area error = %v", err)
+	}
+	if _, err := tpm2.Unmarshal[tpm2.TPMSAttest](got.AKAttestation.CreateAttestation); err != nil {
+		t.Errorf("AK attestation error = %v", err)
+	}
+
+	// The detached signature is verified with the AK public key only
+	block, _ := pem.Decode([]byte(got.AKPublicKey))
+	if block == nil {
+		t.Fatalf("AK public key is not PEM encoded: %q", got.AKPublicKey)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(data)
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], got.Signature)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest[:], got.Signature) {
+			err = errors.New("invalid ECDSA signature")
+		}
+	}
+	if err != nil {
+		t.Errorf("signature verification error = %v", err)
+	}
+
+	// The AK is loaded back in the TPM to answer a credential activation challenge
+	akTPM, err := attest.OpenTPM(attest.OpenConfig{Transport: sim})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eks, err := akTPM.EKs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := attest.NewActivationParameters(eks[0], sig.Attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, challenge, err := params.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ak, err := akTPM.LoadAK(got.AKBlob)
+	if err != nil {
+		t.Fatalf("LoadAK() error = %v", err)
+	}
+	defer func() { _ = ak.Close() }()
+	activated, err := ak.ActivateCredentialWithEK(akTPM, *challenge, eks[0])
+	if err != nil {
+		t.Fatalf("ActivateCredentialWithEK() error = %v", err)
+	}
+	if !bytes.Equal(activated, secret) {
+		t.Errorf("activated secret = %x, want %x", activated, secret)
+	}
+}
+
+// keepOpen is a TPM whose connection is not closed by its users.
+type keepOpen struct {
+	transport.TPMCloser
+}
+
+func (keepOpen) Close() error { return nil }
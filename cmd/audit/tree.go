@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// writeTree renders the verified chain (EK, intermediates, root) as an
+// indented tree, from the root down to the EK certificate at the leaf,
+// each certificate annotated with its subject, validity and fingerprint.
+func writeTree(w io.Writer, chain []*x509.Certificate) error {
+	var b strings.Builder
+	for depth := range chain {
+		cert := chain[len(chain)-1-depth]
+		indent := strings.Repeat("    ", depth)
+		if depth > 0 {
+			fmt.Fprintf(&b, "%s└── ", strings.Repeat("    ", depth-1))
+		}
+		subject := cert.Subject.String()
+		if subject == "" {
+			// EK certificates usually identify the TPM in their SAN only
+			subject = "(empty subject)"
+		}
+		sum := sha256.Sum256(cert.Raw)
+		fmt.Fprintf(&b, "[%s] %s\n", treeRole(depth, len(chain)), subject)
+		fmt.Fprintf(&b, "%s  valid:  %s to %s\n", indent, cert.NotBefore.UTC().Format(time.DateOnly), cert.NotAfter.UTC().Format(time.DateOnly))
+		fmt.Fprintf(&b, "%s  sha256: %s\n", indent, hex.EncodeToString(sum[:]))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// treeRole returns the role of the certificate at depth (0 being the root)
+// in a chain of n certificates.
+func treeRole(depth, n int) string {
+	switch {
+	case depth == n-1:
+		return "EK"
+	case depth == 0:
+		return "root"
+	default:
+		return "intermediate"
+	}
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/x509"
+	"strings"
+	"testing"
+)
+
+func TestWriteTree(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t, "Test Root CA")
+	ek := createTestIssuedCert(t, "Test EK", root, rootKey)
+
+	tests := []struct {
+		name  string
+		chain []*x509.Certificate
+		want  []string
+	}{
+		{
+			name:  "ek and root",
+			chain: []*x509.Certificate{ek, root},
+			want:  []string{"[root] CN=Test Root CA", "  valid:  ", "  sha256: ", "└── [EK] CN=Test EK", "      valid:  ", "      sha256: "},
+		},
+		{
+			name:  "intermediate",
+			chain: []*x509.Certificate{ek, root, root},
+			want:  []string{"[root] CN=Test Root CA", "└── [intermediate] CN=Test Root CA", "    └── [EK] CN=Test EK", "          sha256: "},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			if err := writeTree(&buf, tc.chain); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if len(lines) != 3*len(tc.chain) {
+				t.Fatalf("writeTree() wrote %d lines, want %d:\n%s", len(lines), 3*len(tc.chain), buf.String())
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(buf.String(), "\n"+want) && !strings.HasPrefix(buf.String(), want) {
+					t.Errorf("writeTree() =\n%s\nwant line starting with %q", buf.String(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"crypto/x509"
+	"sync"
+	"time"
+
+	"github.com/loicsikidi/attest/info"
+)
+
+const (
+	// defaultVerdictCacheSize is the default number of results kept by the serve command.
+	defaultVerdictCacheSize = 1024
+	// defaultVerdictCacheTTL is the default lifetime of a cached result.
+	defaultVerdictCacheTTL = 5 * time.Minute
+)
+
+// verdictKey identifies a cached result: the SHA-256 fingerprint of the EK
+// public key along with the submitted manufacturer, which the verdict depends on.
+type verdictKey struct {
+	fingerprint  [sha256.Size]byte
+	manufacturer string
+}
+
+type verdictEntry struct {
+	key verdictKey
+	// raw is the EK certificate, as a new certificate of the same key
+	// (eg. reissued) must be audited again.
+	raw       []byte
+	res       *result
+	expiresAt time.Time
+}
+
+// verdictCacheStats are the counters of a [verdictCache].
+type verdictCacheStats struct {
+	Size    int    `json:"size"`
+	TTL     string `json:"ttl"`
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// verdictCache is an in-memory LRU cache of the results of the serve command,
+// so that repeated audits of the same device do not download its issuers and
+// CRLs again. A result expires after the TTL, or earlier when one of the CRLs
+// used to check its revocation status passes its NextUpdate.
+type verdictCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List // of *verdictEntry, most recently used first
+	entries map[verdictKey]*list.Element
+	hits    uint64
+	misses  uint64
+}
+
+func newVerdictCache(size int, ttl time.Duration) *verdictCache {
+	return &verdictCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		lru:     list.New(),
+		entries: make(map[verdictKey]*list.Element),
+	}
+}
+
+func newVerdictKey(cert *x509.Certificate, m *info.Manufacturer) verdictKey {
+	key := verdictKey{fingerprint: sha256.Sum256(cert.RawSubjectPublicKeyInfo)}
+	if m != nil {
+		key.manufacturer = m.ASCII
+	}
+	return key
+}
+
+// get returns the cached result of cert, or nil if there is none or it expired.
+func (c *verdictCache) get(cert *x509.Certificate, m *info.Manufacturer) *result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[newVerdictKey(cert, m)]
+	if ok {
+		entry := elem.Value.(*verdictEntry)
+		if c.now().Before(entry.expiresAt) && bytes.Equal(entry.raw, cert.Raw) {
+			c.lru.MoveToFront(elem)
+			c.hits++
+			return entry.res
+		}
+		c.remove(elem)
+	}
+	c.misses++
+	return nil
+}
+
+// put caches the result of cert until the TTL elapses or nextUpdate
+// (the earliest NextUpdate of the CRLs used, if not zero) is reached.
+func (c *verdictCache) put(cert *x509.Certificate, m *info.Manufacturer, res *result, nextUpdate time.Time) {
+	expiresAt := c.now().Add(c.ttl)
+	if !nextUpdate.IsZero() && nextUpdate.Before(expiresAt) {
+		expiresAt = nextUpdate
+	}
+	key := newVerdictKey(cert, m)
+	entry := &verdictEntry{key: key, raw: cert.Raw, res: res, expiresAt: expiresAt}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *verdictCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*verdictEntry).key)
+}
+
+func (c *verdictCache) stats() verdictCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return verdictCacheStats{
+		Size:    c.size,
+		TTL:     c.ttl.String(),
+		Entries: c.lru.Len(),
+		Hits:    c.hits,
+		Misses:  c.misses,
+	}
+}
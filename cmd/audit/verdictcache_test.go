@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/attest/info"
+)
+
+func TestVerdictCache(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t, "Test Root CA")
+	ek := createTestIssuedCert(t, "Test EK", root, rootKey)
+	other := createTestIssuedCert(t, "Other EK", root, rootKey)
+	// Same key, other certificate (eg. reissued)
+	reissued := *ek
+	reissued.Raw = append([]byte{0}, ek.Raw...)
+	ifx := &info.Manufacturer{ASCII: "IFX"}
+	res := &result{Verdict: verdictTrusted}
+
+	now := time.Now()
+	tests := []struct {
+		name       string
+		nextUpdate time.Time
+		elapsed    time.Duration
+		lookup     func(c *verdictCache) *result
+		wantHit    bool
+	}{
+		{name: "hit", lookup: func(c *verdictCache) *result { return c.get(ek, nil) }, wantHit: true},
+		{name: "expired", elapsed: time.Hour, lookup: func(c *verdictCache) *result { return c.get(ek, nil) }},
+		{
+			name:       "crl next update",
+			nextUpdate: now.Add(time.Minute),
+			elapsed:    2 * time.Minute,
+			lookup:     func(c *verdictCache) *result { return c.get(ek, nil) },
+		},
+		{
+			name:       "crl next update after ttl",
+			nextUpdate: now.Add(2 * time.Hour),
+			elapsed:    time.Hour,
+			lookup:     func(c *verdictCache) *result { return c.get(ek, nil) },
+		},
+		{name: "other key", lookup: func(c *verdictCache) *result { return c.get(other, nil) }},
+		{name: "other manufacturer", lookup: func(c *verdictCache) *result { return c.get(ek, ifx) }},
+		{name: "reissued", lookup: func(c *verdictCache) *result { return c.get(&reissued, nil) }},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newVerdictCache(10, 30*time.Minute)
+			c.now = func() time.Time { return now }
+			c.put(ek, nil, res, tc.nextUpdate)
+			c.now = func() time.Time { return now.Add(tc.elapsed) }
+
+			got := tc.lookup(c)
+			if (got != nil) != tc.wantHit {
+				t.Fatalf("get() = %v, want hit %v", got, tc.wantHit)
+			}
+			stats := c.stats()
+			if tc.wantHit && (stats.Hits != 1 || stats.Misses != 0) {
+				t.Errorf("stats = %+v, want 1 hit", stats)
+			}
+			if !tc.wantHit && (stats.Hits != 0 || stats.Misses != 1) {
+				t.Errorf("stats = %+v, want 1 miss", stats)
+			}
+		})
+	}
+}
+
+func TestVerdictCacheEviction(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t, "Test Root CA")
+	a := createTestIssuedCert(t, "EK A", root, rootKey)
+	b := createTestIssuedCert(t, "EK B", root, rootKey)
+	c := createTestIssuedCert(t, "EK C", root, rootKey)
+
+	cache := newVerdictCache(2, time.Hour)
+	cache.put(a, nil, &result{}, time.Time{})
+	cache.put(b, nil, &result{}, time.Time{})
+	// a becomes the most recently used, b is evicted
+	if cache.get(a, nil) == nil {
+		t.Fatal("get(a) = nil, want a result")
+	}
+	cache.put(c, nil, &result{}, time.Time{})
+
+	if cache.get(b, nil) != nil {
+		t.Error("get(b) != nil, want the least recently used result to be evicted")
+	}
+	if cache.get(a, nil) == nil || cache.get(c, nil) == nil {
+		t.Error("get() = nil, want the most recently used results to be kept")
+	}
+	if entries := cache.stats().Entries; entries != 2 {
+		t.Errorf("entries = %d, want 2", entries)
+	}
+}
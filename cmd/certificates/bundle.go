@@ -56,7 +56,7 @@ EK certificate chains may be stored in NV indices 0x01c00100 through 0x01c001ff.
 	return cmd
 }
 
-func runBundle(_ context.Context, opts *bundleOptions) error {
+func runBundle(ctx context.Context, opts *bundleOptions) error {
 	if err := opts.Check(); err != nil {
 		return err
 	}
@@ -74,7 +74,7 @@ func runBundle(_ context.Context, opts *bundleOptions) error {
 
 	logger.Info("Reading EK certificate chains from TPM NVRAM")
 
-	tpmInfo, err := tpm.Info(tpm.TPMConfig{
+	tpmInfo, err := tpm.Info(ctx, tpm.TPMConfig{
 		Logger: logger,
 	})
 	if err != nil {
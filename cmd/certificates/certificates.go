@@ -9,12 +9,14 @@ func NewCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "certificates",
 		Short: "manage TPM EK certificates",
-		Long:  `Commands to list and inspect Endorsement Key (EK) certificates from the TPM.`,
+		Long: `Commands to list and inspect Endorsement Key (EK) certificates from the TPM,
+and the manufacturers certificates they are verified against.`,
 	}
 
 	cmd.AddCommand(newListCommand())
 	cmd.AddCommand(newGetCommand())
 	cmd.AddCommand(newBundleCommand())
+	cmd.AddCommand(newShowVendorCommand())
 
 	return cmd
 }
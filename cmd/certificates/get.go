@@ -65,7 +65,7 @@ Available key types (KTY):
 	return cmd
 }
 
-func runGet(_ context.Context, opts *getOptions, args []string) error {
+func runGet(ctx context.Context, opts *getOptions, args []string) error {
 	if err := opts.Check(); err != nil {
 		return err
 	}
@@ -86,7 +86,7 @@ func runGet(_ context.Context, opts *getOptions, args []string) error {
 
 	logger.Infof("Reading %s EK certificate from TPM", keyType)
 
-	result, err := tpm.GetEKCertificate(tpm.TPMConfig{
+	result, err := tpm.GetEKCertificate(ctx, tpm.TPMConfig{
 		Logger:  logger,
 		KeyType: keyType,
 		// "get" fn is not a critical, we can skip public matching for faster operation
@@ -2,11 +2,11 @@ package certificates
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 
 	"github.com/loicsikidi/attest/info"
+	"github.com/loicsikidi/tpm-trust/internal/output"
 	"github.com/loicsikidi/tpm-trust/internal/privilege"
 	"github.com/loicsikidi/tpm-trust/internal/tpm"
 	"github.com/spf13/cobra"
@@ -15,9 +15,10 @@ import (
 )
 
 type listOptions struct {
-	verbose bool
-	format  string
-	tpm     tpmsimulator
+	verbose    bool
+	format     string
+	jsonPretty bool
+	tpm        tpmsimulator
 }
 
 func (o *listOptions) getSimulator() tpmsimulator {
@@ -52,6 +53,9 @@ For each certificate, displays:
   # List in JSON format
   tpm-trust certificates list --format json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed(output.JSONPrettyFlag) {
+				opts.jsonPretty = output.IsTerminal(os.Stdout)
+			}
 			return runList(cmd.Context(), opts)
 		},
 		Args:          cobra.NoArgs,
@@ -61,11 +65,12 @@ For each certificate, displays:
 
 	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "Enable verbose logging")
 	cmd.Flags().StringVar(&opts.format, "format", "text", "Output format: text or json")
+	cmd.Flags().BoolVar(&opts.jsonPretty, output.JSONPrettyFlag, false, "Indent JSON output (default: true when stdout is a terminal)")
 
 	return cmd
 }
 
-func runList(_ context.Context, opts *listOptions) error {
+func runList(ctx context.Context, opts *listOptions) error {
 	if err := opts.Check(); err != nil {
 		return err
 	}
@@ -86,13 +91,13 @@ func runList(_ context.Context, opts *listOptions) error {
 
 	logger.Info("Reading EK certificates from TPM")
 
-	result, err := tpm.GetEKCertificates(tpm.TPMConfig{Logger: logger, TPM: opts.tpm})
+	result, err := tpm.GetEKCertificates(ctx, tpm.TPMConfig{Logger: logger, TPM: opts.tpm})
 	if err != nil {
 		return fmt.Errorf("failed to read EK certificates: %w", err)
 	}
 
 	if opts.format == "json" {
-		return displayListJSON(result)
+		return displayListJSON(result, opts.jsonPretty)
 	}
 
 	return displayListText(logger, result)
@@ -109,20 +114,20 @@ type outputJSON struct {
 	Certificates []certificateJSON `json:"certificates"`
 }
 
-func displayListJSON(result *tpm.EKCertsResponse) error {
+func displayListJSON(result *tpm.EKCertsResponse, pretty bool) error {
 	var length int
 	if result != nil {
 		length = len(result.EKs)
 	}
 
-	output := outputJSON{
+	out := outputJSON{
 		Certificates: make([]certificateJSON, 0, length),
 	}
 
 	if length > 0 {
 		for _, ekInfo := range result.EKs {
 			cert := ekInfo.EK.Certificate
-			output.Certificates = append(output.Certificates, certificateJSON{
+			out.Certificates = append(out.Certificates, certificateJSON{
 				KeyType: ekInfo.KeyType.String(),
 				Issuer:  cert.Issuer.String(),
 				Subject: cert.Subject.String(),
@@ -131,9 +136,7 @@ func displayListJSON(result *tpm.EKCertsResponse) error {
 		}
 	}
 
-	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(output)
+	return output.WriteJSON(os.Stdout, out, pretty)
 }
 
 func displayListText(logger log.Logger, result *tpm.EKCertsResponse) error {
@@ -13,7 +13,8 @@ import (
 
 func TestListCommand(t *testing.T) {
 	baseOpts := &listOptions{
-		format: "json",
+		format:     "json",
+		jsonPretty: true,
 	}
 	tests := []struct {
 		name    string
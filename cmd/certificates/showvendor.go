@@ -0,0 +1,188 @@
+package certificates
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+	"github.com/spf13/cobra"
+
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/output"
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+type showVendorOptions struct {
+	vendor         apiv1beta.VendorID
+	verbose        bool
+	format         string
+	jsonPretty     bool
+	embeddedBundle bool
+}
+
+// Check validates the showVendorOptions configuration.
+func (o *showVendorOptions) Check() error {
+	if o.format != "text" && o.format != "json" {
+		return fmt.Errorf("invalid format: %s (must be 'text' or 'json')", o.format)
+	}
+	if err := o.vendor.Validate(); err != nil {
+		return fmt.Errorf("invalid vendor: %w", err)
+	}
+	return nil
+}
+
+func newShowVendorCommand() *cobra.Command {
+	opts := &showVendorOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show-vendor <id>",
+		Short: "display the certificates trusted for a TPM vendor",
+		Long: `Display the root and intermediate certificates that the manufacturers trusted
+bundle holds for a TPM vendor, identified by its TCG vendor ID (eg. IFX, NTC, STM).
+For each certificate, displays its subject, validity period and SHA-256 fingerprint.`,
+		Example: `  # Display the certificates trusted for Infineon TPMs
+  tpm-trust certificates show-vendor IFX
+
+  # Display them in JSON format
+  tpm-trust certificates show-vendor IFX --format json
+
+  # Inspect the trusted bundle embedded at build time
+  tpm-trust certificates show-vendor NTC --embedded-bundle`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.vendor = apiv1beta.VendorID(strings.ToUpper(strings.TrimSpace(args[0])))
+			if !cmd.Flags().Changed(output.JSONPrettyFlag) {
+				opts.jsonPretty = output.IsTerminal(os.Stdout)
+			}
+			return runShowVendor(cmd.Context(), opts, os.Stdout)
+		},
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "Enable verbose logging")
+	cmd.Flags().StringVar(&opts.format, "format", "text", "Output format: text or json")
+	cmd.Flags().BoolVar(&opts.jsonPretty, output.JSONPrettyFlag, false, "Indent JSON output (default: true when stdout is a terminal)")
+	cmd.Flags().BoolVar(&opts.embeddedBundle, "embedded-bundle", false, "Use the trusted bundle embedded at build time instead of fetching it")
+
+	return cmd
+}
+
+func runShowVendor(ctx context.Context, opts *showVendorOptions, w io.Writer) error {
+	if err := opts.Check(); err != nil {
+		return err
+	}
+
+	var logger log.Logger
+	if opts.format == "json" {
+		// Use noop logger for JSON output to avoid polluting the output
+		logger = log.New(log.WithNoop())
+	} else {
+		logger = log.New(log.WithVerbose(opts.verbose), log.WithOutput(os.Stderr))
+	}
+
+	logger.Info("Loading manufacturers trusted bundle")
+	var trustedBundle apiv1beta.TrustedBundle
+	var err error
+	if opts.embeddedBundle {
+		trustedBundle, err = validate.LoadEmbeddedBundle(ctx)
+	} else {
+		var client *httpclient.Client
+		if client, err = httpclient.New(httpclient.Config{}); err != nil {
+			return fmt.Errorf("failed to create HTTP client: %w", err)
+		}
+		trustedBundle, err = validate.FetchTrustedBundle(ctx, validate.FetchBundleConfig{
+			GetConfig: apiv1beta.GetConfig{
+				AutoUpdate: apiv1beta.AutoUpdateConfig{
+					Disabled: true,
+				},
+				HTTPClient: client,
+			},
+			Logger: logger,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get trusted bundle: %w", err)
+	}
+
+	certs, err := validate.GetVendorCertificates(trustedBundle, opts.vendor)
+	if err != nil {
+		return err
+	}
+
+	if opts.format == "json" {
+		return output.WriteJSON(w, newVendorJSON(certs), opts.jsonPretty)
+	}
+	displayVendorText(w, certs)
+	return nil
+}
+
+type vendorCertificateJSON struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"sha256_fingerprint"`
+}
+
+type vendorJSON struct {
+	Vendor        apiv1beta.VendorID      `json:"vendor"`
+	Roots         []vendorCertificateJSON `json:"roots"`
+	Intermediates []vendorCertificateJSON `json:"intermediates"`
+}
+
+func newVendorJSON(certs *validate.VendorCertificates) vendorJSON {
+	return vendorJSON{
+		Vendor:        certs.Vendor,
+		Roots:         newVendorCertificatesJSON(certs.Roots),
+		Intermediates: newVendorCertificatesJSON(certs.Intermediates),
+	}
+}
+
+func newVendorCertificatesJSON(certs []*x509.Certificate) []vendorCertificateJSON {
+	out := make([]vendorCertificateJSON, 0, len(certs))
+	for _, cert := range certs {
+		out = append(out, vendorCertificateJSON{
+			Subject:     cert.Subject.String(),
+			Issuer:      cert.Issuer.String(),
+			NotBefore:   cert.NotBefore.UTC(),
+			NotAfter:    cert.NotAfter.UTC(),
+			Fingerprint: fingerprint(cert),
+		})
+	}
+	return out
+}
+
+func displayVendorText(w io.Writer, certs *validate.VendorCertificates) {
+	fmt.Fprintf(w, "Vendor: %s\n", certs.Vendor)
+	for _, group := range []struct {
+		name  string
+		certs []*x509.Certificate
+	}{
+		{"Roots", certs.Roots},
+		{"Intermediates", certs.Intermediates},
+	} {
+		fmt.Fprintf(w, "\n%s (%d):\n", group.name, len(group.certs))
+		for _, cert := range group.certs {
+			fmt.Fprintf(w, "  - Subject:    %s\n", cert.Subject.String())
+			fmt.Fprintf(w, "    Issuer:     %s\n", cert.Issuer.String())
+			fmt.Fprintf(w, "    Not Before: %s\n", cert.NotBefore.UTC().Format(time.RFC3339))
+			fmt.Fprintf(w, "    Not After:  %s\n", cert.NotAfter.UTC().Format(time.RFC3339))
+			fmt.Fprintf(w, "    SHA-256:    %s\n", fingerprint(cert))
+		}
+	}
+}
+
+// fingerprint returns the SHA-256 fingerprint of cert (hex).
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+
+	"github.com/loicsikidi/tpm-trust/internal/validate"
+)
+
+func TestDisplayVendor(t *testing.T) {
+	t.Parallel()
+
+	root := createTestRoot(t)
+	sum := sha256.Sum256(root.Raw)
+	certs := &validate.VendorCertificates{Vendor: apiv1beta.IFX, Roots: []*x509.Certificate{root}}
+
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		displayVendorText(&buf, certs)
+		for _, want := range []string{
+			"Vendor: IFX",
+			"Roots (1):",
+			"Subject:    CN=Test Root CA",
+			"Not After:  " + root.NotAfter.UTC().Format(time.RFC3339),
+			"SHA-256:    " + hex.EncodeToString(sum[:]),
+			"Intermediates (0):",
+		} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("displayVendorText() = %q, want %q", buf.String(), want)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		got := newVendorJSON(certs)
+		if got.Vendor != apiv1beta.IFX || len(got.Roots) != 1 {
+			t.Fatalf("newVendorJSON() = %+v, want 1 root of IFX", got)
+		}
+		if got.Roots[0].Fingerprint != hex.EncodeToString(sum[:]) {
+			t.Errorf("newVendorJSON() fingerprint = %s, want %x", got.Roots[0].Fingerprint, sum)
+		}
+		if got.Intermediates == nil {
+			t.Error("newVendorJSON() intermediates = nil, want an empty list")
+		}
+	})
+}
+
+func TestShowVendorOptionsCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    showVendorOptions
+		wantErr bool
+	}{
+		{name: "valid", opts: showVendorOptions{vendor: apiv1beta.IFX, format: "json"}},
+		{name: "invalid-format", opts: showVendorOptions{vendor: apiv1beta.IFX, format: "pem"}, wantErr: true},
+		{name: "invalid-vendor", opts: showVendorOptions{vendor: "NOPE", format: "text"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tc.opts.Check(); (err != nil) != tc.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func createTestRoot(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -0,0 +1,22 @@
+package debug
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// NewCommand creates the debug parent command.
+//
+// It is hidden as its subcommands are meant to gather material for bug reports.
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "debug",
+		Short:  "troubleshoot the TPM",
+		Long:   `Diagnostic commands gathering raw material from the TPM, eg. to attach to a bug report.`,
+		Hidden: true,
+	}
+
+	cmd.AddCommand(newNVCommand())
+	cmd.AddCommand(newListNVCommand())
+
+	return cmd
+}
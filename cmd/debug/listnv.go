@@ -0,0 +1,79 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/spf13/cobra"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/privilege"
+	"github.com/loicsikidi/tpm-trust/internal/tpm"
+)
+
+type listNVOptions struct {
+	verbose bool
+	tpm     transport.TPMCloser
+}
+
+func newListNVCommand() *cobra.Command {
+	opts := &listNVOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list-nv",
+		Short: "list the NV indices defined in the TPM",
+		Long: `List the NV indices defined in the TPM along with their data size and attributes,
+without reading their contents.
+
+Indices where the TCG EK Credential Profile expects an EK certificate are flagged
+with the matching key type, which shows the actual provisioned layout (eg. to
+troubleshoot "structure is the wrong size" errors).`,
+		Example: `  # List the NV indices
+  tpm-trust debug list-nv`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runListNV(cmd.Context(), opts, os.Stdout)
+		},
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "Enable verbose logging")
+
+	return cmd
+}
+
+func runListNV(ctx context.Context, opts *listNVOptions, w io.Writer) error {
+	if opts.tpm == nil {
+		if err := privilege.Elevate(); err != nil {
+			return fmt.Errorf("failed to elevate privileges: %w", err)
+		}
+	}
+
+	logger := log.New(log.WithVerbose(opts.verbose))
+	indices, err := tpm.ListNV(ctx, tpm.TPMConfig{Logger: logger, TPM: opts.tpm})
+	if err != nil {
+		return err
+	}
+	if len(indices) == 0 {
+		logger.Warn("no NV index defined in the TPM")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tSIZE\tEK CERTIFICATE\tATTRIBUTES")
+	for _, nv := range indices {
+		ekCert := "-"
+		if kty, ok := nv.EKCertKeyType(); ok {
+			ekCert = kty.String()
+		}
+		fmt.Fprintf(tw, "0x%X\t%d\t%s\t0x%08X (%s)\n", uint32(nv.Index), nv.DataSize, ekCert,
+			nv.RawAttributes(), strings.Join(nv.AttributeNames(), " | "))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,39 @@
+package debug
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/loicsikidi/go-tpm-kit/tpmtest"
+)
+
+func TestRunListNV(t *testing.T) {
+	opts := &listNVOptions{
+		tpm: tpmtest.OpenSimulator(t, tpmtest.OpenConfig{
+			SkipCleanup: true, // TPM cleanup is handled by the internal code
+		}),
+	}
+	var buf bytes.Buffer
+	if err := runListNV(t.Context(), opts, &buf); err != nil {
+		t.Fatalf("runListNV() error = %v", err)
+	}
+
+	rows := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n")[1:] {
+		fields := strings.Fields(line)
+		rows[fields[0]] = line
+	}
+	for index, kty := range map[tpm2.TPMHandle]string{tpmtest.RSACertIndex: "rsa-2048", tpmtest.ECCCertIndex: "ecc-nist-p256"} {
+		row, ok := rows[fmt.Sprintf("0x%X", uint32(index))]
+		if !ok {
+			t.Errorf("NV index 0x%X not listed:\n%s", uint32(index), buf.String())
+			continue
+		}
+		if fields := strings.Fields(row); fields[2] != kty || !strings.Contains(row, "Written") {
+			t.Errorf("NV index 0x%X row = %q, want an %s EK certificate which was written", uint32(index), row, kty)
+		}
+	}
+}
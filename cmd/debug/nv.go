@@ -0,0 +1,106 @@
+package debug
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/spf13/cobra"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/output"
+	"github.com/loicsikidi/tpm-trust/internal/privilege"
+	"github.com/loicsikidi/tpm-trust/internal/tpm"
+)
+
+type nvOptions struct {
+	verbose    bool
+	outputFile string
+	tpm        transport.TPMCloser
+}
+
+func newNVCommand() *cobra.Command {
+	opts := &nvOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "nv INDEX",
+		Short: "dump the raw contents of an NV index",
+		Long: `Dump the raw contents of an NV index along with its public area (data size, attributes).
+
+The contents are not interpreted, which helps to troubleshoot EK certificates
+which cannot be parsed (eg. "structure is the wrong size").`,
+		Example: `  # Hex-dump the RSA 2048 EK certificate index
+  tpm-trust debug nv 0x1C00002
+
+  # Write the raw contents of the ECC P256 EK certificate index to a file
+  tpm-trust debug nv 0x1C0000A --output-file ek.bin`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runNV(cmd.Context(), opts, args[0])
+		},
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().StringVar(&opts.outputFile, "output-file", "", "Write the raw contents to this file instead of hex-dumping them")
+	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "Enable verbose logging")
+
+	return cmd
+}
+
+// parseNVIndex parses an NV index, in hexadecimal (eg. "0x1C00002") or decimal.
+func parseNVIndex(s string) (tpm2.TPMHandle, error) {
+	index, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid NV index %q", s)
+	}
+	if tpm2.TPMHT(index>>24) != tpm2.TPMHTNVIndex {
+		return 0, fmt.Errorf("invalid NV index %q: not in the NV index range (0x01xxxxxx)", s)
+	}
+	return tpm2.TPMHandle(index), nil
+}
+
+func runNV(ctx context.Context, opts *nvOptions, arg string) error {
+	index, err := parseNVIndex(arg)
+	if err != nil {
+		return err
+	}
+
+	if opts.tpm == nil {
+		if err := privilege.Elevate(); err != nil {
+			return fmt.Errorf("failed to elevate privileges: %w", err)
+		}
+	}
+
+	logger := log.New(log.WithVerbose(opts.verbose))
+	nv, err := tpm.ReadNV(ctx, tpm.TPMConfig{Logger: logger, TPM: opts.tpm}, index)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("NV index 0x%X", uint32(nv.Index))
+	logger.IncreasePadding()
+	logger.WithField("bytes", nv.DataSize).Info("data size")
+	logger.WithField("alg", fmt.Sprintf("0x%04X", uint16(nv.NameAlg))).Info("name algorithm")
+	logger.WithField("raw", fmt.Sprintf("0x%08X", nv.RawAttributes())).
+		Infof("attributes: %s", strings.Join(nv.AttributeNames(), " | "))
+	logger.DecreasePadding()
+
+	if nv.Data == nil {
+		return nil
+	}
+	if opts.outputFile != "" {
+		if err := output.WriteFileAtomic(opts.outputFile, nv.Data, 0o644); err != nil {
+			return fmt.Errorf("failed to write NV contents: %w", err)
+		}
+		logger.WithField("path", opts.outputFile).Infof("%d bytes written", len(nv.Data))
+		return nil
+	}
+	_, err = fmt.Fprint(os.Stdout, hex.Dump(nv.Data))
+	return err
+}
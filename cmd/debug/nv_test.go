@@ -0,0 +1,62 @@
+package debug
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/go-tpm-kit/tpmtest"
+)
+
+func TestParseNVIndex(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		arg     string
+		want    uint32
+		wantErr bool
+	}{
+		{name: "hexadecimal", arg: "0x1C00002", want: 0x1C00002},
+		{name: "decimal", arg: "29360130", want: 0x1C00002},
+		{name: "not an NV index", arg: "0x81010001", wantErr: true},
+		{name: "invalid", arg: "ek", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseNVIndex(tc.arg)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseNVIndex() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if uint32(got) != tc.want {
+				t.Errorf("parseNVIndex() = 0x%X, want 0x%X", uint32(got), tc.want)
+			}
+		})
+	}
+}
+
+func TestRunNV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ek.bin")
+	opts := &nvOptions{
+		outputFile: path,
+		tpm: tpmtest.OpenSimulator(t, tpmtest.OpenConfig{
+			SkipCleanup: true, // TPM cleanup is handled by the internal code
+		}),
+	}
+	if err := runNV(t.Context(), opts, "0x1C00002"); err != nil {
+		t.Fatalf("runNV() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := endorsement.ParseEKCertificate(data); err != nil {
+		t.Errorf("dumped contents are not an EK certificate: %v", err)
+	}
+}
@@ -2,7 +2,6 @@ package info
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"time"
@@ -10,14 +9,16 @@ import (
 	"github.com/loicsikidi/attest/info"
 	"github.com/loicsikidi/tpm-trust/internal/log"
 	"github.com/loicsikidi/tpm-trust/internal/logutil"
+	"github.com/loicsikidi/tpm-trust/internal/output"
 	"github.com/loicsikidi/tpm-trust/internal/privilege"
 	"github.com/loicsikidi/tpm-trust/internal/tpm"
 	"github.com/spf13/cobra"
 )
 
 type options struct {
-	format  string
-	verbose bool
+	format     string
+	verbose    bool
+	jsonPretty bool
 }
 
 // Check validates the options.
@@ -44,9 +45,15 @@ firmware version, supported algorithms, and more.`,
   # Display TPM info in JSON format
   tpm-trust info --format json
 
+  # Display TPM info in compact JSON format, even in a terminal
+  tpm-trust info --format json --json-pretty=false
+
   # Display TPM info with verbose logging
   tpm-trust info --verbose`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed(output.JSONPrettyFlag) {
+				opts.jsonPretty = output.IsTerminal(os.Stdout)
+			}
 			return run(cmd.Context(), opts)
 		},
 		Args:          cobra.NoArgs,
@@ -56,11 +63,12 @@ firmware version, supported algorithms, and more.`,
 
 	cmd.Flags().StringVarP(&opts.format, "format", "f", "text", "Output format (text or json)")
 	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "Enable verbose logging")
+	cmd.Flags().BoolVar(&opts.jsonPretty, output.JSONPrettyFlag, false, "Indent JSON output (default: true when stdout is a terminal)")
 
 	return cmd
 }
 
-func run(_ context.Context, opts *options) error {
+func run(ctx context.Context, opts *options) error {
 	if err := opts.Check(); err != nil {
 		return err
 	}
@@ -80,7 +88,7 @@ func run(_ context.Context, opts *options) error {
 	startRead := time.Now()
 	logger.Info("Reading TPM information")
 
-	tpmInfo, err := tpm.Info(tpm.TPMConfig{Logger: logger})
+	tpmInfo, err := tpm.Info(ctx, tpm.TPMConfig{Logger: logger})
 	if err != nil {
 		return fmt.Errorf("failed to read TPM info: %w", err)
 	}
@@ -89,16 +97,21 @@ func run(_ context.Context, opts *options) error {
 
 	switch opts.format {
 	case "json":
-		return outputJSON(tpmInfo)
+		return outputJSON(tpmInfo, opts.jsonPretty)
 	default: // text
 		return outputText(logger, tpmInfo)
 	}
 }
 
-func outputJSON(tpmInfo *info.TPMInfo) error {
-	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(tpmInfo); err != nil {
+// jsonInfo is the JSON output of the command: the TPM information along
+// with the kind of TPM.
+type jsonInfo struct {
+	*info.TPMInfo
+	Type tpm.Type `json:"type"`
+}
+
+func outputJSON(tpmInfo *info.TPMInfo, pretty bool) error {
+	if err := output.WriteJSON(os.Stdout, jsonInfo{TPMInfo: tpmInfo, Type: tpm.ClassifyType(tpmInfo)}, pretty); err != nil {
 		return fmt.Errorf("failed to encode TPM info as JSON: %w", err)
 	}
 	return nil
@@ -111,6 +124,7 @@ func outputText(logger log.Logger, tpmInfo *info.TPMInfo) error {
 		logger.WithField("id", tpmInfo.Manufacturer.ASCII).
 			WithField("name", tpmInfo.Manufacturer.Name).
 			Info("Manufacturer")
+		logger.WithField("type", tpm.ClassifyType(tpmInfo)).Info("Type")
 		logger.WithField("revision", tpmInfo.Revision).Info("Revision")
 		logger.WithField("major", tpmInfo.FirmwareVersion.Major).
 			WithField("minor", tpmInfo.FirmwareVersion.Minor).
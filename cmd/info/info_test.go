@@ -35,7 +35,7 @@ func TestOutputJSON(t *testing.T) {
 	}
 
 	// Test outputJSON
-	err := outputJSON(tpmInfo)
+	err := outputJSON(tpmInfo, true)
 	if err != nil {
 		t.Fatalf("outputJSON() failed: %v", err)
 	}
@@ -60,6 +60,10 @@ func TestOutputJSON(t *testing.T) {
 	if result["revision"] != "1.54" {
 		t.Errorf("expected revision to be '1.54', got %v", result["revision"])
 	}
+
+	if result["type"] != "unknown" {
+		t.Errorf("expected type to be 'unknown', got %v", result["type"])
+	}
 }
 
 func TestOutputText(t *testing.T) {
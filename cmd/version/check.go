@@ -0,0 +1,109 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
+)
+
+// latestReleaseURL is the GitHub API endpoint of the latest release (overridden in tests).
+var latestReleaseURL = "https://api.github.com/repos/loicsikidi/tpm-trust/releases/latest"
+
+// release is the subset of a GitHub release used by --check.
+type release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// fetchLatestRelease queries the GitHub releases API for the latest release.
+func fetchLatestRelease(ctx context.Context, client httpclient.HTTPClient) (*release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, latestReleaseURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP %d fetching latest release", resp.StatusCode)
+	}
+	var rel release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&rel); err != nil {
+		return nil, fmt.Errorf("failed to decode latest release: %w", err)
+	}
+	if rel.TagName == "" {
+		return nil, fmt.Errorf("latest release has no tag")
+	}
+	return &rel, nil
+}
+
+// checkReport describes how the running version compares to the latest release.
+func checkReport(current string, latest *release) string {
+	cmp, ok := compareVersions(current, latest.TagName)
+	switch {
+	case !ok:
+		return fmt.Sprintf("cannot compare version %q to the latest release %s (development build?)\ndownload: %s", current, latest.TagName, latest.HTMLURL)
+	case cmp < 0:
+		return fmt.Sprintf("a newer release is available: %s (running %s)\ndownload: %s", latest.TagName, current, latest.HTMLURL)
+	default:
+		return fmt.Sprintf("tpm-trust %s is up to date", current)
+	}
+}
+
+// compareVersions compares two semantic versions (eg. "v1.2.3"), returning
+// -1, 0 or +1. A pre-release (eg. "v1.2.3-rc.1") precedes its release.
+// ok is false if a version cannot be parsed.
+func compareVersions(a, b string) (cmp int, ok bool) {
+	va, preA, okA := parseVersion(a)
+	vb, preB, okB := parseVersion(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			if va[i] < vb[i] {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0, true
+	case preA == "":
+		return 1, true
+	case preB == "":
+		return -1, true
+	default:
+		return strings.Compare(preA, preB), true
+	}
+}
+
+// parseVersion parses "vMAJOR.MINOR.PATCH[-PRERELEASE][+BUILD]".
+func parseVersion(v string) (core [3]int, prerelease string, ok bool) {
+	v, _, _ = strings.Cut(strings.TrimPrefix(strings.TrimSpace(v), "v"), "+")
+	v, prerelease, _ = strings.Cut(v, "-")
+	parts := strings.Split(v, ".")
+	if len(parts) != len(core) {
+		return core, "", false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return core, "", false
+		}
+		core[i] = n
+	}
+	return core, prerelease, true
+}
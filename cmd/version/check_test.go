@@ -0,0 +1,95 @@
+package version
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
+)
+
+func TestCompareVersions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		a, b   string
+		want   int
+		wantOK bool
+	}{
+		{name: "equal", a: "v1.2.3", b: "v1.2.3", want: 0, wantOK: true},
+		{name: "older patch", a: "v1.2.3", b: "v1.2.4", want: -1, wantOK: true},
+		{name: "newer minor", a: "v1.10.0", b: "v1.9.9", want: 1, wantOK: true},
+		{name: "without prefix", a: "1.2.3", b: "v1.2.3", want: 0, wantOK: true},
+		{name: "pre-release precedes release", a: "v1.2.3-rc.1", b: "v1.2.3", want: -1, wantOK: true},
+		{name: "build metadata ignored", a: "v1.2.3+dirty", b: "v1.2.3", want: 0, wantOK: true},
+		{name: "development build", a: "(devel)", b: "v1.2.3"},
+		{name: "incomplete", a: "v1.2", b: "v1.2.3"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := compareVersions(tc.a, tc.b)
+			if ok != tc.wantOK {
+				t.Fatalf("compareVersions(%q, %q) ok = %v, want %v", tc.a, tc.b, ok, tc.wantOK)
+			}
+			if got != tc.want {
+				t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("User-Agent"); got != "tpm-trust/v1.0.0" {
+			t.Errorf("User-Agent = %q, want %q", got, "tpm-trust/v1.0.0")
+		}
+		w.Write([]byte(`{"tag_name":"v1.1.0","html_url":"https://github.com/loicsikidi/tpm-trust/releases/tag/v1.1.0"}`)) //nolint:errcheck
+	}))
+	t.Cleanup(srv.Close)
+	original := latestReleaseURL
+	latestReleaseURL = srv.URL
+	t.Cleanup(func() { latestReleaseURL = original })
+
+	client, err := httpclient.New(httpclient.Config{UserAgent: httpclient.UserAgent("v1.0.0")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := fetchLatestRelease(t.Context(), client)
+	if err != nil {
+		t.Fatalf("fetchLatestRelease() error = %v", err)
+	}
+
+	tests := []struct {
+		current string
+		want    string
+	}{
+		{current: "v1.0.0", want: "a newer release is available: v1.1.0 (running v1.0.0)\ndownload: https://github.com/loicsikidi/tpm-trust/releases/tag/v1.1.0"},
+		{current: "v1.1.0", want: "is up to date"},
+		{current: "(devel)", want: "cannot compare"},
+	}
+	for _, tc := range tests {
+		if got := checkReport(tc.current, latest); !strings.Contains(got, tc.want) {
+			t.Errorf("checkReport(%q) = %q, want %q", tc.current, got, tc.want)
+		}
+	}
+}
+
+func TestFetchLatestReleaseError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	t.Cleanup(srv.Close)
+	original := latestReleaseURL
+	latestReleaseURL = srv.URL
+	t.Cleanup(func() { latestReleaseURL = original })
+
+	if _, err := fetchLatestRelease(t.Context(), http.DefaultClient); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("fetchLatestRelease() error = %v, want HTTP 403", err)
+	}
+}
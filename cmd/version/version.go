@@ -1,23 +1,76 @@
 package version
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	goversion "github.com/caarlos0/go-version"
 	"github.com/spf13/cobra"
+
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
 )
 
+// defaultCheckTimeout is the default deadline of --check.
+const defaultCheckTimeout = 10 * time.Second
+
+type options struct {
+	check     bool
+	timeout   time.Duration
+	userAgent string
+}
+
 // NewCommand creates the version command.
 func NewCommand(info goversion.Info) *cobra.Command {
+	opts := &options{}
+
 	cmd := &cobra.Command{
-		Use:          "version",
-		Short:        "display the current version of the cli",
-		Long:         `Display detailed version information including revision, version, build time, and dirty status.`,
-		SilenceUsage: true,
-		Run: func(cmd *cobra.Command, args []string) {
+		Use:   "version",
+		Short: "display the current version of the cli",
+		Long: `Display detailed version information including revision, version, build time, and dirty status.
+
+With --check, the GitHub releases API is queried to report whether a newer
+release exists. The proxy settings of the environment (HTTPS_PROXY, NO_PROXY)
+are honored.`,
+		Example: `  # Display the version
+  tpm-trust version
+
+  # Report whether a newer release exists
+  tpm-trust version --check`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
 			fmt.Println(info.String())
+			if !opts.check {
+				return nil
+			}
+			return runCheck(cmd.Context(), opts, info.GitVersion)
 		},
 	}
 
+	cmd.Flags().BoolVar(&opts.check, "check", false, "Report whether a newer release exists (queries the GitHub releases API)")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", defaultCheckTimeout, "Deadline of the --check query")
+	cmd.Flags().StringVar(&opts.userAgent, "user-agent", httpclient.UserAgent(info.GitVersion), "User-Agent header sent with HTTP requests")
+
 	return cmd
 }
+
+func runCheck(ctx context.Context, opts *options, current string) error {
+	if opts.timeout <= 0 {
+		return fmt.Errorf("--timeout must be positive")
+	}
+	client, err := httpclient.New(httpclient.Config{UserAgent: opts.userAgent})
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	latest, err := fetchLatestRelease(ctx, client)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Println(checkReport(current, latest))
+	return nil
+}
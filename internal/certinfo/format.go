@@ -14,7 +14,8 @@ import (
 // format
 //
 // The output includes: certificate type, key algorithm, serial number,
-// subject, SKI, issuer, AKI, AIA, CRLDP, and validity period.
+// subject, SKI, issuer, AKI, AIA, CRLDP, certificate policies and TPM
+// specification (if any), and validity period.
 func CertificateTextShort(cert *x509.Certificate) (string, error) {
 	if cert == nil {
 		return "", fmt.Errorf("certificate cannot be nil")
@@ -57,6 +58,14 @@ func CertificateTextShort(cert *x509.Certificate) (string, error) {
 	writeField(&b, "AIA", cert.IssuingCertificateURL)
 	writeField(&b, "CRLDP", cert.CRLDistributionPoints)
 
+	// Only reported when present (eg. EK certificates)
+	if policies, err := ParsePolicies(cert); err == nil && len(policies) > 0 {
+		writeField(&b, "Policies", formatPolicies(policies))
+	}
+	if spec, err := ParseTPMSpecification(cert); err == nil && spec != nil {
+		fmt.Fprintf(&b, "  TPM spec:    %s\n", spec)
+	}
+
 	fmt.Fprintf(&b, "  Valid from:  %s\n", cert.NotBefore.Format(time.RFC3339))
 	fmt.Fprintf(&b, "          to:  %s", cert.NotAfter.Format(time.RFC3339))
 
@@ -0,0 +1,209 @@
+package certinfo
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	oidCertificatePolicies        = asn1.ObjectIdentifier{2, 5, 29, 32}
+	oidSubjectDirectoryAttributes = asn1.ObjectIdentifier{2, 5, 29, 9}
+
+	// Policy qualifiers (RFC 5280, section 4.2.1.4)
+	oidQualifierCPS        = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 2, 1}
+	oidQualifierUserNotice = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 2, 2}
+
+	// OID of the TPM specification attribute of the Subject Directory
+	// Attributes, defined in TCG EK Credential Profile
+	oidTPMSpecification = asn1.ObjectIdentifier{2, 23, 133, 2, 16}
+
+	// oidTCG is the arc of the OIDs assigned by the TCG.
+	oidTCG = asn1.ObjectIdentifier{2, 23, 133}
+)
+
+// policyNames names the well-known certificate policies: the ones of the TCG
+// (TCG OID registry) and anyPolicy.
+var policyNames = map[string]string{
+	"2.5.29.32.0":     "anyPolicy",
+	"2.23.133.11.1.1": "tcg-cap-verifiedTPMResidency",
+	"2.23.133.11.1.2": "tcg-cap-verifiedTPMFixed",
+	"2.23.133.11.1.3": "tcg-cap-verifiedTPMRestricted",
+}
+
+// Policy is a certificate policy (RFC 5280, section 4.2.1.4), such as the
+// policy under which an EK certificate was issued.
+type Policy struct {
+	// OID is the policy identifier (eg. "2.23.133.11.1.1").
+	OID string `json:"oid"`
+	// Name is the name of a well-known policy (eg. "tcg-cap-verifiedTPMResidency"),
+	// "TCG" for an unknown policy of the TCG, empty otherwise.
+	Name string `json:"name,omitempty"`
+	// CPS lists the URIs of the Certification Practice Statements.
+	CPS []string `json:"cps,omitempty"`
+	// UserNotices lists the explicit texts of the user notices (eg. "TCG
+	// Trusted Platform Endorsement" as recommended by the EK Credential Profile).
+	UserNotices []string `json:"user_notices,omitempty"`
+}
+
+func (p Policy) String() string {
+	if p.Name == "" {
+		return p.OID
+	}
+	return fmt.Sprintf("%s (%s)", p.Name, p.OID)
+}
+
+// policyName returns the name of the policy oid (see [Policy.Name]).
+func policyName(oid asn1.ObjectIdentifier) string {
+	if name, ok := policyNames[oid.String()]; ok {
+		return name
+	}
+	if len(oid) > len(oidTCG) && oid[:len(oidTCG)].Equal(oidTCG) {
+		return "TCG"
+	}
+	return ""
+}
+
+type policyInformation struct {
+	Policy     asn1.ObjectIdentifier
+	Qualifiers []policyQualifierInfo `asn1:"optional"`
+}
+
+type policyQualifierInfo struct {
+	ID        asn1.ObjectIdentifier
+	Qualifier asn1.RawValue
+}
+
+// userNotice is the UserNotice policy qualifier (RFC 5280, section 4.2.1.4).
+// As both of its fields are optional, they are told apart by their tag: the
+// noticeRef is a SEQUENCE whereas the explicitText is a string.
+type userNotice struct {
+	Fields []asn1.RawValue
+}
+
+// explicitText returns the explicit text of the notice, if any.
+func (n userNotice) explicitText() string {
+	for _, f := range n.Fields {
+		if f.Class == asn1.ClassUniversal && f.Tag != asn1.TagSequence {
+			return displayText(f)
+		}
+	}
+	return ""
+}
+
+// ParsePolicies returns the certificate policies of cert, in order, along
+// with their qualifiers. Qualifiers which cannot be decoded are skipped.
+func ParsePolicies(cert *x509.Certificate) ([]Policy, error) {
+	var policies []Policy
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidCertificatePolicies) {
+			continue
+		}
+		var infos []policyInformation
+		if rest, err := asn1.Unmarshal(ext.Value, &infos); err != nil {
+			return nil, fmt.Errorf("failed to parse certificate policies: %w", err)
+		} else if len(rest) > 0 {
+			return nil, errors.New("failed to parse certificate policies: trailing data")
+		}
+		for _, info := range infos {
+			policy := Policy{OID: info.Policy.String(), Name: policyName(info.Policy)}
+			for _, q := range info.Qualifiers {
+				switch {
+				case q.ID.Equal(oidQualifierCPS) && q.Qualifier.Tag == asn1.TagIA5String:
+					policy.CPS = append(policy.CPS, string(q.Qualifier.Bytes))
+				case q.ID.Equal(oidQualifierUserNotice):
+					var notice userNotice
+					if _, err := asn1.Unmarshal(q.Qualifier.FullBytes, &notice.Fields); err != nil {
+						continue
+					}
+					if text := notice.explicitText(); text != "" {
+						policy.UserNotices = append(policy.UserNotices, text)
+					}
+				}
+			}
+			policies = append(policies, policy)
+		}
+	}
+	return policies, nil
+}
+
+// displayText decodes a DisplayText (RFC 5280, section 4.2.1.4), which may be
+// an IA5String, a VisibleString, a BMPString or a UTF8String.
+func displayText(v asn1.RawValue) string {
+	switch v.Tag {
+	case asn1.TagIA5String, asn1.TagUTF8String, 26: // VisibleString
+		return string(v.Bytes)
+	case asn1.TagBMPString:
+		if len(v.Bytes)%2 != 0 {
+			return ""
+		}
+		units := make([]uint16, 0, len(v.Bytes)/2)
+		for i := 0; i < len(v.Bytes); i += 2 {
+			units = append(units, uint16(v.Bytes[i])<<8|uint16(v.Bytes[i+1]))
+		}
+		return string(utf16.Decode(units))
+	default:
+		return ""
+	}
+}
+
+// TPMSpecification is the TPM specification the TPM of an EK certificate
+// complies with (TCG EK Credential Profile).
+type TPMSpecification struct {
+	// Family is the specification family (eg. "2.0").
+	Family string `json:"family"`
+	// Level is the specification level (eg. 0).
+	Level int `json:"level"`
+	// Revision is the specification revision multiplied by 100 (eg. 138 for 1.38).
+	Revision int `json:"revision"`
+}
+
+func (s TPMSpecification) String() string {
+	return fmt.Sprintf("TPM %s, level %d, revision %d.%02d", s.Family, s.Level, s.Revision/100, s.Revision%100)
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// ParseTPMSpecification returns the TPM specification attribute of the
+// Subject Directory Attributes of cert, or nil if there is none.
+func ParseTPMSpecification(cert *x509.Certificate) (*TPMSpecification, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSubjectDirectoryAttributes) {
+			continue
+		}
+		var attrs []attribute
+		if _, err := asn1.Unmarshal(ext.Value, &attrs); err != nil {
+			return nil, fmt.Errorf("failed to parse subject directory attributes: %w", err)
+		}
+		for _, attr := range attrs {
+			if !attr.Type.Equal(oidTPMSpecification) || len(attr.Values) == 0 {
+				continue
+			}
+			var spec TPMSpecification
+			if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &spec); err != nil {
+				return nil, fmt.Errorf("failed to parse TPM specification: %w", err)
+			}
+			return &spec, nil
+		}
+	}
+	return nil, nil
+}
+
+// formatPolicies formats policies for [CertificateTextShort], along with their user notices.
+func formatPolicies(policies []Policy) []string {
+	var lines []string
+	for _, p := range policies {
+		line := p.String()
+		if len(p.UserNotices) > 0 {
+			line += fmt.Sprintf(" %q", strings.Join(p.UserNotices, "; "))
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
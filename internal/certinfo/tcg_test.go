@@ -0,0 +1,135 @@
+package certinfo
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestParsePolicies(t *testing.T) {
+	const notice = "TCG Trusted Platform Endorsement"
+	bmp := utf16.Encode([]rune(notice))
+	bmpBytes := make([]byte, 0, 2*len(bmp))
+	for _, u := range bmp {
+		bmpBytes = append(bmpBytes, byte(u>>8), byte(u))
+	}
+
+	tests := []struct {
+		name    string
+		infos   []policyInformation
+		want    []Policy
+		wantErr bool
+	}{
+		{name: "no policy"},
+		{
+			name: "well-known TCG policy",
+			infos: []policyInformation{{
+				Policy: asn1.ObjectIdentifier{2, 23, 133, 11, 1, 1},
+				Qualifiers: []policyQualifierInfo{
+					{ID: oidQualifierCPS, Qualifier: asn1.RawValue{Tag: asn1.TagIA5String, Bytes: []byte("https://example.com/cps")}},
+					{ID: oidQualifierUserNotice, Qualifier: marshalUserNotice(t, asn1.RawValue{Tag: asn1.TagUTF8String, Bytes: []byte(notice)})},
+				},
+			}},
+			want: []Policy{{OID: "2.23.133.11.1.1", Name: "tcg-cap-verifiedTPMResidency", CPS: []string{"https://example.com/cps"}, UserNotices: []string{notice}}},
+		},
+		{
+			name: "unknown TCG policy with BMPString notice",
+			infos: []policyInformation{{
+				Policy:     asn1.ObjectIdentifier{2, 23, 133, 99, 1},
+				Qualifiers: []policyQualifierInfo{{ID: oidQualifierUserNotice, Qualifier: marshalUserNotice(t, asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpBytes})}},
+			}},
+			want: []Policy{{OID: "2.23.133.99.1", Name: "TCG", UserNotices: []string{notice}}},
+		},
+		{
+			name:  "vendor policy",
+			infos: []policyInformation{{Policy: asn1.ObjectIdentifier{1, 2, 3, 4}}, {Policy: asn1.ObjectIdentifier{2, 5, 29, 32, 0}}},
+			want:  []Policy{{OID: "1.2.3.4"}, {OID: "2.5.29.32.0", Name: "anyPolicy"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := &x509.Certificate{}
+			if tt.infos != nil {
+				cert.Extensions = []pkix.Extension{{Id: oidCertificatePolicies, Value: mustMarshal(t, tt.infos)}}
+			}
+			got, err := ParsePolicies(cert)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePolicies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.EqualFunc(got, tt.want, func(a, b Policy) bool {
+				return a.OID == b.OID && a.Name == b.Name && slices.Equal(a.CPS, b.CPS) && slices.Equal(a.UserNotices, b.UserNotices)
+			}) {
+				t.Errorf("ParsePolicies() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		cert := &x509.Certificate{Extensions: []pkix.Extension{{Id: oidCertificatePolicies, Value: []byte{0x30, 0x05}}}}
+		if _, err := ParsePolicies(cert); err == nil {
+			t.Error("ParsePolicies() expected an error")
+		}
+	})
+}
+
+func TestParseTPMSpecification(t *testing.T) {
+	spec := TPMSpecification{Family: "2.0", Level: 0, Revision: 138}
+	sda := mustMarshal(t, []attribute{
+		{Type: asn1.ObjectIdentifier{2, 23, 133, 2, 17}, Values: []asn1.RawValue{{FullBytes: mustMarshal(t, "other")}}},
+		{Type: oidTPMSpecification, Values: []asn1.RawValue{{FullBytes: mustMarshal(t, spec)}}},
+	})
+
+	cert := &x509.Certificate{Extensions: []pkix.Extension{{Id: oidSubjectDirectoryAttributes, Value: sda}}}
+	got, err := ParseTPMSpecification(cert)
+	if err != nil {
+		t.Fatalf("ParseTPMSpecification() error = %v", err)
+	}
+	if got == nil || *got != spec {
+		t.Fatalf("ParseTPMSpecification() = %+v, want %+v", got, spec)
+	}
+	if want := "TPM 2.0, level 0, revision 1.38"; got.String() != want {
+		t.Errorf("String() = %q, want %q", got.String(), want)
+	}
+
+	if got, err := ParseTPMSpecification(&x509.Certificate{}); got != nil || err != nil {
+		t.Errorf("ParseTPMSpecification() = %+v, %v, want nil", got, err)
+	}
+
+	short, err := CertificateTextShort(&x509.Certificate{
+		Extensions: []pkix.Extension{
+			{Id: oidSubjectDirectoryAttributes, Value: sda},
+			{Id: oidCertificatePolicies, Value: mustMarshal(t, []policyInformation{{Policy: asn1.ObjectIdentifier{2, 23, 133, 11, 1, 2}}})},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Policies:    tcg-cap-verifiedTPMFixed (2.23.133.11.1.2)", "TPM spec:    TPM 2.0, level 0, revision 1.38"} {
+		if !strings.Contains(short, want) {
+			t.Errorf("CertificateTextShort() output missing %q, got:\n%s", want, short)
+		}
+	}
+}
+
+func marshalUserNotice(t *testing.T, text asn1.RawValue) asn1.RawValue {
+	t.Helper()
+	noticeRef := struct {
+		Organization  string `asn1:"utf8"`
+		NoticeNumbers []int
+	}{"TCG", []int{1}}
+	return asn1.RawValue{FullBytes: mustMarshal(t, []any{noticeRef, text})}
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	der, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
@@ -0,0 +1,128 @@
+// Package codes defines the stable codes of the warnings and errors reported
+// by an audit. Unlike log messages, codes never change once released, so that
+// dashboards may aggregate issues across a fleet.
+//
+// Warning codes start with W (the outcome is unchanged), error codes with E.
+package codes
+
+import (
+	"errors"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+)
+
+// Code identifies a kind of warning or error (eg. "W001_MISSING_CRL_DP").
+type Code string
+
+// Warnings of the certificate checks.
+const (
+	// W001MissingCRLDP means that a certificate has no supported CRL
+	// distribution point: its revocation status is not checked.
+	W001MissingCRLDP Code = "W001_MISSING_CRL_DP"
+	// W002UnsupportedScheme means that a CRL distribution point was skipped
+	// because of its scheme (eg. ldap:// without the ldap build tag).
+	W002UnsupportedScheme Code = "W002_UNSUPPORTED_SCHEME"
+	// W003UnreachableCRLDP means that a CRL could not be downloaded.
+	W003UnreachableCRLDP Code = "W003_UNREACHABLE_CRL_DP"
+	// W004RevocationCheckFailed means that the revocation check failed
+	// and was ignored (soft revocation check).
+	W004RevocationCheckFailed Code = "W004_REVOCATION_CHECK_FAILED"
+	// W005UnhandledCriticalExtension means that a certificate has critical
+	// extensions which are not processed.
+	W005UnhandledCriticalExtension Code = "W005_UNHANDLED_CRITICAL_EXTENSION"
+	// W006MissingEKU means that a certificate lacks the Extended Key Usage
+	// of its kind (EK or AK certificate).
+	W006MissingEKU Code = "W006_MISSING_EKU"
+	// W007WeakSignatureAlgorithm means that a certificate of the chain is
+	// signed with a weak algorithm (eg. SHA-1).
+	W007WeakSignatureAlgorithm Code = "W007_WEAK_SIGNATURE_ALGORITHM"
+	// W008MissingManufacturer means that the EK certificate has no TPM manufacturer attribute.
+	W008MissingManufacturer Code = "W008_MISSING_MANUFACTURER_ATTRIBUTE"
+	// W009ManufacturerMismatch means that the manufacturer of the EK
+	// certificate differs from the one reported by the TPM.
+	W009ManufacturerMismatch Code = "W009_MANUFACTURER_MISMATCH"
+	// W010SystemRoot means that the chain is anchored by a root of the
+	// system store, outside of the trusted bundle.
+	W010SystemRoot Code = "W010_SYSTEM_ROOT"
+	// W011InvalidKeyUsage means that the key usage of the EK certificate
+	// doesn't conform to the TCG EK Credential Profile.
+	W011InvalidKeyUsage Code = "W011_INVALID_KEY_USAGE"
+	// W012SCTNotVerified means that the EK certificate has no valid SCT
+	// from a known Certificate Transparency log.
+	W012SCTNotVerified Code = "W012_SCT_NOT_VERIFIED"
+	// W013CRLRollback means that a downloaded CRL is older than the cached
+	// one of the same issuer (lower CRL number), which was kept instead.
+	W013CRLRollback Code = "W013_CRL_ROLLBACK"
+)
+
+// Errors of the certificate checks (E01x and E02x), of the trusted bundle
+// (E03x), of the TPM (E04x), of the audit itself (E05x) and of the
+// revocation check (E06x).
+const (
+	E010Untrusted                    Code = "E010_UNTRUSTED"
+	E011Revoked                      Code = "E011_REVOKED"
+	E012EKIsCA                       Code = "E012_EK_IS_CA"
+	E013MissingRevocationDP          Code = "E013_MISSING_REVOCATION_DP"
+	E014DisallowedSignatureAlgorithm Code = "E014_DISALLOWED_SIGNATURE_ALGORITHM"
+	E015DisallowedKey                Code = "E015_DISALLOWED_KEY"
+	E016ManufacturerMismatch         Code = "E016_MANUFACTURER_MISMATCH"
+	E017UnhandledCriticalExtension   Code = "E017_UNHANDLED_CRITICAL_EXTENSION"
+	E018CRLOutOfScope                Code = "E018_CRL_OUT_OF_SCOPE"
+	E019PathLenExceeded              Code = "E019_PATH_LEN_EXCEEDED"
+	E020UntrustedAK                  Code = "E020_UNTRUSTED_AK"
+	E021AKIsCA                       Code = "E021_AK_IS_CA"
+	E022AKRootMismatch               Code = "E022_AK_ROOT_MISMATCH"
+	E023UntrustedPlatform            Code = "E023_UNTRUSTED_PLATFORM"
+	E024PlatformEKMismatch           Code = "E024_PLATFORM_EK_MISMATCH"
+	E025InvalidKeyUsage              Code = "E025_INVALID_KEY_USAGE"
+	E026UnsupportedCRLAlgorithm      Code = "E026_UNSUPPORTED_CRL_ALGORITHM"
+	E027EKSelfSigned                 Code = "E027_EK_SELF_SIGNED"
+	E028MissingAIA                   Code = "E028_MISSING_AIA"
+	E029MissingSCT                   Code = "E029_MISSING_SCT"
+
+	E030BundleTooOld Code = "E030_BUNDLE_TOO_OLD"
+
+	E040NoCertSelected     Code = "E040_NO_CERT_SELECTED"
+	E041Lockout            Code = "E041_LOCKOUT"
+	E042PCPUnsupported     Code = "E042_PCP_UNSUPPORTED"
+	E043DevicesUnsupported Code = "E043_DEVICES_UNSUPPORTED"
+	E044EndorsementAuth    Code = "E044_ENDORSEMENT_AUTH"
+
+	E050ManufacturerNotAllowed  Code = "E050_MANUFACTURER_NOT_ALLOWED"
+	E051UnsupportedManufacturer Code = "E051_UNSUPPORTED_MANUFACTURER"
+	E052DeadlineExceeded        Code = "E052_DEADLINE_EXCEEDED"
+
+	E060CRLRollback Code = "E060_CRL_ROLLBACK"
+)
+
+// New returns an error with the given message carrying code (see [Of]).
+// Like the errors of [errors.New], each call returns a distinct error.
+func New(code Code, msg string) error {
+	return &codedError{code: code, msg: msg}
+}
+
+type codedError struct {
+	code Code
+	msg  string
+}
+
+func (e *codedError) Error() string { return e.msg }
+
+func (e *codedError) Code() Code { return e.code }
+
+// Of returns the code carried by err or by an error of its chain,
+// or "" if none has one.
+func Of(err error) Code {
+	if err == nil {
+		return ""
+	}
+	// Returned by the verifier, outside of this module
+	if errors.Is(err, x509util.ErrCertificateRevoked) {
+		return E011Revoked
+	}
+	var coded interface{ Code() Code }
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+	return ""
+}
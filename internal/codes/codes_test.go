@@ -0,0 +1,50 @@
+package codes
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+)
+
+func TestOf(t *testing.T) {
+	t.Parallel()
+
+	errUntrusted := New(E010Untrusted, "EK certificate trust could not be established")
+
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{name: "nil"},
+		{name: "uncoded", err: errors.New("network is unreachable")},
+		{name: "coded", err: errUntrusted, want: E010Untrusted},
+		{name: "wrapped", err: fmt.Errorf("%w: x509: unknown authority", errUntrusted), want: E010Untrusted},
+		{name: "revoked", err: fmt.Errorf("failed: %w", x509util.ErrCertificateRevoked), want: E011Revoked},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := Of(tc.err); got != tc.want {
+				t.Errorf("Of() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	a, b := New(E010Untrusted, "untrusted"), New(E010Untrusted, "untrusted")
+	if errors.Is(a, b) {
+		t.Error("errors.Is() = true, want distinct errors")
+	}
+	if a.Error() != "untrusted" {
+		t.Errorf("Error() = %q, want %q", a.Error(), "untrusted")
+	}
+}
@@ -0,0 +1,215 @@
+package ekfile
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/go-utils/crypto/pemutil"
+	"github.com/loicsikidi/go-utils/system/fsutil"
+)
+
+// Extensions lists the file extensions considered as EK certificates
+// when listing a directory.
+var Extensions = []string{".pem", ".crt", ".cer", ".der"}
+
+// Stdin is the path meaning that the EK certificate is read from the standard input.
+const Stdin = "-"
+
+// Overridden in tests.
+var stdin io.Reader = os.Stdin
+
+// Read reads a PEM or DER encoded EK certificate from path, or from the
+// standard input if path is [Stdin].
+func Read(path string) (*x509.Certificate, error) {
+	data, name, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return cert, nil
+}
+
+// ReadBundle is like [Read] but the file may also hold the chain of the
+// EK certificate, which is returned along with it (see [ParseBundle]).
+func ReadBundle(path string) (endorsement.EK, error) {
+	data, name, err := readFile(path)
+	if err != nil {
+		return endorsement.EK{}, err
+	}
+	ek, err := ParseBundle(data)
+	if err != nil {
+		return endorsement.EK{}, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return ek, nil
+}
+
+// readFile returns the content of path (or of the standard input if path
+// is [Stdin]) along with its name for error messages.
+func readFile(path string) ([]byte, string, error) {
+	name := path
+	var (
+		data []byte
+		err  error
+	)
+	if path == Stdin {
+		name = "standard input"
+		data, err = io.ReadAll(io.LimitReader(stdin, fsutil.DefaultMaxFileSize+1))
+		if err == nil && int64(len(data)) > fsutil.DefaultMaxFileSize {
+			err = fmt.Errorf("too large: exceeds %d bytes", fsutil.DefaultMaxFileSize)
+		}
+	} else {
+		data, err = fsutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, name, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) == 0 {
+		return nil, name, fmt.Errorf("failed to read %s: no data", name)
+	}
+	return data, name, nil
+}
+
+// headSize is the number of bytes reported by [ParseError].
+const headSize = 16
+
+// ParseError reports an EK certificate which cannot be parsed, along with
+// a summary of its content to troubleshoot it.
+type ParseError struct {
+	// Size is the number of bytes of the certificate.
+	Size int
+	// Head holds the first bytes of the certificate.
+	Head []byte
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("malformed EK certificate (%d bytes, starting with % X): %v", e.Size, e.Head, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Parse parses a PEM or DER encoded EK certificate.
+//
+// DER content may be prefixed by the TCG NV header (as stored in the TPM's NV index),
+// followed by other certificates (eg. the chain) or wrapped in a PKCS#7 structure.
+// A [*ParseError] is returned when it is malformed.
+func Parse(data []byte) (*x509.Certificate, error) {
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		return pemutil.ParseCertificate(data)
+	}
+	cert, err := endorsement.ParseEKCertificate(data)
+	if err == nil {
+		return cert, nil
+	}
+	if cert, pkcs7Err := parsePKCS7(stripTCGHeader(data)); pkcs7Err == nil {
+		return cert, nil
+	}
+	return nil, &ParseError{Size: len(data), Head: data[:min(len(data), headSize)], Err: err}
+}
+
+// oidEKCertificate is the extended key usage of EK certificates
+// (TCG EK Credential Profile, section 3.2.16).
+var oidEKCertificate = asn1.ObjectIdentifier{2, 23, 133, 8, 1}
+
+// ParseBundle is like [Parse] but PEM data may hold several certificates,
+// as when the whole chain is exported along with the EK certificate
+// (EK, intermediates and root in any order). The EK certificate is the
+// end-entity certificate with the EK extended key usage (or else the
+// first end-entity certificate) and the other certificates are returned
+// as its chain, so that the bundle can be verified without downloading
+// the issuers. The chain is not trusted as such: it must still lead to
+// a trusted root.
+func ParseBundle(data []byte) (endorsement.EK, error) {
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		cert, err := Parse(data)
+		if err != nil {
+			return endorsement.EK{}, err
+		}
+		return endorsement.EK{Certificate: cert}, nil
+	}
+	var certs []*x509.Certificate
+	for rest := data; len(rest) > 0; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" || len(block.Headers) != 0 {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return endorsement.EK{}, fmt.Errorf("certificate #%d: %w", len(certs)+1, err)
+		}
+		certs = append(certs, cert)
+	}
+	switch len(certs) {
+	case 0:
+		return endorsement.EK{}, errors.New("no certificate found")
+	case 1:
+		return endorsement.EK{Certificate: certs[0]}, nil
+	}
+	i := slices.IndexFunc(certs, func(cert *x509.Certificate) bool {
+		return !cert.IsCA && slices.ContainsFunc(cert.UnknownExtKeyUsage, oidEKCertificate.Equal)
+	})
+	if i < 0 {
+		i = slices.IndexFunc(certs, func(cert *x509.Certificate) bool { return !cert.IsCA })
+	}
+	if i < 0 {
+		return endorsement.EK{}, fmt.Errorf("no end-entity certificate among the %d certificates", len(certs))
+	}
+	ek := certs[i]
+	return endorsement.EK{Certificate: ek, Chain: slices.Delete(certs, i, i+1)}, nil
+}
+
+// List returns the paths of the certificate files found in dir, sorted by name.
+//
+// Sub-directories are not traversed.
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if !slices.Contains(Extensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	return paths, nil
+}
+
+// tcgHeader is the prefix of the certificates stored in NV index along with
+// their length (TCG PC Client Platform TPM Profile, section 7.3.2).
+var tcgHeader = []byte{0x10, 0x01, 0x00}
+
+// stripTCGHeader returns data without the TCG NV header, if any.
+func stripTCGHeader(data []byte) []byte {
+	if len(data) <= 5 || !bytes.HasPrefix(data, tcgHeader) {
+		return data
+	}
+	size := int(binary.BigEndian.Uint16(data[3:5]))
+	if len(data) < 5+size {
+		return data
+	}
+	return data[5 : 5+size]
+}
@@ -0,0 +1,333 @@
+package ekfile
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/pkcs7"
+)
+
+func TestRead(t *testing.T) {
+	der := createCert(t)
+
+	header := []byte{0x10, 0x01, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(header[3:], uint16(len(der)))
+
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr bool
+	}{
+		{
+			name:    "PEM",
+			content: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+		{
+			name:    "DER",
+			content: der,
+		},
+		{
+			name:    "DER with TCG NV header",
+			content: append(header, der...),
+		},
+		{
+			name:    "PKCS#7",
+			content: createPKCS7(t, der),
+		},
+		{
+			name:    "garbage",
+			content: []byte("not a certificate"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(dir, tc.name)
+			if err := os.WriteFile(path, tc.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cert, err := Read(path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && !slices.Equal(cert.Raw, der) {
+				t.Errorf("Read() returned an unexpected certificate")
+			}
+		})
+	}
+}
+
+func TestReadStdin(t *testing.T) {
+	der := createCert(t)
+
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr bool
+	}{
+		{name: "PEM", content: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})},
+		{name: "DER", content: der},
+		{name: "empty", wantErr: true},
+		{name: "garbage", content: []byte("not a certificate"), wantErr: true},
+	}
+
+	t.Cleanup(func() { stdin = os.Stdin })
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stdin = bytes.NewReader(tc.content)
+			cert, err := Read(Stdin)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "standard input") {
+				t.Errorf("Read() error = %q, want it to name the standard input", err)
+			}
+			if err == nil && !slices.Equal(cert.Raw, der) {
+				t.Errorf("Read() returned an unexpected certificate")
+			}
+		})
+	}
+}
+
+func TestParseWrapped(t *testing.T) {
+	t.Parallel()
+
+	want, err := os.ReadFile(filepath.Join("testdata", "ek.der"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ek.p7b holds a CA certificate followed by ek.der, as produced by
+	// openssl crl2pkcs7 -nocrl -certfile ca.pem -certfile ek.pem -outform DER
+	p7, err := os.ReadFile(filepath.Join("testdata", "ek.p7b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []byte{0x10, 0x01, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(header[3:], uint16(len(p7)))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "PKCS#7", data: p7},
+		{name: "PKCS#7 with TCG NV header", data: slices.Concat(header, p7)},
+		{name: "concatenated with chain", data: slices.Concat(want, createCert(t))},
+	}
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cert, err := Parse(tc.data)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !slices.Equal(cert.Raw, want) {
+				t.Errorf("Parse() returned %s, want the EK fixture", cert.Subject)
+			}
+		})
+	}
+}
+
+func TestParseBundle(t *testing.T) {
+	t.Parallel()
+
+	root := createTemplateCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "root"}, IsCA: true, BasicConstraintsValid: true})
+	intermediate := createTemplateCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "intermediate"}, IsCA: true, BasicConstraintsValid: true})
+	ek := createTemplateCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "ek"}, UnknownExtKeyUsage: []asn1.ObjectIdentifier{oidEKCertificate}})
+	leaf := createTemplateCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "leaf"}})
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantEK    []byte
+		wantChain [][]byte
+		wantErr   bool
+	}{
+		{name: "single", data: pemCerts(ek), wantEK: ek},
+		{name: "DER", data: ek, wantEK: ek},
+		{name: "ek-first", data: pemCerts(ek, intermediate, root), wantEK: ek, wantChain: [][]byte{intermediate, root}},
+		{name: "root-first", data: pemCerts(root, intermediate, ek), wantEK: ek, wantChain: [][]byte{root, intermediate}},
+		// The EK extended key usage prevails over the order
+		{name: "ek-oid", data: pemCerts(leaf, ek, root), wantEK: ek, wantChain: [][]byte{leaf, root}},
+		{name: "no-ek-oid", data: pemCerts(root, leaf), wantEK: leaf, wantChain: [][]byte{root}},
+		{name: "no-end-entity", data: pemCerts(root, intermediate), wantErr: true},
+		{name: "malformed", data: slices.Concat(pemCerts(ek), pemCerts([]byte("garbage"))), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseBundle(tc.data)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseBundle() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !slices.Equal(got.Certificate.Raw, tc.wantEK) {
+				t.Errorf("ParseBundle() EK = %s, want %s", got.Certificate.Subject, mustParse(t, tc.wantEK).Subject)
+			}
+			if !slices.EqualFunc(got.Chain, tc.wantChain, func(cert *x509.Certificate, der []byte) bool { return slices.Equal(cert.Raw, der) }) {
+				t.Errorf("ParseBundle() chain has %d certificate(s), want %d in order", len(got.Chain), len(tc.wantChain))
+			}
+		})
+	}
+}
+
+func TestReadBundle(t *testing.T) {
+	t.Parallel()
+
+	root := createTemplateCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "root"}, IsCA: true, BasicConstraintsValid: true})
+	ek := createCert(t)
+	path := filepath.Join(t.TempDir(), "bundle.pem")
+	if err := os.WriteFile(path, pemCerts(root, ek), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadBundle(path)
+	if err != nil {
+		t.Fatalf("ReadBundle() error = %v", err)
+	}
+	if !slices.Equal(got.Certificate.Raw, ek) || len(got.Chain) != 1 || !slices.Equal(got.Chain[0].Raw, root) {
+		t.Errorf("ReadBundle() = %s with %d chain certificate(s), want the EK along with the root", got.Certificate.Subject, len(got.Chain))
+	}
+	if _, err := ReadBundle(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Error("ReadBundle() expected error for missing file")
+	}
+}
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+
+	der := createCert(t)
+	_, err := Parse(der[:100])
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Parse() error = %v, want a *ParseError", err)
+	}
+	if parseErr.Size != 100 {
+		t.Errorf("Size = %d, want 100", parseErr.Size)
+	}
+	if !slices.Equal(parseErr.Head, der[:headSize]) {
+		t.Errorf("Head = % X, want % X", parseErr.Head, der[:headSize])
+	}
+	if want := fmt.Sprintf("(100 bytes, starting with % X)", der[:headSize]); !strings.Contains(err.Error(), want) {
+		t.Errorf("Parse() error = %q, want it to contain %q", err, want)
+	}
+}
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.der", "a.PEM", "c.crt", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.pem"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := List(dir)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "a.PEM"),
+		filepath.Join(dir, "b.der"),
+		filepath.Join(dir, "c.crt"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+
+	if _, err := List(filepath.Join(dir, "missing")); err == nil {
+		t.Error("List() expected error for missing directory")
+	}
+}
+
+func createCert(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ek"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// createTemplateCert returns a self-signed DER encoded certificate made of template.
+func createTemplateCert(t *testing.T, template *x509.Certificate) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(1)
+	template.NotBefore = time.Now()
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// pemCerts returns the PEM encoding of the DER encoded certificates.
+func pemCerts(certs ...[]byte) []byte {
+	var data []byte
+	for _, der := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	return data
+}
+
+func mustParse(t *testing.T, der []byte) *x509.Certificate {
+	t.Helper()
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// createPKCS7 wraps the DER encoded certificates in a "certs-only" PKCS#7 SignedData structure.
+func createPKCS7(t *testing.T, certs ...[]byte) []byte {
+	t.Helper()
+	p7, err := pkcs7.MarshalCertificates(certs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p7
+}
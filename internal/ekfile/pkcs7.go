@@ -0,0 +1,24 @@
+package ekfile
+
+import (
+	"crypto/x509"
+	"errors"
+
+	"github.com/loicsikidi/tpm-trust/internal/pkcs7"
+)
+
+// parsePKCS7 extracts the EK certificate of a PKCS#7 SignedData structure
+// (eg. "certs-only" bundles produced by some provisioning tools).
+// The first end-entity certificate is returned.
+func parsePKCS7(data []byte) (*x509.Certificate, error) {
+	certs, err := pkcs7.ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range certs {
+		if !cert.IsCA {
+			return cert, nil
+		}
+	}
+	return nil, errors.New("no end-entity certificate in PKCS#7 structure")
+}
@@ -3,3 +3,43 @@ package internal
 import "errors"
 
 var ErrSilence = errors.New("silence this error in logs")
+
+// Silence wraps an error which has already been logged: it matches
+// [ErrSilence] while keeping the original error in the chain.
+func Silence(err error) error {
+	return &silencedError{err: err}
+}
+
+type silencedError struct {
+	err error
+}
+
+func (e *silencedError) Error() string { return e.err.Error() }
+
+func (e *silencedError) Unwrap() error { return e.err }
+
+func (e *silencedError) Is(target error) bool { return target == ErrSilence }
+
+// WithExitCode wraps err so that the process exits with code (see [ExitCode])
+// while keeping the original error in the chain.
+func WithExitCode(err error, code int) error {
+	return &exitCodeError{err: err, code: code}
+}
+
+// ExitCode returns the exit code carried by err (see [WithExitCode]), 1 otherwise.
+func ExitCode(err error) int {
+	var e *exitCodeError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return 1
+}
+
+type exitCodeError struct {
+	err  error
+	code int
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+
+func (e *exitCodeError) Unwrap() error { return e.err }
@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSilence(t *testing.T) {
+	cause := errors.New("boom")
+	err := Silence(cause)
+
+	if !errors.Is(err, ErrSilence) {
+		t.Error("expected error to match ErrSilence")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected error to match its cause")
+	}
+	if err.Error() != cause.Error() {
+		t.Errorf("Error() = %q, want %q", err.Error(), cause.Error())
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	cause := errors.New("boom")
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "default", err: cause, want: 1},
+		{name: "with exit code", err: WithExitCode(cause, 3), want: 3},
+		{name: "silenced", err: Silence(WithExitCode(cause, 3)), want: 3},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := ExitCode(tc.err); got != tc.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tc.want)
+			}
+			if !errors.Is(tc.err, cause) {
+				t.Error("expected error to match its cause")
+			}
+		})
+	}
+}
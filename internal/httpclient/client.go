@@ -0,0 +1,202 @@
+package httpclient
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultUserAgent is the User-Agent sent when none is configured.
+const DefaultUserAgent = "tpm-trust"
+
+// DefaultMaxResponseSize is the maximum size of a (decompressed) response body
+// when none is configured. It is large enough for the biggest CRLs seen in the wild.
+const DefaultMaxResponseSize = 10 << 20 // 10 MiB
+
+// ErrResponseTooLarge is returned when a response body exceeds the maximum size.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// HTTPClient is an interface for making HTTP requests, allowing test injection.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Config configures a [Client].
+type Config struct {
+	// Client is the underlying client used to send requests.
+	//
+	// Optional. If nil, a client using a transport created with
+	// [NewTransport] from Transport is used.
+	Client HTTPClient
+	// Transport tunes the connection reuse of the default client.
+	// It is ignored if Client is set.
+	Transport TransportConfig
+	// UserAgent is the value of the User-Agent header set on every request.
+	//
+	// Optional. If empty, [DefaultUserAgent] is used.
+	UserAgent string
+	// MaxResponseSize is the maximum size in bytes of a response body (after
+	// decompression). Reading beyond it fails with [ErrResponseTooLarge].
+	//
+	// Optional. If zero, [DefaultMaxResponseSize] is used.
+	MaxResponseSize int64
+	// Recorder, if set, records every request sent by the client along
+	// with the hash of the response body.
+	Recorder *Recorder
+}
+
+func (c *Config) CheckAndSetDefaults() error {
+	if c.Client == nil {
+		transport, err := NewTransport(c.Transport)
+		if err != nil {
+			return fmt.Errorf("invalid transport: %w", err)
+		}
+		c.Client = &http.Client{Transport: transport}
+	}
+	if c.UserAgent == "" {
+		c.UserAgent = DefaultUserAgent
+	}
+	if c.MaxResponseSize == 0 {
+		c.MaxResponseSize = DefaultMaxResponseSize
+	}
+	if c.MaxResponseSize < 0 {
+		return fmt.Errorf("max response size must be positive")
+	}
+	return nil
+}
+
+// Client decorates outgoing requests (eg. User-Agent header) before
+// delegating them to the underlying [HTTPClient].
+type Client struct {
+	client          HTTPClient
+	userAgent       string
+	maxResponseSize int64
+	recorder        *Recorder
+}
+
+// Ensure *Client implements HTTPClient interface.
+var _ HTTPClient = (*Client)(nil)
+
+// New creates a new [Client] with the given configuration.
+func New(cfg Config) (*Client, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, err
+	}
+	return &Client{
+		client:          cfg.Client,
+		userAgent:       cfg.UserAgent,
+		maxResponseSize: cfg.MaxResponseSize,
+		recorder:        cfg.Recorder,
+	}, nil
+}
+
+// Do sends the HTTP request after setting the User-Agent header
+// (unless the caller already set one).
+//
+// Unless the caller set its own Accept-Encoding header, gzip encoded
+// responses are requested and transparently decompressed: some CRL
+// and issuer endpoints serve compressed bodies.
+//
+// Response bodies are capped to the maximum response size.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.do(req)
+	if c.recorder != nil {
+		return c.recorder.record(req.URL.String(), resp, err)
+	}
+	return resp, err
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	decompress := req.Header.Get("Accept-Encoding") == ""
+	if decompress {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if decompress && strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		if resp, err = gunzip(resp); err != nil {
+			return nil, err
+		}
+	}
+	return c.limit(resp)
+}
+
+// limit caps the body of resp to the maximum response size.
+func (c *Client) limit(resp *http.Response) (*http.Response, error) {
+	if resp.ContentLength > c.maxResponseSize {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrResponseTooLarge, resp.ContentLength, c.maxResponseSize)
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: c.maxResponseSize, max: c.maxResponseSize}
+	return resp, nil
+}
+
+// limitedBody fails with [ErrResponseTooLarge] instead of silently
+// truncating the body when it exceeds the maximum size.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	max       int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Only fail if there is something left to read
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w (max %d bytes)", ErrResponseTooLarge, b.max)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// gunzip replaces the body of a gzip encoded response by its decompressed content.
+func gunzip(resp *http.Response) (*http.Response, error) {
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("failed to decompress gzip response: %w", err)
+	}
+	resp.Body = &gzipBody{Reader: zr, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// gzipBody closes both the gzip reader and the underlying response body.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	return errors.Join(b.Reader.Close(), b.body.Close())
+}
+
+// UserAgent returns the User-Agent value for the given version
+// (eg. "tpm-trust/v1.2.3").
+func UserAgent(version string) string {
+	if version == "" {
+		return DefaultUserAgent
+	}
+	return DefaultUserAgent + "/" + version
+}
@@ -0,0 +1,289 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientUserAgent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		header    string
+		wantAgent string
+	}{
+		{
+			name:      "default user agent",
+			wantAgent: DefaultUserAgent,
+		},
+		{
+			name:      "custom user agent",
+			cfg:       Config{UserAgent: UserAgent("v1.2.3")},
+			wantAgent: "tpm-trust/v1.2.3",
+		},
+		{
+			name:      "caller header is preserved",
+			cfg:       Config{UserAgent: "ignored"},
+			header:    "my-agent",
+			wantAgent: "my-agent",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("User-Agent")
+			}))
+			t.Cleanup(srv.Close)
+
+			client, err := New(tc.cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatalf("failed to build request: %v", err)
+			}
+			if tc.header != "" {
+				req.Header.Set("User-Agent", tc.header)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if got != tc.wantAgent {
+				t.Errorf("User-Agent = %q, want %q", got, tc.wantAgent)
+			}
+		})
+	}
+}
+
+func TestClientGzip(t *testing.T) {
+	t.Parallel()
+
+	crl := createCRL(t)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		gzipped        bool
+		wantBody       []byte
+	}{
+		{
+			name:     "gzip encoded CRL is decompressed",
+			gzipped:  true,
+			wantBody: crl,
+		},
+		{
+			name:     "identity encoded CRL is untouched",
+			wantBody: crl,
+		},
+		{
+			name:           "caller handling its own encoding",
+			acceptEncoding: "gzip",
+			gzipped:        true,
+			wantBody:       gzipData(t, crl),
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotAccept string
+			mock := &mockClient{doFunc: func(req *http.Request) (*http.Response, error) {
+				gotAccept = req.Header.Get("Accept-Encoding")
+				header := http.Header{}
+				body := crl
+				if tc.gzipped {
+					header.Set("Content-Encoding", "gzip")
+					body = gzipData(t, crl)
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     header,
+					Body:       io.NopCloser(bytes.NewReader(body)),
+				}, nil
+			}}
+
+			client, err := New(Config{Client: mock})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://example.com/ca.crl", nil)
+			if err != nil {
+				t.Fatalf("failed to build request: %v", err)
+			}
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			defer resp.Body.Close()
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+
+			if gotAccept != "gzip" {
+				t.Errorf("Accept-Encoding = %q, want %q", gotAccept, "gzip")
+			}
+			if !bytes.Equal(got, tc.wantBody) {
+				t.Fatalf("unexpected body")
+			}
+			if tc.acceptEncoding == "" {
+				if _, err := x509.ParseRevocationList(got); err != nil {
+					t.Errorf("failed to parse CRL: %v", err)
+				}
+			}
+		})
+	}
+}
+
+type mockClient struct {
+	doFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockClient) Do(req *http.Request) (*http.Response, error) {
+	return m.doFunc(req)
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func createCRL(t testing.TB) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "CA"},
+		SubjectKeyId: []byte{1, 2, 3, 4},
+		KeyUsage:     x509.KeyUsageCRLSign,
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, issuer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crl
+}
+
+func TestClientMaxResponseSize(t *testing.T) {
+	t.Parallel()
+
+	const maxSize = 16
+
+	tests := []struct {
+		name          string
+		body          []byte
+		contentLength int64
+		gzipped       bool
+		wantErr       error
+	}{
+		{
+			name:          "body within limit",
+			body:          bytes.Repeat([]byte("a"), maxSize),
+			contentLength: -1,
+		},
+		{
+			name:          "body over limit without content length",
+			body:          bytes.Repeat([]byte("a"), maxSize+1),
+			contentLength: -1,
+			wantErr:       ErrResponseTooLarge,
+		},
+		{
+			name:          "content length over limit",
+			body:          bytes.Repeat([]byte("a"), maxSize+1),
+			contentLength: maxSize + 1,
+			wantErr:       ErrResponseTooLarge,
+		},
+		{
+			name:          "decompressed body over limit",
+			body:          bytes.Repeat([]byte("a"), 10*maxSize),
+			contentLength: -1,
+			gzipped:       true,
+			wantErr:       ErrResponseTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockClient{doFunc: func(req *http.Request) (*http.Response, error) {
+				header := http.Header{}
+				body := tc.body
+				if tc.gzipped {
+					header.Set("Content-Encoding", "gzip")
+					body = gzipData(t, tc.body)
+				}
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        header,
+					ContentLength: tc.contentLength,
+					Body:          io.NopCloser(bytes.NewReader(body)),
+				}, nil
+			}}
+			client, err := New(Config{Client: mock, MaxResponseSize: maxSize})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://crl.example.com/ca.crl", nil)
+			if err != nil {
+				t.Fatalf("failed to build request: %v", err)
+			}
+
+			resp, err := client.Do(req)
+			if err == nil {
+				defer resp.Body.Close() //nolint:errcheck
+				_, err = io.ReadAll(resp.Body)
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
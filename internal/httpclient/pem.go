@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// MaxPEMBodySize is the maximum size of a PEM encoded body decoded by [PEMDecoder].
+const MaxPEMBodySize = 5 << 20 // 5 MiB
+
+// pemBlockTypes lists the PEM block types decoded by [PEMDecoder]
+// (PKCS#7 structures are then decoded by [PKCS7Decoder]).
+var pemBlockTypes = []string{"X509 CRL", "CRL", "CERTIFICATE", "PKCS7"}
+
+var pemPrefix = []byte("-----BEGIN ")
+
+// PEMDecoder decodes PEM encoded CRL and certificate responses to DER
+// before handing them over, as consumers only parse DER (eg. x509util downloader).
+//
+// Bodies which are not PEM encoded are returned untouched. For PEM bodies
+// holding several blocks (eg. a chain), only the first supported block is kept.
+type PEMDecoder struct {
+	Client HTTPClient
+}
+
+// Ensure *PEMDecoder implements HTTPClient interface.
+var _ HTTPClient = (*PEMDecoder)(nil)
+
+// Do sends the HTTP request and decodes the response body if it is PEM encoded.
+func (d *PEMDecoder) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(resp.Body)
+	// Skip leading whitespace without consuming the body.
+	peek, _ := br.Peek(len(pemPrefix) + 64)
+	if !bytes.HasPrefix(bytes.TrimLeft(peek, " \t\r\n"), pemPrefix) {
+		resp.Body = readCloser{Reader: br, Closer: resp.Body}
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(br, MaxPEMBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PEM response: %w", err)
+	}
+	if len(data) > MaxPEMBodySize {
+		return nil, fmt.Errorf("PEM response exceeds %d bytes", MaxPEMBodySize)
+	}
+	der, err := decodePEM(bytes.TrimLeft(data, " \t\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(der))
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = int64(len(der))
+	return resp, nil
+}
+
+// decodePEM returns the content of the first supported PEM block.
+func decodePEM(data []byte) ([]byte, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no CRL, certificate or PKCS#7 structure found in PEM response")
+		}
+		if slices.Contains(pemBlockTypes, block.Type) {
+			return block.Bytes, nil
+		}
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,90 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestPEMDecoder(t *testing.T) {
+	t.Parallel()
+
+	crl := createCRL(t)
+	crlPEM := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("issuer")})
+
+	tests := []struct {
+		name     string
+		body     []byte
+		wantBody []byte
+		wantErr  bool
+	}{
+		{
+			name:     "DER CRL is untouched",
+			body:     crl,
+			wantBody: crl,
+		},
+		{
+			name:     "PEM CRL",
+			body:     crlPEM,
+			wantBody: crl,
+		},
+		{
+			name:     "PEM CRL with leading whitespace",
+			body:     append([]byte("\r\n  "), crlPEM...),
+			wantBody: crl,
+		},
+		{
+			name:     "PEM chain keeps the first certificate",
+			body:     append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("root")})...),
+			wantBody: []byte("issuer"),
+		},
+		{
+			name:     "unsupported block is skipped",
+			body:     append(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("key")}), crlPEM...),
+			wantBody: crl,
+		},
+		{
+			name:    "no supported block",
+			body:    pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("key")}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoder := &PEMDecoder{Client: &mockClient{doFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{},
+					Body:       io.NopCloser(bytes.NewReader(tc.body)),
+				}, nil
+			}}}
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://example.com/ca.crl", nil)
+			if err != nil {
+				t.Fatalf("failed to build request: %v", err)
+			}
+
+			resp, err := decoder.Do(req)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Do() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+			if !bytes.Equal(got, tc.wantBody) {
+				t.Errorf("body = %q, want %q", got, tc.wantBody)
+			}
+		})
+	}
+}
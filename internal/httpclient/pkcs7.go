@@ -0,0 +1,82 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/loicsikidi/tpm-trust/internal/pkcs7"
+)
+
+// MaxPKCS7BodySize is the maximum size of a PKCS#7 body decoded by [PKCS7Decoder].
+const MaxPKCS7BodySize = 5 << 20 // 5 MiB
+
+// pkcs7Prefix is the DER encoded content type (signedData) which follows the
+// header of the outer SEQUENCE of a PKCS#7 structure.
+var pkcs7Prefix = []byte{0x06, 0x09, 0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x07, 0x02}
+
+// PKCS7Decoder extracts the issuer certificate of PKCS#7 responses (eg. .p7c
+// files served by some AIA endpoints, RFC 5280 section 4.2.2.1) before
+// handing them over, as consumers only parse a single DER certificate.
+//
+// Bodies which are not PKCS#7 structures are returned untouched. As only one
+// certificate can be returned, the one which did not issue any other
+// certificate of the structure is kept: the CA closest to the certificate
+// whose AIA pointed to the structure.
+type PKCS7Decoder struct {
+	Client HTTPClient
+}
+
+// Ensure *PKCS7Decoder implements HTTPClient interface.
+var _ HTTPClient = (*PKCS7Decoder)(nil)
+
+// Do sends the HTTP request and decodes the response body if it is a PKCS#7 structure.
+func (d *PKCS7Decoder) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(resp.Body)
+	// SEQUENCE tag and length (at most 5 bytes) then the content type
+	peek, _ := br.Peek(1 + 5 + len(pkcs7Prefix))
+	if len(peek) < 2 || peek[0] != 0x30 || !bytes.Contains(peek[2:], pkcs7Prefix) {
+		resp.Body = readCloser{Reader: br, Closer: resp.Body}
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(br, MaxPKCS7BodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PKCS#7 response: %w", err)
+	}
+	if len(data) > MaxPKCS7BodySize {
+		return nil, fmt.Errorf("PKCS#7 response exceeds %d bytes", MaxPKCS7BodySize)
+	}
+	certs, err := pkcs7.ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	der := lowestCertificate(certs).Raw
+	resp.Body = io.NopCloser(bytes.NewReader(der))
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = int64(len(der))
+	return resp, nil
+}
+
+// lowestCertificate returns the first of certs which did not issue another
+// one (eg. the intermediate of a bundle holding its root as well).
+func lowestCertificate(certs []*x509.Certificate) *x509.Certificate {
+	for _, cert := range certs {
+		if !slices.ContainsFunc(certs, func(other *x509.Certificate) bool {
+			return other != cert && bytes.Equal(other.RawIssuer, cert.RawSubject)
+		}) {
+			return cert
+		}
+	}
+	return certs[0]
+}
@@ -0,0 +1,121 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/pkcs7"
+)
+
+func TestPKCS7Decoder(t *testing.T) {
+	t.Parallel()
+
+	root, intermediate := createIssuerChain(t)
+	bundle, err := pkcs7.MarshalCertificates(root, intermediate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl := createCRL(t)
+
+	tests := []struct {
+		name     string
+		body     []byte
+		pem      bool
+		wantBody []byte
+		wantErr  bool
+	}{
+		{name: "DER certificate is untouched", body: intermediate, wantBody: intermediate},
+		{name: "DER CRL is untouched", body: crl, wantBody: crl},
+		{name: "short body is untouched", body: []byte{0x30}, wantBody: []byte{0x30}},
+		{name: "PKCS#7 keeps the lowest certificate", body: bundle, wantBody: intermediate},
+		{name: "PEM PKCS#7", body: pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: bundle}), pem: true, wantBody: intermediate},
+		{name: "truncated PKCS#7", body: bundle[:len(bundle)/2], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var client HTTPClient = &mockClient{doFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Length": []string{"1"}},
+					Body:       io.NopCloser(bytes.NewReader(tc.body)),
+				}, nil
+			}}
+			if tc.pem {
+				client = &PEMDecoder{Client: client}
+			}
+			decoder := &PKCS7Decoder{Client: client}
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://example.com/ca.p7c", nil)
+			if err != nil {
+				t.Fatalf("failed to build request: %v", err)
+			}
+
+			resp, err := decoder.Do(req)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Do() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+			if !bytes.Equal(got, tc.wantBody) {
+				t.Errorf("body = %x, want %x", got, tc.wantBody)
+			}
+		})
+	}
+}
+
+// createIssuerChain returns a root CA and an intermediate CA issued by it.
+func createIssuerChain(t *testing.T) (root, intermediate []byte) {
+	t.Helper()
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	root, err = x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	intermediate, err = x509.CreateCertificate(rand.Reader, tmpl, rootTmpl, &key.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, intermediate
+}
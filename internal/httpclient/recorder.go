@@ -0,0 +1,117 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/pkcs7"
+)
+
+// Kinds of downloads, guessed from the content of the response.
+const (
+	DownloadKindCRL = "crl"
+	// DownloadKindIssuer is a CA certificate (eg. downloaded via AIA).
+	DownloadKindIssuer = "issuer-certificate"
+	// DownloadKindEK is an end-entity certificate: the only ones downloaded
+	// are EK certificates (eg. from a manufacturer EK certificate URL).
+	DownloadKindEK    = "ek-certificate"
+	DownloadKindOther = "other"
+)
+
+// Download describes a response received by a [Client] (see [Config.Recorder]).
+type Download struct {
+	URL  string `json:"url"`
+	Kind string `json:"kind,omitempty"`
+	// Status is the HTTP status code, unset if no response was received.
+	Status int `json:"status,omitempty"`
+	// Length is the size in bytes of the (decompressed) body.
+	Length int `json:"length"`
+	// SHA256 is the SHA-256 hash of the (decompressed) body (hex).
+	SHA256 string `json:"sha256,omitempty"`
+	// Error is the reason the download failed, if any.
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// Recorder records the downloads of a [Client], eg. to report exactly
+// what an audit fetched. It is safe for concurrent use.
+type Recorder struct {
+	mu        sync.Mutex
+	downloads []Download
+}
+
+// Downloads returns the recorded downloads, in order.
+func (r *Recorder) Downloads() []Download {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.downloads)
+}
+
+// record records the outcome of the request to url. The body of resp is read
+// (to be hashed) and replaced by an in-memory copy.
+func (r *Recorder) record(url string, resp *http.Response, err error) (*http.Response, error) {
+	d := Download{URL: url, Time: time.Now().UTC()}
+	if err != nil {
+		d.Error = err.Error()
+		r.add(d)
+		return nil, err
+	}
+	d.Status = resp.StatusCode
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	sum := sha256.Sum256(data)
+	d.Length = len(data)
+	d.SHA256 = hex.EncodeToString(sum[:])
+	d.Kind = downloadKind(data)
+	if err != nil {
+		d.Error = err.Error()
+		r.add(d)
+		return nil, err
+	}
+	r.add(d)
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+func (r *Recorder) add(d Download) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downloads = append(r.downloads, d)
+}
+
+// downloadKind guesses the kind of a (DER or PEM encoded) body.
+func downloadKind(data []byte) string {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); bytes.HasPrefix(trimmed, pemPrefix) {
+		der, err := decodePEM(trimmed)
+		if err != nil {
+			return DownloadKindOther
+		}
+		data = der
+	}
+	if _, err := x509.ParseRevocationList(data); err == nil {
+		return DownloadKindCRL
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		certs, p7Err := pkcs7.ParseCertificates(data)
+		if p7Err != nil {
+			return DownloadKindOther
+		}
+		cert, err = lowestCertificate(certs), nil
+	}
+	switch {
+	case err != nil:
+		return DownloadKindOther
+	case cert.IsCA:
+		return DownloadKindIssuer
+	default:
+		return DownloadKindEK
+	}
+}
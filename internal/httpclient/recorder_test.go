@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClientRecorder(t *testing.T) {
+	t.Parallel()
+
+	crl := createCRL(t)
+	ca := createCertificate(t, true)
+	ek := createCertificate(t, false)
+	bodies := map[string][]byte{
+		"http://example.com/ca.crl":     gzipData(t, crl),
+		"http://example.com/ca.cer":     ca,
+		"http://example.com/ek.pem":     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ek}),
+		"http://example.com/bundle.txt": []byte("bundle"),
+	}
+	mock := &mockClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		url := req.URL.String()
+		if url == "http://example.com/unreachable" {
+			return nil, errors.New("network is unreachable")
+		}
+		body, ok := bodies[url]
+		if !ok {
+			return &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader([]byte("not found")))}, nil
+		}
+		header := http.Header{}
+		if url == "http://example.com/ca.crl" {
+			header.Set("Content-Encoding", "gzip")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body))}, nil
+	}}
+
+	recorder := &Recorder{}
+	client, err := New(Config{Client: mock, Recorder: recorder})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url  string
+		want Download
+	}{
+		{url: "http://example.com/ca.crl", want: Download{Kind: DownloadKindCRL, Status: http.StatusOK, Length: len(crl), SHA256: sha256Hex(crl)}},
+		{url: "http://example.com/ca.cer", want: Download{Kind: DownloadKindIssuer, Status: http.StatusOK, Length: len(ca), SHA256: sha256Hex(ca)}},
+		{url: "http://example.com/ek.pem", want: Download{Kind: DownloadKindEK, Status: http.StatusOK, Length: len(bodies["http://example.com/ek.pem"]), SHA256: sha256Hex(bodies["http://example.com/ek.pem"])}},
+		{url: "http://example.com/bundle.txt", want: Download{Kind: DownloadKindOther, Status: http.StatusOK, Length: 6, SHA256: sha256Hex([]byte("bundle"))}},
+		{url: "http://example.com/missing", want: Download{Kind: DownloadKindOther, Status: http.StatusNotFound, Length: 9, SHA256: sha256Hex([]byte("not found"))}},
+		{url: "http://example.com/unreachable", want: Download{Error: "network is unreachable"}},
+	}
+	for _, tc := range tests {
+		req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			continue
+		}
+		// The body is still available to the caller
+		data, err := io.ReadAll(resp.Body)
+		if err != nil || len(data) != tc.want.Length {
+			t.Errorf("%s: body = %d bytes (error %v), want %d", tc.url, len(data), err, tc.want.Length)
+		}
+	}
+
+	downloads := recorder.Downloads()
+	if len(downloads) != len(tests) {
+		t.Fatalf("Downloads() = %d entries, want %d", len(downloads), len(tests))
+	}
+	for i, tc := range tests {
+		got := downloads[i]
+		if got.Time.IsZero() {
+			t.Errorf("%s: time is not set", tc.url)
+		}
+		got.Time = time.Time{}
+		tc.want.URL = tc.url
+		if got != tc.want {
+			t.Errorf("download %d = %+v, want %+v", i, got, tc.want)
+		}
+	}
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func createCertificate(t *testing.T, isCA bool) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
@@ -0,0 +1,166 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// IPFamily is the address family used to connect to servers.
+type IPFamily string
+
+const (
+	// IPFamilyAuto connects over IPv4 or IPv6, as resolved (Happy Eyeballs).
+	IPFamilyAuto IPFamily = "auto"
+	// IPFamilyV4 only connects over IPv4.
+	IPFamilyV4 IPFamily = "v4"
+	// IPFamilyV6 only connects over IPv6 (eg. IPv6-only networks).
+	IPFamilyV6 IPFamily = "v6"
+)
+
+// IPFamilies lists the supported address families.
+var IPFamilies = []IPFamily{IPFamilyAuto, IPFamilyV4, IPFamilyV6}
+
+// network returns the network dialed for the family, or "" to keep the requested one.
+func (f IPFamily) network() string {
+	switch f {
+	case IPFamilyV4:
+		return "tcp4"
+	case IPFamilyV6:
+		return "tcp6"
+	default:
+		return ""
+	}
+}
+
+const (
+	// DefaultMaxIdleConnsPerHost is the default number of idle connections kept
+	// per host. Issuers and CRLs of a manufacturer are usually served by the same
+	// host, which the default of [http.DefaultTransport] (2) does not account for.
+	DefaultMaxIdleConnsPerHost = 16
+	// DefaultIdleConnTimeout is the default duration an idle connection is kept.
+	DefaultIdleConnTimeout = 90 * time.Second
+	// DefaultKeepAlive is the default interval of TCP keep-alive probes.
+	DefaultKeepAlive = 30 * time.Second
+
+	// dialTimeout bounds the establishment of a TCP connection.
+	dialTimeout = 30 * time.Second
+)
+
+// TransportConfig tunes the connection reuse of the transport
+// returned by [NewTransport].
+type TransportConfig struct {
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept per host.
+	//
+	// Optional. If zero, [DefaultMaxIdleConnsPerHost] is used.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is the duration an idle connection is kept before being closed.
+	//
+	// Optional. If zero, [DefaultIdleConnTimeout] is used.
+	IdleConnTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes. If negative,
+	// keep-alive probes are disabled (connections are still reused).
+	//
+	// Optional. If zero, [DefaultKeepAlive] is used.
+	KeepAlive time.Duration
+	// DisableHTTP2 prevents negotiating HTTP/2 with TLS servers, which
+	// otherwise multiplex concurrent requests over a single connection.
+	DisableHTTP2 bool
+	// IPFamily restricts the connections to an address family, eg. when
+	// the other one is unreachable (broken A or AAAA record path).
+	//
+	// Optional. If empty, [IPFamilyAuto] is used.
+	IPFamily IPFamily
+	// DirectHosts lists the hosts reached directly, bypassing the proxy of
+	// the environment (HTTP_PROXY, HTTPS_PROXY), in addition to NO_PROXY
+	// (eg. manufacturer CDNs). As with NO_PROXY, a domain also matches its
+	// subdomains, and a leading "." or "*." is ignored.
+	DirectHosts []string
+}
+
+func (c *TransportConfig) CheckAndSetDefaults() error {
+	if c.MaxIdleConnsPerHost == 0 {
+		c.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if c.KeepAlive == 0 {
+		c.KeepAlive = DefaultKeepAlive
+	}
+	if c.IPFamily == "" {
+		c.IPFamily = IPFamilyAuto
+	}
+	if c.MaxIdleConnsPerHost < 0 || c.IdleConnTimeout < 0 {
+		return fmt.Errorf("max idle connections per host and idle connection timeout must be positive")
+	}
+	if !slices.Contains(IPFamilies, c.IPFamily) {
+		return fmt.Errorf("invalid IP family: %s (must be one of: auto, v4, v6)", c.IPFamily)
+	}
+	hosts := make([]string, 0, len(c.DirectHosts))
+	for _, host := range c.DirectHosts {
+		normalized := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(host)), "*"), ".")
+		if normalized == "" || strings.ContainsAny(normalized, "/*") {
+			return fmt.Errorf("invalid direct host: %q (must be a host name or domain, eg. example.com)", host)
+		}
+		hosts = append(hosts, normalized)
+	}
+	c.DirectHosts = hosts
+	return nil
+}
+
+// directProxy returns a proxy function which connects directly to hosts,
+// and delegates to proxy for every other host.
+func directProxy(hosts []string, proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	if len(hosts) == 0 {
+		return proxy
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		host := strings.ToLower(strings.TrimSuffix(req.URL.Hostname(), "."))
+		if slices.ContainsFunc(hosts, func(direct string) bool {
+			return host == direct || strings.HasSuffix(host, "."+direct)
+		}) {
+			return nil, nil
+		}
+		if proxy == nil {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+}
+
+// NewTransport returns a transport derived from [http.DefaultTransport]
+// (proxy settings included, see [TransportConfig.DirectHosts]) whose
+// connections are reused across the requests sent to the same host.
+func NewTransport(cfg TransportConfig) (*http.Transport, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = directProxy(cfg.DirectHosts, transport.Proxy)
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	transport.DialContext = dialer.DialContext
+	if network := cfg.IPFamily.network(); network != "" {
+		transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.MaxIdleConns = max(transport.MaxIdleConns, cfg.MaxIdleConnsPerHost)
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	transport.ForceAttemptHTTP2 = !cfg.DisableHTTP2
+	if cfg.DisableHTTP2 {
+		// A non-nil empty map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport, nil
+}
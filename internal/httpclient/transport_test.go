@@ -0,0 +1,230 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewTransport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		cfg              TransportConfig
+		wantMaxIdle      int
+		wantForceHTTP2   bool
+		wantDisableHTTP2 bool
+		wantErr          bool
+	}{
+		{name: "defaults", wantMaxIdle: DefaultMaxIdleConnsPerHost, wantForceHTTP2: true},
+		{name: "custom", cfg: TransportConfig{MaxIdleConnsPerHost: 4}, wantMaxIdle: 4, wantForceHTTP2: true},
+		{name: "no-http2", cfg: TransportConfig{DisableHTTP2: true}, wantMaxIdle: DefaultMaxIdleConnsPerHost, wantDisableHTTP2: true},
+		{name: "invalid", cfg: TransportConfig{MaxIdleConnsPerHost: -1}, wantErr: true},
+		{name: "invalid-ip-family", cfg: TransportConfig{IPFamily: "v5"}, wantErr: true},
+		{name: "direct-hosts", cfg: TransportConfig{DirectHosts: []string{"*.example.com"}}, wantMaxIdle: DefaultMaxIdleConnsPerHost, wantForceHTTP2: true},
+		{name: "invalid-direct-host", cfg: TransportConfig{DirectHosts: []string{"https://example.com/crl"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transport, err := NewTransport(tc.cfg)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NewTransport() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if transport.MaxIdleConnsPerHost != tc.wantMaxIdle {
+				t.Errorf("MaxIdleConnsPerHost = %d, want %d", transport.MaxIdleConnsPerHost, tc.wantMaxIdle)
+			}
+			if transport.ForceAttemptHTTP2 != tc.wantForceHTTP2 {
+				t.Errorf("ForceAttemptHTTP2 = %v, want %v", transport.ForceAttemptHTTP2, tc.wantForceHTTP2)
+			}
+			if disabled := transport.TLSNextProto != nil && len(transport.TLSNextProto) == 0; disabled != tc.wantDisableHTTP2 {
+				t.Errorf("HTTP/2 disabled = %v, want %v", disabled, tc.wantDisableHTTP2)
+			}
+			if transport.Proxy == nil {
+				t.Error("Proxy = nil, want the proxy settings of the environment")
+			}
+		})
+	}
+}
+
+func TestNewTransportIPFamily(t *testing.T) {
+	t.Parallel()
+
+	// The server only listens on IPv4
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		family  IPFamily
+		wantErr bool
+	}{
+		{family: IPFamilyAuto},
+		{family: IPFamilyV4},
+		{family: IPFamilyV6, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(string(tc.family), func(t *testing.T) {
+			t.Parallel()
+
+			transport, err := NewTransport(TransportConfig{IPFamily: tc.family})
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn, err := transport.DialContext(t.Context(), "tcp", listener.Addr().String())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("DialContext() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if conn != nil {
+				_ = conn.Close()
+			}
+		})
+	}
+}
+
+func TestDirectProxy(t *testing.T) {
+	t.Parallel()
+
+	proxyURL := &url.URL{Scheme: "http", Host: "proxy.internal:3128"}
+	proxy := directProxy([]string{"example.com", "crl.vendor.net"}, func(*http.Request) (*url.URL, error) {
+		return proxyURL, nil
+	})
+
+	tests := []struct {
+		url        string
+		wantDirect bool
+	}{
+		{url: "http://example.com/ca.crt", wantDirect: true},
+		{url: "http://pki.EXAMPLE.com:8080/ca.crl", wantDirect: true},
+		{url: "http://crl.vendor.net/ca.crl", wantDirect: true},
+		{url: "http://vendor.net/ca.crl"},
+		{url: "http://notexample.com/ca.crl"},
+		{url: "https://tpm.dev/bundle.json"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.url, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := proxy(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if direct := got == nil; direct != tc.wantDirect {
+				t.Errorf("proxy(%s) = %v, want direct %v", tc.url, got, tc.wantDirect)
+			}
+		})
+	}
+}
+
+// BenchmarkCRLFetch fetches the same CRL concurrently and reports the number of
+// connections opened to the server per fetch, which is close to zero when the
+// connections are reused.
+func BenchmarkCRLFetch(b *testing.B) {
+	crl := createCRL(b)
+
+	benchmarks := []struct {
+		name      string
+		transport func(b *testing.B) *http.Transport
+	}{
+		{
+			name: "default",
+			transport: func(b *testing.B) *http.Transport {
+				return http.DefaultTransport.(*http.Transport).Clone()
+			},
+		},
+		{
+			name: "tuned",
+			transport: func(b *testing.B) *http.Transport {
+				transport, err := NewTransport(TransportConfig{})
+				if err != nil {
+					b.Fatal(err)
+				}
+				return transport
+			},
+		},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			var conns atomic.Int64
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(crl)
+			}))
+			srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					conns.Add(1)
+				}
+			}
+			srv.Start()
+			b.Cleanup(srv.Close)
+
+			transport := bm.transport(b)
+			b.Cleanup(transport.CloseIdleConnections)
+			client, err := New(Config{Client: &http.Client{Transport: transport}})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			// More concurrent fetches than idle connections kept by default
+			b.SetParallelism(4)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					resp, err := client.Do(req)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					data, err := io.ReadAll(resp.Body)
+					_ = resp.Body.Close()
+					if err != nil || !bytes.Equal(data, crl) {
+						b.Errorf("unexpected response: %v", err)
+						return
+					}
+					if _, err := x509.ParseRevocationList(data); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// jsonLogger emits one JSON object per log entry (time, level, msg and fields),
+// which suits log collectors (eg. journald, container runtimes).
+//
+// Padding is meaningless in this format and is ignored.
+type jsonLogger struct {
+	logger *slog.Logger
+}
+
+// NewJSONLogger creates a new Logger writing JSON entries to w.
+func NewJSONLogger(w io.Writer, verbose bool) Logger {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	return &jsonLogger{logger: slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))}
+}
+
+func (j *jsonLogger) log(level slog.Level, msg string) {
+	j.logger.Log(context.Background(), level, msg)
+}
+
+func (j *jsonLogger) Debug(msg string) {
+	j.log(slog.LevelDebug, msg)
+}
+
+func (j *jsonLogger) Debugf(format string, args ...any) {
+	j.log(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func (j *jsonLogger) Info(msg string) {
+	j.log(slog.LevelInfo, msg)
+}
+
+func (j *jsonLogger) Infof(format string, args ...any) {
+	j.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func (j *jsonLogger) Warn(msg string) {
+	j.log(slog.LevelWarn, msg)
+}
+
+func (j *jsonLogger) Warnf(format string, args ...any) {
+	j.log(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+func (j *jsonLogger) Error(msg string) {
+	j.log(slog.LevelError, msg)
+}
+
+func (j *jsonLogger) Errorf(format string, args ...any) {
+	j.log(slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+func (j *jsonLogger) WithField(key string, value any) FieldLogger {
+	return &jsonLogger{logger: j.logger.With(key, fieldValue(value))}
+}
+
+func (j *jsonLogger) WithError(err error) FieldLogger {
+	return j.WithField("error", err)
+}
+
+func (j *jsonLogger) IncreasePadding() {}
+
+func (j *jsonLogger) DecreasePadding() {}
+
+func (j *jsonLogger) ResetPadding() {}
+
+// fieldValue converts values which don't have a meaningful JSON
+// representation (eg. errors, pkix.Name) to strings.
+func fieldValue(value any) any {
+	switch v := value.(type) {
+	case error:
+		if v == nil {
+			return nil
+		}
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// Ensure jsonLogger implements both Logger and FieldLogger interfaces.
+var _ Logger = (*jsonLogger)(nil)
+var _ FieldLogger = (*jsonLogger)(nil)
@@ -3,8 +3,10 @@ package log
 import (
 	"io"
 	"os"
+	"sync"
 
 	"github.com/caarlos0/log"
+	"github.com/charmbracelet/colorprofile"
 )
 
 // Logger is the interface for logging operations.
@@ -44,8 +46,14 @@ type FieldLogger interface {
 var _ Logger = (*loggerAdapter)(nil)
 
 // loggerAdapter wraps log.Logger to implement the Logger interface.
+//
+// The padding of log.Logger is a plain field, read whenever an entry is
+// created: mu serializes its updates with the creation of entries so that
+// the logger can be shared by goroutines (eg. concurrent checks).
 type loggerAdapter struct {
 	*log.Logger
+
+	mu sync.RWMutex
 }
 
 // NewLogger creates a new Logger from a log.Logger.
@@ -53,12 +61,46 @@ func NewLogger(l *log.Logger) Logger {
 	return &loggerAdapter{Logger: l}
 }
 
+// entry returns a new entry carrying the current padding.
+func (l *loggerAdapter) entry() *log.Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return log.NewEntry(l.Logger)
+}
+
+func (l *loggerAdapter) Debug(msg string)                  { l.entry().Debug(msg) }
+func (l *loggerAdapter) Debugf(format string, args ...any) { l.entry().Debugf(format, args...) }
+func (l *loggerAdapter) Info(msg string)                   { l.entry().Info(msg) }
+func (l *loggerAdapter) Infof(format string, args ...any)  { l.entry().Infof(format, args...) }
+func (l *loggerAdapter) Warn(msg string)                   { l.entry().Warn(msg) }
+func (l *loggerAdapter) Warnf(format string, args ...any)  { l.entry().Warnf(format, args...) }
+func (l *loggerAdapter) Error(msg string)                  { l.entry().Error(msg) }
+func (l *loggerAdapter) Errorf(format string, args ...any) { l.entry().Errorf(format, args...) }
+
 func (l *loggerAdapter) WithField(key string, value any) FieldLogger {
-	return &fieldLoggerAdapter{Entry: l.Logger.WithField(key, value)}
+	return &fieldLoggerAdapter{Entry: l.entry().WithField(key, value)}
 }
 
 func (l *loggerAdapter) WithError(err error) FieldLogger {
-	return &fieldLoggerAdapter{Entry: l.Logger.WithError(err)}
+	return &fieldLoggerAdapter{Entry: l.entry().WithError(err)}
+}
+
+func (l *loggerAdapter) IncreasePadding() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Logger.IncreasePadding()
+}
+
+func (l *loggerAdapter) DecreasePadding() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Logger.DecreasePadding()
+}
+
+func (l *loggerAdapter) ResetPadding() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Logger.ResetPadding()
 }
 
 // fieldLoggerAdapter wraps log.Entry to implement the FieldLogger interface.
@@ -108,6 +150,8 @@ type Option func(*config)
 type config struct {
 	verbose bool
 	noop    bool
+	json    bool
+	noColor bool
 	output  io.Writer
 }
 
@@ -125,6 +169,22 @@ func WithNoop() Option {
 	}
 }
 
+// WithJSON emits structured JSON log entries instead of the pretty console format.
+func WithJSON(json bool) Option {
+	return func(c *config) {
+		c.json = json
+	}
+}
+
+// WithNoColor disables colors and styles, even when the output is a terminal.
+// Colors are already disabled when the output is not a terminal (unless forced
+// by the environment, eg. CLICOLOR_FORCE or CI).
+func WithNoColor(noColor bool) Option {
+	return func(c *config) {
+		c.noColor = noColor
+	}
+}
+
 // WithOutput sets the output writer for the logger.
 func WithOutput(w io.Writer) Option {
 	return func(c *config) {
@@ -157,11 +217,17 @@ func New(opts ...Option) Logger {
 	if cfg.noop {
 		return NewNoopLogger()
 	}
+	if cfg.json {
+		return NewJSONLogger(cfg.output, cfg.verbose)
+	}
 
 	stdLogger := log.New(cfg.output)
 	if cfg.verbose {
 		stdLogger.Level = log.DebugLevel
 	}
+	if cfg.noColor {
+		stdLogger.Writer.Profile = colorprofile.NoTTY
+	}
 
 	return NewLogger(stdLogger)
 }
@@ -2,6 +2,11 @@ package log
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/caarlos0/log"
@@ -64,6 +69,45 @@ func TestLoggerAdapterWithField(t *testing.T) {
 	}
 }
 
+func TestLoggerAdapterPadding(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	logger := NewLogger(log.New(buf))
+
+	logger.IncreasePadding()
+	logger.Info("padded")
+	logger.DecreasePadding()
+	logger.Info("unpadded")
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got: %q", lines)
+	}
+	indent := func(s string) int { return len(s) - len(strings.TrimLeft(s, " ")) }
+	if indent(lines[0]) <= indent(lines[1]) {
+		t.Errorf("expected first line to be more indented than the second, got: %q", lines)
+	}
+}
+
+func TestLoggerAdapterConcurrentPadding(t *testing.T) {
+	t.Parallel()
+
+	logger := NewLogger(log.New(io.Discard))
+
+	// Run with -race: padding updates must not race with the creation of entries
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			logger.IncreasePadding()
+			defer logger.DecreasePadding()
+			logger.Info("test")
+			logger.WithField("key", "value").Debug("test")
+		})
+	}
+	wg.Wait()
+}
+
 func TestNew(t *testing.T) {
 	t.Parallel()
 
@@ -129,3 +173,82 @@ func TestNew(t *testing.T) {
 		}
 	})
 }
+
+func TestNewNoColor(t *testing.T) {
+	// Not parallel: colors are forced through the environment
+	t.Setenv("CLICOLOR_FORCE", "1")
+	t.Setenv("TERM", "xterm-256color")
+
+	buf := &bytes.Buffer{}
+	New(WithOutput(buf)).Info("test message")
+	if !bytes.Contains(buf.Bytes(), []byte("\x1b[")) {
+		t.Fatalf("expected colored output when forced, got: %q", buf.String())
+	}
+
+	buf.Reset()
+	New(WithOutput(buf), WithNoColor(true)).Info("test message")
+	if bytes.Contains(buf.Bytes(), []byte("\x1b[")) {
+		t.Errorf("expected no escape sequence, got: %q", buf.String())
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("test message")) {
+		t.Errorf("expected output to contain 'test message', got: %q", buf.String())
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	logger := New(WithOutput(buf), WithJSON(true))
+
+	logger.Debug("hidden")
+	logger.IncreasePadding()
+	logger.WithField("id", "IFX").
+		WithError(errors.New("boom")).
+		Warn("unsupported manufacturer")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON entry, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"level": "WARN",
+		"msg":   "unsupported manufacturer",
+		"id":    "IFX",
+		"error": "boom",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("entry[%q] = %v, want %v", k, entry[k], v)
+		}
+	}
+}
+
+func TestRedactLogger(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	redact := func(s string) string { return strings.ReplaceAll(s, "secret", "[REDACTED]") }
+	logger := NewRedactLogger(New(WithOutput(buf), WithJSON(true)), redact)
+
+	logger.WithField("serial", "secret").
+		WithField("ms", 42).
+		WithError(errors.New("serial secret revoked")).
+		Infof("audit of %s", "secret")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON entry, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"msg":    "audit of [REDACTED]",
+		"serial": "[REDACTED]",
+		"ms":     float64(42),
+		"error":  "serial [REDACTED] revoked",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("entry[%q] = %v, want %v", k, entry[k], v)
+		}
+	}
+}
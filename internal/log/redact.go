@@ -0,0 +1,132 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+)
+
+// redactLogger passes the messages and field values of a Logger through a
+// redaction function, eg. to mask identifiers before logs are shared.
+type redactLogger struct {
+	Logger
+	redact func(string) string
+}
+
+// NewRedactLogger creates a Logger which writes to l the messages and
+// field values returned by redact.
+func NewRedactLogger(l Logger, redact func(string) string) Logger {
+	return &redactLogger{Logger: l, redact: redact}
+}
+
+func (r *redactLogger) Debug(msg string) {
+	r.Logger.Debug(r.redact(msg))
+}
+
+func (r *redactLogger) Debugf(format string, args ...any) {
+	r.Logger.Debug(r.redact(fmt.Sprintf(format, args...)))
+}
+
+func (r *redactLogger) Info(msg string) {
+	r.Logger.Info(r.redact(msg))
+}
+
+func (r *redactLogger) Infof(format string, args ...any) {
+	r.Logger.Info(r.redact(fmt.Sprintf(format, args...)))
+}
+
+func (r *redactLogger) Warn(msg string) {
+	r.Logger.Warn(r.redact(msg))
+}
+
+func (r *redactLogger) Warnf(format string, args ...any) {
+	r.Logger.Warn(r.redact(fmt.Sprintf(format, args...)))
+}
+
+func (r *redactLogger) Error(msg string) {
+	r.Logger.Error(r.redact(msg))
+}
+
+func (r *redactLogger) Errorf(format string, args ...any) {
+	r.Logger.Error(r.redact(fmt.Sprintf(format, args...)))
+}
+
+func (r *redactLogger) WithField(key string, value any) FieldLogger {
+	return &redactFieldLogger{FieldLogger: r.Logger.WithField(key, redactValue(r.redact, value)), redact: r.redact}
+}
+
+func (r *redactLogger) WithError(err error) FieldLogger {
+	return &redactFieldLogger{FieldLogger: r.Logger.WithError(redactError(r.redact, err)), redact: r.redact}
+}
+
+// redactFieldLogger is the FieldLogger of a [redactLogger].
+type redactFieldLogger struct {
+	FieldLogger
+	redact func(string) string
+}
+
+func (r *redactFieldLogger) Debug(msg string) {
+	r.FieldLogger.Debug(r.redact(msg))
+}
+
+func (r *redactFieldLogger) Debugf(format string, args ...any) {
+	r.FieldLogger.Debug(r.redact(fmt.Sprintf(format, args...)))
+}
+
+func (r *redactFieldLogger) Info(msg string) {
+	r.FieldLogger.Info(r.redact(msg))
+}
+
+func (r *redactFieldLogger) Infof(format string, args ...any) {
+	r.FieldLogger.Info(r.redact(fmt.Sprintf(format, args...)))
+}
+
+func (r *redactFieldLogger) Warn(msg string) {
+	r.FieldLogger.Warn(r.redact(msg))
+}
+
+func (r *redactFieldLogger) Warnf(format string, args ...any) {
+	r.FieldLogger.Warn(r.redact(fmt.Sprintf(format, args...)))
+}
+
+func (r *redactFieldLogger) Error(msg string) {
+	r.FieldLogger.Error(r.redact(msg))
+}
+
+func (r *redactFieldLogger) Errorf(format string, args ...any) {
+	r.FieldLogger.Error(r.redact(fmt.Sprintf(format, args...)))
+}
+
+func (r *redactFieldLogger) WithField(key string, value any) FieldLogger {
+	return &redactFieldLogger{FieldLogger: r.FieldLogger.WithField(key, redactValue(r.redact, value)), redact: r.redact}
+}
+
+func (r *redactFieldLogger) WithError(err error) FieldLogger {
+	return &redactFieldLogger{FieldLogger: r.FieldLogger.WithError(redactError(r.redact, err)), redact: r.redact}
+}
+
+// redactValue returns the redacted string of value, or value itself if
+// nothing was redacted (keeping its type, eg. a number in JSON logs).
+func redactValue(redact func(string) string, value any) any {
+	if value == nil {
+		return nil
+	}
+	s := fmt.Sprint(value)
+	if redacted := redact(s); redacted != s {
+		return redacted
+	}
+	return value
+}
+
+func redactError(redact func(string) string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if redacted := redact(err.Error()); redacted != err.Error() {
+		return errors.New(redacted)
+	}
+	return err
+}
+
+// Ensure redactLogger and redactFieldLogger implement the interfaces.
+var _ Logger = (*redactLogger)(nil)
+var _ FieldLogger = (*redactFieldLogger)(nil)
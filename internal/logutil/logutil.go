@@ -1,19 +1,26 @@
 package logutil
 
 import (
+	"context"
 	"time"
 
 	"github.com/loicsikidi/tpm-trust/internal/log"
 )
 
-func LogDuration(logger log.Logger, start time.Time) {
-	logger.Infof("took: %ds", int(time.Since(start).Seconds()))
+// LogDuration logs the time elapsed since start, with millisecond precision,
+// and returns it.
+func LogDuration(logger log.Logger, start time.Time) time.Duration {
+	elapsed := time.Since(start)
+	logger.Infof("took: %s", elapsed.Round(time.Millisecond))
+	return elapsed
 }
 
-func LogDurationWithPadding(logger log.Logger, start time.Time) {
+func LogDurationWithPadding(logger log.Logger, start time.Time) time.Duration {
+	var elapsed time.Duration
 	LogWithPadding(logger, func() {
-		LogDuration(logger, start)
+		elapsed = LogDuration(logger, start)
 	})
+	return elapsed
 }
 
 func LogWithPadding(logger log.Logger, callback func()) {
@@ -21,3 +28,33 @@ func LogWithPadding(logger log.Logger, callback func()) {
 	callback()
 	logger.DecreasePadding()
 }
+
+// LogProgress periodically logs msg with the elapsed time until the returned
+// stop function is called or ctx is cancelled. It is meant to give feedback
+// during long-running operations (eg. RSA key generation).
+//
+// Nothing is printed when logger is a noop logger (eg. JSON output).
+func LogProgress(ctx context.Context, logger log.Logger, interval time.Duration, msg string) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	start := time.Now()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				logger.WithField("elapsed", time.Since(start).Round(time.Second)).Info(msg)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
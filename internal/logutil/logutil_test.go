@@ -0,0 +1,82 @@
+package logutil
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+func TestLogProgress(t *testing.T) {
+	t.Parallel()
+
+	t.Run("logs until stopped", func(t *testing.T) {
+		t.Parallel()
+
+		buf := &bytes.Buffer{}
+		logger := log.New(log.WithOutput(buf))
+
+		stop := LogProgress(context.Background(), logger, 10*time.Millisecond, "still working")
+		time.Sleep(50 * time.Millisecond)
+		stop()
+
+		output := buf.String()
+		if !strings.Contains(output, "still working") {
+			t.Fatalf("expected progress log, got: %q", output)
+		}
+
+		// no more logs once stopped
+		time.Sleep(30 * time.Millisecond)
+		if buf.String() != output {
+			t.Errorf("expected no log after stop, got: %q", buf.String())
+		}
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stop := LogProgress(ctx, log.New(log.WithNoop()), time.Hour, "still working")
+		cancel()
+		stop() // must not block
+	})
+}
+
+func TestLogDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    string
+	}{
+		// Sub-second durations must not be truncated to 0s
+		{name: "sub-second", elapsed: 143 * time.Millisecond, want: "took: 14"}, // eg. 143ms
+		{name: "seconds", elapsed: 1500 * time.Millisecond, want: "took: 1.5"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := &bytes.Buffer{}
+			logger := log.New(log.WithOutput(buf))
+
+			elapsed := LogDuration(logger, time.Now().Add(-tc.elapsed))
+			if elapsed < tc.elapsed {
+				t.Errorf("LogDuration() = %s, want at least %s", elapsed, tc.elapsed)
+			}
+			output := buf.String()
+			if !strings.Contains(output, tc.want) {
+				t.Errorf("expected %q, got: %q", tc.want, output)
+			}
+			if strings.Contains(output, "took: 0s") {
+				t.Errorf("duration truncated, got: %q", output)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// formulaPrefixes are the leading characters making a spreadsheet
+// interpret a cell as a formula (CSV injection).
+const formulaPrefixes = "=+-@\t\r"
+
+// WriteCSV writes header then rows as CSV (RFC 4180) to w. Cells which a
+// spreadsheet would evaluate as a formula are prefixed with a single quote,
+// so that the output can be imported as is.
+func WriteCSV(w io.Writer, header []string, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to encode CSV: %w", err)
+	}
+	for _, row := range rows {
+		escaped := make([]string, len(row))
+		for i, cell := range row {
+			if cell != "" && strings.ContainsRune(formulaPrefixes, rune(cell[0])) {
+				cell = "'" + cell
+			}
+			escaped[i] = cell
+		}
+		if err := writer.Write(escaped); err != nil {
+			return fmt.Errorf("failed to encode CSV: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to encode CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	header := []string{"source", "verdict"}
+
+	tests := []struct {
+		name string
+		rows [][]string
+		want string
+	}{
+		{
+			name: "header only",
+			want: "source,verdict\n",
+		},
+		{
+			name: "quoted",
+			rows: [][]string{{"ek.pem", "trusted"}, {"CN=EK, O=Acme", "revoked"}},
+			want: "source,verdict\nek.pem,trusted\n\"CN=EK, O=Acme\",revoked\n",
+		},
+		{
+			name: "formula",
+			rows: [][]string{{"=HYPERLINK(\"x\")", "-1"}, {"@cmd", ""}},
+			want: "source,verdict\n\"'=HYPERLINK(\"\"x\"\")\",'-1\n'@cmd,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			if err := WriteCSV(&buf, header, tc.rows); err != nil {
+				t.Fatalf("WriteCSV() error = %v", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("WriteCSV() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// JSONPrettyFlag is the name of the flag controlling JSON indentation.
+const JSONPrettyFlag = "json-pretty"
+
+// WriteJSON writes v as JSON to w, indented when pretty is set
+// (compact otherwise, one document per line).
+func WriteJSON(w io.Writer, v any, pretty bool) error {
+	encoder := json.NewEncoder(w)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
+
+// IsTerminal reports whether w is a terminal.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,46 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	v := map[string]any{"verdict": "trusted", "source": "tpm"}
+
+	tests := []struct {
+		name   string
+		pretty bool
+		want   string
+	}{
+		{
+			name: "compact",
+			want: `{"source":"tpm","verdict":"trusted"}` + "\n",
+		},
+		{
+			name:   "pretty",
+			pretty: true,
+			want:   "{\n  \"source\": \"tpm\",\n  \"verdict\": \"trusted\"\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			if err := WriteJSON(&buf, v, tc.pretty); err != nil {
+				t.Fatalf("WriteJSON() error = %v", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("WriteJSON() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	if IsTerminal(&bytes.Buffer{}) {
+		t.Error("IsTerminal() = true for a buffer")
+	}
+}
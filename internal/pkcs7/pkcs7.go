@@ -0,0 +1,86 @@
+// Package pkcs7 handles the "certs-only" PKCS#7 SignedData structures used to
+// distribute certificates (eg. .p7b/.p7c files served by AIA endpoints or
+// produced by provisioning tools). Signatures are neither produced nor verified.
+package pkcs7
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"slices"
+)
+
+var (
+	// oidData is the content type of the (empty) content of a certs-only structure.
+	oidData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	// oidSignedData is the content type of a PKCS#7 SignedData structure.
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+// contentInfo is the outer structure of a PKCS#7 message (RFC 2315, section 7).
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+// signedData is a PKCS#7 SignedData structure (RFC 2315, section 9.1),
+// only the certificates are decoded.
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// ParseCertificates returns the certificates of a DER encoded PKCS#7
+// SignedData structure, in order. Trailing data (eg. padding of an NV
+// index) is ignored.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(data, &ci); err != nil {
+		return nil, fmt.Errorf("not a PKCS#7 structure: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("unsupported PKCS#7 content type %s", ci.ContentType)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("failed to parse PKCS#7 signed data: %w", err)
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PKCS#7 certificates: %w", err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate in PKCS#7 structure")
+	}
+	return certs, nil
+}
+
+// MarshalCertificates wraps the DER encoded certificates in a certs-only
+// PKCS#7 SignedData structure.
+func MarshalCertificates(certs ...[]byte) ([]byte, error) {
+	data, err := asn1.Marshal(struct{ ContentType asn1.ObjectIdentifier }{oidData})
+	if err != nil {
+		return nil, err
+	}
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+		ContentInfo:      asn1.RawValue{FullBytes: data},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: slices.Concat(certs...)},
+		SignerInfos:      asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
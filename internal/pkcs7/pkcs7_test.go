@@ -0,0 +1,78 @@
+package pkcs7
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestParseCertificates(t *testing.T) {
+	t.Parallel()
+
+	first, second := createCertificate(t, "first"), createCertificate(t, "second")
+	bundle, err := MarshalCertificates(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := MarshalCertificates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []string
+		wantErr bool
+	}{
+		{name: "success", data: bundle, want: []string{"first", "second"}},
+		{name: "success/trailing-data", data: append(append([]byte{}, bundle...), 0xFF, 0xFF), want: []string{"first", "second"}},
+		{name: "error/no-certificate", data: empty, wantErr: true},
+		{name: "error/certificate", data: first, wantErr: true},
+		{name: "error/garbage", data: []byte("not a PKCS#7 structure"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			certs, err := ParseCertificates(tc.data)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseCertificates() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if len(certs) != len(tc.want) {
+				t.Fatalf("ParseCertificates() = %d certificates, want %d", len(certs), len(tc.want))
+			}
+			for i, cert := range certs {
+				if cert.Subject.CommonName != tc.want[i] {
+					t.Errorf("certificate %d = %q, want %q", i, cert.Subject.CommonName, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func createCertificate(t *testing.T, cn string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
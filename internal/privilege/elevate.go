@@ -2,6 +2,12 @@
 
 package privilege
 
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
 // platformImpl contains platform-specific implementations for privilege elevation.
 type platformImpl struct {
 	needsElevation func() bool
@@ -11,6 +17,49 @@ type platformImpl struct {
 // platform is initialized in elevate_linux.go or elevate_windows.go via init().
 var platform platformImpl
 
+// Config configures privilege elevation (only used on Linux).
+type Config struct {
+	// SudoCommand is the command used to re-execute the process with elevated
+	// privileges, possibly with arguments (eg. "doas" or "sudo -E").
+	//
+	// Default: "sudo".
+	SudoCommand string
+	// NonInteractive fails immediately if a password would be required
+	// (sudo -n) instead of prompting for it, eg. in cron jobs or CI.
+	// It is only supported when SudoCommand is sudo.
+	NonInteractive bool
+}
+
+const defaultSudoCommand = "sudo"
+
+func (c *Config) CheckAndSetDefaults() error {
+	if strings.TrimSpace(c.SudoCommand) == "" {
+		c.SudoCommand = defaultSudoCommand
+	}
+	if c.NonInteractive && !isSudo(c.SudoCommand) {
+		return fmt.Errorf("non-interactive elevation is only supported with sudo, not %q", c.SudoCommand)
+	}
+	return nil
+}
+
+// isSudo reports whether the sudo command cmd (possibly with arguments)
+// runs sudo, whose options (eg. -n) may not be supported by other commands.
+func isSudo(cmd string) bool {
+	fields := strings.Fields(cmd)
+	return len(fields) > 0 && filepath.Base(fields[0]) == "sudo"
+}
+
+var config = Config{SudoCommand: defaultSudoCommand}
+
+// Configure sets the configuration used by [Elevate].
+func Configure(cfg Config) error {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return err
+	}
+	config = cfg
+	return nil
+}
+
 // needsElevation checks if the current process needs privilege elevation
 // to access the TPM device.
 func needsElevation() bool {
@@ -19,7 +68,8 @@ func needsElevation() bool {
 
 // Elevate re-executes the current process with elevated privileges if necessary.
 //
-// On Linux, this function re-executes the process using sudo if needed.
+// On Linux, this function re-executes the process using sudo if needed
+// (see [Configure] to use another command or to never prompt for a password).
 // If elevation is successful, this function does not return as the current process
 // exits after spawning the elevated process.
 //
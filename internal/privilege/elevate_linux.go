@@ -3,16 +3,40 @@
 package privilege
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"syscall"
+	"slices"
+	"strings"
 
 	"github.com/caarlos0/log"
 )
 
 const tpmDevicePath = "/dev/tpmrm0"
 
+// preservedEnv lists the environment variables forwarded to the elevated
+// process, as sudo resets the environment: proxy settings, used to download
+// the trusted bundle, issuers and CRLs. TLS settings (eg. SSL_CERT_FILE) are
+// not forwarded, as they would let the caller choose the roots trusted by
+// the elevated process.
+var preservedEnv = []string{
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+	"http_proxy", "https_proxy", "no_proxy",
+}
+
+// envRefusedMsg is reported by sudo when its policy doesn't allow to preserve
+// the environment (eg. sudoers policy without SETENV).
+const envRefusedMsg = "not allowed to preserve the environment"
+
+// errEnvRefused is returned when sudo refused to preserve the environment.
+var errEnvRefused = errors.New("sudo refused to preserve the environment")
+
+// preservedEnvPrefix is the prefix of the environment variables of the tool,
+// which are all forwarded to the elevated process.
+const preservedEnvPrefix = "TPM_TRUST_"
+
 func init() {
 	platform = platformImpl{
 		needsElevation: needsElevationLinux,
@@ -42,29 +66,141 @@ func needsElevationLinux() bool {
 // elevateLinux re-executes the current process with elevated privileges using sudo.
 // It preserves all command-line arguments and returns an error if elevation fails.
 func elevateLinux() error {
-	log.Warn("TPM access requires elevated privileges, re-executing with sudo")
+	log.Warnf("TPM access requires elevated privileges, re-executing with %s", config.SudoCommand)
 
 	executable, err := os.Executable()
 	if err != nil {
 		return fmt.Errorf("failed to get executable path: %w", err)
 	}
 
-	args := append([]string{executable}, os.Args[1:]...)
-	cmd := exec.Command("sudo", args...)
+	env := envToPreserve(os.Environ())
+	if config.NonInteractive {
+		if env, err = probeSudo(config, env); err != nil {
+			return err
+		}
+	}
+
+	args := sudoArgs(config, executable, os.Args[1:], env)
+	code, err := runElevated(args)
+	if errors.Is(err, errEnvRefused) {
+		log.WithField("env", strings.Join(env, ",")).
+			Warnf("%s refused to preserve the environment, elevating without it", config.SudoCommand)
+		args = sudoArgs(config, executable, os.Args[1:], nil)
+		code, err = runElevated(args)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to re-execute with %s: %w", args[0], err)
+	}
+
+	// Exit the current (non-elevated) process.
+	os.Exit(code)
+	return nil
+}
+
+// runElevated runs the command line args with the standard streams of the
+// process and returns its exit code. When the environment is preserved,
+// the start of its error output is kept to tell whether sudo refused to
+// preserve it, in which case [errEnvRefused] is returned.
+func runElevated(args []string) (int, error) {
+	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 
-	if err := cmd.Run(); err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-				os.Exit(status.ExitStatus())
-			}
+	stderr := &headWriter{max: 1024}
+	preserveEnv := slices.ContainsFunc(args, func(arg string) bool {
+		return strings.HasPrefix(arg, "--preserve-env=")
+	})
+	if preserveEnv {
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	}
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if preserveEnv && strings.Contains(string(stderr.buf), envRefusedMsg) {
+			return 0, errEnvRefused
 		}
-		return fmt.Errorf("failed to re-execute with sudo: %w", err)
+		return exitErr.ExitCode(), nil
 	}
+	return 0, err
+}
 
-	// Exit the current (non-elevated) process.
-	os.Exit(0)
-	return nil
+// headWriter keeps the first bytes written to it (up to max) and discards
+// the others.
+type headWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if n := w.max - len(w.buf); n > 0 {
+		w.buf = append(w.buf, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// sudoArgs returns the command line re-executing executable with args
+// through the sudo command of cfg. The sudo options (non-interactive mode,
+// env variables to preserve) are only set when the command is sudo: other
+// commands rely on their own configuration.
+func sudoArgs(cfg Config, executable string, args, env []string) []string {
+	cmdline := strings.Fields(cfg.SudoCommand)
+	if !isSudo(cfg.SudoCommand) {
+		return slices.Concat(cmdline, []string{executable}, args)
+	}
+	if cfg.NonInteractive {
+		cmdline = append(cmdline, "-n")
+	}
+	if len(env) > 0 {
+		cmdline = append(cmdline, "--preserve-env="+strings.Join(env, ","))
+	}
+	return slices.Concat(cmdline, []string{executable}, args)
+}
+
+// envToPreserve returns the names of the variables of environ (as returned
+// by [os.Environ]) which must be forwarded to the elevated process.
+func envToPreserve(environ []string) []string {
+	var names []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if slices.Contains(preservedEnv, name) || strings.HasPrefix(name, preservedEnvPrefix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// probeSudo checks, in non-interactive mode, that the sudo command of cfg
+// with the options used to elevate (env preserved) can run without prompting
+// for a password. As the elevated process may fail on its own, the check is
+// done beforehand to tell a denied elevation apart.
+//
+// It returns the variables of env to preserve: none if sudo only refuses to
+// preserve them (eg. sudoers policy without SETENV).
+func probeSudo(cfg Config, env []string) ([]string, error) {
+	args := sudoArgs(cfg, "true", nil, env)
+	err := runProbe(args)
+	if err == nil {
+		return env, nil
+	}
+	if len(env) > 0 && strings.Contains(err.Error(), envRefusedMsg) && runProbe(sudoArgs(cfg, "true", nil, nil)) == nil {
+		log.WithError(err).
+			WithField("env", strings.Join(env, ",")).
+			Warnf("%s refused to preserve the environment, elevating without it", cfg.SudoCommand)
+		return nil, nil
+	}
+	return nil, fmt.Errorf("non-interactive elevation denied (%s): %w; allow it without password (eg. NOPASSWD in sudoers) or run as root", strings.Join(args[:len(args)-1], " "), err)
+}
+
+// runProbe runs the command line args, reporting its output on failure.
+func runProbe(args []string) error {
+	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	return err
 }
@@ -0,0 +1,163 @@
+//go:build linux
+
+package privilege
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSudoArgs(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"audit", "--ek-cert", "/home/me/my certs/ek.der"}
+	tests := []struct {
+		name string
+		cfg  Config
+		env  []string
+		want []string
+	}{
+		{
+			name: "sudo",
+			cfg:  Config{SudoCommand: "sudo"},
+			want: []string{"sudo", "/usr/bin/tpm-trust", "audit", "--ek-cert", "/home/me/my certs/ek.der"},
+		},
+		{
+			name: "non-interactive",
+			cfg:  Config{SudoCommand: "sudo", NonInteractive: true},
+			want: []string{"sudo", "-n", "/usr/bin/tpm-trust", "audit", "--ek-cert", "/home/me/my certs/ek.der"},
+		},
+		{
+			name: "preserved environment",
+			cfg:  Config{SudoCommand: "/usr/bin/sudo", NonInteractive: true},
+			env:  []string{"HTTPS_PROXY", "TPM_TRUST_CACHE"},
+			want: []string{"/usr/bin/sudo", "-n", "--preserve-env=HTTPS_PROXY,TPM_TRUST_CACHE", "/usr/bin/tpm-trust", "audit", "--ek-cert", "/home/me/my certs/ek.der"},
+		},
+		{
+			name: "environment not preserved by other commands",
+			cfg:  Config{SudoCommand: "doas"},
+			env:  []string{"HTTPS_PROXY"},
+			want: []string{"doas", "/usr/bin/tpm-trust", "audit", "--ek-cert", "/home/me/my certs/ek.der"},
+		},
+		{
+			name: "no sudo options for other commands",
+			cfg:  Config{SudoCommand: "doas -u root", NonInteractive: true},
+			env:  []string{"HTTPS_PROXY"},
+			want: []string{"doas", "-u", "root", "/usr/bin/tpm-trust", "audit", "--ek-cert", "/home/me/my certs/ek.der"},
+		},
+		{
+			name: "command with arguments",
+			cfg:  Config{SudoCommand: "sudo -E", NonInteractive: true},
+			want: []string{"sudo", "-E", "-n", "/usr/bin/tpm-trust", "audit", "--ek-cert", "/home/me/my certs/ek.der"},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := sudoArgs(tc.cfg, "/usr/bin/tpm-trust", args, tc.env); !slices.Equal(got, tc.want) {
+				t.Errorf("sudoArgs() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProbeSudo(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed")
+	denied := filepath.Join(dir, "denied")
+	if err := os.WriteFile(allowed, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(denied, []byte("#!/bin/sh\necho 'sudo: a password is required' >&2\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Like sudo with a sudoers policy without SETENV
+	noSetenv := filepath.Join(dir, "nosetenv", "sudo")
+	if err := os.Mkdir(filepath.Dir(noSetenv), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\ncase \"$*\" in *--preserve-env=*) echo 'sudo: sorry, you are not allowed to preserve the environment' >&2; exit 1;; esac\nexit 0\n"
+	if err := os.WriteFile(noSetenv, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	env := []string{"HTTPS_PROXY"}
+
+	if got, err := probeSudo(Config{SudoCommand: allowed, NonInteractive: true}, env); err != nil || !slices.Equal(got, env) {
+		t.Errorf("probeSudo() = %q, %v, want %q", got, err, env)
+	}
+	if got, err := probeSudo(Config{SudoCommand: noSetenv, NonInteractive: true}, env); err != nil || got != nil {
+		t.Errorf("probeSudo() = %q, %v, want the environment dropped", got, err)
+	}
+	if _, err := probeSudo(Config{SudoCommand: denied, NonInteractive: true}, env); err == nil || !strings.Contains(err.Error(), "a password is required") {
+		t.Errorf("probeSudo() error = %v, want denied elevation", err)
+	}
+}
+
+func TestRunElevated(t *testing.T) {
+	t.Parallel()
+
+	// Like sudo with a sudoers policy without SETENV, running a command
+	// exiting with 3
+	noSetenv := filepath.Join(t.TempDir(), "sudo")
+	script := "#!/bin/sh\ncase \"$*\" in *--preserve-env=*) echo 'sudo: sorry, you are not allowed to preserve the environment' >&2; exit 1;; esac\nexit 3\n"
+	if err := os.WriteFile(noSetenv, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{SudoCommand: noSetenv}
+
+	if _, err := runElevated(sudoArgs(cfg, "/usr/bin/tpm-trust", nil, []string{"HTTPS_PROXY"})); !errors.Is(err, errEnvRefused) {
+		t.Errorf("runElevated() error = %v, want %v", err, errEnvRefused)
+	}
+	code, err := runElevated(sudoArgs(cfg, "/usr/bin/tpm-trust", nil, nil))
+	if err != nil || code != 3 {
+		t.Errorf("runElevated() = %d, %v, want the exit code of the command", code, err)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Cleanup(func() { config = Config{SudoCommand: defaultSudoCommand} })
+
+	if err := Configure(Config{NonInteractive: true}); err != nil {
+		t.Fatal(err)
+	}
+	if config.SudoCommand != defaultSudoCommand || !config.NonInteractive {
+		t.Errorf("config = %+v, want default sudo command in non-interactive mode", config)
+	}
+
+	for _, cmd := range []string{"pkexec", "doas -u root"} {
+		if err := Configure(Config{SudoCommand: cmd, NonInteractive: true}); err == nil {
+			t.Errorf("Configure(%q) expected an error in non-interactive mode", cmd)
+		}
+	}
+	if config.SudoCommand != defaultSudoCommand {
+		t.Errorf("config = %+v, want it unchanged after an invalid configuration", config)
+	}
+}
+
+func TestEnvToPreserve(t *testing.T) {
+	t.Parallel()
+
+	environ := []string{
+		"PATH=/usr/bin",
+		"https_proxy=http://proxy:3128",
+		"TPM_TRUST_FOO=bar",
+		"HTTPS_PROXY=http://proxy:3128",
+		"NO_COLOR=",
+		"CI=true",
+		"HOME=/home/me",
+	}
+	want := []string{"HTTPS_PROXY", "TPM_TRUST_FOO", "https_proxy"}
+	if got := envToPreserve(environ); !slices.Equal(got, want) {
+		t.Errorf("envToPreserve() = %q, want %q", got, want)
+	}
+}
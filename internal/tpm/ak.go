@@ -0,0 +1,74 @@
+package tpm
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/loicsikidi/attest"
+)
+
+// AKSignature is a signature made by an attestation key (AK) generated
+// in the TPM (see [SignWithAK]).
+type AKSignature struct {
+	// Hash is the hash algorithm of the signed message.
+	Hash crypto.Hash
+	// Signature is the signature of the message: PKCS #1 v1.5 for an RSA
+	// AK, ASN.1 DER encoded for an ECC AK.
+	Signature []byte
+	// PublicKey is the public key of the AK.
+	PublicKey crypto.PublicKey
+	// Attestation proves that the AK is a restricted key of the TPM. Along with
+	// the EK, it lets a verifier bind the AK to the TPM (credential activation).
+	Attestation attest.AttestationParameters
+	// Blob is the AK, encrypted by the TPM. It can only be loaded back in the
+	// same TPM (see [attest.TPM.LoadAK]), eg. to answer a credential activation
+	// challenge of a verifier.
+	Blob []byte
+}
+
+// SignWithAK generates an AK in the TPM and signs msg (hashed with SHA-256
+// by the TPM) with it. The AK is unloaded once msg is signed: it is returned
+// encrypted by the TPM, to be loaded back later.
+func SignWithAK(ctx context.Context, cfg TPMConfig, msg []byte) (sig *AKSignature, err error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	logger := cfg.Logger
+	logger.Debug("open connection to TPM")
+	tpm, err := openSession(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open TPM: %w", err)
+	}
+	defer func() {
+		logger.Debug("closing connection to TPM")
+		if closeErr := tpm.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close TPM: %w", closeErr)
+		}
+	}()
+
+	logger.Debug("generating AK")
+	ak, err := tpm.NewAK()
+	if err != nil {
+		return nil, checkLockout(logger, tpm.Tpm(), fmt.Errorf("failed to generate AK: %w", err))
+	}
+	defer func() { _ = ak.Close() }()
+
+	signature, err := ak.Signer().SignMessage(rand.Reader, msg, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with AK: %w", err)
+	}
+	blob, err := ak.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export AK: %w", err)
+	}
+	return &AKSignature{
+		Hash:        crypto.SHA256,
+		Signature:   signature,
+		PublicKey:   ak.Public(),
+		Attestation: ak.AttestationParameters(),
+		Blob:        blob,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package tpm
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/loicsikidi/go-tpm-kit/tpmtest"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+func TestSignWithAK(t *testing.T) {
+	t.Parallel()
+
+	sim := tpmtest.OpenSimulator(t, tpmtest.OpenConfig{SkipProvisioning: true, SkipCleanup: true})
+	msg := []byte(`{"verdict":"trusted"}`)
+	sig, err := SignWithAK(context.Background(), TPMConfig{TPM: sim, Logger: log.New(log.WithNoop())}, msg)
+	if err != nil {
+		t.Fatalf("SignWithAK() error = %v", err)
+	}
+	if sig.Hash != crypto.SHA256 || sig.Attestation.Public == nil {
+		t.Fatalf("SignWithAK() = %+v, want a SHA-256 signature with the AK attestation", sig)
+	}
+
+	digest := sha256.Sum256(msg)
+	switch pub := sig.PublicKey.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig.Signature)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest[:], sig.Signature) {
+			err = errors.New("invalid ECDSA signature")
+		}
+	default:
+		t.Fatalf("unexpected AK public key %T", pub)
+	}
+	if err != nil {
+		t.Errorf("signature verification error = %v", err)
+	}
+}
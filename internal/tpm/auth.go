@@ -0,0 +1,64 @@
+package tpm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/go-tpm-kit/tpmutil"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+// ErrEndorsementAuth is returned when the EK cannot be generated because the
+// authorization value of the endorsement hierarchy is missing or wrong
+// (see [TPMConfig.EndorsementAuth]).
+var ErrEndorsementAuth = codes.New(codes.E044EndorsementAuth, "endorsement hierarchy authorization failed")
+
+// isAuthFailure reports whether err is caused by a wrong authorization value.
+func isAuthFailure(err error) bool {
+	return errors.Is(err, tpm2.TPMRCBadAuth) || errors.Is(err, tpm2.TPMRCAuthFail)
+}
+
+// getEK returns the EK of cfg.Template (see [endorsement.Get]). The TPM
+// library generates it with the empty authorization value, hence it is
+// generated again with the authorization value of the session if the
+// endorsement hierarchy refuses it.
+func (s *session) getEK(cfg endorsement.GetConfig) (endorsement.EK, error) {
+	ek, err := endorsement.Get(s.Tpm(), cfg)
+	if !isAuthFailure(err) {
+		return ek, err
+	}
+	if len(s.endorsementAuth) == 0 {
+		return endorsement.EK{}, fmt.Errorf("%w: the endorsement hierarchy has an authorization value, which was not provided: %w", ErrEndorsementAuth, err)
+	}
+	s.logger.Debug("generating EK with the endorsement hierarchy authorization value")
+	ek, err = s.createEK(cfg)
+	if isAuthFailure(err) {
+		return endorsement.EK{}, fmt.Errorf("%w: wrong authorization value: %w", ErrEndorsementAuth, err)
+	}
+	return ek, err
+}
+
+// createEK generates the EK of cfg.Template under the endorsement hierarchy
+// with the authorization value of the session.
+func (s *session) createEK(cfg endorsement.GetConfig) (endorsement.EK, error) {
+	handle, err := tpmutil.CreatePrimary(s.Tpm(), tpmutil.CreatePrimaryConfig{
+		PrimaryHandle: tpm2.TPMRHEndorsement,
+		Auth:          tpm2.PasswordAuth(s.endorsementAuth),
+		InPublic:      cfg.Template.Public,
+	})
+	if err != nil {
+		return endorsement.EK{}, fmt.Errorf("CreatePrimary failed: %w", err)
+	}
+	defer handle.Close() //nolint:errcheck
+
+	ek := endorsement.EK{Template: cfg.Template, Public: handle.Public()}
+	pub, err := ek.PublicKey()
+	if err != nil {
+		return endorsement.EK{}, fmt.Errorf("failed to get EK public key: %w", err)
+	}
+	ek.CertificateURL = endorsement.EkCertURL(pub, cfg.Info.Manufacturer.ASCII)
+	return ek, nil
+}
@@ -0,0 +1,21 @@
+package tpm
+
+import "github.com/loicsikidi/tpm-trust/internal/codes"
+
+// sysfsTPMRoot is the sysfs class of the TPM devices.
+const sysfsTPMRoot = "/sys/class/tpm"
+
+var ErrDevicesNotSupported = codes.New(codes.E043DevicesUnsupported, "TPM devices can only be enumerated and selected on Linux")
+
+// DevicesSupported reports whether TPM devices can be enumerated
+// (see [ListDevices]) and selected (see [TPMConfig.Device]) on this platform.
+func DevicesSupported() bool {
+	return devicesSupported
+}
+
+// ListDevices returns the paths of the TPM devices of the host (eg. /dev/tpmrm0),
+// ordered by name. The kernel resource manager of a TPM is preferred over its
+// raw device, like the automatic detection does.
+func ListDevices() ([]string, error) {
+	return listDevices(sysfsTPMRoot)
+}
@@ -0,0 +1,48 @@
+//go:build linux
+
+package tpm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/google/go-tpm/tpm2/transport/linuxtpm"
+)
+
+const devicesSupported = true
+
+// listDevices returns the TPM devices registered under root (see [ListDevices]).
+// TPM 1.2 devices, which expose capabilities in sysfs, are skipped.
+func listDevices(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list TPM devices: %w", err)
+	}
+	var devices []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "tpm") || strings.HasPrefix(entry.Name(), "tpmrm") {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, "caps")); err == nil {
+			continue
+		}
+		device := filepath.Join("/dev", entry.Name())
+		if rm, err := os.ReadDir(filepath.Join(dir, "device", "tpmrm")); err == nil && len(rm) > 0 {
+			device = filepath.Join("/dev", rm[0].Name())
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// openDevice opens the TPM device at path (eg. /dev/tpmrm0).
+func openDevice(path string) (transport.TPMCloser, error) {
+	return linuxtpm.Open(path)
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package tpm
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestListDevices(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	mkdir := func(parts ...string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(append([]string{root}, parts...)...), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// tpm0 has a resource manager, tpm1 (eg. virtual) doesn't
+	mkdir("tpm0", "device", "tpmrm", "tpmrm0")
+	mkdir("tpmrm0")
+	mkdir("tpm1")
+	// TPM 1.2 devices are skipped
+	mkdir("tpm2")
+	if err := os.WriteFile(filepath.Join(root, "tpm2", "caps"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := listDevices(root)
+	if err != nil {
+		t.Fatalf("listDevices() error = %v", err)
+	}
+	if want := []string{"/dev/tpmrm0", "/dev/tpm1"}; !slices.Equal(devices, want) {
+		t.Errorf("listDevices() = %v, want %v", devices, want)
+	}
+
+	devices, err = listDevices(filepath.Join(root, "missing"))
+	if err != nil || len(devices) != 0 {
+		t.Errorf("listDevices(missing) = %v, %v, want no device", devices, err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package tpm
+
+import "github.com/google/go-tpm/tpm2/transport"
+
+const devicesSupported = false
+
+func listDevices(string) ([]string, error) {
+	return nil, ErrDevicesNotSupported
+}
+
+func openDevice(string) (transport.TPMCloser, error) {
+	return nil, ErrDevicesNotSupported
+}
@@ -5,10 +5,14 @@ import (
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"slices"
 	"strings"
 	"time"
@@ -18,8 +22,11 @@ import (
 	"github.com/loicsikidi/attest"
 	"github.com/loicsikidi/attest/endorsement"
 	"github.com/loicsikidi/attest/info"
+	"github.com/loicsikidi/go-tpm-kit/tpmutil"
 	goutils "github.com/loicsikidi/go-utils"
 
+	"github.com/loicsikidi/tpm-trust/internal/ekfile"
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
 	"github.com/loicsikidi/tpm-trust/internal/log"
 	"github.com/loicsikidi/tpm-trust/internal/logutil"
 )
@@ -48,6 +55,17 @@ const (
 	KeyTypeUnknown     KeyType = "unknown"
 )
 
+// maxEKCertResponseSize is the maximum size of a manufacturer's EK certificate service response.
+const maxEKCertResponseSize = 64 << 10 // 64 KiB
+
+// DefaultDownloadTimeout is the default timeout of a single EK certificate
+// download, see [TPMConfig.DownloadTimeout].
+const DefaultDownloadTimeout = 10 * time.Second
+
+// rsaProgressInterval is the interval between two progress logs
+// while an RSA key pair is generated in the TPM.
+const rsaProgressInterval = 3 * time.Second
+
 // validKeyTypes contains all supported key types for validation.
 var validKeyTypes = []KeyType{
 	KeyTypeRSA2048,
@@ -64,6 +82,46 @@ type TPMConfig struct {
 	KeyType KeyType
 	// If true, skip matching the public key during EK certificate search for faster operation
 	SkipPublicMatching bool
+	// Source is where EK certificates are read from first (default: [SourceNV]).
+	// Whichever source is selected, the other one is used as a fallback
+	// when the platform supports it.
+	Source Source
+	// HttpClient is used to fetch the EK certificate from the manufacturer's URL.
+	// If nil, [http.DefaultClient] is used.
+	HttpClient httpClient
+	// DownloadTimeout is the timeout of a single EK certificate download
+	// from the manufacturer's URL.
+	//
+	// Optional. If zero, [DefaultDownloadTimeout] is used.
+	DownloadTimeout time.Duration
+	// Selector, if set, selects the EK certificate to use among the ones available
+	// in NV storage instead of the automatic preference order.
+	// It is only supported by [SearchEKCertificate] with [SourceNV].
+	Selector *CertSelector
+	// WaitForTPM is how long to keep trying to open the TPM when it is not ready yet.
+	// If zero, only busy devices are retried (briefly).
+	WaitForTPM time.Duration
+	// Device is the path of the TPM device to open (eg. /dev/tpmrm1), which
+	// is needed when the host has several TPMs (see [ListDevices]).
+	//
+	// Optional. If empty, the first available TPM is opened. Only supported on Linux.
+	Device string
+	// NVReadSize, if set, is the number of bytes read from the NV index of
+	// the EK certificate instead of the size of its public area. It is an
+	// escape hatch for TPMs reporting a wrong size (eg. "structure is the
+	// wrong size" errors) and bypasses the NV reads of the TPM library.
+	NVReadSize int
+	// EndorsementAuth is the authorization value of the endorsement hierarchy,
+	// needed to generate the EK when it is not empty (see [ErrEndorsementAuth]).
+	//
+	// Optional. If nil, the empty authorization value is used.
+	EndorsementAuth []byte
+	// OnCertificateURL, if set, is called with the URL of the EK certificate
+	// derived from the EK public key (AMD and Intel fTPMs), before it is logged
+	// or fetched: the URL identifies the TPM (eg. to redact it).
+	//
+	// Optional.
+	OnCertificateURL func(url string)
 	// Use only in tests
 	TPM transport.TPMCloser
 }
@@ -72,6 +130,37 @@ func (c *TPMConfig) CheckAndSetDefaults() error {
 	if c.Logger == nil {
 		c.Logger = log.New(log.WithNoop())
 	}
+	if c.HttpClient == nil {
+		c.HttpClient = http.DefaultClient
+	}
+	if c.DownloadTimeout < 0 {
+		return fmt.Errorf("invalid download timeout: %s (must be positive)", c.DownloadTimeout)
+	}
+	if c.DownloadTimeout == 0 {
+		c.DownloadTimeout = DefaultDownloadTimeout
+	}
+	if c.WaitForTPM < 0 {
+		return fmt.Errorf("invalid wait duration: %s (must be positive)", c.WaitForTPM)
+	}
+	if c.Source == "" {
+		c.Source = SourceNV
+	}
+	if !slices.Contains(validSources, c.Source) {
+		validSrcs := goutils.Map(validSources, Source.String)
+		return fmt.Errorf("invalid source: %s (must be one of: %s)", c.Source, strings.Join(validSrcs, ", "))
+	}
+	if c.Source == SourcePCP && !PCPSupported() {
+		return ErrPCPNotSupported
+	}
+	if c.Device != "" && !DevicesSupported() {
+		return ErrDevicesNotSupported
+	}
+	if c.NVReadSize < 0 || c.NVReadSize > math.MaxUint16 {
+		return fmt.Errorf("invalid NV read size: %d (must be positive and at most %d bytes)", c.NVReadSize, math.MaxUint16)
+	}
+	if c.Selector != nil && (c.Source != SourceNV || c.KeyType != "") {
+		return fmt.Errorf("certificate selector cannot be combined with a key type or another source than %s", SourceNV)
+	}
 
 	if c.KeyType != "" {
 		if !slices.Contains(validKeyTypes, c.KeyType) {
@@ -86,6 +175,27 @@ func (c *TPMConfig) CheckAndSetDefaults() error {
 type EKResponse struct {
 	EK           endorsement.EK
 	Manufacturer info.Manufacturer
+	// Type is the kind of TPM, guessed from its manufacturer (see [ClassifyType]).
+	Type Type
+	// Available lists the EK certificates found in the TPM NV storage,
+	// including the ones which were not selected.
+	Available []CertificateLocation
+}
+
+// CertificateLocation describes an EK certificate stored in the TPM NV storage.
+type CertificateLocation struct {
+	KeyType KeyType
+	// Index is the NV index holding the certificate.
+	Index tpm2.TPMHandle
+}
+
+// locations returns the location of the EK certificates of the templates.
+func locations(templates []attest.EKCertTemplate) []CertificateLocation {
+	var locs []CertificateLocation
+	for _, t := range templates {
+		locs = append(locs, CertificateLocation{KeyType: findKeyType(t.Public), Index: t.Index})
+	}
+	return locs
 }
 
 // EKInfo contains information about an available EK certificate.
@@ -101,7 +211,7 @@ type EKCertsResponse struct {
 
 // GetEKCertificates retrieves all available Endorsement Key (EK) certificates from the TPM.
 // It opens the TPM device, searches for all available EK certificates, and returns them.
-func GetEKCertificates(cfg TPMConfig) (*EKCertsResponse, error) {
+func GetEKCertificates(ctx context.Context, cfg TPMConfig) (*EKCertsResponse, error) {
 	if err := cfg.CheckAndSetDefaults(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
@@ -111,7 +221,7 @@ func GetEKCertificates(cfg TPMConfig) (*EKCertsResponse, error) {
 	defer logger.DecreasePadding()
 
 	logger.Debug("open connection to TPM")
-	tpm, err := attest.OpenTPM(attest.OpenConfig{Transport: cfg.TPM})
+	tpm, err := openSession(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open TPM: %w", err)
 	}
@@ -133,7 +243,7 @@ func GetEKCertificates(cfg TPMConfig) (*EKCertsResponse, error) {
 	var ekInfos []EKInfo
 	for _, ek := range ekCerts {
 		ekInfos = append(ekInfos, EKInfo{
-			KeyType: findKeyTypeFromCert(ek.Certificate),
+			KeyType: KeyTypeFromCert(ek.Certificate),
 			EK:      ek,
 		})
 	}
@@ -152,7 +262,7 @@ func GetEKCertificates(cfg TPMConfig) (*EKCertsResponse, error) {
 // It differs from [GetEKCertificates] because it will ensure:
 //   - cert is bound to TPM (public key matches TPM key)
 //   - search logic is blazingly fast
-func SearchEKCertificate(cfg TPMConfig) (*EKResponse, error) {
+func SearchEKCertificate(ctx context.Context, cfg TPMConfig) (*EKResponse, error) {
 	if err := cfg.CheckAndSetDefaults(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
@@ -162,7 +272,7 @@ func SearchEKCertificate(cfg TPMConfig) (*EKResponse, error) {
 	defer logger.DecreasePadding()
 
 	logger.Debug("open connection to TPM")
-	tpm, err := attest.OpenTPM(attest.OpenConfig{Transport: cfg.TPM})
+	tpm, err := openSession(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open TPM: %w", err)
 	}
@@ -179,18 +289,22 @@ func SearchEKCertificate(cfg TPMConfig) (*EKResponse, error) {
 		return nil, fmt.Errorf("failed to get TPM info: %w", err)
 	}
 	logger.WithField("id", info.Manufacturer.ASCII).Infof("manufacturer: %q", info.Manufacturer.Name)
+	logger.WithField("type", ClassifyType(info)).Debug("TPM type")
 
-	ek, err := search(logger, tpm, info)
+	ek, available, err := search(ctx, logger, tpm, info, cfg)
 	if err != nil {
-		return nil, err
+		return nil, checkLockout(logger, tpm.Tpm(), err)
 	}
-	return &EKResponse{EK: ek, Manufacturer: info.Manufacturer}, nil
+	return &EKResponse{EK: ek, Manufacturer: info.Manufacturer, Type: ClassifyType(info), Available: locations(available)}, nil
 }
 
 // search looks for an Endorsement Key (EK) certificate in the NVRAM of the TPM.
-// If no certificates are found in NV, it falls back to fetching from the
-// manufacturer's EK certificate URL (supported for AMD and Intel).
-func search(logger log.Logger, tpm *attest.TPM, tpmInfo *info.TPMInfo) (endorsement.EK, error) {
+// If no certificates are found in NV, it falls back to the Platform Crypto Provider
+// (Windows only) and then to fetching from the manufacturer's EK certificate URL
+// (supported for AMD and Intel). With [SourcePCP], the Platform Crypto Provider is
+// searched first. With a [CertSelector], the selected NV certificate is used.
+// The templates of the certificates available in NV are returned along with the selected EK.
+func search(ctx context.Context, logger log.Logger, tpm *session, tpmInfo *info.TPMInfo, cfg TPMConfig) (endorsement.EK, []attest.EKCertTemplate, error) {
 	// Objective: be the fastest possible because the app is user visible.
 	// In order to achieve that, we:
 	// 1. Search available EK certs in TPM (using nv indices)
@@ -199,19 +313,42 @@ func search(logger log.Logger, tpm *attest.TPM, tpmInfo *info.TPMInfo) (endorsem
 	//   3.a Try ECC first (faster key generation)
 	//   3.b Fallback to RSA if ECC is not available
 	logger.Info("start searching for EK certificates")
+	if cfg.Source == SourcePCP {
+		ek, err := searchPCP(logger, tpm, tpmInfo, "")
+		if err == nil {
+			return ek, nil, nil
+		}
+		logger.WithError(err).Warn("no EK certificate found in platform crypto provider, falling back to NV")
+	}
 	availableCerts := endorsement.SearchAvailableCertificates(tpm.Tpm())
 	if len(availableCerts) == 0 {
+		if cfg.Selector != nil {
+			return endorsement.EK{}, nil, fmt.Errorf("%w: %s (no EK certificate in NV storage)", ErrNoCertSelected, cfg.Selector)
+		}
+		if ek, ok := fallbackToPCP(logger, tpm, tpmInfo, cfg.Source); ok {
+			return ek, nil, nil
+		}
 		logger.Debug("no EK certificates found in NV, falling back to manufacturer EK cert URL")
-		return fetchEKCertFromURL(logger, tpm, tpmInfo)
+		ek, err := fetchEKCertFromURL(ctx, logger, tpm, tpmInfo, cfg.HttpClient, cfg.DownloadTimeout, cfg.OnCertificateURL, defaultURLTemplates)
+		return ek, nil, err
 	}
 	logger.Infof("found %d EK certificate(s):", len(availableCerts))
 	logutil.LogWithPadding(logger, func() {
 		for _, t := range availableCerts {
 			logger.WithField("kty", findKeyType(t.Public)).
+				WithField("index", fmt.Sprintf("0x%X", t.Index)).
 				Info("certificate")
 		}
 	})
 
+	if cfg.Selector != nil {
+		ek, err := getSelectedEK(ctx, logger, tpm, availableCerts, cfg.Selector)
+		if err != nil {
+			return endorsement.EK{}, nil, err
+		}
+		return ek, availableCerts, nil
+	}
+
 	templates := tpm.PersistedEKs()
 	var (
 		ek     endorsement.EK
@@ -226,9 +363,9 @@ func search(logger log.Logger, tpm *attest.TPM, tpmInfo *info.TPMInfo) (endorsem
 					Debug("handle")
 			}
 		})
-		ek, errGet = tpm.EK(attest.GetEKCertConfig{Template: templates[0]})
+		ek, errGet = getNVEK(tpm, attest.GetEKCertConfig{Template: templates[0]})
 		if errGet != nil {
-			return endorsement.EK{}, fmt.Errorf("failed to get EK from persisted handle: %w", errGet)
+			return endorsement.EK{}, nil, fmt.Errorf("failed to get EK from persisted handle: %w", errGet)
 		}
 	case len(templates) == 0:
 		logger.Debug("no persisted handles found")
@@ -241,26 +378,57 @@ func search(logger log.Logger, tpm *attest.TPM, tpmInfo *info.TPMInfo) (endorsem
 			break
 		}
 		if errors.Is(errGet, attest.ErrEKCertNotFound) {
-			logger.Debug("no ECC certificate found, trying RSA")
+			logger.WithError(errGet).Debug("no ECC certificate found, trying RSA")
 			logger.WithField("reason", `for security reasons, the key pair associated
 with the certificate is regenerated in the TPM
 to ensure proper binding. Unfortunately, RSA key
 generation is computationally expensive.`).
 				Warn("can take a bit of time...")
+			errECC := errGet
+			stop := logutil.LogProgress(ctx, logger, rsaProgressInterval, "still generating RSA key pair...")
 			ek, errGet = getEK(tpm, tpm2.TPMAlgRSA, availableCerts)
+			stop()
 			if errGet != nil {
-				return endorsement.EK{}, fmt.Errorf("failed to get any EK cert: %w", errGet)
+				if errECC != attest.ErrEKCertNotFound {
+					// the ECC certificate is present but unusable (eg. malformed)
+					return endorsement.EK{}, nil, fmt.Errorf("failed to get any EK cert: %w (ECC: %w)", errGet, errECC)
+				}
+				return endorsement.EK{}, nil, fmt.Errorf("failed to get any EK cert: %w", errGet)
 			}
 			logger.Debug("found RSA certificate")
+			break
 		}
-		return endorsement.EK{}, fmt.Errorf("failed to get EK ECC cert: %w", errGet)
+		return endorsement.EK{}, nil, fmt.Errorf("failed to get EK ECC cert: %w", errGet)
+	}
+	logger.WithField("issuer", ek.Certificate.Issuer).
+		Infof("select %s certificate", KeyTypeFromCert(ek.Certificate))
+	return ek, availableCerts, nil
+}
+
+// getSelectedEK returns the EK of the available certificate matching selector.
+func getSelectedEK(ctx context.Context, logger log.Logger, tpm *session, availableCerts []attest.EKCertTemplate, selector *CertSelector) (endorsement.EK, error) {
+	template, err := selectTemplate(tpm.TPM, availableCerts, selector)
+	if err != nil {
+		return endorsement.EK{}, err
+	}
+	kty := findKeyType(template.Public)
+	logger.WithField("index", fmt.Sprintf("0x%X", template.Index)).
+		Debugf("%s certificate selected by %q", kty, selector)
+
+	if template.Type() == tpm2.TPMAlgRSA {
+		stop := logutil.LogProgress(ctx, logger, rsaProgressInterval, "still generating RSA key pair...")
+		defer stop()
+	}
+	ek, err := getNVEK(tpm, attest.GetEKCertConfig{Template: template})
+	if err != nil {
+		return endorsement.EK{}, fmt.Errorf("failed to get selected EK certificate: %w", err)
 	}
 	logger.WithField("issuer", ek.Certificate.Issuer).
-		Infof("select %s certificate", findKeyTypeFromCert(ek.Certificate))
+		Infof("select %s certificate (via %q)", kty, selector)
 	return ek, nil
 }
 
-func getEK(tpm *attest.TPM, alg tpm2.TPMAlgID, availableCerts []attest.EKCertTemplate) (endorsement.EK, error) {
+func getEK(tpm *session, alg tpm2.TPMAlgID, availableCerts []attest.EKCertTemplate) (endorsement.EK, error) {
 	if slices.ContainsFunc(availableCerts, func(t attest.EKCertTemplate) bool {
 		return t.Type() == alg
 	}) {
@@ -271,37 +439,119 @@ func getEK(tpm *attest.TPM, alg tpm2.TPMAlgID, availableCerts []attest.EKCertTem
 				break
 			}
 		}
-		return tpm.EK(attest.GetEKCertConfig{Template: template})
+		return getNVEK(tpm, attest.GetEKCertConfig{Template: template})
 	}
 	return endorsement.EK{}, attest.ErrEKCertNotFound
 }
 
-// fetchEKCertFromURL generates an EK public key and fetches the EK certificate
-// from the manufacturer's URL (supported for AMD and Intel fTPMs where the
-// certificate is not pre-provisioned in TPM NV storage).
-// It tries ECC first (faster key generation), then RSA, as both key types may have a URL.
-func fetchEKCertFromURL(logger log.Logger, tpm *attest.TPM, tpmInfo *info.TPMInfo) (endorsement.EK, error) {
-	return fetchEKCertFromURLWithClient(logger, tpm, tpmInfo, http.DefaultClient)
+// getNVEK returns the EK of the NV certificate of cfg.Template. When the
+// certificate cannot be parsed, its raw content is read again to report the
+// NV index, size and first bytes, or to recover a certificate stored in a
+// format the TPM library does not support (eg. PKCS#7).
+//
+// With a NV read size override (see [TPMConfig.NVReadSize]), the raw content
+// is read directly.
+//
+// The EK is generated by the session, hence at most once per template.
+func getNVEK(tpm *session, cfg attest.GetEKCertConfig) (endorsement.EK, error) {
+	tpmInfo, err := tpm.Info()
+	if err != nil {
+		return endorsement.EK{}, fmt.Errorf("failed to get TPM info: %w", err)
+	}
+	var ek endorsement.EK
+	if tpm.nvReadSize > 0 {
+		tpm.logger.WithField("index", fmt.Sprintf("0x%X", cfg.Template.Index)).
+			WithField("size", tpm.nvReadSize).
+			Warn("reading EK certificate with a manual NV read size override")
+		ek, err = getRawNVEK(tpm, cfg.Template, tpmInfo, nil)
+	} else {
+		ek, err = tpm.EK(attest.GetEKCertConfig{Template: cfg.Template, SkipPublicMatching: true, Info: tpmInfo})
+		if errors.Is(err, attest.ErrEKCertNotFound) {
+			ek, err = getRawNVEK(tpm, cfg.Template, tpmInfo, err)
+		}
+	}
+	if err != nil || cfg.SkipPublicMatching {
+		return ek, err
+	}
+
+	generated, err := tpm.generateEK(cfg.Template, tpmInfo)
+	if err != nil {
+		return endorsement.EK{}, fmt.Errorf("failed to get EK public key: %w", err)
+	}
+	ek.Public = generated.Public
+	if !cfg.SkipCheck {
+		if err := ek.Check(); err != nil {
+			return endorsement.EK{}, fmt.Errorf("%w: EK certificate validation failed for NV index 0x%X: %w", endorsement.ErrUntrustedEK, cfg.Template.Index, err)
+		}
+	}
+	return ek, nil
 }
 
-// fetchEKCertFromURLWithClient is the internal implementation with an injectable HTTP client.
-func fetchEKCertFromURLWithClient(logger log.Logger, tpm *attest.TPM, tpmInfo *info.TPMInfo, client httpClient) (endorsement.EK, error) {
-	// Try ECC first (faster key generation), then RSA. AMD and Intel compute cert URLs for both key types.
+// getRawNVEK parses the raw content of the NV certificate of template, which
+// the TPM library failed to read with notFoundErr. If notFoundErr is nil, the
+// size of the content is overridden (see [TPMConfig.NVReadSize]) and read
+// errors are reported as is.
+func getRawNVEK(tpm *session, template attest.EKCertTemplate, tpmInfo *info.TPMInfo, notFoundErr error) (endorsement.EK, error) {
+	if notFoundErr == nil {
+		data, err := readNVPrefix(tpm.Tpm(), template.Index, tpm.nvReadSize)
+		if err != nil {
+			return endorsement.EK{}, err
+		}
+		return parseRawNVEK(template, tpmInfo, data)
+	}
+	data, readErr := tpmutil.NVRead(tpm.Tpm(), tpmutil.NVReadConfig{Index: template.Index})
+	if readErr != nil || len(data) == 0 {
+		// the certificate is missing rather than malformed
+		return endorsement.EK{}, notFoundErr
+	}
+	return parseRawNVEK(template, tpmInfo, data)
+}
+
+// parseRawNVEK returns the EK of the raw NV certificate of template.
+func parseRawNVEK(template attest.EKCertTemplate, tpmInfo *info.TPMInfo, data []byte) (endorsement.EK, error) {
+	cert, parseErr := ekfile.Parse(data)
+	if parseErr != nil {
+		return endorsement.EK{}, fmt.Errorf("%w: NV index 0x%X: %w", attest.ErrEKCertNotFound, template.Index, parseErr)
+	}
+	ek := endorsement.EK{Template: template, Certificate: cert}
+	if tpmInfo.HasEKCertChains() {
+		ek.AddChain(tpmInfo.EKCertChains)
+	}
+	return ek, nil
+}
+
+// defaultURLTemplates lists the EK templates tried when fetching the EK certificate
+// from the manufacturer's URL: ECC first (faster key generation), then RSA.
+var defaultURLTemplates = []endorsement.Template{endorsement.TemplateECC, endorsement.TemplateRSA}
+
+// fetchEKCertFromURL generates an EK public key and fetches the EK certificate
+// from the manufacturer's URL (supported for AMD and Intel fTPMs where the
+// certificate is not pre-provisioned in TPM NV storage). For Intel, the URL
+// is keyed by the hash of the EK public key (pubhash).
+// Discrete TPM manufacturers (eg. Nuvoton, Infineon, STMicroelectronics)
+// provision the EK certificate in NV storage and run no such service: no URL
+// is derived for them, hence nothing is fetched.
+// Templates are tried in order, as each key type may have a URL.
+// Each download is bounded by timeout.
+// If set, onURL is called with every URL before it is fetched.
+func fetchEKCertFromURL(ctx context.Context, logger log.Logger, tpm *session, tpmInfo *info.TPMInfo, client httpClient, timeout time.Duration, onURL func(string), templates []endorsement.Template) (endorsement.EK, error) {
 	var lastFetchErr error
-	for _, tmpl := range []endorsement.Template{endorsement.TemplateECC, endorsement.TemplateRSA} {
-		ek, err := endorsement.Get(tpm.Tpm(), endorsement.GetConfig{
-			Template: tmpl,
-			Info:     *tpmInfo,
-		})
+	for _, tmpl := range templates {
+		ek, err := tpm.generateEK(tmpl, tpmInfo)
 		if err != nil || ek.CertificateURL == "" {
 			continue
 		}
+		if onURL != nil {
+			onURL(ek.CertificateURL)
+		}
 
 		logger.WithField("url", ek.CertificateURL).Debug("fetching EK certificate from manufacturer URL")
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		cert, err := fetchCertFromURL(ctx, ek.CertificateURL, client)
+		cert, err := func() (*x509.Certificate, error) {
+			fetchCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return fetchCertFromURL(fetchCtx, ek.CertificateURL, client)
+		}()
 		if err != nil {
 			logger.WithField("url", ek.CertificateURL).Debugf("failed to fetch certificate, trying next template: %v", err)
 			lastFetchErr = err
@@ -309,8 +559,15 @@ func fetchEKCertFromURLWithClient(logger log.Logger, tpm *attest.TPM, tpmInfo *i
 		}
 
 		ek.Certificate = cert
+		// The URL is derived from the EK public key, but nothing guarantees
+		// that the service returned the matching certificate.
+		if err := ek.Check(); err != nil {
+			lastFetchErr = fmt.Errorf("certificate fetched from %s doesn't match the EK: %w", ek.CertificateURL, err)
+			logger.WithField("url", ek.CertificateURL).Debug(lastFetchErr.Error())
+			continue
+		}
 		logger.WithField("issuer", cert.Issuer).
-			Infof("select %s certificate (via URL)", findKeyTypeFromCert(cert))
+			Infof("select %s certificate (via URL)", KeyTypeFromCert(cert))
 		return ek, nil
 	}
 
@@ -320,7 +577,15 @@ func fetchEKCertFromURLWithClient(logger log.Logger, tpm *attest.TPM, tpmInfo *i
 	return endorsement.EK{}, fmt.Errorf("no EK certificates found: TPM NV storage is empty and manufacturer %q did not provide an EK certificate URL for ECC or RSA", tpmInfo.Manufacturer.ASCII)
 }
 
-// fetchCertFromURL fetches a DER-encoded EK certificate from the given URL.
+// intelEKCertResponse is the JSON response of Intel's EK certificate service.
+type intelEKCertResponse struct {
+	PubHash     string `json:"pubhash"`
+	Certificate string `json:"certificate"`
+}
+
+// fetchCertFromURL fetches an EK certificate from the given URL.
+// The response is either the DER-encoded certificate (AMD) or
+// a JSON document embedding it (Intel).
 func fetchCertFromURL(ctx context.Context, certURL string, client httpClient) (*x509.Certificate, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
 	if err != nil {
@@ -337,10 +602,21 @@ func fetchCertFromURL(ctx context.Context, certURL string, client httpClient) (*
 		return nil, fmt.Errorf("unexpected HTTP %d fetching EK certificate from %s", resp.StatusCode, certURL)
 	}
 
-	certData, err := io.ReadAll(resp.Body)
+	// The client may not cap responses (eg. http.DefaultClient)
+	certData, err := io.ReadAll(io.LimitReader(resp.Body, maxEKCertResponseSize+1))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read EK certificate response: %w", err)
 	}
+	if len(certData) > maxEKCertResponseSize {
+		return nil, fmt.Errorf("%w: EK certificate response from %s exceeds %d bytes", httpclient.ErrResponseTooLarge, certURL, maxEKCertResponseSize)
+	}
+
+	if json.Valid(certData) {
+		certData, err = decodeIntelEKCertResponse(certData)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	cert, err := endorsement.ParseEKCertificate(certData)
 	if err != nil {
@@ -350,9 +626,32 @@ func fetchCertFromURL(ctx context.Context, certURL string, client httpClient) (*
 	return cert, nil
 }
 
+// decodeIntelEKCertResponse extracts the DER-encoded certificate of
+// an Intel EK certificate service response.
+//
+// The certificate is base64url encoded, with its padding URL-escaped (eg. %3D).
+func decodeIntelEKCertResponse(data []byte) ([]byte, error) {
+	var resp intelEKCertResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode EK certificate service response: %w", err)
+	}
+	if resp.Certificate == "" {
+		return nil, fmt.Errorf("EK certificate service response has no certificate")
+	}
+	raw, err := url.QueryUnescape(resp.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unescape EK certificate: %w", err)
+	}
+	der, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(raw, "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode EK certificate: %w", err)
+	}
+	return der, nil
+}
+
 // GetEKCertificate retrieves a specific Endorsement Key (EK) certificate by key type.
 // This function doesn't perform any security checks.
-func GetEKCertificate(cfg TPMConfig) (*EKResponse, error) {
+func GetEKCertificate(ctx context.Context, cfg TPMConfig) (*EKResponse, error) {
 	if err := cfg.CheckAndSetDefaults(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
@@ -362,7 +661,7 @@ func GetEKCertificate(cfg TPMConfig) (*EKResponse, error) {
 	defer logger.DecreasePadding()
 
 	logger.Debug("open connection to TPM")
-	tpm, err := attest.OpenTPM(attest.OpenConfig{Transport: cfg.TPM})
+	tpm, err := openSession(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open TPM: %w", err)
 	}
@@ -373,10 +672,34 @@ func GetEKCertificate(cfg TPMConfig) (*EKResponse, error) {
 		}
 	}()
 
+	resp, err := getEKCertificate(ctx, logger, tpm, cfg)
+	if err != nil {
+		return nil, checkLockout(logger, tpm.Tpm(), err)
+	}
+	return resp, nil
+}
+
+func getEKCertificate(ctx context.Context, logger log.Logger, tpm *session, cfg TPMConfig) (*EKResponse, error) {
 	logger.Debugf("searching for %s EK certificate", cfg.KeyType)
+	if cfg.Source == SourcePCP {
+		resp, err := getEKCertificateFromPCP(logger, tpm, cfg)
+		if err == nil {
+			return resp, nil
+		}
+		logger.WithError(err).Warn("no EK certificate found in platform crypto provider, falling back to NV")
+	}
 	availableCerts := endorsement.SearchAvailableCertificates(tpm.Tpm())
 	if len(availableCerts) == 0 {
-		return nil, fmt.Errorf("no EK certificates available in TPM")
+		if cfg.Source == SourceNV && PCPSupported() {
+			logger.Debug("no EK certificates found in NV, falling back to platform crypto provider")
+			resp, err := getEKCertificateFromPCP(logger, tpm, cfg)
+			if err == nil {
+				return resp, nil
+			}
+			logger.WithError(err).Debug("no EK certificate found in platform crypto provider")
+		}
+		logger.Debug("no EK certificates found in NV, falling back to manufacturer EK cert URL")
+		return getEKCertificateFromURL(ctx, logger, tpm, cfg)
 	}
 
 	var targetTemplate *attest.EKCertTemplate
@@ -391,7 +714,7 @@ func GetEKCertificate(cfg TPMConfig) (*EKResponse, error) {
 		return nil, fmt.Errorf("no EK certificate found for key type %s", cfg.KeyType)
 	}
 
-	ek, err := tpm.EK(attest.GetEKCertConfig{
+	ek, err := getNVEK(tpm, attest.GetEKCertConfig{
 		Template:           *targetTemplate,
 		SkipPublicMatching: cfg.SkipPublicMatching,
 	})
@@ -409,9 +732,61 @@ func GetEKCertificate(cfg TPMConfig) (*EKResponse, error) {
 	return &EKResponse{
 		EK:           ek,
 		Manufacturer: info.Manufacturer,
+		Type:         ClassifyType(info),
+		Available:    locations(availableCerts),
 	}, nil
 }
 
+// getEKCertificateFromPCP reads the EK certificate of cfg.KeyType from the Platform Crypto Provider.
+func getEKCertificateFromPCP(logger log.Logger, tpm *session, cfg TPMConfig) (*EKResponse, error) {
+	tpmInfo, err := tpm.Info()
+	if err != nil {
+		return nil, err
+	}
+	ek, err := searchPCP(logger, tpm, tpmInfo, cfg.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	return &EKResponse{EK: ek, Manufacturer: tpmInfo.Manufacturer, Type: ClassifyType(tpmInfo)}, nil
+}
+
+// fallbackToPCP searches the Platform Crypto Provider when NV storage has no
+// EK certificate, unless it was already searched (ie. source is [SourcePCP])
+// or the platform does not support it.
+func fallbackToPCP(logger log.Logger, tpm *session, tpmInfo *info.TPMInfo, source Source) (endorsement.EK, bool) {
+	if source != SourceNV || !PCPSupported() {
+		return endorsement.EK{}, false
+	}
+	logger.Debug("no EK certificates found in NV, falling back to platform crypto provider")
+	ek, err := searchPCP(logger, tpm, tpmInfo, "")
+	if err != nil {
+		logger.WithError(err).Debug("no EK certificate found in platform crypto provider")
+		return endorsement.EK{}, false
+	}
+	return ek, true
+}
+
+// getEKCertificateFromURL fetches the EK certificate of cfg.KeyType from the manufacturer's URL.
+func getEKCertificateFromURL(ctx context.Context, logger log.Logger, tpm *session, cfg TPMConfig) (*EKResponse, error) {
+	tpmInfo, err := tpm.Info()
+	if err != nil {
+		return nil, err
+	}
+	templates := slices.Concat(endorsement.TemplatesByType[tpm2.TPMAlgECC], endorsement.TemplatesByType[tpm2.TPMAlgRSA])
+	idx := slices.IndexFunc(templates, func(t endorsement.Template) bool {
+		return findKeyType(t.Public) == cfg.KeyType
+	})
+	if idx < 0 {
+		return nil, fmt.Errorf("no EK certificates available in TPM")
+	}
+	// Templates sharing the same public area lead to the same URL: only the first one is tried
+	ek, err := fetchEKCertFromURL(ctx, logger, tpm, tpmInfo, cfg.HttpClient, cfg.DownloadTimeout, cfg.OnCertificateURL, templates[idx:idx+1])
+	if err != nil {
+		return nil, err
+	}
+	return &EKResponse{EK: ek, Manufacturer: tpmInfo.Manufacturer, Type: ClassifyType(tpmInfo)}, nil
+}
+
 // findKeyType determines the key type from a [tpm2.TPMTPublic].
 // It returns a [KeyType] describing the key algorithm and size (e.g., [KeyTypeRSA2048], [KeyTypeECCNistP256]).
 // Returns [KeyTypeUnknown] for unsupported key types.
@@ -448,10 +823,10 @@ func findKeyType(public tpm2.TPMTPublic) KeyType {
 	}
 }
 
-// findKeyTypeFromCert determines the key type from an [x509.Certificate].
+// KeyTypeFromCert determines the key type from an [x509.Certificate].
 // It returns a [KeyType] describing the key algorithm and size (e.g., [KeyTypeRSA2048], [KeyTypeECCNistP256]).
 // Returns [KeyTypeUnknown] for unsupported key types.
-func findKeyTypeFromCert(cert *x509.Certificate) KeyType {
+func KeyTypeFromCert(cert *x509.Certificate) KeyType {
 	if cert == nil || cert.PublicKey == nil {
 		return KeyTypeUnknown
 	}
@@ -1,12 +1,21 @@
 package tpm
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/loicsikidi/attest"
 	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/go-tpm-kit/tpmtest"
+	"github.com/loicsikidi/go-tpm-kit/tpmutil"
+
+	"github.com/loicsikidi/tpm-trust/internal/ekfile"
 )
 
 // TestFetchEKCertFromURLOnRealTPM probes whether the manufacturer's EK certificate URL
@@ -65,3 +74,125 @@ func TestFetchEKCertFromURLOnRealTPM(t *testing.T) {
 		})
 	}
 }
+
+func TestTPMConfigDownloadTimeout(t *testing.T) {
+	t.Parallel()
+
+	cfg := TPMConfig{}
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		t.Fatalf("CheckAndSetDefaults() error = %v", err)
+	}
+	if cfg.DownloadTimeout != DefaultDownloadTimeout {
+		t.Errorf("default download timeout = %s, want %s", cfg.DownloadTimeout, DefaultDownloadTimeout)
+	}
+
+	cfg = TPMConfig{DownloadTimeout: -time.Second}
+	if err := cfg.CheckAndSetDefaults(); err == nil {
+		t.Error("CheckAndSetDefaults() expected an error for a negative download timeout")
+	}
+}
+
+func TestSearchEKCertificateMalformed(t *testing.T) {
+	t.Parallel()
+
+	sim := tpmtest.OpenSimulator(t, tpmtest.OpenConfig{
+		SkipProvisioning: true, // disable EK cert provisioning
+		SkipCleanup:      true, // TPM cleanup is handled by the internal code
+	})
+	// truncated DER certificate
+	data := []byte{0x30, 0x82, 0x02, 0x10, 0x30, 0x82, 0x01, 0xB6}
+	if err := tpmutil.NVWrite(sim, tpmutil.NVWriteConfig{Index: tpmtest.ECCCertIndex, Data: data}); err != nil {
+		t.Fatalf("NVWrite() error = %v", err)
+	}
+
+	_, err := SearchEKCertificate(context.Background(), TPMConfig{TPM: sim})
+	var parseErr *ekfile.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("SearchEKCertificate() error = %v, want a *ekfile.ParseError", err)
+	}
+	if parseErr.Size != len(data) {
+		t.Errorf("Size = %d, want %d", parseErr.Size, len(data))
+	}
+	if want := fmt.Sprintf("NV index 0x%X", tpmtest.ECCCertIndex); !strings.Contains(err.Error(), want) {
+		t.Errorf("SearchEKCertificate() error = %q, want it to contain %q", err, want)
+	}
+}
+
+func TestSearchEKCertificateRSAFallback(t *testing.T) {
+	t.Parallel()
+
+	// No ECC certificate: the search falls back to the RSA one
+	sim := tpmtest.OpenSimulator(t, tpmtest.OpenConfig{
+		EKCerts:     []tpmtest.Template{tpmtest.TemplateRSA},
+		SkipCleanup: true, // TPM cleanup is handled by the internal code
+	})
+
+	resp, err := SearchEKCertificate(context.Background(), TPMConfig{TPM: sim})
+	if err != nil {
+		t.Fatalf("SearchEKCertificate() error = %v", err)
+	}
+	if kty := KeyTypeFromCert(resp.EK.Certificate); kty != KeyTypeRSA2048 {
+		t.Errorf("key type = %s, want %s", kty, KeyTypeRSA2048)
+	}
+}
+
+func TestSearchEKCertificateNVReadSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		extra   int // bytes read beyond the certificate
+		wantErr bool
+	}{
+		{name: "exact size"},
+		{name: "beyond index size", extra: 4096, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sim := tpmtest.OpenSimulator(t, tpmtest.OpenConfig{SkipCleanup: true})
+			data, err := tpmutil.NVRead(sim, tpmutil.NVReadConfig{Index: tpmtest.ECCCertIndex})
+			if err != nil {
+				t.Fatalf("NVRead() error = %v", err)
+			}
+
+			resp, err := SearchEKCertificate(context.Background(), TPMConfig{TPM: sim, NVReadSize: len(data) + tc.extra})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("SearchEKCertificate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && !bytes.Equal(resp.EK.Certificate.Raw, data) {
+				t.Error("SearchEKCertificate() returned another certificate")
+			}
+		})
+	}
+}
+
+func TestReadNVPrefix(t *testing.T) {
+	t.Parallel()
+
+	sim := tpmtest.OpenSimulator(t)
+	const index = 0x1500010
+	data := make([]byte, 2*nvReadChunkSize+100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	if err := tpmutil.NVWrite(sim, tpmutil.NVWriteConfig{Index: index, Data: data}); err != nil {
+		t.Fatalf("NVWrite() error = %v", err)
+	}
+
+	for _, size := range []int{10, nvReadChunkSize, len(data)} {
+		got, err := readNVPrefix(sim, index, size)
+		if err != nil {
+			t.Fatalf("readNVPrefix(%d) error = %v", size, err)
+		}
+		if !bytes.Equal(got, data[:size]) {
+			t.Errorf("readNVPrefix(%d) = %d bytes, want the first %d bytes of the index", size, len(got), size)
+		}
+	}
+	if _, err := readNVPrefix(sim, index, len(data)+1); err == nil {
+		t.Error("readNVPrefix() beyond the index size error = nil, want an error")
+	}
+}
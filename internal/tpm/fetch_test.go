@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -67,6 +69,26 @@ func TestFetchCertFromURL(t *testing.T) {
 				_, _ = io.Copy(w, bytes.NewReader(certDER))
 			},
 		},
+		{
+			name: "success/intel-json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_ = json.NewEncoder(w).Encode(intelEKCertResponse{
+					PubHash:     url.QueryEscape(base64.URLEncoding.EncodeToString([]byte("pubhash"))),
+					Certificate: url.QueryEscape(base64.URLEncoding.EncodeToString(certDER)),
+				})
+			},
+		},
+		{
+			name: "error/intel-json-without-certificate",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				_, _ = io.WriteString(w, `{"pubhash":"cHViaGFzaA%3D%3D"}`)
+			},
+			wantErr:     true,
+			errContains: "has no certificate",
+		},
 		{
 			name: "error/http-404",
 			handler: func(w http.ResponseWriter, r *http.Request) {
@@ -83,6 +105,15 @@ func TestFetchCertFromURL(t *testing.T) {
 			wantErr:     true,
 			errContains: "unexpected HTTP 500",
 		},
+		{
+			name: "error/response-too-large",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(bytes.Repeat([]byte{0}, maxEKCertResponseSize+1))
+			},
+			wantErr:     true,
+			errContains: "response too large",
+		},
 		{
 			name: "error/invalid-cert-data",
 			handler: func(w http.ResponseWriter, r *http.Request) {
@@ -1,15 +1,15 @@
 package tpm
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/loicsikidi/attest"
 	"github.com/loicsikidi/attest/info"
 )
 
 // Info retrieves static information about the TPM.
 // It opens the TPM device, retrieves information, and returns it as [info.TPMInfo].
-func Info(cfg TPMConfig) (*info.TPMInfo, error) {
+func Info(ctx context.Context, cfg TPMConfig) (*info.TPMInfo, error) {
 	if err := cfg.CheckAndSetDefaults(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
@@ -19,7 +19,7 @@ func Info(cfg TPMConfig) (*info.TPMInfo, error) {
 	defer logger.ResetPadding()
 
 	logger.Debug("open connection to TPM")
-	tpm, err := attest.OpenTPM(attest.OpenConfig{Transport: cfg.TPM})
+	tpm, err := openTPM(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open TPM: %w", err)
 	}
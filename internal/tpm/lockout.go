@@ -0,0 +1,79 @@
+package tpm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+// ErrLockout is returned when the TPM refuses to generate the EK because
+// its dictionary attack protection is triggered.
+var ErrLockout = codes.New(codes.E041Lockout, "TPM is in lockout; wait or reset the DA counter (eg. tpm2_dictionarylockout --clear-lockout)")
+
+// lockoutStatus is the state of the dictionary attack protection of the TPM.
+type lockoutStatus struct {
+	// Counter is the number of authorization failures.
+	Counter uint32
+	// MaxTries is the number of failures which triggers the lockout.
+	MaxTries uint32
+	// Interval is the time after which a failure is forgiven (0: never).
+	Interval time.Duration
+}
+
+func (s lockoutStatus) String() string {
+	status := fmt.Sprintf("%d/%d authorization failures", s.Counter, s.MaxTries)
+	if s.Interval == 0 {
+		return status + ", failures are never forgiven"
+	}
+	return fmt.Sprintf("%s, retry in up to %s", status, s.Interval)
+}
+
+// readLockoutStatus queries the dictionary attack protection state of the TPM.
+func readLockoutStatus(t transport.TPM) (lockoutStatus, error) {
+	rsp, err := tpm2.GetCapability{
+		Capability:    tpm2.TPMCapTPMProperties,
+		Property:      uint32(tpm2.TPMPTLockoutCounter),
+		PropertyCount: 3,
+	}.Execute(t)
+	if err != nil {
+		return lockoutStatus{}, fmt.Errorf("failed to get lockout properties: %w", err)
+	}
+	props, err := rsp.CapabilityData.Data.TPMProperties()
+	if err != nil {
+		return lockoutStatus{}, fmt.Errorf("failed to parse lockout properties: %w", err)
+	}
+
+	var status lockoutStatus
+	for _, prop := range props.TPMProperty {
+		switch prop.Property {
+		case tpm2.TPMPTLockoutCounter:
+			status.Counter = prop.Value
+		case tpm2.TPMPTMaxAuthFail:
+			status.MaxTries = prop.Value
+		case tpm2.TPMPTLockoutInterval:
+			status.Interval = time.Duration(prop.Value) * time.Second
+		}
+	}
+	return status, nil
+}
+
+// checkLockout wraps err with [ErrLockout], along with the lockout status if
+// it can be read, when err is caused by the dictionary attack protection.
+// Otherwise, err is returned as is.
+func checkLockout(logger log.Logger, t transport.TPM, err error) error {
+	if !errors.Is(err, tpm2.TPMRCLockout) {
+		return err
+	}
+	status, statusErr := readLockoutStatus(t)
+	if statusErr != nil {
+		logger.WithError(statusErr).Debug("failed to read lockout status")
+		return fmt.Errorf("%w: %w", ErrLockout, err)
+	}
+	return fmt.Errorf("%w (%s): %w", ErrLockout, status, err)
+}
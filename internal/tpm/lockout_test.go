@@ -0,0 +1,119 @@
+package tpm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+// capabilityTPM answers TPM2_GetCapability with the given TPM properties.
+type capabilityTPM struct {
+	props map[tpm2.TPMPT]uint32
+	err   error
+}
+
+func (c *capabilityTPM) Send(input []byte) ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	params := binary.BigEndian.AppendUint32([]byte{0}, uint32(tpm2.TPMCapTPMProperties)) // moreData, capability
+	params = binary.BigEndian.AppendUint32(params, uint32(len(c.props)))
+	for _, pt := range []tpm2.TPMPT{tpm2.TPMPTLockoutCounter, tpm2.TPMPTMaxAuthFail, tpm2.TPMPTLockoutInterval} {
+		if v, ok := c.props[pt]; ok {
+			params = binary.BigEndian.AppendUint32(params, uint32(pt))
+			params = binary.BigEndian.AppendUint32(params, v)
+		}
+	}
+	rsp := binary.BigEndian.AppendUint16(nil, uint16(tpm2.TPMSTNoSessions))
+	rsp = binary.BigEndian.AppendUint32(rsp, uint32(10+len(params)))
+	rsp = binary.BigEndian.AppendUint32(rsp, uint32(tpm2.TPMRCSuccess))
+	return append(rsp, params...), nil
+}
+
+func TestReadLockoutStatus(t *testing.T) {
+	t.Parallel()
+
+	got, err := readLockoutStatus(&capabilityTPM{props: map[tpm2.TPMPT]uint32{
+		tpm2.TPMPTLockoutCounter:  32,
+		tpm2.TPMPTMaxAuthFail:     32,
+		tpm2.TPMPTLockoutInterval: 7200,
+	}})
+	if err != nil {
+		t.Fatalf("readLockoutStatus() error = %v", err)
+	}
+	want := lockoutStatus{Counter: 32, MaxTries: 32, Interval: 2 * time.Hour}
+	if got != want {
+		t.Errorf("readLockoutStatus() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckLockout(t *testing.T) {
+	t.Parallel()
+
+	lockoutErr := fmt.Errorf("failed to get EK ECC cert: CreatePrimary failed: %w", tpm2.TPMRCLockout)
+	tests := []struct {
+		name        string
+		err         error
+		tpm         *capabilityTPM
+		wantLockout bool
+		wantStatus  string
+	}{
+		{
+			name: "other error",
+			err:  errors.New("no EK certificate found"),
+			tpm:  &capabilityTPM{},
+		},
+		{
+			name: "lockout",
+			err:  lockoutErr,
+			tpm: &capabilityTPM{props: map[tpm2.TPMPT]uint32{
+				tpm2.TPMPTLockoutCounter:  3,
+				tpm2.TPMPTMaxAuthFail:     3,
+				tpm2.TPMPTLockoutInterval: 600,
+			}},
+			wantLockout: true,
+			wantStatus:  "3/3 authorization failures, retry in up to 10m0s",
+		},
+		{
+			name: "lockout without forgiveness",
+			err:  lockoutErr,
+			tpm: &capabilityTPM{props: map[tpm2.TPMPT]uint32{
+				tpm2.TPMPTLockoutCounter: 3,
+				tpm2.TPMPTMaxAuthFail:    3,
+			}},
+			wantLockout: true,
+			wantStatus:  "failures are never forgiven",
+		},
+		{
+			name:        "lockout with unreadable status",
+			err:         lockoutErr,
+			tpm:         &capabilityTPM{err: errors.New("device busy")},
+			wantLockout: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkLockout(log.New(log.WithNoop()), tc.tpm, tc.err)
+			if !errors.Is(err, tc.err) {
+				t.Errorf("checkLockout() = %v, must wrap %v", err, tc.err)
+			}
+			if got := errors.Is(err, ErrLockout); got != tc.wantLockout {
+				t.Fatalf("errors.Is(ErrLockout) = %v, want %v (err: %v)", got, tc.wantLockout, err)
+			}
+			if !strings.Contains(err.Error(), tc.wantStatus) {
+				t.Errorf("checkLockout() = %q, want status %q", err, tc.wantStatus)
+			}
+		})
+	}
+}
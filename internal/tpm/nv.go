@@ -0,0 +1,227 @@
+package tpm
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/go-tpm-kit/tpmutil"
+)
+
+// NVIndex holds the public area of an NV index along with its raw contents.
+type NVIndex struct {
+	Index tpm2.TPMHandle
+	// NameAlg is the algorithm used to compute the name of the index.
+	NameAlg tpm2.TPMAlgID
+	// Attributes are the TPMA_NV attributes of the index.
+	Attributes tpm2.TPMANV
+	// DataSize is the size of the data area, as declared in the public area.
+	DataSize uint16
+	// Data is the raw contents of the index (nil if the index was never written).
+	Data []byte
+}
+
+// RawAttributes returns the TPMA_NV attributes as a 32-bit value.
+func (n *NVIndex) RawAttributes() uint32 {
+	return binary.BigEndian.Uint32(tpm2.Marshal(n.Attributes))
+}
+
+// AttributeNames returns the names of the TPMA_NV attributes which are set (eg. "OwnerRead").
+func (n *NVIndex) AttributeNames() []string {
+	var names []string
+	v := reflect.ValueOf(n.Attributes)
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if field.IsExported() && field.Type.Kind() == reflect.Bool && v.Field(i).Bool() {
+			names = append(names, field.Name)
+		}
+	}
+	return names
+}
+
+// ReadNV reads the public area and the raw contents of an NV index, without
+// interpreting them. It is meant to troubleshoot EK certificates which
+// cannot be parsed.
+func ReadNV(ctx context.Context, cfg TPMConfig, index tpm2.TPMHandle) (*NVIndex, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	logger := cfg.Logger
+	logger.IncreasePadding()
+	defer logger.DecreasePadding()
+
+	logger.Debug("open connection to TPM")
+	tpm, err := openTPM(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open TPM: %w", err)
+	}
+	defer func() {
+		logger.Debug("closing connection to TPM")
+		if closeErr := tpm.Close(); closeErr != nil {
+			err = fmt.Errorf("failed to close TPM: %w (original error: %v)", closeErr, err)
+		}
+	}()
+
+	nv, err := readNVPublic(tpm.Tpm(), index)
+	if err != nil {
+		return nil, err
+	}
+	if !nv.Attributes.Written {
+		logger.Warn("NV index was never written")
+		return nv, nil
+	}
+
+	// EK certificate indices are readable with the owner authorization,
+	// other indices may only be readable with their own authorization
+	hierarchy := tpm2.TPMRHOwner
+	if !nv.Attributes.OwnerRead && nv.Attributes.AuthRead {
+		hierarchy = index
+	}
+	nv.Data, err = tpmutil.NVRead(tpm.Tpm(), tpmutil.NVReadConfig{
+		Index:     index,
+		Hierarchy: hierarchy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NV index 0x%X: %w", uint32(index), err)
+	}
+	return nv, nil
+}
+
+// ListNV returns the public area of every NV index defined in the TPM, ordered
+// by index. The contents are not read (Data is nil), which makes it possible to
+// review the provisioned layout even when some indices cannot be read.
+func ListNV(ctx context.Context, cfg TPMConfig) ([]NVIndex, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	logger := cfg.Logger
+	logger.IncreasePadding()
+	defer logger.DecreasePadding()
+
+	logger.Debug("open connection to TPM")
+	tpm, err := openTPM(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open TPM: %w", err)
+	}
+	defer func() {
+		logger.Debug("closing connection to TPM")
+		if closeErr := tpm.Close(); closeErr != nil {
+			err = fmt.Errorf("failed to close TPM: %w (original error: %v)", closeErr, err)
+		}
+	}()
+
+	handles, err := nvHandles(tpm.Tpm())
+	if err != nil {
+		return nil, err
+	}
+	indices := make([]NVIndex, 0, len(handles))
+	for _, handle := range handles {
+		nv, err := readNVPublic(tpm.Tpm(), handle)
+		if err != nil {
+			return nil, err
+		}
+		indices = append(indices, *nv)
+	}
+	return indices, nil
+}
+
+// EKCertKeyType returns the key type of the EK whose certificate is expected
+// at this index by the TCG EK Credential Profile (eg. rsa-2048 for 0x1C00002),
+// or false if the index is not an EK certificate index.
+func (n *NVIndex) EKCertKeyType() (KeyType, bool) {
+	for _, templates := range endorsement.TemplatesByType {
+		for _, template := range templates {
+			if template.Index == n.Index {
+				return findKeyType(template.Public), true
+			}
+		}
+	}
+	return "", false
+}
+
+// nvReadChunkSize is the maximum number of bytes read by a single TPM2_NV_Read,
+// which is below the TPM_PT_NV_BUFFER_MAX of every TPM (usually 1024).
+const nvReadChunkSize = 512
+
+// readNVPrefix reads the first size bytes of an NV index with the owner
+// authorization, whatever the size declared in its public area, in chunks
+// of at most [nvReadChunkSize] bytes.
+func readNVPrefix(t transport.TPM, index tpm2.TPMHandle, size int) ([]byte, error) {
+	rsp, err := tpm2.NVReadPublic{NVIndex: index}.Execute(t)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public area of NV index 0x%X: %w", uint32(index), err)
+	}
+	data := make([]byte, 0, size)
+	for len(data) < size {
+		chunk := min(size-len(data), nvReadChunkSize)
+		readRsp, err := tpm2.NVRead{
+			AuthHandle: tpm2.AuthHandle{Handle: tpm2.TPMRHOwner, Auth: tpm2.PasswordAuth(nil)},
+			NVIndex:    tpm2.NamedHandle{Handle: index, Name: rsp.NVName},
+			Size:       uint16(chunk),
+			Offset:     uint16(len(data)),
+		}.Execute(t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %d bytes at offset %d of NV index 0x%X: %w", chunk, len(data), uint32(index), err)
+		}
+		if len(readRsp.Data.Buffer) == 0 {
+			return nil, fmt.Errorf("failed to read NV index 0x%X: no data at offset %d", uint32(index), len(data))
+		}
+		data = append(data, readRsp.Data.Buffer...)
+	}
+	return data, nil
+}
+
+// readNVPublic reads the public area of an NV index.
+func readNVPublic(t transport.TPM, index tpm2.TPMHandle) (*NVIndex, error) {
+	rsp, err := tpm2.NVReadPublic{NVIndex: index}.Execute(t)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public area of NV index 0x%X: %w", uint32(index), err)
+	}
+	pub, err := rsp.NVPublic.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public area of NV index 0x%X: %w", uint32(index), err)
+	}
+	return &NVIndex{
+		Index:      index,
+		NameAlg:    pub.NameAlg,
+		Attributes: pub.Attributes,
+		DataSize:   pub.DataSize,
+	}, nil
+}
+
+// nvHandles returns the handles of the NV indices defined in the TPM.
+func nvHandles(t transport.TPM) ([]tpm2.TPMHandle, error) {
+	var handles []tpm2.TPMHandle
+	property := uint32(tpm2.TPMHTNVIndex) << 24
+	for {
+		rsp, err := tpm2.GetCapability{
+			Capability:    tpm2.TPMCapHandles,
+			Property:      property,
+			PropertyCount: 64,
+		}.Execute(t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list NV indices: %w", err)
+		}
+		list, err := rsp.CapabilityData.Data.Handles()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse NV indices: %w", err)
+		}
+		for _, handle := range list.Handle {
+			// The TPM returns the handles which follow the NV index range as well
+			if tpm2.TPMHT(handle>>24) != tpm2.TPMHTNVIndex {
+				return handles, nil
+			}
+			handles = append(handles, handle)
+		}
+		if !rsp.MoreData || len(list.Handle) == 0 {
+			return handles, nil
+		}
+		property = uint32(list.Handle[len(list.Handle)-1]) + 1
+	}
+}
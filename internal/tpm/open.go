@@ -0,0 +1,107 @@
+package tpm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/loicsikidi/attest"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+const (
+	// maxOpenRetries is the maximum number of attempts to open a busy TPM.
+	maxOpenRetries = 5
+	// openRetryInitialDelay is the delay before the first retry, doubled after each attempt.
+	openRetryInitialDelay = 100 * time.Millisecond
+	// openRetryMaxDelay caps the delay between two attempts.
+	openRetryMaxDelay = time.Second
+	// waitPollInterval is the interval between two attempts to open a TPM which is not ready yet.
+	waitPollInterval = 500 * time.Millisecond
+)
+
+// openTPM opens a connection to the TPM described by cfg: cfg.Device if set,
+// otherwise the first available TPM.
+//
+// When the device is temporarily held by another process (eg. tpm2-abrmd),
+// the operation is retried with an exponential backoff bounded by ctx.
+// The original error is returned if retries are exhausted.
+//
+// If cfg.WaitForTPM is set, any failure is retried until the duration elapses.
+func openTPM(ctx context.Context, cfg TPMConfig) (*attest.TPM, error) {
+	open := func() (*attest.TPM, error) {
+		if cfg.TPM == nil && cfg.Device != "" {
+			rwc, err := openDevice(cfg.Device)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open %s: %w", cfg.Device, err)
+			}
+			return attest.OpenTPM(attest.OpenConfig{Transport: rwc})
+		}
+		return attest.OpenTPM(attest.OpenConfig{Transport: cfg.TPM})
+	}
+	if cfg.WaitForTPM > 0 {
+		return waitForTPM(ctx, cfg.Logger, cfg.WaitForTPM, waitPollInterval, open)
+	}
+	return openWithRetry(ctx, cfg.Logger, open)
+}
+
+// waitForTPM polls open until it succeeds or wait elapses
+// (eg. at boot, while the TPM device is not available yet).
+func waitForTPM(ctx context.Context, logger log.Logger, wait, interval time.Duration, open func() (*attest.TPM, error)) (*attest.TPM, error) {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		tpm, err := openWithRetry(ctx, logger, open)
+		if err == nil {
+			return tpm, nil
+		}
+		if attempt == 1 {
+			logger.WithError(err).Infof("TPM is not ready, waiting up to %s", wait)
+		} else {
+			logger.WithError(err).
+				WithField("attempt", attempt).
+				Debug("TPM is still not ready")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("TPM not ready after %s: %w", wait, err)
+		case <-time.After(interval):
+		}
+	}
+}
+
+func openWithRetry(ctx context.Context, logger log.Logger, open func() (*attest.TPM, error)) (*attest.TPM, error) {
+	delay := openRetryInitialDelay
+	for attempt := 1; ; attempt++ {
+		tpm, err := open()
+		if err == nil || !isBusyError(err) || attempt >= maxOpenRetries {
+			return tpm, err
+		}
+
+		logger.WithError(err).
+			WithField("attempt", attempt).
+			Debugf("TPM is busy, retrying in %s", delay)
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, openRetryMaxDelay)
+	}
+}
+
+// isBusyError reports whether err indicates that the TPM is temporarily
+// unavailable because it is used by another process.
+func isBusyError(err error) bool {
+	return errors.Is(err, syscall.EBUSY) ||
+		errors.Is(err, tpm2.TPMRCRetry) ||
+		errors.Is(err, tpm2.TPMRCYielded) ||
+		errors.Is(err, tpm2.TPMRCTesting)
+}
@@ -0,0 +1,132 @@
+package tpm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/loicsikidi/attest"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+func TestIsBusyError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "EBUSY", err: fmt.Errorf("open /dev/tpmrm0: %w", syscall.EBUSY), want: true},
+		{name: "TPM_RC_RETRY", err: tpm2.TPMRCRetry, want: true},
+		{name: "TPM_RC_YIELDED", err: tpm2.TPMRCYielded, want: true},
+		{name: "permission denied", err: syscall.EACCES, want: false},
+		{name: "TPM not available", err: attest.ErrTPMNotAvailable, want: false},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := isBusyError(tc.err); got != tc.want {
+				t.Errorf("isBusyError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOpenWithRetry(t *testing.T) {
+	t.Parallel()
+
+	logger := log.New(log.WithNoop())
+
+	t.Run("succeeds once device is released", func(t *testing.T) {
+		t.Parallel()
+
+		calls := 0
+		_, err := openWithRetry(context.Background(), logger, func() (*attest.TPM, error) {
+			calls++
+			if calls < 3 {
+				return nil, syscall.EBUSY
+			}
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("openWithRetry() error = %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 attempts, got %d", calls)
+		}
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		t.Parallel()
+
+		calls := 0
+		_, err := openWithRetry(context.Background(), logger, func() (*attest.TPM, error) {
+			calls++
+			return nil, attest.ErrTPMNotAvailable
+		})
+		if !errors.Is(err, attest.ErrTPMNotAvailable) {
+			t.Fatalf("openWithRetry() error = %v, want %v", err, attest.ErrTPMNotAvailable)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 attempt, got %d", calls)
+		}
+	})
+
+	t.Run("returns original error when context expires", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := openWithRetry(ctx, logger, func() (*attest.TPM, error) {
+			return nil, syscall.EBUSY
+		})
+		if !errors.Is(err, syscall.EBUSY) {
+			t.Fatalf("openWithRetry() error = %v, want %v", err, syscall.EBUSY)
+		}
+	})
+}
+
+func TestWaitForTPM(t *testing.T) {
+	t.Parallel()
+
+	logger := log.New(log.WithNoop())
+
+	t.Run("succeeds once device is available", func(t *testing.T) {
+		t.Parallel()
+
+		calls := 0
+		_, err := waitForTPM(context.Background(), logger, time.Second, time.Millisecond, func() (*attest.TPM, error) {
+			calls++
+			if calls < 3 {
+				return nil, attest.ErrTPMNotAvailable
+			}
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("waitForTPM() error = %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 attempts, got %d", calls)
+		}
+	})
+
+	t.Run("returns last error when duration elapses", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := waitForTPM(context.Background(), logger, 50*time.Millisecond, 10*time.Millisecond, func() (*attest.TPM, error) {
+			return nil, attest.ErrTPMNotAvailable
+		})
+		if !errors.Is(err, attest.ErrTPMNotAvailable) {
+			t.Fatalf("waitForTPM() error = %v, want %v", err, attest.ErrTPMNotAvailable)
+		}
+	})
+}
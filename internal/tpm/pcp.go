@@ -0,0 +1,109 @@
+package tpm
+
+import (
+	"crypto/x509"
+	"fmt"
+	"slices"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/attest/info"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+// Source represents where EK certificates are read from.
+type Source string
+
+func (s Source) String() string {
+	return string(s)
+}
+
+const (
+	// SourceNV reads EK certificates from the TPM NV storage.
+	SourceNV Source = "nv"
+	// SourcePCP reads EK certificates through the Windows Platform Crypto Provider (NCrypt).
+	SourcePCP Source = "pcp"
+)
+
+// validSources contains all supported sources for validation.
+var validSources = []Source{SourceNV, SourcePCP}
+
+var ErrPCPNotSupported = codes.New(codes.E042PCPUnsupported, "platform crypto provider is only available on Windows")
+
+// Overridden in tests.
+var readPCPCertificates = pcpCertificates
+
+// PCPSupported reports whether the Platform Crypto Provider is available on this platform.
+func PCPSupported() bool {
+	return pcpSupported
+}
+
+// searchPCP looks for an EK certificate exposed by the Platform Crypto Provider.
+// If keyType is set, only certificates of this key type are considered.
+//
+// As nothing guarantees that the provider exposes the certificate of this TPM,
+// the associated EK is generated in the TPM to ensure proper binding.
+func searchPCP(logger log.Logger, tpm *session, tpmInfo *info.TPMInfo, keyType KeyType) (endorsement.EK, error) {
+	certs, err := readPCPCertificates()
+	if err != nil {
+		return endorsement.EK{}, fmt.Errorf("failed to read EK certificates from platform crypto provider: %w", err)
+	}
+	logger.Debugf("found %d EK certificate(s) in platform crypto provider", len(certs))
+
+	// ECC first because key generation is faster
+	slices.SortStableFunc(certs, func(a, b *x509.Certificate) int {
+		return pcpPriority(a) - pcpPriority(b)
+	})
+	for _, cert := range certs {
+		kty := KeyTypeFromCert(cert)
+		if keyType != "" && kty != keyType {
+			continue
+		}
+		ek, err := bindEK(tpm, tpmInfo, cert)
+		if err != nil {
+			logger.WithField("kty", kty).Debugf("skipping certificate: %v", err)
+			continue
+		}
+		logger.WithField("issuer", cert.Issuer).
+			Infof("select %s certificate (via platform crypto provider)", kty)
+		return ek, nil
+	}
+	if keyType != "" {
+		return endorsement.EK{}, fmt.Errorf("no %s EK certificate of the platform crypto provider matches the TPM", keyType)
+	}
+	return endorsement.EK{}, fmt.Errorf("no EK certificate of the platform crypto provider matches the TPM")
+}
+
+func pcpPriority(cert *x509.Certificate) int {
+	if slices.Contains(eccKeyTypes, KeyTypeFromCert(cert)) {
+		return 0
+	}
+	return 1
+}
+
+// eccKeyTypes lists the ECC key types.
+var eccKeyTypes = []KeyType{KeyTypeECCNistP256, KeyTypeECCNistP384, KeyTypeECCNistP521, KeyTypeECCSM2P256}
+
+// bindEK generates the EK matching the key type of cert and ensures
+// that cert certifies it.
+func bindEK(tpm *session, tpmInfo *info.TPMInfo, cert *x509.Certificate) (endorsement.EK, error) {
+	kty := KeyTypeFromCert(cert)
+	templates := slices.Concat(endorsement.TemplatesByType[tpm2.TPMAlgECC], endorsement.TemplatesByType[tpm2.TPMAlgRSA])
+	idx := slices.IndexFunc(templates, func(t endorsement.Template) bool {
+		return findKeyType(t.Public) == kty
+	})
+	if idx < 0 {
+		return endorsement.EK{}, fmt.Errorf("unsupported key type: %s", kty)
+	}
+	ek, err := tpm.generateEK(templates[idx], tpmInfo)
+	if err != nil {
+		return endorsement.EK{}, fmt.Errorf("failed to generate %s EK: %w", kty, err)
+	}
+	ek.Certificate = cert
+	if err := ek.Check(); err != nil {
+		return endorsement.EK{}, fmt.Errorf("certificate doesn't match the EK: %w", err)
+	}
+	return ek, nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package tpm
+
+import "crypto/x509"
+
+const pcpSupported = false
+
+func pcpCertificates() ([]*x509.Certificate, error) {
+	return nil, ErrPCPNotSupported
+}
@@ -0,0 +1,74 @@
+package tpm
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+func TestSearchPCP(t *testing.T) {
+	// Not parallel: readPCPCertificates is overridden
+
+	tests := []struct {
+		name    string
+		read    func() ([]*x509.Certificate, error)
+		keyType KeyType
+		wantErr error
+	}{
+		{
+			name:    "provider unavailable",
+			read:    func() ([]*x509.Certificate, error) { return nil, ErrPCPNotSupported },
+			wantErr: ErrPCPNotSupported,
+		},
+		{
+			name: "no certificate",
+			read: func() ([]*x509.Certificate, error) { return nil, nil },
+		},
+		{
+			name:    "no certificate of the key type",
+			read:    func() ([]*x509.Certificate, error) { return []*x509.Certificate{{}}, nil },
+			keyType: KeyTypeRSA2048,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			orig := readPCPCertificates
+			t.Cleanup(func() { readPCPCertificates = orig })
+			readPCPCertificates = tc.read
+
+			_, err := searchPCP(log.New(log.WithNoop()), nil, nil, tc.keyType)
+			if err == nil {
+				t.Fatal("searchPCP() expected an error")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("searchPCP() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestTPMConfigSource(t *testing.T) {
+	t.Parallel()
+
+	cfg := TPMConfig{}
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		t.Fatalf("CheckAndSetDefaults() error = %v", err)
+	}
+	if cfg.Source != SourceNV {
+		t.Errorf("default source = %q, want %q", cfg.Source, SourceNV)
+	}
+
+	cfg = TPMConfig{Source: "registry"}
+	if err := cfg.CheckAndSetDefaults(); err == nil {
+		t.Error("CheckAndSetDefaults() expected an error for an unknown source")
+	}
+
+	cfg = TPMConfig{Source: SourcePCP}
+	err := cfg.CheckAndSetDefaults()
+	if PCPSupported() != (err == nil) {
+		t.Errorf("CheckAndSetDefaults() error = %v, PCP supported = %t", err, PCPSupported())
+	}
+}
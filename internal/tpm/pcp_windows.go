@@ -0,0 +1,120 @@
+//go:build windows
+
+package tpm
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/loicsikidi/attest/endorsement"
+	"golang.org/x/sys/windows"
+)
+
+const pcpSupported = true
+
+const (
+	// msPlatformCryptoProvider is the name of the Platform Crypto Provider (MS_PLATFORM_CRYPTO_PROVIDER).
+	msPlatformCryptoProvider = "Microsoft Platform Crypto Provider"
+
+	// Provider properties whose value is a handle to a certificate store holding EK certificates.
+	// See ncrypt.h (NCRYPT_PCP_EKCERT_PROPERTY and NCRYPT_PCP_EKNVCERT_PROPERTY).
+	pcpEKCertProperty   = "PCP_EKCERT"
+	pcpEKNVCertProperty = "PCP_EKNVCERT"
+)
+
+var (
+	ncrypt                        = windows.NewLazySystemDLL("ncrypt.dll")
+	procNCryptOpenStorageProvider = ncrypt.NewProc("NCryptOpenStorageProvider")
+	procNCryptGetProperty         = ncrypt.NewProc("NCryptGetProperty")
+	procNCryptFreeObject          = ncrypt.NewProc("NCryptFreeObject")
+)
+
+// pcpCertificates returns the EK certificates exposed by the Platform Crypto Provider.
+func pcpCertificates() ([]*x509.Certificate, error) {
+	if err := ncrypt.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load ncrypt.dll: %w", err)
+	}
+
+	name, err := windows.UTF16PtrFromString(msPlatformCryptoProvider)
+	if err != nil {
+		return nil, err
+	}
+	var provider uintptr
+	if r, _, _ := procNCryptOpenStorageProvider.Call(uintptr(unsafe.Pointer(&provider)), uintptr(unsafe.Pointer(name)), 0); r != 0 {
+		return nil, fmt.Errorf("failed to open platform crypto provider: %w", windows.Errno(r))
+	}
+	defer procNCryptFreeObject.Call(provider) //nolint:errcheck
+
+	var certs []*x509.Certificate
+	for _, property := range []string{pcpEKCertProperty, pcpEKNVCertProperty} {
+		found, err := storeCertificates(provider, property)
+		if err != nil {
+			return nil, err
+		}
+		for _, cert := range found {
+			if !containsCert(certs, cert) {
+				certs = append(certs, cert)
+			}
+		}
+	}
+	return certs, nil
+}
+
+// storeCertificates returns the certificates of the store referenced by property.
+// A missing property is not an error.
+func storeCertificates(provider uintptr, property string) ([]*x509.Certificate, error) {
+	prop, err := windows.UTF16PtrFromString(property)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		store windows.Handle
+		size  uint32
+	)
+	r, _, _ := procNCryptGetProperty.Call(
+		provider,
+		uintptr(unsafe.Pointer(prop)),
+		uintptr(unsafe.Pointer(&store)),
+		unsafe.Sizeof(store),
+		uintptr(unsafe.Pointer(&size)),
+		0,
+	)
+	if r != 0 {
+		if windows.Errno(r) == windows.Errno(windows.NTE_NOT_FOUND) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get %s property: %w", property, windows.Errno(r))
+	}
+	defer windows.CertCloseStore(store, 0) //nolint:errcheck
+
+	var (
+		certs   []*x509.Certificate
+		certCtx *windows.CertContext
+	)
+	for {
+		certCtx, err = windows.CertEnumCertificatesInStore(store, certCtx)
+		if certCtx == nil {
+			if errors.Is(err, windows.Errno(windows.CRYPT_E_NOT_FOUND)) {
+				return certs, nil
+			}
+			return nil, fmt.Errorf("failed to enumerate %s certificates: %w", property, err)
+		}
+		cert, parseErr := endorsement.ParseEKCertificate(bytes.Clone(unsafe.Slice(certCtx.EncodedCert, certCtx.Length)))
+		if parseErr != nil {
+			continue
+		}
+		certs = append(certs, cert)
+	}
+}
+
+func containsCert(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
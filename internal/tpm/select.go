@@ -0,0 +1,91 @@
+package tpm
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/loicsikidi/attest"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+var ErrNoCertSelected = codes.New(codes.E040NoCertSelected, "no EK certificate matches the selector")
+
+// CertSelector selects an EK certificate among the ones available in NV storage,
+// instead of the automatic preference order. Exactly one criterion is set.
+type CertSelector struct {
+	// Index is the NV index holding the certificate.
+	Index tpm2.TPMHandle
+	// Position is the 1-based position of the certificate in the list of available certificates.
+	Position int
+	// Serial is the serial number of the certificate.
+	Serial *big.Int
+
+	raw string
+}
+
+// ParseCertSelector parses an EK certificate selector, which is either:
+//   - an NV index in hexadecimal (eg. "0x1C0000A");
+//   - the 1-based position of the certificate among the available ones (eg. "2");
+//   - a serial number, in decimal or hexadecimal (eg. "serial:1234" or "serial:0x4D2").
+func ParseCertSelector(s string) (*CertSelector, error) {
+	sel := &CertSelector{raw: s}
+	switch {
+	case strings.HasPrefix(s, "serial:"):
+		serial, ok := new(big.Int).SetString(strings.TrimPrefix(s, "serial:"), 0)
+		if !ok || serial.Sign() < 0 {
+			return nil, fmt.Errorf("invalid certificate selector %q: invalid serial number", s)
+		}
+		sel.Serial = serial
+	case strings.HasPrefix(strings.ToLower(s), "0x"):
+		index, err := strconv.ParseUint(s[2:], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate selector %q: invalid NV index", s)
+		}
+		sel.Index = tpm2.TPMHandle(index)
+	default:
+		position, err := strconv.Atoi(s)
+		if err != nil || position < 1 {
+			return nil, fmt.Errorf("invalid certificate selector %q (must be an NV index, a position or serial:<number>)", s)
+		}
+		sel.Position = position
+	}
+	return sel, nil
+}
+
+func (s *CertSelector) String() string {
+	return s.raw
+}
+
+// selectTemplate returns the template of the available certificate matching s.
+// Certificates are read from NV storage (without generating their key) only when
+// selecting by serial number.
+func selectTemplate(tpm *attest.TPM, availableCerts []attest.EKCertTemplate, s *CertSelector) (attest.EKCertTemplate, error) {
+	switch {
+	case s.Position > 0:
+		if s.Position <= len(availableCerts) {
+			return availableCerts[s.Position-1], nil
+		}
+		return attest.EKCertTemplate{}, fmt.Errorf("%w: %s (%d certificate(s) available)", ErrNoCertSelected, s, len(availableCerts))
+	case s.Serial != nil:
+		for _, t := range availableCerts {
+			ek, err := tpm.EK(attest.GetEKCertConfig{Template: t, SkipPublicMatching: true, SkipCheck: true})
+			if err != nil || ek.Certificate == nil {
+				continue
+			}
+			if ek.Certificate.SerialNumber.Cmp(s.Serial) == 0 {
+				return t, nil
+			}
+		}
+	default:
+		for _, t := range availableCerts {
+			if t.Index == s.Index {
+				return t, nil
+			}
+		}
+	}
+	return attest.EKCertTemplate{}, fmt.Errorf("%w: %s", ErrNoCertSelected, s)
+}
@@ -0,0 +1,98 @@
+package tpm
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/loicsikidi/attest"
+	"github.com/loicsikidi/attest/endorsement"
+)
+
+func TestParseCertSelector(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    CertSelector
+		wantErr bool
+	}{
+		{name: "NV index", input: "0x1C0000A", want: CertSelector{Index: 0x1C0000A}},
+		{name: "lowercase NV index", input: "0x1c0000a", want: CertSelector{Index: 0x1C0000A}},
+		{name: "position", input: "2", want: CertSelector{Position: 2}},
+		{name: "decimal serial", input: "serial:1234", want: CertSelector{Serial: big.NewInt(1234)}},
+		{name: "hexadecimal serial", input: "serial:0x4D2", want: CertSelector{Serial: big.NewInt(1234)}},
+		{name: "invalid NV index", input: "0xZZ", wantErr: true},
+		{name: "zero position", input: "0", wantErr: true},
+		{name: "invalid serial", input: "serial:abc", wantErr: true},
+		{name: "unknown selector", input: "ecc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseCertSelector(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseCertSelector(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Index != tc.want.Index || got.Position != tc.want.Position {
+				t.Errorf("ParseCertSelector(%q) = %+v, want %+v", tc.input, got, tc.want)
+			}
+			if (got.Serial == nil) != (tc.want.Serial == nil) || (got.Serial != nil && got.Serial.Cmp(tc.want.Serial) != 0) {
+				t.Errorf("ParseCertSelector(%q) serial = %v, want %v", tc.input, got.Serial, tc.want.Serial)
+			}
+			if got.String() != tc.input {
+				t.Errorf("String() = %q, want %q", got.String(), tc.input)
+			}
+		})
+	}
+}
+
+func TestSelectTemplate(t *testing.T) {
+	t.Parallel()
+
+	ecc := endorsement.TemplateECC
+	ecc.Index = tpm2.TPMHandle(0x1C0000A)
+	rsa := endorsement.TemplateRSA
+	rsa.Index = tpm2.TPMHandle(0x1C00002)
+	available := []attest.EKCertTemplate{rsa, ecc}
+
+	tests := []struct {
+		name      string
+		selector  string
+		wantIndex tpm2.TPMHandle
+		wantErr   error
+	}{
+		{name: "by NV index", selector: "0x1C0000A", wantIndex: ecc.Index},
+		{name: "by position", selector: "1", wantIndex: rsa.Index},
+		{name: "unknown NV index", selector: "0x1C00016", wantErr: ErrNoCertSelected},
+		{name: "position out of range", selector: "3", wantErr: ErrNoCertSelected},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sel, err := ParseCertSelector(tc.selector)
+			if err != nil {
+				t.Fatalf("ParseCertSelector() error = %v", err)
+			}
+			// The TPM is only used to select by serial number
+			got, err := selectTemplate(nil, available, sel)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("selectTemplate() error = %v, want %v", err, tc.wantErr)
+			}
+			if err == nil && got.Index != tc.wantIndex {
+				t.Errorf("selectTemplate() index = 0x%X, want 0x%X", got.Index, tc.wantIndex)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package tpm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/loicsikidi/attest"
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/attest/info"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+// maxTransientHandles is the number of transient handles listed at once.
+const maxTransientHandles = 64
+
+// session is a connection to the TPM for the lifetime of a single operation
+// (eg. [SearchEKCertificate]). It caches the EKs generated from a template, so
+// that an expensive key generation (eg. RSA) happens at most once even if the
+// certificates of several sources (NV, PCP, URL) are matched against the same EK.
+type session struct {
+	*attest.TPM
+	logger log.Logger
+	// eks holds the generated EKs (without certificate), by template public area.
+	eks map[string]endorsement.EK
+	// transients are the transient objects loaded before the session was opened.
+	transients []tpm2.TPMHandle
+	// nvReadSize overrides the size of the EK certificates read from NV (see [TPMConfig.NVReadSize]).
+	nvReadSize int
+	// endorsementAuth is the authorization value of the endorsement hierarchy (see [TPMConfig.EndorsementAuth]).
+	endorsementAuth []byte
+}
+
+// openSession opens a connection to the TPM (see [openTPM]).
+// It must be closed with [session.Close].
+func openSession(ctx context.Context, cfg TPMConfig) (*session, error) {
+	tpm, err := openTPM(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	transients, err := transientHandles(tpm.Tpm())
+	if err != nil {
+		cfg.Logger.WithError(err).Debug("failed to list transient objects")
+	}
+	return &session{TPM: tpm, logger: cfg.Logger, eks: make(map[string]endorsement.EK), transients: transients, nvReadSize: cfg.NVReadSize, endorsementAuth: cfg.EndorsementAuth}, nil
+}
+
+// generateEK returns the EK of template, generating it in the TPM
+// on first use. The returned EK has no certificate.
+func (s *session) generateEK(template endorsement.Template, tpmInfo *info.TPMInfo) (endorsement.EK, error) {
+	key := string(tpm2.Marshal(template.Public))
+	if ek, ok := s.eks[key]; ok {
+		s.logger.WithField("kty", findKeyType(template.Public)).Debug("reusing generated EK")
+		return ek, nil
+	}
+	cfg := endorsement.GetConfig{Template: template, Info: *tpmInfo}
+	ek, err := s.getEK(cfg)
+	if errors.Is(err, tpm2.TPMRCObjectMemory) {
+		// Objects leaked by previous runs (eg. killed while generating a key)
+		// stay loaded until flushed when no resource manager is used
+		s.logger.WithError(err).Warn("no more object slots in the TPM, flushing transient objects")
+		if flushErr := s.flushTransients(nil); flushErr != nil {
+			s.logger.WithError(flushErr).Debug("failed to flush transient objects")
+		}
+		s.transients = nil
+		ek, err = s.getEK(cfg)
+	}
+	if err != nil {
+		return endorsement.EK{}, err
+	}
+	s.eks[key] = ek
+	return ek, nil
+}
+
+// Close flushes the transient objects left by the session, if any,
+// then closes the connection to the TPM.
+func (s *session) Close() error {
+	return errors.Join(s.flushTransients(s.transients), s.TPM.Close())
+}
+
+// flushTransients flushes the transient objects loaded in the TPM, except the ones of keep.
+func (s *session) flushTransients(keep []tpm2.TPMHandle) error {
+	handles, err := transientHandles(s.Tpm())
+	if err != nil {
+		s.logger.WithError(err).Debug("failed to list transient objects")
+		return nil
+	}
+	var flushErr error
+	for _, handle := range handles {
+		if slices.Contains(keep, handle) {
+			continue
+		}
+		s.logger.WithField("handle", fmt.Sprintf("0x%X", uint32(handle))).Debug("flushing transient object")
+		if _, err := (tpm2.FlushContext{FlushHandle: handle}).Execute(s.Tpm()); err != nil {
+			flushErr = errors.Join(flushErr, fmt.Errorf("failed to flush transient object 0x%X: %w", uint32(handle), err))
+		}
+	}
+	return flushErr
+}
+
+// transientHandles lists the transient objects loaded in the TPM.
+func transientHandles(t transport.TPM) ([]tpm2.TPMHandle, error) {
+	rsp, err := tpm2.GetCapability{
+		Capability:    tpm2.TPMCapHandles,
+		Property:      uint32(tpm2.TPMHTTransient) << 24,
+		PropertyCount: maxTransientHandles,
+	}.Execute(t)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transient handles: %w", err)
+	}
+	handles, err := rsp.CapabilityData.Data.Handles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse transient handles: %w", err)
+	}
+	return handles.Handle, nil
+}
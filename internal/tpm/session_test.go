@@ -0,0 +1,194 @@
+package tpm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/go-tpm-kit/tpmtest"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+// recordingTPM records the command codes sent to the TPM.
+type recordingTPM struct {
+	transport.TPMCloser
+	commands []tpm2.TPMCC
+}
+
+func (r *recordingTPM) Send(cmd []byte) ([]byte, error) {
+	if len(cmd) >= 10 {
+		r.commands = append(r.commands, tpm2.TPMCC(binary.BigEndian.Uint32(cmd[6:10])))
+	}
+	return r.TPMCloser.Send(cmd)
+}
+
+func (r *recordingTPM) count(cc tpm2.TPMCC) int {
+	n := 0
+	for _, c := range r.commands {
+		if c == cc {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSessionGenerateEKOnce(t *testing.T) {
+	t.Parallel()
+
+	sim := &recordingTPM{TPMCloser: tpmtest.OpenSimulator(t, tpmtest.OpenConfig{SkipProvisioning: true, SkipCleanup: true})}
+	s, err := openSession(context.Background(), TPMConfig{TPM: sim, Logger: log.New(log.WithNoop())})
+	if err != nil {
+		t.Fatalf("openSession() error = %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	tpmInfo, err := s.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := sim.count(tpm2.TPMCCCreatePrimary)
+	first, err := s.generateEK(endorsement.TemplateECC, tpmInfo)
+	if err != nil {
+		t.Fatalf("generateEK() error = %v", err)
+	}
+	second, err := s.generateEK(endorsement.TemplateECC, tpmInfo)
+	if err != nil {
+		t.Fatalf("generateEK() error = %v", err)
+	}
+	if got := sim.count(tpm2.TPMCCCreatePrimary) - before; got != 1 {
+		t.Errorf("EK generated %d time(s), want 1", got)
+	}
+	if !slices.Equal(tpm2.Marshal(first.Public), tpm2.Marshal(second.Public)) {
+		t.Error("generateEK() returned another EK")
+	}
+}
+
+func TestSessionGenerateEKWithEndorsementAuth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		auth    []byte
+		wantErr error
+	}{
+		{name: "success", auth: []byte("secret")},
+		{name: "error/missing-auth", wantErr: ErrEndorsementAuth},
+		{name: "error/wrong-auth", auth: []byte("wrong"), wantErr: ErrEndorsementAuth},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sim := tpmtest.OpenSimulator(t, tpmtest.OpenConfig{SkipProvisioning: true, SkipCleanup: true})
+			_, err := tpm2.HierarchyChangeAuth{
+				AuthHandle: tpm2.AuthHandle{Handle: tpm2.TPMRHEndorsement, Auth: tpm2.PasswordAuth(nil)},
+				NewAuth:    tpm2.TPM2BAuth{Buffer: []byte("secret")},
+			}.Execute(sim)
+			if err != nil {
+				t.Fatalf("HierarchyChangeAuth() error = %v", err)
+			}
+			s, err := openSession(context.Background(), TPMConfig{TPM: sim, Logger: log.New(log.WithNoop()), EndorsementAuth: tc.auth})
+			if err != nil {
+				t.Fatalf("openSession() error = %v", err)
+			}
+			t.Cleanup(func() { _ = s.Close() })
+			tpmInfo, err := s.Info()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ek, err := s.generateEK(endorsement.TemplateECC, tpmInfo)
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Fatalf("generateEK() error = %v, want %v", err, tc.wantErr)
+			}
+			if err == nil && ek.Public == nil {
+				t.Error("generateEK() returned an EK without public area")
+			}
+		})
+	}
+}
+
+func TestSessionCloseFlushesTransients(t *testing.T) {
+	t.Parallel()
+
+	sim := &recordingTPM{TPMCloser: tpmtest.OpenSimulator(t, tpmtest.OpenConfig{SkipProvisioning: true, SkipCleanup: true})}
+	s, err := openSession(context.Background(), TPMConfig{TPM: sim, Logger: log.New(log.WithNoop())})
+	if err != nil {
+		t.Fatalf("openSession() error = %v", err)
+	}
+	// Leak a transient object
+	rsp, err := tpm2.CreatePrimary{
+		PrimaryHandle: tpm2.TPMRHEndorsement,
+		InPublic:      tpm2.New2B(endorsement.TemplateECC.Public),
+	}.Execute(s.Tpm())
+	if err != nil {
+		t.Fatalf("CreatePrimary() error = %v", err)
+	}
+
+	before := sim.count(tpm2.TPMCCFlushContext)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := sim.count(tpm2.TPMCCFlushContext) - before; got != 1 {
+		t.Errorf("%d object(s) flushed, want 1 (handle 0x%X)", got, uint32(rsp.ObjectHandle))
+	}
+}
+
+func TestSearchEKCertificateGeneratesEKOnce(t *testing.T) {
+	t.Parallel()
+
+	sim := &recordingTPM{TPMCloser: tpmtest.OpenSimulator(t, tpmtest.OpenConfig{SkipCleanup: true})}
+	resp, err := SearchEKCertificate(context.Background(), TPMConfig{TPM: sim})
+	if err != nil {
+		t.Fatalf("SearchEKCertificate() error = %v", err)
+	}
+	if resp.EK.Public == nil || resp.EK.Check() != nil {
+		t.Error("SearchEKCertificate() returned an EK which does not match its certificate")
+	}
+	if got := sim.count(tpm2.TPMCCCreatePrimary); got > 1 {
+		t.Errorf("EK generated %d times, want at most 1", got)
+	}
+}
+
+func TestSessionGenerateEKWithoutObjectSlots(t *testing.T) {
+	t.Parallel()
+
+	sim := &recordingTPM{TPMCloser: tpmtest.OpenSimulator(t, tpmtest.OpenConfig{SkipProvisioning: true, SkipCleanup: true})}
+	// Leak transient objects (eg. previous runs which were killed) until the TPM is full
+	for {
+		_, err := tpm2.CreatePrimary{
+			PrimaryHandle: tpm2.TPMRHOwner,
+			InPublic:      tpm2.New2B(endorsement.TemplateECC.Public),
+		}.Execute(sim)
+		if errors.Is(err, tpm2.TPMRCObjectMemory) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("CreatePrimary() error = %v", err)
+		}
+	}
+
+	s, err := openSession(context.Background(), TPMConfig{TPM: sim, Logger: log.New(log.WithNoop())})
+	if err != nil {
+		t.Fatalf("openSession() error = %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	tpmInfo, err := s.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.generateEK(endorsement.TemplateECC, tpmInfo); err != nil {
+		t.Fatalf("generateEK() error = %v", err)
+	}
+	if sim.count(tpm2.TPMCCFlushContext) == 0 {
+		t.Error("leaked transient objects were not flushed")
+	}
+}
@@ -0,0 +1,71 @@
+package tpm
+
+import (
+	"strings"
+
+	"github.com/loicsikidi/attest/info"
+)
+
+// Type is the kind of implementation of a TPM, which matters to assess the
+// protection of its keys beyond the validity of its EK certificate.
+type Type string
+
+func (t Type) String() string {
+	return string(t)
+}
+
+const (
+	// TypeDiscrete is a dedicated hardware chip (eg. Infineon, Nuvoton).
+	TypeDiscrete Type = "discrete"
+	// TypeFirmware is a TPM running in the firmware of the main processor (eg. AMD fTPM, Intel PTT).
+	TypeFirmware Type = "firmware"
+	// TypeVirtual is a TPM emulated in software (eg. hypervisor vTPM, simulator).
+	TypeVirtual Type = "virtual"
+	// TypeUnknown is used when the manufacturer does not tell the implementation.
+	TypeUnknown Type = "unknown"
+)
+
+// manufacturerTypes maps the manufacturer IDs (as ASCII) to the kind of TPM they ship.
+var manufacturerTypes = map[string]Type{
+	"AMD":  TypeFirmware,
+	"INTC": TypeFirmware,
+	"QCOM": TypeFirmware,
+	"GOOG": TypeVirtual,
+	"IBM":  TypeVirtual,
+	"ATML": TypeDiscrete,
+	"BRCM": TypeDiscrete,
+	"FLYS": TypeDiscrete,
+	"IFX":  TypeDiscrete,
+	"NSG":  TypeDiscrete,
+	"NSM":  TypeDiscrete,
+	"NTC":  TypeDiscrete,
+	"NTZ":  TypeDiscrete,
+	"SEAL": TypeDiscrete,
+	"SECE": TypeDiscrete,
+	"SMSC": TypeDiscrete,
+	"SNS":  TypeDiscrete,
+	"STM":  TypeDiscrete,
+	"TXN":  TypeDiscrete,
+	"WEC":  TypeDiscrete,
+}
+
+// ClassifyType guesses the kind of TPM from its manufacturer and vendor string.
+// TPMs don't report it, so the result is a hint and [TypeUnknown] is returned
+// when the manufacturer ships several kinds (eg. Microsoft).
+func ClassifyType(tpmInfo *info.TPMInfo) Type {
+	vendor := strings.Join(strings.Fields(tpmInfo.Vendor), " ")
+	switch {
+	// The TCG reference implementation is used by simulators and hypervisors
+	case vendor == "xCG fTPM", vendor == "SW TPM":
+		return TypeVirtual
+	case strings.HasPrefix(tpmInfo.Manufacturer.ASCII, "SIM"), strings.HasPrefix(tpmInfo.Manufacturer.ASCII, "TST"):
+		return TypeVirtual
+	}
+	if t, ok := manufacturerTypes[tpmInfo.Manufacturer.ASCII]; ok {
+		return t
+	}
+	if strings.Contains(vendor, "fTPM") {
+		return TypeFirmware
+	}
+	return TypeUnknown
+}
@@ -0,0 +1,42 @@
+package tpm
+
+import (
+	"testing"
+
+	"github.com/loicsikidi/attest/info"
+)
+
+func TestClassifyType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		vendor       string
+		manufacturer string
+		want         Type
+	}{
+		{name: "infineon", vendor: "SLB9670", manufacturer: "IFX", want: TypeDiscrete},
+		{name: "nuvoton", vendor: "rls NPCT 75x", manufacturer: "NTC", want: TypeDiscrete},
+		{name: "amd ftpm", vendor: "AMD", manufacturer: "AMD", want: TypeFirmware},
+		{name: "intel ptt", vendor: "Intel", manufacturer: "INTC", want: TypeFirmware},
+		{name: "other ftpm", vendor: "ACME fTPM", manufacturer: "MSFT", want: TypeFirmware},
+		{name: "reference implementation", vendor: "xCG fTPM", manufacturer: "MSFT", want: TypeVirtual},
+		{name: "swtpm", vendor: "SW   TPM", manufacturer: "IBM", want: TypeVirtual},
+		{name: "google vtpm", vendor: "vTPM", manufacturer: "GOOG", want: TypeVirtual},
+		{name: "simulator", vendor: "", manufacturer: "SIM0", want: TypeVirtual},
+		{name: "unknown", vendor: "", manufacturer: "MSFT", want: TypeUnknown},
+	}
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tpmInfo := &info.TPMInfo{
+				Vendor:       tc.vendor,
+				Manufacturer: info.Manufacturer{ASCII: tc.manufacturer},
+			}
+			if got := ClassifyType(tpmInfo); got != tc.want {
+				t.Errorf("ClassifyType() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
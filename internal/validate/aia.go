@@ -0,0 +1,69 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+var (
+	// ErrSelfSignedEK is returned when the EK certificate is signed by its own
+	// key: it is self-contained and cannot chain to a manufacturer root.
+	ErrSelfSignedEK = codes.New(codes.E027EKSelfSigned, "EK certificate is self-signed")
+	// ErrMissingAIA is returned when a certificate has no AIA issuing
+	// certificate URL while its issuer is needed: either its issuer could not
+	// be found elsewhere (the chain structurally cannot be built), or the AIA
+	// is required (see [CheckConfig.RequireAIA]).
+	ErrMissingAIA = codes.New(codes.E028MissingAIA, "certificate has no AIA issuing certificate URL")
+)
+
+// isSelfSigned reports whether cert is issued and signed by its own key.
+// Unlike [x509util.IsRoot], cert does not have to be a CA.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// checkChainable returns an error if cert structurally cannot be chained to a
+// root: it is self-signed or, if requireAIA is set, it has no AIA issuing
+// certificate URL.
+func checkChainable(cert *x509.Certificate, requireAIA bool) error {
+	if isSelfSigned(cert) {
+		return ErrSelfSignedEK
+	}
+	if requireAIA && len(cert.IssuingCertificateURL) == 0 {
+		return fmt.Errorf("%w: %s", ErrMissingAIA, cert.Subject.String())
+	}
+	return nil
+}
+
+// missingAIAError returns an error wrapping [ErrMissingAIA] if the chain of
+// cert could not be completed (err) because the last certificate linked
+// through the local candidates, whose issuer is missing, has no AIA issuing
+// certificate URL. Otherwise, nil is returned (eg. the issuer could not be
+// downloaded).
+func missingAIAError(cert *x509.Certificate, candidates []*x509.Certificate, err error) error {
+	if !errors.Is(err, x509util.ErrChainIncomplete) {
+		return nil
+	}
+	last := cert
+	for range candidates {
+		i := slices.IndexFunc(candidates, func(candidate *x509.Certificate) bool {
+			return bytes.Equal(candidate.RawSubject, last.RawIssuer) && last.CheckSignatureFrom(candidate) == nil
+		})
+		if i < 0 {
+			break
+		}
+		last = candidates[i]
+	}
+	if len(last.IssuingCertificateURL) > 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: issuer %q of %q not found: %v", ErrMissingAIA, last.Issuer.String(), last.Subject.String(), err)
+}
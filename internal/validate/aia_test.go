@@ -0,0 +1,138 @@
+package validate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCheckChainable(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	ekWithAIA := createTestEKWithAIA(t, root, rootKey)
+	selfSigned := createTestSelfSignedEK(t)
+
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		requireAIA bool
+		wantErr    error
+	}{
+		{name: "issued", cert: ek},
+		{name: "issued/require-aia", cert: ek, requireAIA: true, wantErr: ErrMissingAIA},
+		{name: "aia/require-aia", cert: ekWithAIA, requireAIA: true},
+		{name: "self-signed", cert: selfSigned, wantErr: ErrSelfSignedEK},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkChainable(tc.cert, tc.requireAIA)
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Errorf("checkChainable() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyChainMissingAIA(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	intermediate, intermediateKey := createTestIntermediate(t, root, rootKey)
+
+	tests := []struct {
+		name    string
+		ek      *x509.Certificate
+		wantAIA bool
+	}{
+		// The intermediate is neither provided nor downloadable
+		{name: "no-aia", ek: createTestEK(t, intermediate, intermediateKey), wantAIA: true},
+		// The intermediate could not be downloaded
+		{name: "aia", ek: createTestEKWithAIA(t, intermediate, intermediateKey)},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					return nil, errors.New("network unreachable")
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = checker.(*ekchecker).verifyChain(t.Context(), tc.ek, nil, true, false, ErrUntrustedCertificate)
+			if err == nil {
+				t.Fatal("verifyChain() error = nil, want an error")
+			}
+			if errors.Is(err, ErrMissingAIA) != tc.wantAIA {
+				t.Errorf("verifyChain() error = %v, want %v: %v", err, ErrMissingAIA, tc.wantAIA)
+			}
+		})
+	}
+}
+
+// createTestEKWithAIA is like createTestEK but sets an AIA issuing certificate URL.
+func createTestEKWithAIA(t *testing.T, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageKeyAgreement,
+		IssuingCertificateURL: []string{"http://example.com/intermediate.cer"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func createTestSelfSignedEK(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "Self-signed EK"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyAgreement,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -0,0 +1,114 @@
+package validate
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"slices"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+var (
+	ErrUntrustedAKCertificate = codes.New(codes.E020UntrustedAK, "AK certificate trust could not be established")
+	ErrAKCannotBeCA           = codes.New(codes.E021AKIsCA, "AK certificate cannot be a CA certificate")
+	ErrAKRootMismatch         = codes.New(codes.E022AKRootMismatch, "AK certificate doesn't chain to the same root as the EK certificate")
+)
+
+var (
+	// OID defined in TCG EK Credential Profile, version 2.6
+	// See section 3.2.16 "Extended Key Usage" (tcg-kp-AIKCertificate)
+	AKCertificate = []int{2, 23, 133, 8, 3}
+)
+
+// AKCheckConfig configures the verification of an Attestation Key (AK) certificate,
+// such as an IAK certificate provisioned by the platform manufacturer.
+type AKCheckConfig struct {
+	// Certificate is the AK certificate to verify.
+	Certificate *x509.Certificate
+	// Chain contains optional intermediates provided along with the certificate.
+	Chain []*x509.Certificate
+	// EKChains are the verified chains of the EK certificate of the same TPM
+	// (see [Checker.CheckWithChains]).
+	//
+	// Optional. If set, the AK certificate must chain to one of their roots.
+	EKChains               [][]*x509.Certificate
+	SkipRevocationCheck    bool
+	RequireRevocationCheck bool
+	// SoftRevocationCheck only logs revocation failures (see [CheckConfig.SoftRevocationCheck]).
+	SoftRevocationCheck bool
+	// Warnings, if set, collects the warnings of the check (see [CheckConfig.Warnings]).
+	Warnings *[]Warning
+	// Context bounds the downloads of the check (see [CheckConfig.Context]).
+	//
+	// Optional. If nil, [context.Background] is used.
+	Context context.Context
+}
+
+func (c *AKCheckConfig) CheckAndSetDefaults() error {
+	if c.Certificate == nil {
+		return fmt.Errorf("AK certificate must be provided")
+	}
+	if c.SkipRevocationCheck && c.RequireRevocationCheck {
+		return fmt.Errorf("revocation check cannot be both skipped and required")
+	}
+	if c.SkipRevocationCheck && c.SoftRevocationCheck {
+		return fmt.Errorf("revocation check cannot be both skipped and soft")
+	}
+	if c.Context == nil {
+		c.Context = context.Background()
+	}
+	return nil
+}
+
+func (c *ekchecker) CheckAK(cfg AKCheckConfig) ([][]*x509.Certificate, error) {
+	c.logger.IncreasePadding()
+	defer c.logger.DecreasePadding()
+
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, fmt.Errorf("invalid check config: %w", err)
+	}
+	c = c.withWarnings(cfg.Warnings).withCRLRecord()
+	if err := c.checkAK(&cfg); err != nil {
+		return nil, err
+	}
+
+	chains, err := c.verifyChain(cfg.Context, cfg.Certificate, cfg.Chain, cfg.SkipRevocationCheck, cfg.SoftRevocationCheck, ErrUntrustedAKCertificate)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.EKChains) > 0 && !sameRoot(chains, cfg.EKChains) {
+		return nil, ErrAKRootMismatch
+	}
+	return chains, nil
+}
+
+func (c *ekchecker) checkAK(cfg *AKCheckConfig) error {
+	if cfg.Certificate.IsCA {
+		return ErrAKCannotBeCA
+	}
+	skip, err := c.revocationSkipped(cfg.Certificate, cfg.SkipRevocationCheck, cfg.RequireRevocationCheck, cfg.SoftRevocationCheck)
+	if err != nil {
+		return err
+	}
+	cfg.SkipRevocationCheck = skip
+	if !slices.ContainsFunc(cfg.Certificate.UnknownExtKeyUsage, func(oid asn1.ObjectIdentifier) bool {
+		return oid.Equal(AKCertificate)
+	}) {
+		c.warn(c.logger, codes.W006MissingEKU, "certificate is missing AK Extended Key Usage (2.23.133.8.3)", "")
+	}
+	return nil
+}
+
+// sameRoot reports whether a and b share at least one root certificate.
+func sameRoot(a, b [][]*x509.Certificate) bool {
+	for _, chainA := range a {
+		for _, chainB := range b {
+			if len(chainA) > 0 && len(chainB) > 0 && chainA[len(chainA)-1].Equal(chainB[len(chainB)-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
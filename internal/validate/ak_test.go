@@ -0,0 +1,80 @@
+package validate
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"testing"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+func TestCheckAK(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		cfg      AKCheckConfig
+		wantErr  error
+		wantSkip bool
+	}{
+		{
+			name:    "error/ak-is-ca",
+			cfg:     AKCheckConfig{Certificate: &x509.Certificate{IsCA: true}},
+			wantErr: ErrAKCannotBeCA,
+		},
+		{
+			name:     "success/missing-crl-dp-is-skipped",
+			cfg:      AKCheckConfig{Certificate: &x509.Certificate{}},
+			wantSkip: true,
+		},
+		{
+			name:    "error/missing-crl-dp-with-required-revocation",
+			cfg:     AKCheckConfig{Certificate: &x509.Certificate{}, RequireRevocationCheck: true},
+			wantErr: ErrMissingRevocationDP,
+		},
+		{
+			name: "success/crl-dp",
+			cfg: AKCheckConfig{Certificate: &x509.Certificate{
+				CRLDistributionPoints: []string{"http://crl.example.com/iak.crl"},
+				UnknownExtKeyUsage:    []asn1.ObjectIdentifier{AKCertificate},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &ekchecker{logger: log.New(log.WithNoop())}
+			cfg := tc.cfg
+			err := c.checkAK(&cfg)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("checkAK() error = %v, want %v", err, tc.wantErr)
+			}
+			if cfg.SkipRevocationCheck != tc.wantSkip {
+				t.Errorf("SkipRevocationCheck = %v, want %v", cfg.SkipRevocationCheck, tc.wantSkip)
+			}
+		})
+	}
+}
+
+func TestSameRoot(t *testing.T) {
+	t.Parallel()
+
+	newCert := func(cn string) *x509.Certificate {
+		subject := pkix.Name{CommonName: cn}
+		return &x509.Certificate{Raw: []byte(cn), Subject: subject}
+	}
+	leaf, rootA, rootB := newCert("leaf"), newCert("root A"), newCert("root B")
+
+	ek := [][]*x509.Certificate{{leaf, rootA}}
+	if !sameRoot([][]*x509.Certificate{{leaf, rootB}, {leaf, rootA}}, ek) {
+		t.Error("sameRoot() = false, want true")
+	}
+	if sameRoot([][]*x509.Certificate{{leaf, rootB}}, ek) {
+		t.Error("sameRoot() = true, want false")
+	}
+}
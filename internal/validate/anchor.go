@@ -0,0 +1,46 @@
+package validate
+
+import "crypto/x509"
+
+// trustStore is the subset of [apiv1beta.TrustedBundle] used to verify
+// certificates, which is also implemented by [trustAnchor].
+type trustStore interface {
+	Contains(cert *x509.Certificate) bool
+	ContainsFunc(fn func(c *x509.Certificate) bool) bool
+	FindFunc(fn func(c *x509.Certificate) bool) *x509.Certificate
+	GetRootCertPool() *x509.CertPool
+	GetIntermediateCertPool() *x509.CertPool
+}
+
+// trustAnchor is a trust store made of a single root, provided explicitly
+// instead of the trusted bundle (see [EKCheckerConfig.TrustAnchor]).
+type trustAnchor struct {
+	root *x509.Certificate
+}
+
+func (a *trustAnchor) Contains(cert *x509.Certificate) bool {
+	return a.root.Equal(cert)
+}
+
+func (a *trustAnchor) ContainsFunc(fn func(c *x509.Certificate) bool) bool {
+	return fn(a.root)
+}
+
+func (a *trustAnchor) FindFunc(fn func(c *x509.Certificate) bool) *x509.Certificate {
+	if fn(a.root) {
+		return a.root
+	}
+	return nil
+}
+
+func (a *trustAnchor) GetRootCertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.root)
+	return pool
+}
+
+// GetIntermediateCertPool returns an empty pool: intermediates come
+// from the chain of the certificate, local files or AIA.
+func (a *trustAnchor) GetIntermediateCertPool() *x509.CertPool {
+	return x509.NewCertPool()
+}
@@ -0,0 +1,114 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestTrustAnchor(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	otherRoot, _ := createTestCA(t)
+	intermediate, intermediateKey := createTestIntermediate(t, root, rootKey)
+	ek := createTestEK(t, intermediate, intermediateKey)
+
+	tests := []struct {
+		name    string
+		anchor  *x509.Certificate
+		wantErr error
+	}{
+		{name: "success/root", anchor: root},
+		{name: "success/intermediate", anchor: intermediate},
+		{name: "error/other-root", anchor: otherRoot, wantErr: ErrUntrustedCertificate},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				// The bundle trusts the root but must be ignored
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				TrustAnchor:   tc.anchor,
+				// The root is downloaded via the AIA of the intermediate
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(root.Raw))}, nil
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			chains, err := checker.(*ekchecker).verifyChain(t.Context(), ek, []*x509.Certificate{intermediate}, true, false, ErrUntrustedCertificate)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("verifyChain() error = %v, want %v", err, tc.wantErr)
+			}
+			if err == nil && !chains[0][len(chains[0])-1].Equal(tc.anchor) {
+				t.Errorf("chain ends with %q, want the trust anchor", chains[0][len(chains[0])-1].Subject)
+			}
+		})
+	}
+}
+
+func TestSystemRoots(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	bundleRoot, _ := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	systemRoots := x509.NewCertPool()
+	systemRoots.AddCert(root)
+
+	tests := []struct {
+		name        string
+		systemRoots *x509.CertPool
+		wantErr     error
+	}{
+		{name: "success/system-root", systemRoots: systemRoots},
+		{name: "error/disabled", wantErr: ErrUntrustedCertificate},
+		{name: "error/not-a-system-root", systemRoots: x509.NewCertPool(), wantErr: ErrUntrustedCertificate},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{bundleRoot}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(root.Raw))}, nil
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Use a custom pool instead of the one of the host
+			checker.(*ekchecker).systemRoots = tc.systemRoots
+
+			chains, err := checker.(*ekchecker).verifyChain(t.Context(), ek, []*x509.Certificate{root}, true, false, ErrUntrustedCertificate)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("verifyChain() error = %v, want %v", err, tc.wantErr)
+			}
+			if err == nil && !chains[0][len(chains[0])-1].Equal(root) {
+				t.Errorf("chain ends with %q, want the system root", chains[0][len(chains[0])-1].Subject)
+			}
+		})
+	}
+}
+
+func TestSystemRootsWithTrustAnchor(t *testing.T) {
+	t.Parallel()
+
+	root, _ := createTestCA(t)
+	_, err := NewEKChecker(EKCheckerConfig{TrustAnchor: root, SystemRoots: true})
+	if err == nil {
+		t.Error("NewEKChecker() expected an error")
+	}
+}
@@ -0,0 +1,153 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+const (
+	// DefaultBundleTimeout is the default deadline of a trusted bundle fetch, retries included.
+	DefaultBundleTimeout = 30 * time.Second
+	// DefaultBundleBackoff is the default delay before the first retry of a failed
+	// trusted bundle fetch. The delay doubles after each attempt.
+	DefaultBundleBackoff = 500 * time.Millisecond
+)
+
+// Overridden in tests.
+var (
+	getTrustedBundle  = apiv1beta.GetTrustedBundle
+	loadTrustedBundle = apiv1beta.LoadTrustedBundle
+)
+
+type FetchBundleConfig struct {
+	// GetConfig is the configuration of each fetch attempt.
+	GetConfig apiv1beta.GetConfig
+	// Timeout bounds the fetch, retries included.
+	Timeout time.Duration
+	// Backoff is the delay before the first retry.
+	Backoff time.Duration
+	Logger  log.Logger
+}
+
+func (c *FetchBundleConfig) CheckAndSetDefaults() error {
+	if c.Logger == nil {
+		c.Logger = log.New(log.WithNoop())
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultBundleTimeout
+	}
+	if c.Backoff == 0 {
+		c.Backoff = DefaultBundleBackoff
+	}
+	if c.Timeout < 0 || c.Backoff < 0 {
+		return fmt.Errorf("timeout and backoff must be positive")
+	}
+	return nil
+}
+
+// FetchTrustedBundle fetches the trusted bundle, retrying with an exponential
+// backoff until the timeout expires. If every attempt fails, the bundle cached
+// on disk by a previous fetch (if any) is loaded and verified offline instead,
+// and as a last resort the snapshot embedded at build time (if any).
+func FetchTrustedBundle(ctx context.Context, cfg FetchBundleConfig) (apiv1beta.TrustedBundle, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	tb, fetchErr := fetchWithRetry(ctx, cfg)
+	if fetchErr == nil {
+		return tb, nil
+	}
+	if ctx.Err() != nil {
+		// canceled by the caller
+		return nil, fetchErr
+	}
+
+	tb, err := loadTrustedBundle(ctx, apiv1beta.LoadConfig{
+		CachePath:   cfg.GetConfig.CachePath,
+		OfflineMode: true,
+	})
+	if err == nil {
+		cfg.Logger.WithError(fetchErr).
+			Warn("failed to fetch trusted bundle, using cached bundle")
+		return tb, nil
+	}
+	cfg.Logger.WithError(err).Debug("no usable cached trusted bundle")
+
+	tb, err = LoadEmbeddedBundle(ctx)
+	if err != nil {
+		cfg.Logger.WithError(err).Debug("no usable embedded trusted bundle")
+		return nil, fetchErr
+	}
+	cfg.Logger.WithError(fetchErr).
+		Warn("failed to fetch trusted bundle, using embedded bundle")
+	return tb, nil
+}
+
+func fetchWithRetry(ctx context.Context, cfg FetchBundleConfig) (apiv1beta.TrustedBundle, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	backoff := cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		tb, err := getTrustedBundle(ctx, cfg.GetConfig)
+		if err == nil {
+			return tb, nil
+		}
+		cfg.Logger.WithError(err).
+			WithField("attempt", attempt).
+			Debug("failed to fetch trusted bundle")
+
+		deadline, _ := ctx.Deadline()
+		if ctx.Err() != nil || time.Until(deadline) < backoff {
+			return nil, err
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+var ErrBundleTooOld = codes.New(codes.E030BundleTooOld, "trusted bundle is too old")
+
+// BundleInfo describes the release of a trusted bundle.
+type BundleInfo struct {
+	// Date is the release date of the root bundle.
+	Date time.Time
+	// Commit is the source commit of the root bundle.
+	Commit string
+}
+
+// GetBundleInfo returns the release information of the root bundle of tb.
+func GetBundleInfo(tb apiv1beta.TrustedBundle) (BundleInfo, error) {
+	metadata := tb.GetRootMetadata()
+	if metadata == nil {
+		return BundleInfo{}, fmt.Errorf("trusted bundle has no metadata")
+	}
+	date, err := time.Parse(time.DateOnly, metadata.Date)
+	if err != nil {
+		return BundleInfo{}, fmt.Errorf("invalid trusted bundle date: %w", err)
+	}
+	return BundleInfo{Date: date, Commit: metadata.Commit}, nil
+}
+
+// Age returns the age of the bundle at now.
+func (i BundleInfo) Age(now time.Time) time.Duration {
+	return now.Sub(i.Date)
+}
+
+// CheckAge returns [ErrBundleTooOld] if the bundle is older than maxAge at now.
+func (i BundleInfo) CheckAge(now time.Time, maxAge time.Duration) error {
+	if age := i.Age(now); age > maxAge {
+		return fmt.Errorf("%w: released on %s (%d day(s) ago, max age is %s)", ErrBundleTooOld, i.Date.Format(time.DateOnly), int(age.Hours()/24), maxAge)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+)
+
+func TestFetchTrustedBundle(t *testing.T) {
+	errFetch := errors.New("connection reset by peer")
+	errNoCache := errors.New("cache directory does not exist")
+	fetched, cached, embedded := &mockTrustedBundle{}, &mockTrustedBundle{}, &mockTrustedBundle{}
+
+	tests := []struct {
+		name         string
+		failures     int
+		cache        bool
+		embedded     bool
+		want         apiv1beta.TrustedBundle
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "success/first-attempt",
+			want:         fetched,
+			wantAttempts: 1,
+		},
+		{
+			name:         "success/after-retries",
+			failures:     2,
+			want:         fetched,
+			wantAttempts: 3,
+		},
+		{
+			name:     "success/fallback-to-cache",
+			failures: 100,
+			cache:    true,
+			want:     cached,
+		},
+		{
+			name:     "success/fallback-to-embedded",
+			failures: 100,
+			embedded: true,
+			want:     embedded,
+		},
+		{
+			name:     "success/cache-before-embedded",
+			failures: 100,
+			cache:    true,
+			embedded: true,
+			want:     cached,
+		},
+		{
+			name:     "error/no-cache",
+			failures: 100,
+			wantErr:  errFetch,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+			getTrustedBundle = func(ctx context.Context, _ ...apiv1beta.GetConfig) (apiv1beta.TrustedBundle, error) {
+				attempts++
+				if attempts <= tc.failures {
+					return nil, errFetch
+				}
+				return fetched, nil
+			}
+			loadTrustedBundle = func(ctx context.Context, cfg apiv1beta.LoadConfig) (apiv1beta.TrustedBundle, error) {
+				if !cfg.OfflineMode {
+					t.Error("cached bundle must be verified offline")
+				}
+				if cfg.CachePath != "" {
+					// extracted embedded snapshot
+					return embedded, nil
+				}
+				if !tc.cache {
+					return nil, errNoCache
+				}
+				return cached, nil
+			}
+			if tc.embedded {
+				snapshotFS = fstest.MapFS{apiv1beta.CacheConfigFilename: {Data: []byte("{}")}}
+			}
+			t.Cleanup(func() {
+				getTrustedBundle = apiv1beta.GetTrustedBundle
+				loadTrustedBundle = apiv1beta.LoadTrustedBundle
+				snapshotFS = mustSub(snapshotFiles, "snapshot")
+			})
+
+			got, err := FetchTrustedBundle(t.Context(), FetchBundleConfig{
+				Timeout: 50 * time.Millisecond,
+				Backoff: time.Millisecond,
+			})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("FetchTrustedBundle() error = %v, want %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("FetchTrustedBundle() returned an unexpected bundle")
+			}
+			if tc.wantAttempts > 0 && attempts != tc.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tc.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestLoadEmbeddedBundle(t *testing.T) {
+	t.Cleanup(func() {
+		loadTrustedBundle = apiv1beta.LoadTrustedBundle
+		snapshotFS = mustSub(snapshotFiles, "snapshot")
+	})
+
+	// The snapshot is not committed
+	if _, err := LoadEmbeddedBundle(t.Context()); !errors.Is(err, ErrNoEmbeddedBundle) {
+		t.Fatalf("LoadEmbeddedBundle() error = %v, want %v", err, ErrNoEmbeddedBundle)
+	}
+
+	snapshot := fstest.MapFS{
+		apiv1beta.CacheConfigFilename:     {Data: []byte(`{"version":"2025-12-01"}`)},
+		apiv1beta.CacheRootBundleFilename: {Data: []byte("roots")},
+	}
+	snapshotFS = snapshot
+	want := &mockTrustedBundle{}
+	loadTrustedBundle = func(ctx context.Context, cfg apiv1beta.LoadConfig) (apiv1beta.TrustedBundle, error) {
+		if !cfg.OfflineMode {
+			t.Error("embedded bundle must be verified offline")
+		}
+		for name, file := range snapshot {
+			data, err := os.ReadFile(filepath.Join(cfg.CachePath, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, file.Data) {
+				t.Errorf("%s = %q, want %q", name, data, file.Data)
+			}
+		}
+		return want, nil
+	}
+
+	got, err := LoadEmbeddedBundle(t.Context())
+	if err != nil {
+		t.Fatalf("LoadEmbeddedBundle() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("LoadEmbeddedBundle() returned an unexpected bundle")
+	}
+}
+
+func TestBundleInfoCheckAge(t *testing.T) {
+	t.Parallel()
+
+	info := BundleInfo{Date: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)}
+	now := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	if err := info.CheckAge(now, 30*24*time.Hour); err != nil {
+		t.Errorf("CheckAge() error = %v, want nil", err)
+	}
+	if err := info.CheckAge(now, 29*24*time.Hour); !errors.Is(err, ErrBundleTooOld) {
+		t.Errorf("CheckAge() error = %v, want %v", err, ErrBundleTooOld)
+	}
+}
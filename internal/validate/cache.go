@@ -0,0 +1,296 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
+)
+
+// ErrStaleCache is returned when the cached material cannot be used
+// to verify a certificate offline (eg. missing or expired CRL).
+var ErrStaleCache = errors.New("cache is stale")
+
+// maxCRLSize is the maximum size of a response recorded by [crlRecorder].
+const maxCRLSize = 20 << 20 // 20 MiB
+
+// Cache holds the material gathered while verifying an EK certificate,
+// allowing to verify it again without network access.
+type Cache struct {
+	// Chain lists the issuers of the EK certificate (intermediates and root).
+	Chain []*x509.Certificate
+	// CRLs lists the CRLs covering the EK certificate and its intermediates.
+	CRLs []*x509.RevocationList
+}
+
+// NextUpdate returns the earliest NextUpdate of the cached CRLs,
+// or the zero time if there is none.
+func (c *Cache) NextUpdate() time.Time {
+	var next time.Time
+	for _, rl := range c.CRLs {
+		if !rl.NextUpdate.IsZero() && (next.IsZero() || rl.NextUpdate.Before(next)) {
+			next = rl.NextUpdate
+		}
+	}
+	return next
+}
+
+// verifyCached verifies cert against the cached chain and checks its
+// revocation status using the cached CRLs, without any network call
+// (only logging a revoked certificate if softRevocation is set).
+// A custom revocation checker is queried instead of the cached CRLs.
+// ErrStaleCache is returned if the cache cannot be used.
+func (c *ekchecker) verifyCached(ctx context.Context, cert *x509.Certificate, cache *Cache, skipRevocation, softRevocation bool) ([][]*x509.Certificate, error) {
+	if len(cache.Chain) == 0 {
+		return nil, fmt.Errorf("%w: no cached chain", ErrStaleCache)
+	}
+	chains, err := c.verifyWithIntermediates(cert, cache.Chain)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStaleCache, err)
+	}
+	if !skipRevocation && !c.crlBased() {
+		ctx, cancel := context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+		if err := c.checkRevocation(ctx, cert, chains[0][1:], softRevocation); err != nil {
+			return nil, err
+		}
+	} else if !skipRevocation {
+		err := checkCachedRevocation(chains[0], cache.CRLs)
+		if errors.Is(err, ErrStaleCache) {
+			return nil, err
+		}
+		if err := c.softRevocationError(err, softRevocation); err != nil {
+			return nil, err
+		}
+	}
+	return chains, nil
+}
+
+// refreshCache stores the issuers of chain along with the CRLs
+// downloaded while verifying it. A cached CRL newer than the downloaded
+// one is kept instead, so that the highest CRL number seen is never
+// forgotten (see [ekchecker.checkCRLRollback]).
+func (c *ekchecker) refreshCache(cache *Cache, chain []*x509.Certificate) {
+	previous := cache.CRLs
+	cache.Chain = slices.Clone(chain[1:])
+	cache.CRLs = nil
+	for _, cert := range chain[:len(chain)-1] {
+		for _, dp := range cert.CRLDistributionPoints {
+			if rl := c.crls.get(dp); rl != nil {
+				if newer := newerCRL(rl, previous, x509util.CertificatesAbove(cert, chain)); newer != nil {
+					rl = newer
+				}
+				cache.CRLs = append(cache.CRLs, rl)
+			}
+		}
+	}
+}
+
+// checkCachedRevocation checks the revocation status of every certificate
+// of chain (except the root) having a supported CRL distribution point.
+func checkCachedRevocation(chain []*x509.Certificate, crls []*x509.RevocationList) error {
+	for i := 0; i < len(chain)-1; i++ {
+		cert, issuer := chain[i], chain[i+1]
+		if !slices.ContainsFunc(cert.CRLDistributionPoints, isSupportedCRLDP) {
+			continue
+		}
+		crl, err := findCRL(cert, issuer, crls)
+		if err != nil {
+			return err
+		}
+		if crl.IsRevoked(cert) {
+			return fmt.Errorf("%w: %s", x509util.ErrCertificateRevoked, cert.Subject.String())
+		}
+	}
+	return nil
+}
+
+// findCRL returns the first currently valid CRL signed by issuer
+// which covers cert (see [checkCRLScope]).
+func findCRL(cert, issuer *x509.Certificate, crls []*x509.RevocationList) (x509util.CRL, error) {
+	var algErr error
+	for _, rl := range crls {
+		crl, err := x509util.NewCRL(rl)
+		if err != nil {
+			// expired or not yet valid
+			continue
+		}
+		err = checkCRLSignature(rl, issuer)
+		if errors.Is(err, ErrUnsupportedCRLSignatureAlgorithm) {
+			algErr = err
+		}
+		if err == nil && checkCRLScope(rl, cert) == nil {
+			return crl, nil
+		}
+	}
+	if algErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStaleCache, algErr)
+	}
+	return nil, fmt.Errorf("%w: no valid CRL issued by %q covering %q", ErrStaleCache, issuer.Subject.String(), cert.Subject.String())
+}
+
+// crlRecorder records the CRLs downloaded through its client, along with
+// the download failures, into the [crlRecord] held by the context of the
+// request (see [contextWithCRLRecord]). As the checker may be shared by
+// concurrent checks, each check has its own record.
+//
+// The CRLs of LDAP distribution points, which the verifier doesn't download,
+// are fetched and recorded alike (see [crlRecorder.fetchLDAP]).
+type crlRecorder struct {
+	client httpClient
+	// ldap fetches the CRL of an LDAP distribution point
+	// (only supported by builds with the ldap tag).
+	ldap func(ctx context.Context, dp string) ([]byte, error)
+	// timeout bounds each LDAP fetch.
+	timeout time.Duration
+}
+
+func newCRLRecorder(client httpClient, timeout time.Duration) *crlRecorder {
+	return &crlRecorder{client: client, ldap: fetchLDAPCRL, timeout: timeout}
+}
+
+// crlRecord keeps the last CRL downloaded from each URL during a check so
+// that it can be stored in a [Cache], along with the last download failure
+// of each URL so that unreachable CRL distribution points can be reported.
+type crlRecord struct {
+	mu       sync.Mutex
+	crls     map[string]*x509.RevocationList
+	failures map[string]downloadFailure
+}
+
+type downloadFailure struct {
+	err error
+	at  time.Time
+}
+
+func newCRLRecord() *crlRecord {
+	return &crlRecord{
+		crls:     make(map[string]*x509.RevocationList),
+		failures: make(map[string]downloadFailure),
+	}
+}
+
+type crlRecordKey struct{}
+
+// contextWithCRLRecord returns a copy of ctx recording the CRL downloads
+// of [crlRecorder] into r.
+func contextWithCRLRecord(ctx context.Context, r *crlRecord) context.Context {
+	return context.WithValue(ctx, crlRecordKey{}, r)
+}
+
+// withCRLRecord returns a copy of the checker recording the CRL downloads
+// of a check into a record of its own.
+func (c *ekchecker) withCRLRecord() *ekchecker {
+	checker := *c
+	checker.crls = newCRLRecord()
+	return &checker
+}
+
+// Do sends the HTTP request and records the response body if it is a CRL.
+// Requests without a record in their context are sent as is.
+func (r *crlRecorder) Do(req *http.Request) (*http.Response, error) {
+	record, _ := req.Context().Value(crlRecordKey{}).(*crlRecord)
+	if record == nil {
+		return r.client.Do(req)
+	}
+	url := req.URL.String()
+	resp, err := r.client.Do(req)
+	if err != nil {
+		record.fail(url, err)
+		return resp, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		record.fail(url, fmt.Errorf("unexpected status: %s", resp.Status))
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	// The client may not cap responses (eg. http.DefaultClient)
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCRLSize+1))
+	if err == nil && len(data) > maxCRLSize {
+		err = fmt.Errorf("%w: response from %s exceeds %d bytes", httpclient.ErrResponseTooLarge, url, maxCRLSize)
+	}
+	if err != nil {
+		record.fail(url, err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	// Issuer certificates are also downloaded through the recorder:
+	// their failures are recorded but never looked up
+	_, _ = record.parse(url, data)
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// fetchLDAP fetches the CRL of the LDAP distribution point dp and records
+// it into the record held by ctx, like the CRLs downloaded over HTTP.
+func (r *crlRecorder) fetchLDAP(ctx context.Context, dp string) (*x509.RevocationList, error) {
+	record, _ := ctx.Value(crlRecordKey{}).(*crlRecord)
+	if record == nil {
+		record = newCRLRecord()
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	data, err := r.ldap(ctx, dp)
+	if err == nil && len(data) > maxCRLSize {
+		err = fmt.Errorf("%w: CRL from %s exceeds %d bytes", httpclient.ErrResponseTooLarge, dp, maxCRLSize)
+	}
+	if err != nil {
+		record.fail(dp, err)
+		return nil, err
+	}
+	return record.parse(dp, data)
+}
+
+// parse parses data, downloaded from url, as a currently valid CRL and
+// records it, or records the failure.
+func (r *crlRecord) parse(url string, data []byte) (*x509.RevocationList, error) {
+	rl, err := x509.ParseRevocationList(data)
+	if err == nil {
+		_, err = x509util.NewCRL(rl)
+	}
+	if err != nil {
+		r.fail(url, err)
+		return nil, err
+	}
+	r.add(url, rl)
+	return rl, nil
+}
+
+func (r *crlRecord) add(url string, rl *x509.RevocationList) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.crls[url] = rl
+	delete(r.failures, url)
+}
+
+func (r *crlRecord) fail(url string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[url] = downloadFailure{err: err, at: time.Now()}
+}
+
+func (r *crlRecord) get(url string) *x509.RevocationList {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.crls[url]
+}
+
+// failure returns the last download failure of url which occurred since the given time, if any.
+func (r *crlRecord) failure(url string, since time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.failures[url]
+	if !ok || f.at.Before(since) {
+		return nil
+	}
+	return f.err
+}
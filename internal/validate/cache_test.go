@@ -0,0 +1,416 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
+)
+
+const testCRLDP = "http://crl.example.com/ek.crl"
+
+func TestCheckCachedRevocation(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	otherRoot, otherKey := createTestCA(t)
+
+	now := time.Now()
+	validCRL := createTestCRL(t, root, rootKey, now.Add(time.Hour))
+	expiredCRL := createTestCRL(t, root, rootKey, now.Add(-time.Minute))
+	revokedCRL := createTestCRL(t, root, rootKey, now.Add(time.Hour), ek.SerialNumber)
+	foreignCRL := createTestCRL(t, otherRoot, otherKey, now.Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		ek      *x509.Certificate
+		crls    []*x509.RevocationList
+		wantErr error
+	}{
+		{
+			name: "success/valid-crl",
+			ek:   ek,
+			crls: []*x509.RevocationList{validCRL},
+		},
+		{
+			name: "success/expired-crl-is-skipped",
+			ek:   ek,
+			crls: []*x509.RevocationList{expiredCRL, validCRL},
+		},
+		{
+			name: "success/no-crl-dp",
+			ek:   &x509.Certificate{},
+		},
+		{
+			name:    "error/no-cached-crl",
+			ek:      ek,
+			wantErr: ErrStaleCache,
+		},
+		{
+			name:    "error/expired-crl",
+			ek:      ek,
+			crls:    []*x509.RevocationList{expiredCRL},
+			wantErr: ErrStaleCache,
+		},
+		{
+			name:    "error/crl-from-another-issuer",
+			ek:      ek,
+			crls:    []*x509.RevocationList{foreignCRL},
+			wantErr: ErrStaleCache,
+		},
+		{
+			name:    "error/revoked",
+			ek:      ek,
+			crls:    []*x509.RevocationList{revokedCRL},
+			wantErr: x509util.ErrCertificateRevoked,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkCachedRevocation([]*x509.Certificate{tc.ek, root}, tc.crls)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("checkCachedRevocation() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCRLRollback(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	otherRoot, otherKey := createTestCA(t)
+	now := time.Now()
+	// Expired, hence the cache is stale and the CRL downloaded again
+	cachedCRL := createTestNumberedCRL(t, root, rootKey, 5, now.Add(-time.Minute))
+	foreignCRL := createTestNumberedCRL(t, otherRoot, otherKey, 5, now.Add(-time.Minute))
+
+	tests := []struct {
+		name        string
+		cached      *x509.RevocationList
+		number      int64
+		soft        bool
+		wantErr     error
+		wantWarning bool
+		wantNumber  int64
+	}{
+		{name: "newer", cached: cachedCRL, number: 7, wantNumber: 7},
+		{name: "same", cached: cachedCRL, number: 5, wantNumber: 5},
+		{name: "rollback", cached: cachedCRL, number: 3, wantErr: ErrCRLRollback},
+		{name: "rollback/soft", cached: cachedCRL, number: 3, soft: true, wantWarning: true, wantNumber: 5},
+		{name: "other-issuer", cached: foreignCRL, number: 3, wantNumber: 3},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			downloaded := createTestNumberedCRL(t, root, rootKey, tc.number, now.Add(time.Hour))
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(downloaded.Raw))}, nil
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cache := &Cache{Chain: []*x509.Certificate{root}, CRLs: []*x509.RevocationList{tc.cached}}
+			var warnings []Warning
+			err = checker.Check(CheckConfig{
+				EK:                  endorsement.EK{Certificate: ek},
+				Cache:               cache,
+				SoftRevocationCheck: tc.soft,
+				Warnings:            &warnings,
+			})
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Fatalf("Check() error = %v, want %v", err, tc.wantErr)
+			}
+			if gotWarning := slices.ContainsFunc(warnings, func(w Warning) bool { return w.Code == codes.W013CRLRollback }); gotWarning != tc.wantWarning {
+				t.Errorf("warnings = %v, want %s: %v", warnings, codes.W013CRLRollback, tc.wantWarning)
+			}
+			if err != nil {
+				return
+			}
+			// The highest CRL number seen is kept
+			if len(cache.CRLs) != 1 || cache.CRLs[0].Number.Int64() != tc.wantNumber {
+				t.Errorf("cached CRLs = %d, want a single CRL numbered %d", len(cache.CRLs), tc.wantNumber)
+			}
+		})
+	}
+}
+
+// BenchmarkCheckCachedRevocation measures the lookup of the EK in cached
+// CRLs of increasing size, a foreign CRL being tried first.
+func BenchmarkCheckCachedRevocation(b *testing.B) {
+	root, rootKey := createTestCA(b)
+	ek := createTestEK(b, root, rootKey)
+	otherRoot, otherKey := createTestCA(b)
+	foreignCRL := createTestCRL(b, otherRoot, otherKey, time.Now().Add(time.Hour))
+
+	for _, size := range []int{10, 1000, 100000} {
+		b.Run(fmt.Sprintf("entries=%d", size), func(b *testing.B) {
+			revoked := make([]*big.Int, size)
+			for i := range revoked {
+				revoked[i] = big.NewInt(int64(1000 + i))
+			}
+			crls := []*x509.RevocationList{foreignCRL, createTestCRL(b, root, rootKey, time.Now().Add(time.Hour), revoked...)}
+			chain := []*x509.Certificate{ek, root}
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := checkCachedRevocation(chain, crls); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheNextUpdate(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	soon := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour))
+	later := createTestCRL(t, root, rootKey, time.Now().Add(2*time.Hour))
+
+	if next := (&Cache{}).NextUpdate(); !next.IsZero() {
+		t.Errorf("NextUpdate() = %v, want zero without CRL", next)
+	}
+	cache := &Cache{CRLs: []*x509.RevocationList{later, soon}}
+	if next := cache.NextUpdate(); !next.Equal(soon.NextUpdate) {
+		t.Errorf("NextUpdate() = %v, want %v", next, soon.NextUpdate)
+	}
+}
+
+func TestCRLRecorder(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	crl := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour))
+	responses := map[string][]byte{
+		testCRLDP:                       crl.Raw,
+		"http://pki.example.com/ca.cer": root.Raw,
+	}
+
+	r := newCRLRecorder(&mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader(responses[req.URL.String()])),
+		}, nil
+	}}, DefaultDownloadTimeout)
+
+	record := newCRLRecord()
+	ctx := contextWithCRLRecord(t.Context(), record)
+	for url, want := range responses {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("failed to build request: %v", err)
+		}
+		resp, err := r.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("body of %s was altered", url)
+		}
+	}
+
+	if got := record.get(testCRLDP); got == nil || !bytes.Equal(got.Raw, crl.Raw) {
+		t.Errorf("CRL of %s was not recorded", testCRLDP)
+	}
+	if got := record.get("http://pki.example.com/ca.cer"); got != nil {
+		t.Error("certificate must not be recorded as a CRL")
+	}
+}
+
+func TestCRLRecorderFailures(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	crl := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour))
+	status := http.StatusInternalServerError
+
+	r := newCRLRecorder(&mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Status:     http.StatusText(status),
+			Body:       io.NopCloser(bytes.NewReader(crl.Raw)),
+		}, nil
+	}}, DefaultDownloadTimeout)
+	record := newCRLRecord()
+	do := func() {
+		t.Helper()
+		req, err := http.NewRequestWithContext(contextWithCRLRecord(t.Context(), record), http.MethodGet, testCRLDP, nil)
+		if err != nil {
+			t.Fatalf("failed to build request: %v", err)
+		}
+		if _, err := r.Do(req); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+
+	start := time.Now()
+	do()
+	if err := record.failure(testCRLDP, start); err == nil {
+		t.Fatal("failure of unreachable CRL DP was not recorded")
+	}
+	if err := record.failure(testCRLDP, time.Now().Add(time.Second)); err != nil {
+		t.Errorf("failure prior to the check must be ignored, got %v", err)
+	}
+
+	status = http.StatusOK
+	do()
+	if err := record.failure(testCRLDP, start); err != nil {
+		t.Errorf("failure must be cleared after a successful download, got %v", err)
+	}
+}
+
+func TestCRLRecorderTooLarge(t *testing.T) {
+	t.Parallel()
+
+	r := newCRLRecorder(&mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader(make([]byte, maxCRLSize+1))),
+		}, nil
+	}}, DefaultDownloadTimeout)
+	record := newCRLRecord()
+	req, err := http.NewRequestWithContext(contextWithCRLRecord(t.Context(), record), http.MethodGet, testCRLDP, nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := r.Do(req); !errors.Is(err, httpclient.ErrResponseTooLarge) {
+		t.Errorf("Do() error = %v, want %v", err, httpclient.ErrResponseTooLarge)
+	}
+	if err := record.failure(testCRLDP, start); !errors.Is(err, httpclient.ErrResponseTooLarge) {
+		t.Errorf("failure() = %v, want %v", err, httpclient.ErrResponseTooLarge)
+	}
+}
+
+type mockHTTPClient struct {
+	doFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return m.doFunc(req)
+}
+
+func createTestCA(t testing.TB) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func createTestEK(t testing.TB, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageKeyAgreement,
+		CRLDistributionPoints: []string{testCRLDP},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// createTestNumberedCRL is like createTestCRL but with the given CRL number.
+func createTestNumberedCRL(t testing.TB, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, number int64, nextUpdate time.Time) *x509.RevocationList {
+	t.Helper()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(number),
+		ThisUpdate: nextUpdate.Add(-2 * time.Hour),
+		NextUpdate: nextUpdate,
+	}, issuer, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+func createTestCRL(t testing.TB, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, nextUpdate time.Time, revoked ...*big.Int) *x509.RevocationList {
+	t.Helper()
+	var entries []x509.RevocationListEntry
+	for _, serial := range revoked {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                nextUpdate.Add(-2 * time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}, issuer, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
@@ -1,27 +1,41 @@
 package validate
 
 import (
-	"bytes"
 	"context"
 	"crypto/x509"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/attest/info"
+	goutils "github.com/loicsikidi/go-utils"
 	"github.com/loicsikidi/go-utils/crypto/x509util"
 	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/httpclient"
 	"github.com/loicsikidi/tpm-trust/internal/log"
 )
 
 var (
-	ErrUntrustedCertificate = errors.New("EK certificate trust could not be established")
-	ErrEKCannotBeCA         = errors.New("EK certificate cannot be a CA certificate")
+	ErrUntrustedCertificate = codes.New(codes.E010Untrusted, "EK certificate trust could not be established")
+	ErrEKCannotBeCA         = codes.New(codes.E012EKIsCA, "EK certificate cannot be a CA certificate")
+	ErrMissingRevocationDP  = codes.New(codes.E013MissingRevocationDP, "certificate has no supported CRL distribution point: revocation status cannot be checked")
+	// ErrUnhandledCriticalExtension is returned in strict mode when the EK certificate
+	// has critical extensions which are not processed (RFC 5280, section 4.2).
+	ErrUnhandledCriticalExtension = codes.New(codes.E017UnhandledCriticalExtension, "EK certificate has unhandled critical extensions")
 )
 
+// supportedCRLSchemes lists the CRL distribution point schemes
+// that can be downloaded: by the verifier, and ldap by the checker
+// in builds with the ldap tag.
+var supportedCRLSchemes = []string{"http", "https"}
+
 var (
 	// OID defined in TCG EK Credential Profile, version 2.6
 	// See section 3.2.16 "Extended Key Usage"
@@ -29,8 +43,21 @@ var (
 	EKCertificate = []int{2, 23, 133, 8, 1}
 )
 
+// Checker verifies EK and AK certificates.
+//
+// A Checker is safe for concurrent use by multiple goroutines: checks do not
+// modify the checker nor the configuration they are given, except for
+// [CheckConfig.Cache] and [CheckConfig.Warnings] which must not be shared
+// by concurrent checks.
 type Checker interface {
+	// Check verifies that the EK certificate chains to a trusted root.
 	Check(cfg CheckConfig) error
+	// CheckWithChains is like [Checker.Check] but also returns the verified
+	// chains (EK, intermediates, root) as returned by [x509.Certificate.Verify].
+	CheckWithChains(cfg CheckConfig) ([][]*x509.Certificate, error)
+	// CheckAK verifies that the AK certificate chains to a trusted root
+	// and returns the verified chains.
+	CheckAK(cfg AKCheckConfig) ([][]*x509.Certificate, error)
 }
 
 type httpClient interface {
@@ -39,16 +66,80 @@ type httpClient interface {
 
 type ekchecker struct {
 	verifier *x509util.CertVerifier
-	tb       apiv1beta.TrustedBundle
+	tb       trustStore
 	logger   log.Logger
 	timeout  time.Duration
+	// recorder fetches the CRLs of LDAP distribution points.
+	recorder *crlRecorder
+	// crls records the CRL downloads of the current check.
+	crls *crlRecord
+	// intermediates are looked up before downloading issuers via AIA.
+	intermediates []*x509.Certificate
+	// explain logs the chain building reasoning (see [EKCheckerConfig.Explain]).
+	explain bool
+	// systemRoots, if set, are trusted when no chain leads to a root of tb
+	// (see [EKCheckerConfig.SystemRoots]).
+	systemRoots *x509.CertPool
+	// warnings, if set, collects the warnings of the current check.
+	warnings *[]Warning
+	// failures, if set, collects the failures of the current check
+	// (see [CheckConfig.CollectErrors]).
+	failures *[]error
+	// revocation, if set, replaces the CRLs to check the revocation status
+	// of the chains (see [EKCheckerConfig.RevocationChecker]).
+	revocation RevocationChecker
+	// ctLogs are the trusted CT logs (see [EKCheckerConfig.CTLogs]).
+	ctLogs []CTLog
 }
 
+const (
+	// DefaultTimeout is the default overall deadline of a check.
+	DefaultTimeout = 10 * time.Second
+	// DefaultDownloadTimeout is the default timeout of a single download.
+	DefaultDownloadTimeout = x509util.DefaultDownloadTimeout
+)
+
 type EKCheckerConfig struct {
 	TrustedBundle apiv1beta.TrustedBundle
 	HttpClient    httpClient
-	Timeout       time.Duration
-	Logger        log.Logger
+	// Transport tunes the connection reuse of the downloads (eg. idle
+	// connections kept per host, HTTP/2) if HttpClient is not set.
+	Transport httpclient.TransportConfig
+	// Timeout is the overall deadline of a check, bounding the sum
+	// of its downloads (issuers and CRLs).
+	Timeout time.Duration
+	// DownloadTimeout bounds each download. It cannot exceed Timeout.
+	DownloadTimeout time.Duration
+	Logger          log.Logger
+	// Intermediates lists locally available issuer certificates (eg. downloaded
+	// beforehand from the manufacturer's website). They are used to complete
+	// the chain, issuers being downloaded via AIA only when missing.
+	Intermediates []*x509.Certificate
+	// Explain logs the chain building reasoning step by step: where each issuer
+	// comes from (provided, local, trusted bundle or downloaded via AIA), the
+	// root the chain leads to and the exact verification error.
+	Explain bool
+	// TrustAnchor, if set, is the only trusted root: certificates must chain
+	// to it, and the trusted bundle is neither fetched nor used.
+	TrustAnchor *x509.Certificate
+	// EmbeddedBundle uses the trusted bundle snapshot embedded at build time
+	// (see [LoadEmbeddedBundle]) instead of fetching it, if TrustedBundle is not set.
+	EmbeddedBundle bool
+	// SystemRoots also trusts the root store of the operating system when
+	// no chain leads to a root of the trusted bundle. It weakens the check:
+	// any publicly trusted CA (eg. a TLS CA) may then vouch for an EK.
+	SystemRoots bool
+	// RevocationChecker, if set, checks the revocation status of the
+	// certificates instead of their CRLs (eg. an internal revocation service).
+	// Certificates are then checked whether or not they have CRL distribution
+	// points, and the CRLs of [CheckConfig.Cache] are ignored.
+	//
+	// Optional. If nil, the CRLs are downloaded from the CRL distribution points.
+	RevocationChecker RevocationChecker
+	// CTLogs lists the Certificate Transparency logs whose SCTs are trusted
+	// (see [ParseCTLogList]). If set, the SCTs embedded in EK certificates
+	// are verified (see [CheckConfig.RequireSCT]).
+	CTLogs []CTLog
 }
 
 func (e *EKCheckerConfig) CheckAndSetDefaults() error {
@@ -56,17 +147,37 @@ func (e *EKCheckerConfig) CheckAndSetDefaults() error {
 		e.Logger = log.New(log.WithNoop())
 	}
 	if e.Timeout == 0 {
-		e.Timeout = 5 * time.Second
+		e.Timeout = DefaultTimeout
+	}
+	if e.DownloadTimeout == 0 {
+		e.DownloadTimeout = min(DefaultDownloadTimeout, e.Timeout)
+	}
+	if e.DownloadTimeout > e.Timeout {
+		return fmt.Errorf("download timeout (%s) cannot exceed overall timeout (%s)", e.DownloadTimeout, e.Timeout)
 	}
 	if e.HttpClient == nil {
-		e.HttpClient = http.DefaultClient
+		transport, err := httpclient.NewTransport(e.Transport)
+		if err != nil {
+			return fmt.Errorf("invalid transport: %w", err)
+		}
+		e.HttpClient = &http.Client{Transport: transport}
+	}
+	if e.TrustAnchor != nil && e.SystemRoots {
+		return fmt.Errorf("system roots cannot be trusted along with a trust anchor")
+	}
+	if e.TrustedBundle == nil && e.TrustAnchor == nil && e.EmbeddedBundle {
+		var err error
+		if e.TrustedBundle, err = LoadEmbeddedBundle(context.Background()); err != nil {
+			return err
+		}
 	}
-	if e.TrustedBundle == nil {
-		ctx, cancel := context.WithTimeout(context.Background(), e.Timeout)
-		defer cancel()
+	if e.TrustedBundle == nil && e.TrustAnchor == nil {
 		var err error
-		cfg := apiv1beta.GetConfig{AutoUpdate: apiv1beta.AutoUpdateConfig{Disabled: true}}
-		e.TrustedBundle, err = apiv1beta.GetTrustedBundle(ctx, cfg)
+		e.TrustedBundle, err = FetchTrustedBundle(context.Background(), FetchBundleConfig{
+			GetConfig: apiv1beta.GetConfig{AutoUpdate: apiv1beta.AutoUpdateConfig{Disabled: true}},
+			Timeout:   e.Timeout,
+			Logger:    e.Logger,
+		})
 		if err != nil {
 			return err
 		}
@@ -79,14 +190,30 @@ func NewEKChecker(cfg EKCheckerConfig) (Checker, error) {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
+	var store trustStore = cfg.TrustedBundle
+	if cfg.TrustAnchor != nil {
+		store = &trustAnchor{root: cfg.TrustAnchor}
+	}
+	var systemRoots *x509.CertPool
+	if cfg.SystemRoots {
+		var err error
+		if systemRoots, err = x509.SystemCertPool(); err != nil {
+			return nil, fmt.Errorf("failed to load system roots: %w", err)
+		}
+	}
+
+	// Some CAs serve PEM encoded CRLs and issuer certificates, or issuer
+	// certificates wrapped in PKCS#7 structures
+	crls := newCRLRecorder(&httpclient.PKCS7Decoder{Client: &httpclient.PEMDecoder{Client: cfg.HttpClient}}, cfg.DownloadTimeout)
 	v, err := x509util.NewCertVerifier(x509util.VerifierConfig{
-		HttpClient: cfg.HttpClient,
+		HttpClient: crls,
+		Timeout:    cfg.DownloadTimeout,
 		AfterDownloadHook: func(url *url.URL, kind string) {
 			cfg.Logger.
 				WithField("url", url.String()).
 				Infof("%s downloaded", kind)
 		},
-		Cache: cfg.TrustedBundle,
+		Cache: store,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create certificate verifier: %w", err)
@@ -94,76 +221,323 @@ func NewEKChecker(cfg EKCheckerConfig) (Checker, error) {
 
 	return &ekchecker{
 		verifier: v,
-		tb:       cfg.TrustedBundle,
+		tb:       store,
 		logger:   cfg.Logger,
 		timeout:  cfg.Timeout,
+		recorder: crls,
+
+		intermediates: cfg.Intermediates,
+		explain:       cfg.Explain,
+		systemRoots:   systemRoots,
+		revocation:    cfg.RevocationChecker,
+		ctLogs:        cfg.CTLogs,
 	}, nil
 }
 
 type CheckConfig struct {
 	EK                  endorsement.EK
 	SkipRevocationCheck bool
+	// RequireRevocationCheck fails the check when the revocation status
+	// cannot be established (eg. no CRL distribution point) instead of
+	// silently skipping the revocation check.
+	RequireRevocationCheck bool
+	// SoftRevocationCheck downloads and evaluates CRLs but only logs a warning
+	// when a certificate is revoked or its CRLs cannot be downloaded.
+	SoftRevocationCheck bool
+	// DisallowedSignatureAlgorithms lists the signature algorithms which are rejected
+	// for the EK and its intermediates. Weak algorithms which are not listed only
+	// trigger a warning.
+	DisallowedSignatureAlgorithms []x509.SignatureAlgorithm
+	// KeyPolicy constrains the EK public key (eg. minimum RSA size, allowed curves).
+	KeyPolicy KeyPolicy
+	// Manufacturer, if set, is the manufacturer reported by the TPM. It is
+	// cross-checked with the TPM manufacturer attribute of the EK certificate.
+	Manufacturer *info.Manufacturer
+	// StrictManufacturer fails the check when the manufacturers disagree
+	// instead of only logging a warning.
+	StrictManufacturer bool
+	// Cache, if set, is used to verify the EK certificate without network access.
+	// If it is stale, the EK certificate is verified online and Cache is refreshed
+	// (hence it must not be shared by concurrent checks). A downloaded CRL older
+	// than a cached one (lower CRL number) fails the check with [ErrCRLRollback],
+	// or is only reported as a warning with SoftRevocationCheck.
+	Cache *Cache
+	// StrictExtensions fails the check when the EK certificate has critical
+	// extensions which are not processed instead of only logging them.
+	StrictExtensions bool
+	// StrictKeyUsage fails the check when the key usage of the EK certificate
+	// doesn't conform to the TCG EK Credential Profile (eg. keyEncipherment for
+	// RSA EKs) instead of only logging a warning.
+	StrictKeyUsage bool
+	// RequireAIA fails the check when the EK certificate has no AIA issuing
+	// certificate URL, even if its issuers are available (eg. provided chain,
+	// local intermediates).
+	RequireAIA bool
+	// RequireSCT fails the check when the EK certificate has no valid SCT from
+	// a trusted CT log (see [EKCheckerConfig.CTLogs]) instead of only logging it.
+	RequireSCT bool
+	// Warnings, if set, collects the warnings of the check (eg. missing CRL
+	// distribution point), which are logged but don't change its outcome.
+	Warnings *[]Warning
+	// CollectErrors goes on after a failure (eg. revoked certificate) instead
+	// of stopping at the first one, so that every applicable failure is
+	// reported at once: the returned error is then a [CheckErrors] if several
+	// failures were found. Failures which prevent the next checks (eg. chain
+	// which cannot be completed) still stop the check.
+	CollectErrors bool
+	// Context bounds the downloads of the check along with the checker timeout
+	// (eg. a deadline of the whole audit).
+	//
+	// Optional. If nil, [context.Background] is used.
+	Context context.Context
 }
 
 func (c *CheckConfig) CheckAndSetDefaults() error {
 	if c.EK.Certificate == nil {
 		return fmt.Errorf("EK certificate must be provided")
 	}
+	if c.SkipRevocationCheck && c.RequireRevocationCheck {
+		return fmt.Errorf("revocation check cannot be both skipped and required")
+	}
+	if c.SkipRevocationCheck && c.SoftRevocationCheck {
+		return fmt.Errorf("revocation check cannot be both skipped and soft")
+	}
+	if c.Context == nil {
+		c.Context = context.Background()
+	}
 	return nil
 }
 
 func (c *ekchecker) Check(cfg CheckConfig) error {
+	_, err := c.CheckWithChains(cfg)
+	return err
+}
+
+func (c *ekchecker) CheckWithChains(cfg CheckConfig) ([][]*x509.Certificate, error) {
 	c.logger.IncreasePadding()
 	defer c.logger.DecreasePadding()
 
 	if err := cfg.CheckAndSetDefaults(); err != nil {
-		return fmt.Errorf("invalid check config: %w", err)
+		return nil, fmt.Errorf("invalid check config: %w", err)
 	}
-	if err := c.check(&cfg); err != nil {
-		return err
+	c = c.withWarnings(cfg.Warnings).withCRLRecord()
+	var failures []error
+	if cfg.CollectErrors {
+		c = c.withFailures(&failures)
+	}
+	chains, err := c.checkEK(&cfg)
+	if err := c.fail(err); err != nil {
+		return nil, err
 	}
+	switch len(failures) {
+	case 0:
+		return chains, nil
+	case 1:
+		return nil, failures[0]
+	default:
+		return nil, CheckErrors(failures)
+	}
+}
 
-	v := c.verifier
+// checkEK runs every check of the EK certificate and returns its verified chains.
+func (c *ekchecker) checkEK(cfg *CheckConfig) ([][]*x509.Certificate, error) {
+	skipRevocation, err := c.check(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	chains, err := c.verifyEK(cfg, skipRevocation)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.fail(c.checkChainsSignatureAlgorithms(chains, cfg.DisallowedSignatureAlgorithms)); err != nil {
+		return nil, err
+	}
+	if len(c.ctLogs) > 0 {
+		if err := c.fail(c.checkSCT(chains[0][0], chains[0][1], cfg.RequireSCT)); err != nil {
+			return nil, err
+		}
+	}
+	return chains, nil
+}
+
+// verifyEK verifies the EK certificate using the cache if possible,
+// falling back to the network otherwise. The revocation check is skipped
+// if skipRevocation is set (see [ekchecker.check]).
+func (c *ekchecker) verifyEK(cfg *CheckConfig, skipRevocation bool) ([][]*x509.Certificate, error) {
+	if cfg.Cache != nil {
+		chains, err := c.verifyCached(cfg.Context, cfg.EK.Certificate, cfg.Cache, skipRevocation, cfg.SoftRevocationCheck)
+		if err == nil {
+			c.logger.Info("verified against cached chain and CRLs")
+			return chains, nil
+		}
+		if !errors.Is(err, ErrStaleCache) {
+			return nil, err
+		}
+		c.logger.WithError(err).Debug("cache cannot be used, falling back to network")
+	}
+
+	chains, err := c.verifyChain(cfg.Context, cfg.EK.Certificate, cfg.EK.Chain, skipRevocation, cfg.SoftRevocationCheck, ErrUntrustedCertificate)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Cache != nil {
+		if !skipRevocation && c.crlBased() {
+			if err := c.fail(c.checkCRLRollback(chains[0], cfg.Cache.CRLs, cfg.SoftRevocationCheck)); err != nil {
+				return nil, err
+			}
+		}
+		c.refreshCache(cfg.Cache, chains[0])
+	}
+	return chains, nil
+}
+
+// verifyChain completes the chain of cert (eg. downloading issuers via AIA),
+// checks its revocation status unless skipRevocation is set (only logging
+// revocation failures if softRevocation is set) and verifies it against the
+// trusted bundle. Alternative paths (eg. through cross-certificates)
+// are tried when the chain does not lead to a trusted root. If no chain to
+// a trusted root can be built, the returned error wraps untrusted.
+func (c *ekchecker) verifyChain(ctx context.Context, cert *x509.Certificate, chain []*x509.Certificate, skipRevocation, softRevocation bool, untrusted error) ([][]*x509.Certificate, error) {
+	// The deadline is shared by every download of the check
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
-	issuers, err := v.GetFullChain(ctx, cfg.EK.Certificate, cfg.EK.Chain)
-	if err != nil && !c.safeToContinue(cfg) {
+	ctx = contextWithCRLRecord(ctx, c.crls)
+	known := slices.Concat(chain, c.intermediates)
+	candidates := slices.Concat(known, c.bundleIssuers(cert, known))
+	issuers, err := c.verifier.GetFullChain(ctx, cert, candidates)
+	if errors.Is(err, x509util.ErrChainIncomplete) {
+		// A downloaded intermediate missing from the bundle (eg. a regional
+		// sub-CA) may be issued by any trusted root of the vendor, not only by
+		// the first one with the same subject. The downloaded issuers are kept
+		// by the verifier: they are not downloaded again.
+		if roots := c.trustedRoots(); len(roots) > 0 {
+			candidates = slices.Concat(candidates, roots)
+			issuers, err = c.verifier.GetFullChain(ctx, cert, candidates)
+		}
+	}
+	if err == nil {
+		// The verifier links issuers by subject and signature only
+		err = checkPathLen(issuers)
+	}
+	if err != nil {
+		// The linear chain may be broken while another path exists (eg. through a cross-certificate)
+		if path, pathErr := c.verifyWithIntermediates(cert, crossCandidates(known)); pathErr == nil {
+			c.logger.WithError(err).Debug("chain completed through an alternative path")
+			issuers, err = path[0][1:], nil
+		}
+	}
+	c.explainChain(cert, chain, issuers, err)
+	if err != nil && !c.safeToContinue(cert, chain, skipRevocation || softRevocation) {
 		c.logger.WithError(err).Debug("failed to get full chain")
-		return fmt.Errorf("failed to get full chain: %w", err)
+		if aiaErr := missingAIAError(cert, candidates, err); aiaErr != nil {
+			return nil, aiaErr
+		}
+		return nil, fmt.Errorf("failed to get full chain: %w", err)
 	}
 
-	if !cfg.SkipRevocationCheck {
-		config := x509util.RevocationConfig{
-			Chain:     issuers,
-			FullChain: true,
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
-		defer cancel()
-		if err := v.Verify(ctx, cfg.EK.Certificate, config); err != nil {
-			return err
+	if !skipRevocation {
+		if err := c.fail(c.checkRevocation(ctx, cert, issuers, softRevocation)); err != nil {
+			return nil, err
 		}
 	}
 
 	// Try verification with extended intermediates pool
-	if err := c.verifyCertificateWithIssuers(cfg.EK.Certificate, issuers); err != nil {
+	chains, err := c.verifyCertificateWithIssuers(cert, issuers, known)
+	c.explainVerification(issuers, chains, err)
+	if err != nil {
 		c.logger.WithError(err).Debug("certificate verification error")
-		return fmt.Errorf("%w: %v", ErrUntrustedCertificate, err)
+		return nil, fmt.Errorf("%w: %v", untrusted, err)
 	}
+	// The verified path may differ from the one checked above (eg. cross-certificate)
+	if verified := chains[0][1:]; !skipRevocation && !slices.EqualFunc(verified, issuers, (*x509.Certificate).Equal) {
+		if err := c.fail(c.checkRevocation(ctx, cert, verified, softRevocation)); err != nil {
+			return nil, err
+		}
+	}
+	return chains, nil
+}
+
+// checkRevocation checks the revocation status of cert and of its issuers
+// (except the root) with the revocation checker: a revoked intermediate CA
+// invalidates the chain like a revoked EK. In soft mode, failures are
+// logged and nil is returned.
+func (c *ekchecker) checkRevocation(ctx context.Context, cert *x509.Certificate, issuers []*x509.Certificate, soft bool) error {
+	revoked, err := c.revocationChecker().IsRevoked(ctx, cert, issuers)
+	if err == nil && revoked {
+		err = x509util.ErrCertificateRevoked
+		if c.crlBased() {
+			if issuer := c.revokedCertificate(issuers); issuer != nil {
+				c.logger.WithField("subject", issuer.Subject.String()).Debug("intermediate CA is revoked")
+				err = fmt.Errorf("%w: intermediate CA %s", err, issuer.Subject.String())
+			}
+		}
+	}
+	return c.softRevocationError(err, soft)
+}
+
+// softRevocationError logs err and returns nil in soft mode, err otherwise.
+func (c *ekchecker) softRevocationError(err error, soft bool) error {
+	if err == nil || !soft {
+		return err
+	}
+	c.warn(c.logger.WithError(err).WithField("outcome", "ignored (soft revocation check)"),
+		codes.W004RevocationCheckFailed, "revocation check failed", err.Error())
 	return nil
 }
 
-func (c *ekchecker) check(cfg *CheckConfig) error {
+// check runs the checks of the EK certificate which don't need its chain and
+// reports whether its revocation check must be skipped: either on request or
+// because it has no supported CRL distribution point. cfg is left untouched so
+// that the caller may reuse it for another certificate.
+func (c *ekchecker) check(cfg *CheckConfig) (bool, error) {
+	if cfg.RequireSCT && len(c.ctLogs) == 0 {
+		return false, errors.New("SCTs cannot be required without trusted CT logs")
+	}
 	if cfg.EK.Certificate.IsCA {
-		return ErrEKCannotBeCA
+		if err := c.fail(ErrEKCannotBeCA); err != nil {
+			return false, err
+		}
+	}
+	if err := c.fail(checkChainable(cfg.EK.Certificate, cfg.RequireAIA)); err != nil {
+		return false, err
+	}
+	if err := c.fail(c.checkSignatureAlgorithm(cfg.EK.Certificate, cfg.DisallowedSignatureAlgorithms)); err != nil {
+		return false, err
 	}
-	if len(cfg.EK.Certificate.CRLDistributionPoints) == 0 {
-		c.logger.WithField("outcome", "revocation check will be skipped").Warn("missing CRL DP")
-		cfg.SkipRevocationCheck = true
+	if err := c.fail(cfg.KeyPolicy.check(cfg.EK.Certificate)); err != nil {
+		return false, err
 	}
-	if len(cfg.EK.Certificate.UnhandledCriticalExtensions) > 0 {
-		c.logger.WithField("extensions", cfg.EK.Certificate.UnhandledCriticalExtensions).
-			Debug("found: unhandled critical extensions")
+	if err := c.fail(c.checkKeyUsage(cfg.EK.Certificate, cfg.StrictKeyUsage)); err != nil {
+		return false, err
+	}
+	if cfg.Manufacturer != nil {
+		if err := c.fail(c.checkManufacturer(cfg.EK.Certificate, *cfg.Manufacturer, cfg.StrictManufacturer)); err != nil {
+			return false, err
+		}
+	}
+	if unhandled := unhandledCriticalExtensions(cfg.EK.Certificate); len(unhandled) > 0 {
+		oids := strings.Join(goutils.Map(unhandled, asn1.ObjectIdentifier.String), ", ")
+		if cfg.StrictExtensions {
+			if err := c.fail(fmt.Errorf("%w: %s", ErrUnhandledCriticalExtension, oids)); err != nil {
+				return false, err
+			}
+		} else {
+			// Only logged in verbose mode: the warning is reported in the
+			// result, and StrictExtensions turns it into a failure
+			c.logger.WithField("extensions", unhandled).
+				WithField("code", codes.W005UnhandledCriticalExtension).
+				Debug("found: unhandled critical extensions")
+			c.collect(codes.W005UnhandledCriticalExtension, "found: unhandled critical extensions", oids)
+		}
+	}
+	skip, err := c.revocationSkipped(cfg.EK.Certificate, cfg.SkipRevocationCheck, cfg.RequireRevocationCheck, cfg.SoftRevocationCheck)
+	if err != nil {
+		if err := c.fail(err); err != nil {
+			return false, err
+		}
+		// The revocation status cannot be established
+		skip = true
 	}
 	found := false
 	for _, ext := range cfg.EK.Certificate.UnknownExtKeyUsage {
@@ -173,15 +547,120 @@ func (c *ekchecker) check(cfg *CheckConfig) error {
 		}
 	}
 	if !found {
-		c.logger.Warn("certificate is missing EK Extended Key Usage (2.23.133.8.1)")
+		c.warn(c.logger, codes.W006MissingEKU, "certificate is missing EK Extended Key Usage (2.23.133.8.1)", "")
 	}
-	return nil
+	return skip, nil
+}
+
+// revocationSkipped reports whether the revocation check of cert must be skipped:
+// either on request (skip) or because cert has no CRL distribution point
+// (unless a custom revocation checker is used). An error is returned if the
+// revocation check is required but cannot be performed, or if cert only has
+// distribution points which cannot be downloaded (eg. ftp://): it may be
+// revoked, hence this is only reported as a warning in soft mode.
+func (c *ekchecker) revocationSkipped(cert *x509.Certificate, skip, require, soft bool) (bool, error) {
+	if !c.crlBased() || c.hasSupportedCRLDP(cert) {
+		return skip, nil
+	}
+	if require {
+		if len(cert.OCSPServer) > 0 {
+			return false, fmt.Errorf("%w (OCSP is not supported)", ErrMissingRevocationDP)
+		}
+		return false, ErrMissingRevocationDP
+	}
+	if len(cert.CRLDistributionPoints) > 0 && !skip {
+		err := fmt.Errorf("%w: no CRL distribution point with a supported scheme (%s)",
+			x509util.ErrCRLNotFound, strings.Join(cert.CRLDistributionPoints, ", "))
+		if err := c.softRevocationError(err, soft); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	c.warn(c.logger.WithField("outcome", "revocation check will be skipped"), codes.W001MissingCRLDP, "missing CRL DP", cert.Subject.String())
+	return true, nil
+}
+
+// hasSupportedCRLDP reports whether cert has at least one CRL distribution
+// point which can be downloaded. Unsupported ones (eg. ftp://) are logged.
+func (c *ekchecker) hasSupportedCRLDP(cert *x509.Certificate) bool {
+	supported := false
+	for _, dp := range cert.CRLDistributionPoints {
+		if !isSupportedCRLDP(dp) {
+			c.warn(c.logger.WithField("url", dp), codes.W002UnsupportedScheme, "CRL DP skipped: unsupported scheme", dp)
+			continue
+		}
+		supported = true
+	}
+	return supported
+}
+
+// crlFailures logs the CRL distribution points of certs which could not
+// be downloaded since start and returns the failures. As the distribution points
+// are tried in order, the revocation check succeeds as long as one of
+// them is reachable.
+func (c *ekchecker) crlFailures(certs []*x509.Certificate, start time.Time) []string {
+	var failures []string
+	for _, cert := range certs {
+		for _, dp := range cert.CRLDistributionPoints {
+			err := c.crls.failure(dp, start)
+			if err == nil {
+				continue
+			}
+			c.warn(c.logger.WithField("url", dp).WithError(err), codes.W003UnreachableCRLDP, "CRL DP unreachable", dp)
+			failures = append(failures, fmt.Sprintf("%s: %v", dp, err))
+		}
+	}
+	return failures
+}
+
+// unhandledCriticalExtensions returns the critical extensions of cert which
+// are processed neither by the x509 package nor by the checker.
+//
+// EK certificates usually have a critical Subject Alternative Name holding
+// only the TPM device attributes (a directory name), which the x509 package
+// reports as unhandled: it is processed by [ParseTPMAttributes].
+func unhandledCriticalExtensions(cert *x509.Certificate) []asn1.ObjectIdentifier {
+	var unhandled []asn1.ObjectIdentifier
+	for _, oid := range cert.UnhandledCriticalExtensions {
+		if oid.Equal(oidSubjectAltName) {
+			if attrs, err := ParseTPMAttributes(cert); err == nil && attrs != (TPMAttributes{}) {
+				continue
+			}
+		}
+		unhandled = append(unhandled, oid)
+	}
+	return unhandled
+}
+
+// isSupportedCRLDP reports whether the CRL distribution point can be downloaded.
+func isSupportedCRLDP(dp string) bool {
+	u, err := url.Parse(dp)
+	return err == nil && slices.Contains(supportedCRLSchemes, u.Scheme)
+}
+
+// isLDAPCRLDP reports whether the CRL distribution point is a supported
+// LDAP one (see [fetchLDAPCRL]).
+func isLDAPCRLDP(dp string) bool {
+	u, err := url.Parse(dp)
+	return err == nil && u.Scheme == "ldap" && isSupportedCRLDP(dp)
 }
 
-func (c *ekchecker) verifyCertificateWithIssuers(cert *x509.Certificate, issuers []*x509.Certificate) error {
+// verifyCertificateWithIssuers verifies cert using its issuers (as resolved by
+// [x509util.CertVerifier.GetFullChain]). If no trusted chain can be built,
+// the other known certificates are tried as they may provide an alternative
+// path (eg. a cross-certificate signed by a trusted root).
+func (c *ekchecker) verifyCertificateWithIssuers(cert *x509.Certificate, issuers, known []*x509.Certificate) ([][]*x509.Certificate, error) {
 	var missingIssuers []*x509.Certificate
 	for _, issuer := range issuers {
 		if !c.tb.Contains(issuer) {
+			if _, anchored := c.tb.(*trustAnchor); anchored && x509util.IsRoot(issuer) {
+				c.logger.WithField("subject", issuer.Subject.String()).
+					Error("root certificate is not the trust anchor")
+				continue
+			}
+			if x509util.IsRoot(issuer) && c.isSystemRoot(issuer) {
+				continue
+			}
 			if x509util.IsRoot(issuer) {
 				c.logger.WithField("subject", issuer.Subject.String()).
 					WithField("reason", `unfortunately, the root certificate
@@ -197,22 +676,98 @@ https://github.com/loicsikidi/tpm-ca-certificates/issues/new
 			missingIssuers = append(missingIssuers, issuer)
 		}
 	}
-	return c.tb.Verify(cert, missingIssuers)
+
+	chains, err := c.verifyWithIntermediates(cert, missingIssuers)
+	if err != nil {
+		alternatives := slices.DeleteFunc(crossCandidates(known), func(candidate *x509.Certificate) bool {
+			return slices.ContainsFunc(missingIssuers, candidate.Equal) || c.tb.Contains(candidate)
+		})
+		if len(alternatives) == 0 {
+			return nil, err
+		}
+		c.logger.WithField("candidates", len(alternatives)).
+			Debug("chain cannot be verified, trying alternative paths")
+		missingIssuers = append(missingIssuers, alternatives...)
+		if chains, err = c.verifyWithIntermediates(cert, missingIssuers); err != nil {
+			return nil, err
+		}
+	}
+	c.logDynamicIntermediates(chains, missingIssuers)
+	return chains, nil
+}
+
+// verifyWithIntermediates verifies cert against the trusted bundle's roots
+// using a dynamic intermediate pool made of the bundle's intermediates
+// extended with the provided ones (eg. downloaded via AIA). If enabled,
+// the system roots are tried when no chain leads to a root of the bundle.
+func (c *ekchecker) verifyWithIntermediates(cert *x509.Certificate, intermediates []*x509.Certificate) ([][]*x509.Certificate, error) {
+	// Copy the EK certificate and mark all critical extensions as handled
+	// to work around TPM-specific OIDs that x509 package doesn't recognize
+	ekCopy := *cert
+	ekCopy.UnhandledCriticalExtensions = nil
+
+	pool := c.tb.GetIntermediateCertPool()
+	for _, intermediate := range intermediates {
+		pool.AddCert(intermediate)
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         c.tb.GetRootCertPool(),
+		Intermediates: pool,
+		// TPM EK certificates don't have standard key usages, so we need to allow any usage
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	chains, err := ekCopy.Verify(opts)
+	if err == nil || c.systemRoots == nil {
+		return chains, err
+	}
+	opts.Roots = c.systemRoots
+	systemChains, systemErr := ekCopy.Verify(opts)
+	if systemErr != nil {
+		return nil, err
+	}
+	root := systemChains[0][len(systemChains[0])-1]
+	c.warn(c.logger.WithField("subject", root.Subject.String()), codes.W010SystemRoot,
+		"chain anchored by a system root, outside of the trusted bundle", root.Subject.String())
+	return systemChains, nil
+}
+
+// isSystemRoot reports whether cert is trusted by the system roots, if enabled.
+func (c *ekchecker) isSystemRoot(cert *x509.Certificate) bool {
+	if c.systemRoots == nil {
+		return false
+	}
+	_, err := cert.Verify(x509.VerifyOptions{Roots: c.systemRoots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	return err == nil
+}
+
+// logDynamicIntermediates logs the intermediates which are not part of
+// the trusted bundle but were used to build a verified chain.
+func (c *ekchecker) logDynamicIntermediates(chains [][]*x509.Certificate, intermediates []*x509.Certificate) {
+	var used []*x509.Certificate
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if slices.ContainsFunc(intermediates, cert.Equal) && !slices.ContainsFunc(used, cert.Equal) {
+				used = append(used, cert)
+			}
+		}
+	}
+	for _, cert := range used {
+		c.logger.WithField("subject", cert.Subject.String()).
+			Info("chain built with intermediate outside of the trusted bundle")
+	}
 }
 
-func (c *ekchecker) safeToContinue(cfg CheckConfig) bool {
-	if !cfg.SkipRevocationCheck {
+func (c *ekchecker) safeToContinue(cert *x509.Certificate, chain []*x509.Certificate, skipRevocation bool) bool {
+	if !skipRevocation {
 		return false
 	}
 
-	candidate := cfg.EK.Certificate
-	if len(cfg.EK.Chain) > 0 {
-		lastIdx := len(cfg.EK.Chain) - 1
-		candidate = cfg.EK.Chain[lastIdx]
+	candidate := cert
+	if len(chain) > 0 {
+		candidate = chain[len(chain)-1]
 	}
 
 	// Check if the candidate's issuer is in the trusted bundle
-	return c.tb.ContainsFunc(func(c *x509.Certificate) bool {
-		return bytes.Equal(c.RawSubject, candidate.RawIssuer)
-	})
+	return c.bundleIssuer(candidate, len(chain)) != nil
 }
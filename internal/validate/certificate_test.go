@@ -0,0 +1,517 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/tpm"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		require    bool
+		soft       bool
+		disallowed []x509.SignatureAlgorithm
+		strictExts bool
+		wantErr    error
+		wantSkip   bool
+	}{
+		{
+			name:    "error/ek-is-ca",
+			cert:    &x509.Certificate{IsCA: true},
+			wantErr: ErrEKCannotBeCA,
+		},
+		{
+			name:     "success/sha1-is-only-a-warning",
+			cert:     &x509.Certificate{SignatureAlgorithm: x509.SHA1WithRSA},
+			wantSkip: true,
+		},
+		{
+			name:       "error/sha1-is-disallowed",
+			cert:       &x509.Certificate{SignatureAlgorithm: x509.SHA1WithRSA},
+			disallowed: SHA1SignatureAlgorithms,
+			wantErr:    ErrDisallowedSignatureAlgorithm,
+		},
+		{
+			name:     "success/missing-crl-dp-is-skipped",
+			cert:     &x509.Certificate{},
+			wantSkip: true,
+		},
+		{
+			name:    "error/missing-crl-dp-with-required-revocation",
+			cert:    &x509.Certificate{},
+			require: true,
+			wantErr: ErrMissingRevocationDP,
+		},
+		{
+			name:    "error/ocsp-only-with-required-revocation",
+			cert:    &x509.Certificate{OCSPServer: []string{"http://ocsp.example.com"}},
+			require: true,
+			wantErr: ErrMissingRevocationDP,
+		},
+		{
+			// The certificate may be revoked: the check fails closed
+			name:    "error/unsupported-crl-dp",
+			cert:    &x509.Certificate{CRLDistributionPoints: []string{"ftp://ftp.example.com/ca.crl"}},
+			wantErr: x509util.ErrCRLNotFound,
+		},
+		{
+			name:     "success/unsupported-crl-dp-is-skipped-with-soft-revocation",
+			cert:     &x509.Certificate{CRLDistributionPoints: []string{"ftp://ftp.example.com/ca.crl"}},
+			soft:     true,
+			wantSkip: true,
+		},
+		{
+			name:    "error/unsupported-crl-dp-with-required-revocation",
+			cert:    &x509.Certificate{CRLDistributionPoints: []string{"ftp://ftp.example.com/ca.crl"}},
+			require: true,
+			wantErr: ErrMissingRevocationDP,
+		},
+		{
+			name:     "success/unhandled-critical-extension-is-only-logged",
+			cert:     &x509.Certificate{UnhandledCriticalExtensions: []asn1.ObjectIdentifier{{1, 2, 3, 4}}},
+			wantSkip: true,
+		},
+		{
+			name:       "error/unhandled-critical-extension-in-strict-mode",
+			cert:       &x509.Certificate{UnhandledCriticalExtensions: []asn1.ObjectIdentifier{{1, 2, 3, 4}}},
+			strictExts: true,
+			wantErr:    ErrUnhandledCriticalExtension,
+		},
+		{
+			name:       "success/critical-san-with-tpm-attributes-in-strict-mode",
+			cert:       criticalSAN(newSANCertificate(t, "id:4E544300", "NPCT75x", "")),
+			strictExts: true,
+			wantSkip:   true,
+		},
+		{
+			name:    "success/crl-dp-with-required-revocation",
+			cert:    &x509.Certificate{CRLDistributionPoints: []string{"http://crl.example.com/ek.crl"}},
+			require: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &ekchecker{logger: log.New(log.WithNoop())}
+			cfg := &CheckConfig{
+				EK:                            endorsement.EK{Certificate: tc.cert},
+				RequireRevocationCheck:        tc.require,
+				SoftRevocationCheck:           tc.soft,
+				DisallowedSignatureAlgorithms: tc.disallowed,
+				StrictExtensions:              tc.strictExts,
+			}
+
+			skip, err := c.check(cfg)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("check() error = %v, want %v", err, tc.wantErr)
+			}
+			if skip != tc.wantSkip {
+				t.Errorf("check() skip = %v, want %v", skip, tc.wantSkip)
+			}
+			if cfg.SkipRevocationCheck {
+				t.Error("check() modified SkipRevocationCheck")
+			}
+		})
+	}
+}
+
+// criticalSAN marks the Subject Alternative Name of cert as an unhandled
+// critical extension, as the x509 package does for directory names.
+func criticalSAN(cert *x509.Certificate) *x509.Certificate {
+	cert.UnhandledCriticalExtensions = []asn1.ObjectIdentifier{oidSubjectAltName}
+	return cert
+}
+
+func TestKeyPolicy(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	eccKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECC key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		policy  KeyPolicy
+		pub     any
+		wantErr error
+	}{
+		{name: "success/no-policy", pub: &rsaKey.PublicKey},
+		{name: "error/rsa-below-minimum", policy: KeyPolicy{MinRSABits: 2048}, pub: &rsaKey.PublicKey, wantErr: ErrDisallowedKey},
+		{name: "success/rsa-above-minimum", policy: KeyPolicy{MinRSABits: 1024}, pub: &rsaKey.PublicKey},
+		{name: "success/allowed-curve", policy: KeyPolicy{AllowedCurves: []tpm.KeyType{tpm.KeyTypeECCNistP384}}, pub: &eccKey.PublicKey},
+		{name: "error/disallowed-curve", policy: KeyPolicy{AllowedCurves: []tpm.KeyType{tpm.KeyTypeECCNistP256}}, pub: &eccKey.PublicKey, wantErr: ErrDisallowedKey},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.policy.check(&x509.Certificate{PublicKey: tc.pub})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("check() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestEKCheckerConfigTimeouts(t *testing.T) {
+	t.Parallel()
+
+	cfg := EKCheckerConfig{Timeout: time.Second, DownloadTimeout: 2 * time.Second}
+	if err := cfg.CheckAndSetDefaults(); err == nil {
+		t.Fatal("expected error when download timeout exceeds overall timeout")
+	}
+
+	cfg = EKCheckerConfig{Timeout: time.Second, TrustedBundle: &mockTrustedBundle{}}
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		t.Fatalf("CheckAndSetDefaults() error = %v", err)
+	}
+	if cfg.DownloadTimeout != time.Second {
+		t.Errorf("DownloadTimeout = %s, want %s", cfg.DownloadTimeout, time.Second)
+	}
+}
+
+// mockTrustedBundle is a trusted bundle whose methods are not expected to be called.
+type mockTrustedBundle struct {
+	apiv1beta.TrustedBundle
+}
+
+func TestSoftRevocationCheck(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	revokedCRL := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour), ek.SerialNumber)
+
+	tests := []struct {
+		name    string
+		crl     []byte // nil: CRL DP unreachable
+		soft    bool
+		wantErr bool
+	}{
+		{name: "enforce/revoked", crl: revokedCRL.Raw, wantErr: true},
+		{name: "enforce/unreachable", wantErr: true},
+		{name: "soft/revoked", crl: revokedCRL.Raw, soft: true},
+		{name: "soft/unreachable", soft: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					if tc.crl == nil {
+						return nil, errors.New("network is unreachable")
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(tc.crl))}, nil
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = checker.(*ekchecker).withCRLRecord().verifyChain(t.Context(), ek, nil, false, tc.soft, ErrUntrustedCertificate)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("verifyChain() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckCollectErrors(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey) // ECC P-256
+	revokedCRL := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour), ek.SerialNumber)
+	validCRL := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour))
+	p384Only := KeyPolicy{AllowedCurves: []tpm.KeyType{tpm.KeyTypeECCNistP384}}
+
+	tests := []struct {
+		name     string
+		crl      *x509.RevocationList
+		policy   KeyPolicy
+		collect  bool
+		wantErrs []error
+	}{
+		{name: "fail-fast/stops-at-first", crl: revokedCRL, policy: p384Only, wantErrs: []error{ErrDisallowedKey}},
+		{name: "collect/every-failure", crl: revokedCRL, policy: p384Only, collect: true, wantErrs: []error{ErrDisallowedKey, x509util.ErrCertificateRevoked}},
+		{name: "collect/single-failure", crl: revokedCRL, collect: true, wantErrs: []error{x509util.ErrCertificateRevoked}},
+		{name: "collect/no-failure", crl: validCRL, collect: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(tc.crl.Raw))}, nil
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			chains, err := checker.CheckWithChains(CheckConfig{
+				EK:            endorsement.EK{Certificate: ek},
+				KeyPolicy:     tc.policy,
+				CollectErrors: tc.collect,
+			})
+			if len(tc.wantErrs) == 0 {
+				if err != nil || len(chains) == 0 {
+					t.Fatalf("CheckWithChains() = %d chains, error = %v, want a chain", len(chains), err)
+				}
+				return
+			}
+			for _, want := range tc.wantErrs {
+				if !errors.Is(err, want) {
+					t.Errorf("CheckWithChains() error = %v, want %v", err, want)
+				}
+			}
+			var failures CheckErrors
+			if isMultiple := errors.As(err, &failures); isMultiple != (len(tc.wantErrs) > 1) {
+				t.Errorf("CheckWithChains() error = %v, want %d failure(s)", err, len(tc.wantErrs))
+			}
+			if len(failures) > 1 && len(failures) != len(tc.wantErrs) {
+				t.Errorf("CheckWithChains() = %d failures, want %d", len(failures), len(tc.wantErrs))
+			}
+		})
+	}
+}
+
+func TestCheckCollectsPerCall(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	crl := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour))
+	var unreachable atomic.Bool
+	checker, err := NewEKChecker(EKCheckerConfig{
+		TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+		HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			if unreachable.Load() {
+				return nil, errors.New("network is unreachable")
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(crl.Raw))}, nil
+		}},
+		Logger: log.New(log.WithNoop()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var firstWarnings []Warning
+	first := &Cache{}
+	if err := checker.Check(CheckConfig{EK: endorsement.EK{Certificate: ek}, Warnings: &firstWarnings, Cache: first}); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(first.CRLs) != 1 {
+		t.Fatalf("cache = %d CRL(s), want the downloaded CRL", len(first.CRLs))
+	}
+	firstCount := len(firstWarnings)
+
+	// The warnings and downloads of a check only go to its own config
+	unreachable.Store(true)
+	var secondWarnings []Warning
+	second := &Cache{}
+	if err := checker.Check(CheckConfig{EK: endorsement.EK{Certificate: ek}, SoftRevocationCheck: true, Warnings: &secondWarnings, Cache: second}); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !slices.ContainsFunc(secondWarnings, func(w Warning) bool { return w.Code == codes.W004RevocationCheckFailed }) {
+		t.Errorf("second check warnings = %v, want %s", secondWarnings, codes.W004RevocationCheckFailed)
+	}
+	if len(firstWarnings) != firstCount {
+		t.Errorf("first check warnings = %v, want none of the second check", firstWarnings)
+	}
+	if len(second.Chain) == 0 {
+		t.Error("second cache has no chain, want the issuers of the second check")
+	}
+	if len(first.CRLs) != 1 || !bytes.Equal(first.CRLs[0].Raw, crl.Raw) {
+		t.Errorf("first cache = %d CRL(s), want the CRL of the first check only", len(first.CRLs))
+	}
+	if len(second.CRLs) != 0 {
+		t.Errorf("second cache = %d CRL(s), want none as the download failed", len(second.CRLs))
+	}
+}
+
+func TestCheckConcurrent(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	crl := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour))
+
+	checker, err := NewEKChecker(EKCheckerConfig{
+		TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+		HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(crl.Raw))}, nil
+		}},
+		// The console logger is the one tracking padding
+		Logger: log.New(log.WithOutput(io.Discard), log.WithVerbose(true)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Run with -race: concurrent checks must not share mutable state
+	cfg := CheckConfig{EK: endorsement.EK{Certificate: ek}}
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Go(func() {
+			_, errs[i] = checker.CheckWithChains(cfg)
+		})
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("check #%d failed: %v", i, err)
+		}
+	}
+}
+
+func TestCheckConcurrentFailures(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	crl := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour))
+	// Both EK certificates share the unreachable CRL DP, which is only
+	// tried by the first check as the other one has a reachable DP first
+	const reachableDP = "http://crl2.example.com/ek.crl"
+	ek := createTestEK(t, root, rootKey)
+	other := createTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(43),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageKeyAgreement,
+		CRLDistributionPoints: []string{reachableDP, testCRLDP},
+	}, root, rootKey)
+
+	// The reachable DP answers once the first check is over, so that the
+	// failure of the first check occurs while the other one is running
+	otherRunning := make(chan struct{})
+	firstDone := make(chan struct{})
+	checker, err := NewEKChecker(EKCheckerConfig{
+		TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+		HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.String() == testCRLDP {
+				return nil, errors.New("network is unreachable")
+			}
+			close(otherRunning)
+			<-firstDone
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(crl.Raw))}, nil
+		}},
+		Logger: log.New(log.WithNoop()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var otherWarnings []Warning
+	var otherErr error
+	wg.Go(func() {
+		otherErr = checker.Check(CheckConfig{EK: endorsement.EK{Certificate: other}, SoftRevocationCheck: true, Warnings: &otherWarnings})
+	})
+	<-otherRunning
+	var firstWarnings []Warning
+	firstErr := checker.Check(CheckConfig{EK: endorsement.EK{Certificate: ek}, SoftRevocationCheck: true, Warnings: &firstWarnings})
+	close(firstDone)
+	wg.Wait()
+
+	unreachable := func(w Warning) bool { return w.Code == codes.W003UnreachableCRLDP }
+	if firstErr != nil || !slices.ContainsFunc(firstWarnings, unreachable) {
+		t.Errorf("first check = %v, warnings %v, want %s", firstErr, firstWarnings, codes.W003UnreachableCRLDP)
+	}
+	if otherErr != nil || slices.ContainsFunc(otherWarnings, unreachable) {
+		t.Errorf("other check = %v, warnings %v, want no failure of the first check", otherErr, otherWarnings)
+	}
+}
+
+// BenchmarkCheck measures the check of an EK certificate issued through an
+// intermediate CA (provided along with it), its CRL being served from memory.
+func BenchmarkCheck(b *testing.B) {
+	root, rootKey := createTestCA(b)
+	intermediate, intermediateKey := createTestIntermediate(b, root, rootKey)
+	ek := createTestEK(b, intermediate, intermediateKey)
+	// A CRL of a real-world size, not listing the EK
+	revoked := make([]*big.Int, 1000)
+	for i := range revoked {
+		revoked[i] = big.NewInt(int64(1000 + i))
+	}
+	crl := createTestCRL(b, intermediate, intermediateKey, time.Now().Add(time.Hour), revoked...)
+
+	benchmarks := []struct {
+		name           string
+		skipRevocation bool
+	}{
+		{name: "revocation"},
+		{name: "skip-revocation", skipRevocation: true},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(crl.Raw))}, nil
+				}},
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			cfg := CheckConfig{
+				EK:                  endorsement.EK{Certificate: ek, Chain: []*x509.Certificate{intermediate}},
+				SkipRevocationCheck: bm.skipRevocation,
+			}
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := checker.Check(cfg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+// ErrCRLOutOfScope is returned when the CRL downloaded for a certificate does
+// not cover it according to its Issuing Distribution Point extension (eg. a CRL
+// only listing CA certificates), hence its revocation status is unknown.
+var ErrCRLOutOfScope = codes.New(codes.E018CRLOutOfScope, "CRL does not cover the certificate")
+
+// ErrCRLRollback is returned when a downloaded CRL is older (lower CRL number)
+// than a cached CRL of the same issuer and scope, eg. a stale CRL replayed
+// to hide a revocation.
+var ErrCRLRollback = codes.New(codes.E060CRLRollback, "CRL is older than the cached one")
+
+// ErrUnsupportedCRLSignatureAlgorithm is returned when the signature of a CRL
+// cannot be verified because its algorithm is not supported (or deemed insecure,
+// eg. MD5), which is a limitation of the verifier rather than a trust issue.
+var ErrUnsupportedCRLSignatureAlgorithm = codes.New(codes.E026UnsupportedCRLAlgorithm, "CRL signature algorithm is not supported")
+
+// oidIssuingDistributionPoint is defined in RFC 5280, section 5.2.5.
+var oidIssuingDistributionPoint = asn1.ObjectIdentifier{2, 5, 29, 28}
+
+// generalNameURI is the tag of the uniformResourceIdentifier GeneralName.
+const generalNameURI = 6
+
+// issuingDistributionPoint is the Issuing Distribution Point extension of a CRL
+// (RFC 5280, section 5.2.5), which scopes the certificates it covers.
+type issuingDistributionPoint struct {
+	DistributionPoint          distributionPointName `asn1:"optional,tag:0"`
+	OnlyContainsUserCerts      bool                  `asn1:"optional,tag:1"`
+	OnlyContainsCACerts        bool                  `asn1:"optional,tag:2"`
+	OnlySomeReasons            asn1.BitString        `asn1:"optional,tag:3"`
+	IndirectCRL                bool                  `asn1:"optional,tag:4"`
+	OnlyContainsAttributeCerts bool                  `asn1:"optional,tag:5"`
+}
+
+type distributionPointName struct {
+	FullName     []asn1.RawValue  `asn1:"optional,tag:0"`
+	RelativeName pkix.RDNSequence `asn1:"optional,tag:1"`
+}
+
+// parseIssuingDistributionPoint returns the Issuing Distribution Point
+// extension of rl, or nil if rl covers every certificate of its issuer.
+func parseIssuingDistributionPoint(rl *x509.RevocationList) (*issuingDistributionPoint, error) {
+	for _, ext := range rl.Extensions {
+		if !ext.Id.Equal(oidIssuingDistributionPoint) {
+			continue
+		}
+		var idp issuingDistributionPoint
+		rest, err := asn1.Unmarshal(ext.Value, &idp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse issuing distribution point: %w", err)
+		}
+		if len(rest) > 0 {
+			return nil, errors.New("failed to parse issuing distribution point: trailing data")
+		}
+		return &idp, nil
+	}
+	return nil, nil
+}
+
+// uris returns the URIs of the full name of the distribution point.
+func (n distributionPointName) uris() []string {
+	var uris []string
+	for _, name := range n.FullName {
+		if name.Class == asn1.ClassContextSpecific && name.Tag == generalNameURI {
+			uris = append(uris, string(name.Bytes))
+		}
+	}
+	return uris
+}
+
+// checkCRLScope returns an error wrapping [ErrCRLOutOfScope] if the Issuing
+// Distribution Point extension of rl excludes cert:
+//   - the CRL only covers end-entity certificates, CA certificates or
+//     attribute certificates and cert is not of this kind;
+//   - the CRL names distribution points and none of them is listed by cert.
+//
+// A CRL partitioned by revocation reason still covers cert: its entries are
+// revocations. Distribution points named relatively to the CRL issuer cannot
+// be compared, they are assumed to match.
+func checkCRLScope(rl *x509.RevocationList, cert *x509.Certificate) error {
+	idp, err := parseIssuingDistributionPoint(rl)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCRLOutOfScope, err)
+	}
+	if idp == nil {
+		return nil
+	}
+	switch {
+	case idp.OnlyContainsAttributeCerts:
+		return fmt.Errorf("%w: CRL only contains attribute certificates", ErrCRLOutOfScope)
+	case idp.OnlyContainsUserCerts && cert.IsCA:
+		return fmt.Errorf("%w: CRL only contains end-entity certificates", ErrCRLOutOfScope)
+	case idp.OnlyContainsCACerts && !cert.IsCA:
+		return fmt.Errorf("%w: CRL only contains CA certificates", ErrCRLOutOfScope)
+	}
+	if uris := idp.DistributionPoint.uris(); len(uris) > 0 &&
+		!slices.ContainsFunc(uris, func(uri string) bool { return slices.Contains(cert.CRLDistributionPoints, uri) }) {
+		return fmt.Errorf("%w: CRL distribution point %v is not listed by the certificate", ErrCRLOutOfScope, uris)
+	}
+	return nil
+}
+
+// checkCRLSignature verifies that rl is signed by one of issuers. Unlike
+// [x509util.CRL.Verify], which reports every failure as
+// [x509util.ErrUnknownAuthorityError], an error wrapping
+// [ErrUnsupportedCRLSignatureAlgorithm] is returned when the signature
+// could not be checked because of its algorithm.
+func checkCRLSignature(rl *x509.RevocationList, issuers ...*x509.Certificate) error {
+	var algErr error
+	for _, issuer := range issuers {
+		err := rl.CheckSignatureFrom(issuer)
+		if err == nil {
+			return nil
+		}
+		var insecure x509.InsecureAlgorithmError
+		if errors.Is(err, x509.ErrUnsupportedAlgorithm) || errors.As(err, &insecure) {
+			algErr = fmt.Errorf("%w: %s (issuer %q): %v", ErrUnsupportedCRLSignatureAlgorithm, rl.SignatureAlgorithm, issuer.Subject.String(), err)
+		}
+	}
+	if algErr != nil {
+		return algErr
+	}
+	return x509util.ErrUnknownAuthorityError
+}
+
+// checkDownloadedCRLSignatures returns an error wrapping
+// [ErrUnsupportedCRLSignatureAlgorithm] if the signature of a CRL downloaded
+// since start to check the revocation status of certs could not be verified
+// because of its algorithm, which the verifier reports as a mere
+// verification failure.
+func (c *ekchecker) checkDownloadedCRLSignatures(certs []*x509.Certificate, start time.Time) error {
+	for _, cert := range certs {
+		if x509util.IsRoot(cert) {
+			continue
+		}
+		for _, dp := range cert.CRLDistributionPoints {
+			if !isSupportedCRLDP(dp) || c.crls.failure(dp, start) != nil {
+				continue
+			}
+			rl := c.crls.get(dp)
+			if rl == nil {
+				break
+			}
+			if err := checkCRLSignature(rl, x509util.CertificatesAbove(cert, certs)...); errors.Is(err, ErrUnsupportedCRLSignatureAlgorithm) {
+				return fmt.Errorf("%w (CRL of %s)", err, cert.Subject.String())
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// checkDownloadedCRLScope checks that the CRLs downloaded since start to check
+// the revocation status of certs cover them (see [checkCRLScope]). As the
+// distribution points of a certificate are tried in order, its status is given
+// by the first one which could be downloaded.
+func (c *ekchecker) checkDownloadedCRLScope(certs []*x509.Certificate, start time.Time) error {
+	for _, cert := range certs {
+		if x509util.IsRoot(cert) {
+			continue
+		}
+		for _, dp := range cert.CRLDistributionPoints {
+			if !isSupportedCRLDP(dp) || c.crls.failure(dp, start) != nil {
+				continue
+			}
+			rl := c.crls.get(dp)
+			if rl == nil {
+				// Not downloaded: the revocation check stopped earlier
+				break
+			}
+			if err := checkCRLScope(rl, cert); err != nil {
+				c.logger.WithField("url", dp).WithError(err).Debug("CRL out of scope")
+				return fmt.Errorf("%w (%s)", err, cert.Subject.String())
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// checkCRLRollback compares the CRLs downloaded to check the revocation status
+// of the certificates of chain with the cached ones: a CRL older than a cached
+// CRL of the same issuer and scope returns an error wrapping [ErrCRLRollback],
+// or is only reported as a warning if soft is set.
+func (c *ekchecker) checkCRLRollback(chain []*x509.Certificate, cached []*x509.RevocationList, soft bool) error {
+	for _, cert := range chain {
+		if x509util.IsRoot(cert) {
+			continue
+		}
+		for _, dp := range cert.CRLDistributionPoints {
+			if !isSupportedCRLDP(dp) {
+				continue
+			}
+			rl := c.crls.get(dp)
+			if rl == nil {
+				continue
+			}
+			newer := newerCRL(rl, cached, x509util.CertificatesAbove(cert, chain))
+			if newer == nil {
+				continue
+			}
+			err := fmt.Errorf("%w: CRL number %s of %s is lower than %s cached (%s)", ErrCRLRollback, rl.Number, dp, newer.Number, cert.Subject.String())
+			if !soft {
+				return err
+			}
+			c.warn(c.logger.WithError(err).WithField("url", dp), codes.W013CRLRollback, "CRL rollback", err.Error())
+		}
+	}
+	return nil
+}
+
+// newerCRL returns the CRL of cached with the same issuer and scope as rl
+// but a higher CRL number, if any. Only the CRLs signed by one of issuers
+// are considered.
+func newerCRL(rl *x509.RevocationList, cached []*x509.RevocationList, issuers []*x509.Certificate) *x509.RevocationList {
+	if rl.Number == nil {
+		return nil
+	}
+	for _, prev := range cached {
+		if prev.Number != nil && prev.Number.Cmp(rl.Number) > 0 && sameCRLScope(prev, rl) && checkCRLSignature(prev, issuers...) == nil {
+			return prev
+		}
+	}
+	return nil
+}
+
+// sameCRLScope reports whether a and b are issued by the same CA key for the
+// same scope, whose CRL numbers increase monotonically (RFC 5280, section 5.2.3).
+func sameCRLScope(a, b *x509.RevocationList) bool {
+	return bytes.Equal(a.RawIssuer, b.RawIssuer) &&
+		bytes.Equal(a.AuthorityKeyId, b.AuthorityKeyId) &&
+		bytes.Equal(issuingDistributionPointValue(a), issuingDistributionPointValue(b))
+}
+
+// issuingDistributionPointValue returns the raw Issuing Distribution Point
+// extension of rl, or nil if it has none.
+func issuingDistributionPointValue(rl *x509.RevocationList) []byte {
+	for _, ext := range rl.Extensions {
+		if ext.Id.Equal(oidIssuingDistributionPoint) {
+			return ext.Value
+		}
+	}
+	return nil
+}
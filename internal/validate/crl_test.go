@@ -0,0 +1,252 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+)
+
+func TestCheckCRLScope(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	otherDP := uriFullName("http://crl.example.com/other.crl")
+
+	tests := []struct {
+		name    string
+		idp     *issuingDistributionPoint // nil: no extension
+		cert    *x509.Certificate
+		wantErr bool
+	}{
+		{name: "no-idp", cert: ek},
+		{name: "user-certs/ek", idp: &issuingDistributionPoint{OnlyContainsUserCerts: true}, cert: ek},
+		{name: "user-certs/ca", idp: &issuingDistributionPoint{OnlyContainsUserCerts: true}, cert: root, wantErr: true},
+		{name: "ca-certs/ek", idp: &issuingDistributionPoint{OnlyContainsCACerts: true}, cert: ek, wantErr: true},
+		{name: "ca-certs/ca", idp: &issuingDistributionPoint{OnlyContainsCACerts: true}, cert: root},
+		{name: "attribute-certs", idp: &issuingDistributionPoint{OnlyContainsAttributeCerts: true}, cert: ek, wantErr: true},
+		{
+			name: "dp/match",
+			idp:  &issuingDistributionPoint{DistributionPoint: uriFullName(testCRLDP)},
+			cert: ek,
+		},
+		{
+			name:    "dp/mismatch",
+			idp:     &issuingDistributionPoint{DistributionPoint: otherDP},
+			cert:    ek,
+			wantErr: true,
+		},
+		{
+			name: "reasons",
+			idp:  &issuingDistributionPoint{OnlySomeReasons: asn1.BitString{Bytes: []byte{0x40}, BitLength: 2}},
+			cert: ek,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rl := createTestScopedCRL(t, root, rootKey, tc.idp)
+			err := checkCRLScope(rl, tc.cert)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("checkCRLScope() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrCRLOutOfScope) {
+				t.Errorf("checkCRLScope() error = %v, want %v", err, ErrCRLOutOfScope)
+			}
+		})
+	}
+}
+
+func TestCheckCRLScopeMalformed(t *testing.T) {
+	t.Parallel()
+
+	rl := &x509.RevocationList{Extensions: []pkix.Extension{{Id: oidIssuingDistributionPoint, Value: []byte{0x30, 0x03}}}}
+	if err := checkCRLScope(rl, &x509.Certificate{}); !errors.Is(err, ErrCRLOutOfScope) {
+		t.Errorf("checkCRLScope() error = %v, want %v", err, ErrCRLOutOfScope)
+	}
+}
+
+func TestRevocationCRLScope(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+
+	tests := []struct {
+		name    string
+		idp     *issuingDistributionPoint
+		revoked bool
+		wantErr error
+	}{
+		{name: "in-scope/revoked", idp: &issuingDistributionPoint{OnlyContainsUserCerts: true}, revoked: true, wantErr: x509util.ErrCertificateRevoked},
+		{name: "in-scope/valid", idp: &issuingDistributionPoint{OnlyContainsUserCerts: true}},
+		// A CRL listing CA certificates says nothing about the EK
+		{name: "out-of-scope/revoked", idp: &issuingDistributionPoint{OnlyContainsCACerts: true}, revoked: true, wantErr: ErrCRLOutOfScope},
+		{name: "out-of-scope/valid", idp: &issuingDistributionPoint{OnlyContainsCACerts: true}, wantErr: ErrCRLOutOfScope},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var revoked []*big.Int
+			if tc.revoked {
+				revoked = append(revoked, ek.SerialNumber)
+			}
+			rl := createTestScopedCRL(t, root, rootKey, tc.idp, revoked...)
+
+			t.Run("online", func(t *testing.T) {
+				t.Parallel()
+
+				checker, err := NewEKChecker(EKCheckerConfig{
+					TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+					HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(rl.Raw))}, nil
+					}},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = checker.(*ekchecker).withCRLRecord().verifyChain(t.Context(), ek, nil, false, false, ErrUntrustedCertificate)
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("verifyChain() error = %v, want %v", err, tc.wantErr)
+				}
+			})
+
+			t.Run("cached", func(t *testing.T) {
+				t.Parallel()
+
+				wantErr := tc.wantErr
+				if errors.Is(wantErr, ErrCRLOutOfScope) {
+					// The cache is useless and the network must be used
+					wantErr = ErrStaleCache
+				}
+				err := checkCachedRevocation([]*x509.Certificate{ek, root}, []*x509.RevocationList{rl})
+				if !errors.Is(err, wantErr) {
+					t.Errorf("checkCachedRevocation() error = %v, want %v", err, wantErr)
+				}
+			})
+		})
+	}
+}
+
+func TestCheckCRLSignature(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	other, _ := createTestCA(t)
+	crl := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour))
+	unsupported := withUnknownSignatureAlgorithm(t, crl)
+
+	tests := []struct {
+		name    string
+		crl     *x509.RevocationList
+		issuers []*x509.Certificate
+		wantErr error
+	}{
+		{name: "valid", crl: crl, issuers: []*x509.Certificate{other, root}},
+		{name: "unknown-authority", crl: crl, issuers: []*x509.Certificate{other}, wantErr: x509util.ErrUnknownAuthorityError},
+		{name: "unsupported-algorithm", crl: unsupported, issuers: []*x509.Certificate{root}, wantErr: ErrUnsupportedCRLSignatureAlgorithm},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkCRLSignature(tc.crl, tc.issuers...)
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Errorf("checkCRLSignature() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRevocationCRLSignatureAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	rl := withUnknownSignatureAlgorithm(t, createTestCRL(t, root, rootKey, time.Now().Add(time.Hour)))
+
+	checker, err := NewEKChecker(EKCheckerConfig{
+		TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+		HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(rl.Raw))}, nil
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The algorithm limitation must not be mistaken for an untrusted CRL
+	_, err = checker.(*ekchecker).withCRLRecord().verifyChain(t.Context(), ek, nil, false, false, ErrUntrustedCertificate)
+	if !errors.Is(err, ErrUnsupportedCRLSignatureAlgorithm) {
+		t.Errorf("verifyChain() error = %v, want %v", err, ErrUnsupportedCRLSignatureAlgorithm)
+	}
+}
+
+// withUnknownSignatureAlgorithm returns rl whose signature algorithm
+// (ecdsa-with-SHA256) is replaced by an unknown one.
+func withUnknownSignatureAlgorithm(t *testing.T, rl *x509.RevocationList) *x509.RevocationList {
+	t.Helper()
+	ecdsaWithSHA256 := []byte{0x06, 0x08, 0x2A, 0x86, 0x48, 0xCE, 0x3D, 0x04, 0x03, 0x02}
+	unknown := []byte{0x06, 0x08, 0x2A, 0x86, 0x48, 0xCE, 0x3D, 0x04, 0x03, 0x7F}
+	if bytes.Count(rl.Raw, ecdsaWithSHA256) != 2 {
+		t.Fatal("CRL is not signed with ecdsa-with-SHA256")
+	}
+	patched, err := x509.ParseRevocationList(bytes.ReplaceAll(rl.Raw, ecdsaWithSHA256, unknown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return patched
+}
+
+// uriFullName returns a distribution point name made of the given URI.
+func uriFullName(uri string) distributionPointName {
+	return distributionPointName{FullName: []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: generalNameURI, Bytes: []byte(uri)}}}
+}
+
+// createTestScopedCRL is like createTestCRL but adds idp as
+// Issuing Distribution Point extension (if set).
+func createTestScopedCRL(t *testing.T, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, idp *issuingDistributionPoint, revoked ...*big.Int) *x509.RevocationList {
+	t.Helper()
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for _, serial := range revoked {
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: time.Now()})
+	}
+	if idp != nil {
+		value, err := asn1.Marshal(*idp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl.ExtraExtensions = []pkix.Extension{{Id: oidIssuingDistributionPoint, Critical: true, Value: value}}
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, issuer, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
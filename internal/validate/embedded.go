@@ -0,0 +1,79 @@
+package validate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+)
+
+//go:generate go run github.com/loicsikidi/tpm-ca-certificates@v0.11.2 bundle save --output-dir snapshot --force
+
+// ErrNoEmbeddedBundle is returned when the binary was built without a
+// trusted bundle snapshot (see snapshot/README.md).
+var ErrNoEmbeddedBundle = errors.New("no trusted bundle embedded in this binary")
+
+//go:embed snapshot
+var snapshotFiles embed.FS
+
+// Overridden in tests.
+var snapshotFS fs.FS = mustSub(snapshotFiles, "snapshot")
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// HasEmbeddedBundle reports whether a trusted bundle snapshot was embedded at build time.
+func HasEmbeddedBundle() bool {
+	_, err := fs.Stat(snapshotFS, apiv1beta.CacheConfigFilename)
+	return err == nil
+}
+
+// LoadEmbeddedBundle loads the trusted bundle snapshot embedded at build time.
+// Like a cached bundle, it is verified offline with the assets it ships with.
+func LoadEmbeddedBundle(ctx context.Context) (apiv1beta.TrustedBundle, error) {
+	if !HasEmbeddedBundle() {
+		return nil, ErrNoEmbeddedBundle
+	}
+	// The bundle can only be loaded from a cache directory
+	dir, err := os.MkdirTemp("", "tpm-trust-bundle-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	entries, err := fs.ReadDir(snapshotFS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded bundle: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := fs.ReadFile(snapshotFS, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded bundle: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, entry.Name()), data, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to extract embedded bundle: %w", err)
+		}
+	}
+
+	tb, err := loadTrustedBundle(ctx, apiv1beta.LoadConfig{
+		CachePath:   dir,
+		OfflineMode: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded bundle: %w", err)
+	}
+	return tb, nil
+}
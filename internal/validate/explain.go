@@ -0,0 +1,95 @@
+package validate
+
+import (
+	"crypto/x509"
+	"errors"
+	"slices"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+
+	"github.com/loicsikidi/tpm-trust/internal/logutil"
+)
+
+// Sources of the issuers reported in explain mode.
+const (
+	sourceProvided = "provided with the EK certificate"
+	sourceLocal    = "local intermediates"
+	sourceBundle   = "trusted bundle"
+	sourceAIA      = "downloaded via AIA"
+)
+
+// explainChain logs, in explain mode, the chain built for cert: where each
+// issuer comes from and, if the chain is incomplete, the issuer which is
+// missing along with the URLs it was looked up at.
+func (c *ekchecker) explainChain(cert *x509.Certificate, chain, issuers []*x509.Certificate, err error) {
+	if !c.explain {
+		return
+	}
+	c.logger.Info("chain building:")
+	logutil.LogWithPadding(c.logger, func() {
+		c.logger.WithField("subject", cert.Subject.String()).
+			WithField("issuer", cert.Issuer.String()).
+			WithField("aia", cert.IssuingCertificateURL).
+			Info("EK certificate")
+		for _, issuer := range issuers {
+			entry := c.logger.WithField("subject", issuer.Subject.String()).
+				WithField("source", c.issuerSource(issuer, chain))
+			if x509util.IsRoot(issuer) {
+				entry.WithField("trusted", c.tb.Contains(issuer)).Info("root")
+				continue
+			}
+			entry.WithField("aia", issuer.IssuingCertificateURL).Info("intermediate")
+		}
+		if errors.Is(err, ErrPathLenExceeded) {
+			c.logger.WithError(err).Warn("path length constraint exceeded")
+		} else if err != nil {
+			last := cert
+			if len(issuers) > 0 {
+				last = issuers[len(issuers)-1]
+			}
+			c.logger.WithField("issuer", last.Issuer.String()).
+				WithField("aia", last.IssuingCertificateURL).
+				WithError(err).
+				Warn("issuer not found")
+		}
+	})
+}
+
+// explainVerification logs, in explain mode, the outcome of the verification
+// against the roots of the trusted bundle: the verified path or the exact
+// error, along with the root the chain was expected to lead to.
+func (c *ekchecker) explainVerification(issuers []*x509.Certificate, chains [][]*x509.Certificate, err error) {
+	if !c.explain {
+		return
+	}
+	c.logger.Info("verification against the trusted bundle:")
+	logutil.LogWithPadding(c.logger, func() {
+		if err != nil {
+			entry := c.logger.WithError(err)
+			if len(issuers) > 0 {
+				root := issuers[len(issuers)-1]
+				entry = entry.WithField("root", root.Subject.String()).
+					WithField("trusted", c.tb.Contains(root))
+			}
+			entry.Error("no chain to a trusted root")
+			return
+		}
+		for _, cert := range chains[0][1:] {
+			c.logger.WithField("subject", cert.Subject.String()).Info("verified path")
+		}
+	})
+}
+
+// issuerSource tells where issuer comes from (see [sourceProvided] and co.).
+func (c *ekchecker) issuerSource(issuer *x509.Certificate, chain []*x509.Certificate) string {
+	switch {
+	case slices.ContainsFunc(chain, issuer.Equal):
+		return sourceProvided
+	case slices.ContainsFunc(c.intermediates, issuer.Equal):
+		return sourceLocal
+	case c.tb.Contains(issuer):
+		return sourceBundle
+	default:
+		return sourceAIA
+	}
+}
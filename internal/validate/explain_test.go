@@ -0,0 +1,83 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	intermediate, intermediateKey := createTestIntermediate(t, root, rootKey)
+	ek := createTestEK(t, intermediate, intermediateKey)
+
+	tests := []struct {
+		name          string
+		intermediates []*x509.Certificate
+		explain       bool
+		want          []string
+	}{
+		{
+			name:          "trusted",
+			intermediates: []*x509.Certificate{intermediate},
+			explain:       true,
+			want: []string{
+				`"source":"local intermediates"`,
+				`"source":"trusted bundle","trusted":true`,
+				`"msg":"verified path"`,
+			},
+		},
+		{
+			name:    "missing intermediate",
+			explain: true,
+			want: []string{
+				`"msg":"issuer not found"`,
+				`"issuer":"CN=Test Intermediate CA"`,
+				`"error":"certificate chain cannot be completed"`,
+			},
+		},
+		{
+			name:          "disabled",
+			intermediates: []*x509.Certificate{intermediate},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					return nil, errors.New("network is unreachable")
+				}},
+				Intermediates: tc.intermediates,
+				Logger:        log.NewJSONLogger(&buf, false),
+				Explain:       tc.explain,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = checker.(*ekchecker).verifyChain(t.Context(), ek, nil, true, false, ErrUntrustedCertificate)
+
+			out := buf.String()
+			for _, want := range tc.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output does not contain %s:\n%s", want, out)
+				}
+			}
+			if !tc.explain && strings.Contains(out, "chain building") {
+				t.Errorf("unexpected explanation:\n%s", out)
+			}
+		})
+	}
+}
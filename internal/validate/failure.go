@@ -0,0 +1,39 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	goutils "github.com/loicsikidi/go-utils"
+)
+
+// CheckErrors are the failures of a check which went on after the first one
+// (see [CheckConfig.CollectErrors]), in the order they were found.
+type CheckErrors []error
+
+func (e CheckErrors) Error() string {
+	return fmt.Sprintf("%d checks failed: %s", len(e), strings.Join(goutils.Map(e, error.Error), "; "))
+}
+
+func (e CheckErrors) Unwrap() []error {
+	return e
+}
+
+// withFailures returns a copy of the checker collecting the failures
+// of a check into failures (if set) instead of stopping at the first one.
+func (c *ekchecker) withFailures(failures *[]error) *ekchecker {
+	checker := *c
+	checker.failures = failures
+	return &checker
+}
+
+// fail returns err, unless the failures of the check are collected: err is
+// then collected and nil is returned so that the check goes on.
+func (c *ekchecker) fail(err error) error {
+	if err == nil || c.failures == nil {
+		return err
+	}
+	c.logger.WithError(err).Debug("check failed, going on")
+	*c.failures = append(*c.failures, err)
+	return nil
+}
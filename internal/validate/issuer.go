@@ -0,0 +1,134 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"slices"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+// maxChainDepth bounds the number of issuers resolved from the trusted bundle.
+const maxChainDepth = 10
+
+// ErrPathLenExceeded is returned when a chain has more intermediates below
+// a CA than allowed by its basicConstraints pathLenConstraint.
+var ErrPathLenExceeded = codes.New(codes.E019PathLenExceeded, "chain exceeds the path length constraint of a CA")
+
+// bundleIssuers resolves the issuers of cert which are part of the trusted bundle,
+// walking up the chain (the issuers provided in chain are used but not returned).
+//
+// The verifier looks up the trusted bundle by subject only: if a CA re-keyed,
+// it may pick the wrong generation and fall back to AIA downloads (or fail).
+// Passing the issuers resolved here along with chain avoids the ambiguity.
+func (c *ekchecker) bundleIssuers(cert *x509.Certificate, chain []*x509.Certificate) []*x509.Certificate {
+	var issuers []*x509.Certificate
+	current := cert
+	for below := range maxChainDepth {
+		if x509util.IsRoot(current) {
+			break
+		}
+		issuer := selectIssuer(current, chain, below)
+		if issuer == nil {
+			if issuer = c.bundleIssuer(current, below); issuer == nil {
+				break
+			}
+			issuers = append(issuers, issuer)
+		}
+		current = issuer
+	}
+	return issuers
+}
+
+// trustedRoots returns the roots of the trusted bundle (or the trust anchor).
+func (c *ekchecker) trustedRoots() []*x509.Certificate {
+	var roots []*x509.Certificate
+	c.tb.ContainsFunc(func(candidate *x509.Certificate) bool {
+		if x509util.IsRoot(candidate) {
+			roots = append(roots, candidate)
+		}
+		return false // visit every certificate
+	})
+	return roots
+}
+
+// bundleIssuer returns the issuer of cert from the trusted bundle, if any,
+// below being the number of intermediates between the EK and cert.
+func (c *ekchecker) bundleIssuer(cert *x509.Certificate, below int) *x509.Certificate {
+	var candidates []*x509.Certificate
+	c.tb.ContainsFunc(func(candidate *x509.Certificate) bool {
+		if bytes.Equal(candidate.RawSubject, cert.RawIssuer) {
+			candidates = append(candidates, candidate)
+		}
+		return false // visit every certificate
+	})
+	return selectIssuer(cert, candidates, below)
+}
+
+// selectIssuer returns the candidate which issued cert. Candidates whose
+// Subject Key Identifier matches the Authority Key Identifier of cert are
+// tried first, as several generations of a re-keyed CA share the same subject.
+// Candidates whose path length constraint does not allow below intermediates
+// under them are skipped.
+func selectIssuer(cert *x509.Certificate, candidates []*x509.Certificate, below int) *x509.Certificate {
+	candidates = slices.Clone(candidates)
+	slices.SortStableFunc(candidates, func(a, b *x509.Certificate) int {
+		return keyIDPriority(cert, a) - keyIDPriority(cert, b)
+	})
+	for _, candidate := range candidates {
+		if bytes.Equal(candidate.RawSubject, cert.RawIssuer) && allowsPathLen(candidate, below) && cert.CheckSignatureFrom(candidate) == nil {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// allowsPathLen reports whether the basicConstraints of ca allow below
+// intermediates between the EK and ca. A CA without pathLenConstraint
+// allows any number of them.
+func allowsPathLen(ca *x509.Certificate, below int) bool {
+	if !ca.BasicConstraintsValid || ca.MaxPathLen < 0 || (ca.MaxPathLen == 0 && !ca.MaxPathLenZero) {
+		return true
+	}
+	return below <= ca.MaxPathLen
+}
+
+// checkPathLen checks the path length constraint of the issuers of a
+// certificate, ordered from its direct issuer up to the root.
+func checkPathLen(issuers []*x509.Certificate) error {
+	for below, issuer := range issuers {
+		if !allowsPathLen(issuer, below) {
+			return fmt.Errorf("%w: %q allows %d intermediate(s) below it, got %d",
+				ErrPathLenExceeded, issuer.Subject.String(), issuer.MaxPathLen, below)
+		}
+	}
+	return nil
+}
+
+func keyIDPriority(cert, candidate *x509.Certificate) int {
+	if len(cert.AuthorityKeyId) > 0 && bytes.Equal(cert.AuthorityKeyId, candidate.SubjectKeyId) {
+		return 0
+	}
+	return 1
+}
+
+// crossCandidates returns the CA certificates of certs which can be part of
+// a path (ie. intermediates and cross-certificates, roots being only trusted
+// from the trusted bundle).
+//
+// Unlike [x509util.CertVerifier.GetFullChain], which follows a single issuer
+// per certificate, [x509.Certificate.Verify] tries every candidate issuer of
+// its pool: it finds a path through a cross-certificate (the same key signed
+// by several CAs) as long as the cross-certificate is in the pool.
+func crossCandidates(certs []*x509.Certificate) []*x509.Certificate {
+	var candidates []*x509.Certificate
+	for _, cert := range certs {
+		if cert.IsCA && !x509util.IsRoot(cert) && !slices.ContainsFunc(candidates, cert.Equal) {
+			candidates = append(candidates, cert)
+		}
+	}
+	return candidates
+}
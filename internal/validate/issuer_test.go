@@ -0,0 +1,508 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+	"github.com/loicsikidi/tpm-trust/internal/pkcs7"
+)
+
+func TestBundleIssuer(t *testing.T) {
+	t.Parallel()
+
+	// Both generations of the re-keyed CA share the same subject
+	oldRoot, _ := createTestCA(t)
+	newRoot, newKey := createTestCA(t)
+	ek := createTestEK(t, newRoot, newKey)
+	if !bytes.Equal(oldRoot.RawSubject, newRoot.RawSubject) {
+		t.Fatal("test CAs must share the same subject")
+	}
+
+	tests := []struct {
+		name  string
+		certs []*x509.Certificate
+		want  *x509.Certificate
+	}{
+		{
+			name:  "success/current-generation-first",
+			certs: []*x509.Certificate{newRoot, oldRoot},
+			want:  newRoot,
+		},
+		{
+			name:  "success/previous-generation-first",
+			certs: []*x509.Certificate{oldRoot, newRoot},
+			want:  newRoot,
+		},
+		{
+			name:  "error/issuer-not-in-bundle",
+			certs: []*x509.Certificate{oldRoot},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &ekchecker{logger: log.New(log.WithNoop()), tb: &certsTrustedBundle{certs: tc.certs}}
+			got := c.bundleIssuer(ek, 0)
+			if got != tc.want {
+				t.Fatalf("bundleIssuer() = %v, want %v", got, tc.want)
+			}
+
+			issuers := c.bundleIssuers(ek, nil)
+			if tc.want == nil && len(issuers) > 0 {
+				t.Errorf("bundleIssuers() = %d issuer(s), want none", len(issuers))
+			}
+			if tc.want != nil && !slices.Equal(issuers, []*x509.Certificate{tc.want}) {
+				t.Errorf("bundleIssuers() did not resolve the issuer")
+			}
+		})
+	}
+}
+
+func TestSelectIssuerPrefersAuthorityKeyID(t *testing.T) {
+	t.Parallel()
+
+	root, key := createTestCA(t)
+	ek := createTestEK(t, root, key)
+	if len(ek.AuthorityKeyId) == 0 || !bytes.Equal(ek.AuthorityKeyId, root.SubjectKeyId) {
+		t.Fatal("EK authority key ID must match the issuer subject key ID")
+	}
+	if got := keyIDPriority(ek, root); got != 0 {
+		t.Errorf("keyIDPriority() = %d, want 0", got)
+	}
+	other, _ := createTestCA(t)
+	if got := keyIDPriority(ek, other); got != 1 {
+		t.Errorf("keyIDPriority() = %d, want 1", got)
+	}
+}
+
+// BenchmarkSelectIssuer measures the selection of the issuer of the EK among
+// candidates sharing its subject (eg. generations of a re-keyed CA) and
+// unrelated ones, the issuer being the last candidate before they are
+// sorted by key identifier.
+func BenchmarkSelectIssuer(b *testing.B) {
+	root, rootKey := createTestCA(b)
+	ek := createTestEK(b, root, rootKey)
+	var candidates []*x509.Certificate
+	for range 50 {
+		rekeyed, rekeyedKey := createTestCA(b)
+		candidates = append(candidates, rekeyed)
+		unrelated, _ := createTestIntermediate(b, rekeyed, rekeyedKey)
+		candidates = append(candidates, unrelated)
+	}
+	candidates = append(candidates, root)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if issuer := selectIssuer(ek, candidates, 0); issuer != root {
+			b.Fatalf("selectIssuer() = %v, want the root", issuer)
+		}
+	}
+}
+
+// certsTrustedBundle is a trusted bundle made of the provided certificates.
+type certsTrustedBundle struct {
+	apiv1beta.TrustedBundle
+	certs []*x509.Certificate
+}
+
+func (b *certsTrustedBundle) ContainsFunc(fn func(c *x509.Certificate) bool) bool {
+	return slices.ContainsFunc(b.certs, fn)
+}
+
+func TestVerifyChainWithLocalIntermediates(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	intermediate, intermediateKey := createTestIntermediate(t, root, rootKey)
+	ek := createTestEK(t, intermediate, intermediateKey)
+
+	tests := []struct {
+		name          string
+		intermediates []*x509.Certificate
+		wantErr       bool
+	}{
+		{
+			name:          "success/local-intermediate",
+			intermediates: []*x509.Certificate{root, intermediate},
+		},
+		{
+			name:    "error/missing-intermediate",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var downloads atomic.Int32
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					downloads.Add(1)
+					return nil, errors.New("network is unreachable")
+				}},
+				Intermediates: tc.intermediates,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = checker.(*ekchecker).verifyChain(t.Context(), ek, nil, true, false, ErrUntrustedCertificate)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("verifyChain() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && downloads.Load() > 0 {
+				t.Errorf("downloads = %d, want none", downloads.Load())
+			}
+		})
+	}
+}
+
+func TestVerifyChainWithRegionalIntermediate(t *testing.T) {
+	t.Parallel()
+
+	// Two generations of the vendor root share the same subject
+	root, rootKey := createTestCA(t)
+	previousRoot, _ := createTestCA(t)
+	regional, regionalKey := createTestIntermediate(t, root, rootKey)
+	ek := createTestEKWithAIA(t, regional, regionalKey)
+
+	tests := []struct {
+		name    string
+		bundle  []*x509.Certificate
+		wantErr bool
+	}{
+		{name: "success/exact-root", bundle: []*x509.Certificate{root}},
+		// The first root of the bundle matching the issuer subject did not issue the regional intermediate
+		{name: "success/other-root-first", bundle: []*x509.Certificate{previousRoot, root}},
+		{name: "error/untrusted-root", bundle: []*x509.Certificate{previousRoot}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: tc.bundle},
+				// The regional intermediate is not in the bundle and the root cannot be downloaded
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					if req.URL.String() == "http://example.com/intermediate.cer" {
+						return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(regional.Raw))}, nil
+					}
+					return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			chains, err := checker.(*ekchecker).verifyChain(t.Context(), ek, nil, true, false, ErrUntrustedCertificate)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("verifyChain() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && !chains[0][len(chains[0])-1].Equal(root) {
+				t.Errorf("verifyChain() root = %q, want the root which issued the regional intermediate", chains[0][len(chains[0])-1].Subject)
+			}
+		})
+	}
+}
+
+func TestVerifyChainWithPKCS7Issuer(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	intermediate, intermediateKey := createTestIntermediate(t, root, rootKey)
+	ek := createTestEKWithAIA(t, intermediate, intermediateKey)
+	// Some AIA endpoints serve the issuer along with its own issuer (.p7c)
+	bundle, err := pkcs7.MarshalCertificates(root.Raw, intermediate.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker, err := NewEKChecker(EKCheckerConfig{
+		TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+		HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.String() == "http://example.com/intermediate.cer" {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"application/pkcs7-mime"}},
+					Body:       io.NopCloser(bytes.NewReader(bundle)),
+				}, nil
+			}
+			return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chains, err := checker.(*ekchecker).verifyChain(t.Context(), ek, nil, true, false, ErrUntrustedCertificate)
+	if err != nil {
+		t.Fatalf("verifyChain() error = %v", err)
+	}
+	if !chains[0][1].Equal(intermediate) {
+		t.Errorf("verifyChain() issuer = %q, want %q", chains[0][1].Subject, intermediate.Subject)
+	}
+}
+
+func createTestIntermediate(t testing.TB, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		IssuingCertificateURL: []string{"http://example.com/root.cer"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func (b *certsTrustedBundle) Contains(cert *x509.Certificate) bool {
+	return slices.ContainsFunc(b.certs, cert.Equal)
+}
+
+func (b *certsTrustedBundle) FindFunc(fn func(c *x509.Certificate) bool) *x509.Certificate {
+	if i := slices.IndexFunc(b.certs, fn); i >= 0 {
+		return b.certs[i]
+	}
+	return nil
+}
+
+func (b *certsTrustedBundle) GetRootCertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range b.certs {
+		if x509util.IsRoot(cert) {
+			pool.AddCert(cert)
+		}
+	}
+	return pool
+}
+
+func (b *certsTrustedBundle) GetIntermediateCertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range b.certs {
+		if !x509util.IsRoot(cert) {
+			pool.AddCert(cert)
+		}
+	}
+	return pool
+}
+
+func TestVerifyChainWithCrossCertificate(t *testing.T) {
+	t.Parallel()
+
+	trustedRoot, trustedKey := createTestCA(t)
+	untrustedRoot, untrustedKey := createTestCA(t)
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same CA key certified by both roots
+	crossTrusted := createTestCrossCert(t, caKey, trustedRoot, trustedKey)
+	crossUntrusted := createTestCrossCert(t, caKey, untrustedRoot, untrustedKey)
+	ek := createTestEK(t, crossTrusted, caKey)
+
+	tests := []struct {
+		name          string
+		chain         []*x509.Certificate
+		intermediates []*x509.Certificate
+		wantErr       bool
+	}{
+		{
+			name:          "success/untrusted-path-first",
+			chain:         []*x509.Certificate{crossUntrusted, untrustedRoot},
+			intermediates: []*x509.Certificate{crossTrusted},
+		},
+		{
+			name:          "success/broken-path-first",
+			chain:         []*x509.Certificate{crossUntrusted},
+			intermediates: []*x509.Certificate{crossTrusted},
+		},
+		{
+			name:    "error/untrusted-path-only",
+			chain:   []*x509.Certificate{crossUntrusted, untrustedRoot},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{trustedRoot}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					return nil, errors.New("network is unreachable")
+				}},
+				Intermediates: tc.intermediates,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			chains, err := checker.(*ekchecker).verifyChain(t.Context(), ek, tc.chain, true, false, ErrUntrustedCertificate)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("verifyChain() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && !chains[0][1].Equal(crossTrusted) {
+				t.Errorf("verifyChain() did not go through the trusted cross-certificate")
+			}
+		})
+	}
+}
+
+func createTestCrossCert(t *testing.T, key *ecdsa.PrivateKey, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(3),
+		Subject:               pkix.Name{CommonName: "Test Cross-Certified CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestVerifyChainPathLen(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+
+	tests := []struct {
+		name       string
+		maxPathLen int // of the CA issued by the root
+		wantErr    bool
+	}{
+		{name: "success/unconstrained", maxPathLen: -1},
+		{name: "success/path-len-1", maxPathLen: 1},
+		// The CA may only issue end-entity certificates, not another CA
+		{name: "error/path-len-0", maxPathLen: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ca, caKey := createTestPathLenCA(t, "Test Path Length CA", root, rootKey, tc.maxPathLen)
+			issuing, issuingKey := createTestPathLenCA(t, "Test Issuing CA", ca, caKey, -1)
+			ek := createTestEK(t, issuing, issuingKey)
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root, ca}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					return nil, errors.New("network is unreachable")
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = checker.(*ekchecker).verifyChain(t.Context(), ek, []*x509.Certificate{issuing}, true, false, ErrUntrustedCertificate)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("verifyChain() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr && !errors.Is(err, ErrPathLenExceeded) {
+				t.Errorf("verifyChain() error = %v, want %v", err, ErrPathLenExceeded)
+			}
+		})
+	}
+}
+
+func TestBundleIssuerPathLen(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ca, caKey := createTestPathLenCA(t, "Test Path Length CA", root, rootKey, 0)
+	issuing, _ := createTestPathLenCA(t, "Test Issuing CA", ca, caKey, -1)
+	c := &ekchecker{logger: log.New(log.WithNoop()), tb: &certsTrustedBundle{certs: []*x509.Certificate{root, ca}}}
+
+	// ca may issue the EK directly, not the issuing CA of the EK
+	if got := c.bundleIssuer(issuing, 0); got != ca {
+		t.Errorf("bundleIssuer(0) = %v, want %v", got, ca)
+	}
+	if got := c.bundleIssuer(issuing, 1); got != nil {
+		t.Errorf("bundleIssuer(1) = %v, want none", got.Subject)
+	}
+	if err := checkPathLen([]*x509.Certificate{issuing, ca, root}); !errors.Is(err, ErrPathLenExceeded) {
+		t.Errorf("checkPathLen() error = %v, want %v", err, ErrPathLenExceeded)
+	}
+}
+
+// createTestPathLenCA creates an intermediate CA named cn with the given
+// path length constraint (negative: none).
+func createTestPathLenCA(t *testing.T, cn string, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, maxPathLen int) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(4),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		MaxPathLen:            maxPathLen,
+		MaxPathLenZero:        maxPathLen == 0,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
@@ -0,0 +1,47 @@
+package validate
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+// ErrInvalidKeyUsage is returned in strict mode when the key usage of the EK
+// certificate doesn't conform to the TCG EK Credential Profile.
+var ErrInvalidKeyUsage = codes.New(codes.E025InvalidKeyUsage, "EK certificate key usage does not conform to the TCG EK Credential Profile")
+
+// requiredKeyUsage returns the key usage which must be asserted by an EK
+// certificate holding pub, as per TCG EK Credential Profile ("Key Usage"):
+// keyEncipherment for RSA EKs (used to decrypt credentials) and keyAgreement
+// for ECC EKs (used in ECDH). false is returned for other key types, which the
+// profile doesn't define.
+func requiredKeyUsage(pub any) (x509.KeyUsage, string, bool) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return x509.KeyUsageKeyEncipherment, "keyEncipherment", true
+	case *ecdsa.PublicKey:
+		return x509.KeyUsageKeyAgreement, "keyAgreement", true
+	default:
+		return 0, "", false
+	}
+}
+
+// checkKeyUsage verifies that the key usage of the EK certificate conforms to
+// the profile of its key type. A nonconforming certificate only triggers a
+// warning, unless strict is set in which case an error is returned.
+func (c *ekchecker) checkKeyUsage(cert *x509.Certificate, strict bool) error {
+	usage, name, ok := requiredKeyUsage(cert.PublicKey)
+	if !ok || cert.KeyUsage&usage != 0 {
+		return nil
+	}
+	detail := fmt.Sprintf("%s EK certificate must assert %s", cert.PublicKeyAlgorithm, name)
+	if strict {
+		return fmt.Errorf("%w: %s", ErrInvalidKeyUsage, detail)
+	}
+	c.warn(c.logger.WithField("expected", name), codes.W011InvalidKeyUsage,
+		"certificate key usage does not conform to the TCG EK Credential Profile", detail)
+	return nil
+}
@@ -0,0 +1,86 @@
+package validate
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+func TestCheckKeyUsage(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eccKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		pub         crypto.PublicKey
+		usage       x509.KeyUsage
+		strict      bool
+		wantWarning bool
+		wantErr     bool
+	}{
+		{name: "rsa/conforming", pub: &rsaKey.PublicKey, usage: x509.KeyUsageKeyEncipherment},
+		{name: "rsa/key-agreement", pub: &rsaKey.PublicKey, usage: x509.KeyUsageKeyAgreement, wantWarning: true},
+		{name: "rsa/missing", pub: &rsaKey.PublicKey, wantWarning: true},
+		{name: "rsa/missing/strict", pub: &rsaKey.PublicKey, strict: true, wantErr: true},
+		{name: "ecc/conforming", pub: &eccKey.PublicKey, usage: x509.KeyUsageKeyAgreement},
+		{name: "ecc/conforming/strict", pub: &eccKey.PublicKey, usage: x509.KeyUsageKeyAgreement | x509.KeyUsageDigitalSignature, strict: true},
+		{name: "ecc/key-encipherment", pub: &eccKey.PublicKey, usage: x509.KeyUsageKeyEncipherment, wantWarning: true},
+		{name: "ecc/key-encipherment/strict", pub: &eccKey.PublicKey, usage: x509.KeyUsageKeyEncipherment, strict: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpl := &x509.Certificate{
+				SerialNumber: big.NewInt(42),
+				NotBefore:    time.Now().Add(-time.Hour),
+				NotAfter:     time.Now().Add(time.Hour),
+				KeyUsage:     tc.usage,
+			}
+			der, err := x509.CreateCertificate(rand.Reader, tmpl, root, tc.pub, rootKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checker, err := NewEKChecker(EKCheckerConfig{TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var warnings []Warning
+			err = checker.(*ekchecker).withWarnings(&warnings).checkKeyUsage(cert, tc.strict)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("checkKeyUsage() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidKeyUsage) {
+				t.Errorf("checkKeyUsage() error = %v, want %v", err, ErrInvalidKeyUsage)
+			}
+			if gotWarning := len(warnings) == 1 && warnings[0].Code == codes.W011InvalidKeyUsage; gotWarning != tc.wantWarning || len(warnings) > 1 {
+				t.Errorf("checkKeyUsage() warnings = %v, wantWarning %v", warnings, tc.wantWarning)
+			}
+		})
+	}
+}
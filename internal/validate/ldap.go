@@ -0,0 +1,91 @@
+//go:build ldap
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func init() {
+	supportedCRLSchemes = append(supportedCRLSchemes, "ldap")
+}
+
+// defaultLDAPCRLAttribute is the attribute read when an LDAP distribution
+// point doesn't name one.
+const defaultLDAPCRLAttribute = "certificateRevocationList;binary"
+
+// fetchLDAPCRL reads anonymously the CRL of the LDAP distribution point dp,
+// ie. the attribute of the entry named by the URL (RFC 4516), eg.
+// ldap://ldap.example.com/CN=Example%20CA,O=Example?certificateRevocationList;binary.
+func fetchLDAPCRL(ctx context.Context, dp string) ([]byte, error) {
+	addr, dn, attr, err := parseLDAPURL(dp)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	nc, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := ldap.NewConn(nc, false)
+	conn.Start()
+	defer conn.Close() //nolint:errcheck
+	// Searches are not bound to a context
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetTimeout(time.Until(deadline))
+	}
+
+	req := ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, 0, false, "(objectClass=*)", []string{attr}, nil)
+	res, err := conn.Search(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LDAP entry %q: %w", dn, err)
+	}
+	for _, entry := range res.Entries {
+		// Servers may return the attribute without the binary option
+		for _, name := range []string{attr, strings.TrimSuffix(attr, ";binary")} {
+			if values := entry.GetEqualFoldRawAttributeValues(name); len(values) > 0 {
+				return values[0], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("LDAP entry %q has no %s attribute", dn, attr)
+}
+
+// parseLDAPURL returns the address of the server (with the default port
+// if none is set), the DN of the entry and the attribute (the first one
+// listed, if any) of the LDAP URL dp.
+func parseLDAPURL(dp string) (addr, dn, attr string, err error) {
+	u, err := url.Parse(dp)
+	if err != nil {
+		return "", "", "", err
+	}
+	if u.Hostname() == "" {
+		return "", "", "", fmt.Errorf("LDAP URL without host: %s", dp)
+	}
+	addr = u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "389")
+	}
+	dn = strings.TrimPrefix(u.Path, "/")
+	if dn == "" {
+		return "", "", "", fmt.Errorf("LDAP URL without DN: %s", dp)
+	}
+	attr = defaultLDAPCRLAttribute
+	if attrs, _, _ := strings.Cut(u.RawQuery, "?"); attrs != "" {
+		first, _, _ := strings.Cut(attrs, ",")
+		if attr, err = url.PathUnescape(first); err != nil {
+			return "", "", "", fmt.Errorf("invalid attribute in LDAP URL %s: %w", dp, err)
+		}
+	}
+	return addr, dn, attr, nil
+}
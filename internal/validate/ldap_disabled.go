@@ -0,0 +1,14 @@
+//go:build !ldap
+
+package validate
+
+import (
+	"context"
+	"errors"
+)
+
+// fetchLDAPCRL fails as LDAP distribution points are only supported by
+// builds with the ldap tag: they are skipped as unsupported otherwise.
+func fetchLDAPCRL(context.Context, string) ([]byte, error) {
+	return nil, errors.New("LDAP CRL distribution points require a build with the ldap tag")
+}
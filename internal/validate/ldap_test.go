@@ -0,0 +1,165 @@
+//go:build ldap
+
+package validate
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+const testLDAPCRLDP = "ldap://ldap.example.com/CN=Example%20CA,O=Example?certificateRevocationList;binary"
+
+func TestParseLDAPURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dp       string
+		wantAddr string
+		wantDN   string
+		wantAttr string
+		wantErr  bool
+	}{
+		{name: "attribute", dp: testLDAPCRLDP, wantAddr: "ldap.example.com:389", wantDN: "CN=Example CA,O=Example", wantAttr: "certificateRevocationList;binary"},
+		{name: "default-attribute", dp: "ldap://ldap.example.com:1389/CN=CA", wantAddr: "ldap.example.com:1389", wantDN: "CN=CA", wantAttr: defaultLDAPCRLAttribute},
+		{name: "first-attribute", dp: "ldap://ldap.example.com/CN=CA?authorityRevocationList;binary,certificateRevocationList;binary?base", wantAddr: "ldap.example.com:389", wantDN: "CN=CA", wantAttr: "authorityRevocationList;binary"},
+		{name: "no-host", dp: "ldap:///CN=CA", wantErr: true},
+		{name: "no-dn", dp: "ldap://ldap.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			addr, dn, attr, err := parseLDAPURL(tc.dp)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseLDAPURL() error = %v, want error: %v", err, tc.wantErr)
+			}
+			if addr != tc.wantAddr || dn != tc.wantDN || attr != tc.wantAttr {
+				t.Errorf("parseLDAPURL() = %q, %q, %q, want %q, %q, %q", addr, dn, attr, tc.wantAddr, tc.wantDN, tc.wantAttr)
+			}
+		})
+	}
+}
+
+func TestFetchLDAPCRLUnreachable(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	if _, err := fetchLDAPCRL(t.Context(), "ldap://"+addr+"/CN=CA"); err == nil {
+		t.Error("fetchLDAPCRL() expected an error for an unreachable server")
+	}
+}
+
+func TestCheckLDAPCRL(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	otherRoot, otherKey := createTestCA(t)
+	now := time.Now()
+	validCRL := createTestNumberedCRL(t, root, rootKey, 5, now.Add(time.Hour))
+	revokedCRL := createTestCRL(t, root, rootKey, now.Add(time.Hour), big.NewInt(42))
+	olderCRL := createTestNumberedCRL(t, root, rootKey, 3, now.Add(time.Hour))
+	foreignCRL := createTestCRL(t, otherRoot, otherKey, now.Add(time.Hour))
+	// Expired, hence the CRL is fetched again
+	cachedCRL := createTestNumberedCRL(t, root, rootKey, 4, now.Add(-time.Minute))
+
+	ldapOnly := []string{testLDAPCRLDP}
+	httpFirst := []string{testCRLDP, testLDAPCRLDP}
+
+	tests := []struct {
+		name        string
+		dps         []string
+		crl         *x509.RevocationList
+		fetchErr    error
+		wantErr     error
+		wantWarning codes.Code
+		wantFetch   bool
+	}{
+		{name: "valid", dps: ldapOnly, crl: validCRL, wantFetch: true},
+		{name: "revoked", dps: ldapOnly, crl: revokedCRL, wantErr: x509util.ErrCertificateRevoked, wantFetch: true},
+		{name: "rollback", dps: ldapOnly, crl: olderCRL, wantErr: ErrCRLRollback, wantFetch: true},
+		{name: "foreign", dps: ldapOnly, crl: foreignCRL, wantErr: x509util.ErrUnknownAuthorityError, wantFetch: true},
+		{name: "unreachable", dps: ldapOnly, fetchErr: errors.New("connection refused"), wantErr: x509util.ErrCRLNotFound, wantWarning: codes.W003UnreachableCRLDP, wantFetch: true},
+		{name: "http-first", dps: httpFirst, crl: validCRL},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ek := createTestCert(t, &x509.Certificate{
+				SerialNumber:          big.NewInt(42),
+				NotBefore:             now.Add(-time.Hour),
+				NotAfter:              now.Add(time.Hour),
+				KeyUsage:              x509.KeyUsageKeyAgreement,
+				CRLDistributionPoints: tc.dps,
+			}, root, rootKey)
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(validCRL.Raw))}, nil
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fetched := false
+			checker.(*ekchecker).recorder.ldap = func(_ context.Context, dp string) ([]byte, error) {
+				if dp != testLDAPCRLDP {
+					t.Errorf("fetched %s, want %s", dp, testLDAPCRLDP)
+				}
+				fetched = true
+				if tc.fetchErr != nil {
+					return nil, tc.fetchErr
+				}
+				return tc.crl.Raw, nil
+			}
+
+			cache := &Cache{Chain: []*x509.Certificate{root}, CRLs: []*x509.RevocationList{cachedCRL}}
+			var warnings []Warning
+			err = checker.Check(CheckConfig{EK: endorsement.EK{Certificate: ek}, Cache: cache, Warnings: &warnings})
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Fatalf("Check() error = %v, want %v", err, tc.wantErr)
+			}
+			if fetched != tc.wantFetch {
+				t.Errorf("LDAP CRL fetched = %v, want %v", fetched, tc.wantFetch)
+			}
+			if tc.wantWarning != "" && !slices.ContainsFunc(warnings, func(w Warning) bool { return w.Code == tc.wantWarning }) {
+				t.Errorf("warnings = %v, want %s", warnings, tc.wantWarning)
+			}
+			if slices.ContainsFunc(warnings, func(w Warning) bool { return w.Code == codes.W002UnsupportedScheme }) {
+				t.Errorf("warnings = %v, want no %s", warnings, codes.W002UnsupportedScheme)
+			}
+			if err != nil {
+				return
+			}
+			// The CRL is cached, hence the check works offline
+			if len(cache.CRLs) == 0 || !bytes.Equal(cache.CRLs[0].Raw, tc.crl.Raw) {
+				t.Errorf("cached CRLs = %d, want the fetched CRL", len(cache.CRLs))
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+var (
+	ErrUntrustedPlatformCertificate = codes.New(codes.E023UntrustedPlatform, "platform certificate trust could not be established")
+	ErrPlatformEKMismatch           = codes.New(codes.E024PlatformEKMismatch, "platform certificate doesn't reference the EK certificate")
+)
+
+// pemPlatformCertificate is the PEM block type of attribute certificates (RFC 5755).
+const pemPlatformCertificate = "ATTRIBUTE CERTIFICATE"
+
+// signatureAlgorithms maps the OIDs of the signature algorithms supported for
+// platform certificates to their [x509.SignatureAlgorithm].
+var signatureAlgorithms = map[string]x509.SignatureAlgorithm{
+	"1.2.840.113549.1.1.11": x509.SHA256WithRSA,
+	"1.2.840.113549.1.1.12": x509.SHA384WithRSA,
+	"1.2.840.113549.1.1.13": x509.SHA512WithRSA,
+	"1.2.840.10045.4.3.2":   x509.ECDSAWithSHA256,
+	"1.2.840.10045.4.3.3":   x509.ECDSAWithSHA384,
+	"1.2.840.10045.4.3.4":   x509.ECDSAWithSHA512,
+}
+
+// PlatformCertificate is a TCG Platform Certificate: an attribute certificate
+// (RFC 5755) issued by the platform manufacturer, which describes the platform
+// and references the EK certificate of its TPM.
+type PlatformCertificate struct {
+	Raw          []byte
+	SerialNumber *big.Int
+	Issuer       pkix.Name
+	NotBefore    time.Time
+	NotAfter     time.Time
+	// HolderSerial is the serial number of the referenced EK certificate.
+	HolderSerial *big.Int
+
+	rawTBS             []byte
+	rawIssuer          []byte
+	rawHolderIssuer    []byte
+	signatureAlgorithm asn1.ObjectIdentifier
+	signature          []byte
+}
+
+// ASN.1 structures of attribute certificates (RFC 5755, section 4.1).
+type (
+	attributeCertificate struct {
+		Info               asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		SignatureValue     asn1.BitString
+	}
+	attributeCertificateInfo struct {
+		Version        int
+		Holder         holder
+		Issuer         asn1.RawValue
+		Signature      pkix.AlgorithmIdentifier
+		SerialNumber   *big.Int
+		Validity       attCertValidityPeriod
+		Attributes     asn1.RawValue
+		IssuerUniqueID asn1.BitString   `asn1:"optional"`
+		Extensions     []pkix.Extension `asn1:"optional"`
+	}
+	holder struct {
+		BaseCertificateID issuerSerial  `asn1:"optional,tag:0"`
+		EntityName        asn1.RawValue `asn1:"optional,tag:1"`
+		ObjectDigestInfo  asn1.RawValue `asn1:"optional,tag:2"`
+	}
+	issuerSerial struct {
+		Issuer    asn1.RawValue
+		Serial    *big.Int
+		IssuerUID asn1.BitString `asn1:"optional"`
+	}
+	v2Form struct {
+		IssuerName asn1.RawValue
+	}
+	attCertValidityPeriod struct {
+		NotBefore time.Time `asn1:"generalized"`
+		NotAfter  time.Time `asn1:"generalized"`
+	}
+)
+
+// ParsePlatformCertificate parses a PEM or DER encoded platform certificate.
+func ParsePlatformCertificate(data []byte) (*PlatformCertificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != pemPlatformCertificate {
+			return nil, fmt.Errorf("unexpected PEM block %q (want %q)", block.Type, pemPlatformCertificate)
+		}
+		data = block.Bytes
+	}
+
+	var ac attributeCertificate
+	if _, err := asn1.Unmarshal(data, &ac); err != nil {
+		return nil, fmt.Errorf("failed to parse attribute certificate: %w", err)
+	}
+	var info attributeCertificateInfo
+	if _, err := asn1.Unmarshal(ac.Info.FullBytes, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse attribute certificate info: %w", err)
+	}
+	if info.Holder.BaseCertificateID.Serial == nil {
+		return nil, errors.New("attribute certificate holder doesn't reference a certificate")
+	}
+	rawHolderIssuer, err := directoryName(info.Holder.BaseCertificateID.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid holder issuer: %w", err)
+	}
+	// Only the v2Form ([0]) is allowed for the issuer (RFC 5755, section 4.2.3)
+	var form v2Form
+	if _, err := asn1.UnmarshalWithParams(info.Issuer.FullBytes, &form, "tag:0"); err != nil {
+		return nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+	rawIssuer, err := directoryName(form.IssuerName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+	var rdns pkix.RDNSequence
+	if _, err := asn1.Unmarshal(rawIssuer, &rdns); err != nil {
+		return nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+
+	pc := &PlatformCertificate{
+		Raw:                data,
+		SerialNumber:       info.SerialNumber,
+		NotBefore:          info.Validity.NotBefore,
+		NotAfter:           info.Validity.NotAfter,
+		HolderSerial:       info.Holder.BaseCertificateID.Serial,
+		rawTBS:             ac.Info.FullBytes,
+		rawIssuer:          rawIssuer,
+		rawHolderIssuer:    rawHolderIssuer,
+		signatureAlgorithm: ac.SignatureAlgorithm.Algorithm,
+		signature:          ac.SignatureValue.RightAlign(),
+	}
+	pc.Issuer.FillFromRDNSequence(&rdns)
+	return pc, nil
+}
+
+// directoryName returns the first directory name (DER encoded) of GeneralNames.
+func directoryName(generalNames asn1.RawValue) ([]byte, error) {
+	rest := generalNames.Bytes
+	for len(rest) > 0 {
+		var name asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &name); err != nil {
+			return nil, err
+		}
+		if name.Class == asn1.ClassContextSpecific && name.Tag == 4 {
+			return name.Bytes, nil
+		}
+	}
+	return nil, errors.New("no directory name")
+}
+
+// PlatformCheckConfig configures the verification of a platform certificate.
+type PlatformCheckConfig struct {
+	// Certificate is the platform certificate to verify.
+	Certificate *PlatformCertificate
+	// EK is the EK certificate the platform certificate must reference.
+	EK *x509.Certificate
+	// CAs are the trusted platform CA certificates: self-signed ones are used as
+	// roots, the others as intermediates. Platform CAs are not part of the trusted
+	// bundle, which only holds the TPM manufacturers CAs.
+	CAs []*x509.Certificate
+	// Now is the verification time (default: current time).
+	Now time.Time
+}
+
+func (c *PlatformCheckConfig) CheckAndSetDefaults() error {
+	if c.Certificate == nil {
+		return fmt.Errorf("platform certificate must be provided")
+	}
+	if c.EK == nil {
+		return fmt.Errorf("EK certificate must be provided")
+	}
+	if len(c.CAs) == 0 {
+		return fmt.Errorf("platform CA certificates must be provided")
+	}
+	if c.Now.IsZero() {
+		c.Now = time.Now()
+	}
+	return nil
+}
+
+// CheckPlatformCertificate verifies that the platform certificate references
+// the EK certificate, is valid at cfg.Now and is signed by a CA chaining to one
+// of the trusted roots of cfg.CAs. The verified chain of its issuer is returned.
+func CheckPlatformCertificate(cfg PlatformCheckConfig) ([]*x509.Certificate, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, fmt.Errorf("invalid platform check config: %w", err)
+	}
+	pc := cfg.Certificate
+
+	if !bytes.Equal(pc.rawHolderIssuer, cfg.EK.RawIssuer) || pc.HolderSerial.Cmp(cfg.EK.SerialNumber) != 0 {
+		return nil, fmt.Errorf("%w: it references serial %s, EK certificate serial is %s", ErrPlatformEKMismatch, pc.HolderSerial, cfg.EK.SerialNumber)
+	}
+	if cfg.Now.Before(pc.NotBefore) || cfg.Now.After(pc.NotAfter) {
+		return nil, fmt.Errorf("%w: valid from %s to %s", ErrUntrustedPlatformCertificate, pc.NotBefore.Format(time.RFC3339), pc.NotAfter.Format(time.RFC3339))
+	}
+	algo, ok := signatureAlgorithms[pc.signatureAlgorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported signature algorithm %s", ErrUntrustedPlatformCertificate, pc.signatureAlgorithm)
+	}
+
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for _, ca := range cfg.CAs {
+		if x509util.IsRoot(ca) {
+			roots.AddCert(ca)
+		} else {
+			intermediates.AddCert(ca)
+		}
+	}
+	var lastErr error = fmt.Errorf("no CA certificate matches issuer %q", pc.Issuer.String())
+	for _, issuer := range cfg.CAs {
+		if !bytes.Equal(issuer.RawSubject, pc.rawIssuer) {
+			continue
+		}
+		if err := issuer.CheckSignature(algo, pc.rawTBS, pc.signature); err != nil {
+			lastErr = fmt.Errorf("invalid signature: %w", err)
+			continue
+		}
+		chains, err := issuer.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   cfg.Now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return slices.Clone(chains[0]), nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUntrustedPlatformCertificate, lastErr)
+}
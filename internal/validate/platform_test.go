@@ -0,0 +1,141 @@
+package validate
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCheckPlatformCertificate(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	platformCA, platformCAKey := createTestIntermediate(t, root, rootKey)
+	ek := createTestEK(t, root, rootKey)
+	otherEK := createTestEK(t, platformCA, platformCAKey)
+	otherRoot, _ := createTestCA(t)
+	valid := createTestPlatformCert(t, ek, platformCA, platformCAKey)
+
+	tampered := createTestPlatformCert(t, ek, platformCA, platformCAKey)
+	tampered.signature[len(tampered.signature)-1] ^= 0xFF
+
+	tests := []struct {
+		name    string
+		cert    *PlatformCertificate
+		ek      *x509.Certificate
+		cas     []*x509.Certificate
+		now     time.Time
+		wantErr error
+	}{
+		{name: "success", cert: valid, ek: ek, cas: []*x509.Certificate{root, platformCA}},
+		{name: "error/other EK", cert: valid, ek: otherEK, cas: []*x509.Certificate{root, platformCA}, wantErr: ErrPlatformEKMismatch},
+		{name: "error/expired", cert: valid, ek: ek, cas: []*x509.Certificate{root, platformCA}, now: time.Now().Add(48 * time.Hour), wantErr: ErrUntrustedPlatformCertificate},
+		{name: "error/unknown issuer", cert: valid, ek: ek, cas: []*x509.Certificate{root}, wantErr: ErrUntrustedPlatformCertificate},
+		{name: "error/untrusted root", cert: valid, ek: ek, cas: []*x509.Certificate{otherRoot, platformCA}, wantErr: ErrUntrustedPlatformCertificate},
+		{name: "error/invalid signature", cert: tampered, ek: ek, cas: []*x509.Certificate{root, platformCA}, wantErr: ErrUntrustedPlatformCertificate},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			chain, err := CheckPlatformCertificate(PlatformCheckConfig{
+				Certificate: tc.cert,
+				EK:          tc.ek,
+				CAs:         tc.cas,
+				Now:         tc.now,
+			})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("CheckPlatformCertificate() error = %v, want %v", err, tc.wantErr)
+			}
+			if err == nil && (len(chain) != 2 || !chain[1].Equal(root)) {
+				t.Errorf("CheckPlatformCertificate() chain = %v, want the platform CA and its root", chain)
+			}
+		})
+	}
+}
+
+func TestParsePlatformCertificate(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	want := createTestPlatformCert(t, ek, root, rootKey)
+
+	pc, err := ParsePlatformCertificate(pem.EncodeToMemory(&pem.Block{Type: "ATTRIBUTE CERTIFICATE", Bytes: want.Raw}))
+	if err != nil {
+		t.Fatalf("ParsePlatformCertificate() error = %v", err)
+	}
+	if pc.HolderSerial.Cmp(ek.SerialNumber) != 0 {
+		t.Errorf("HolderSerial = %s, want %s", pc.HolderSerial, ek.SerialNumber)
+	}
+	if pc.Issuer.String() != root.Subject.String() {
+		t.Errorf("Issuer = %q, want %q", pc.Issuer, root.Subject)
+	}
+
+	if _, err := ParsePlatformCertificate(ek.Raw); err == nil {
+		t.Error("ParsePlatformCertificate() expected error for an X.509 certificate")
+	}
+	if _, err := ParsePlatformCertificate(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ek.Raw})); err == nil {
+		t.Error("ParsePlatformCertificate() expected error for a CERTIFICATE PEM block")
+	}
+}
+
+// createTestPlatformCert creates a platform certificate referencing ek, valid for a day.
+func createTestPlatformCert(t *testing.T, ek, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) *PlatformCertificate {
+	t.Helper()
+	generalNames := func(name []byte) asn1.RawValue {
+		dn, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: dn}
+	}
+	issuerForm, err := asn1.MarshalWithParams(v2Form{IssuerName: generalNames(issuer.RawSubject)}, "tag:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureAlgorithm := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}}
+	now := time.Now().UTC().Truncate(time.Second)
+	tbs, err := asn1.Marshal(attributeCertificateInfo{
+		Version: 1,
+		Holder: holder{BaseCertificateID: issuerSerial{
+			Issuer: generalNames(ek.RawIssuer),
+			Serial: ek.SerialNumber,
+		}},
+		Issuer:       asn1.RawValue{FullBytes: issuerForm},
+		Signature:    signatureAlgorithm,
+		SerialNumber: big.NewInt(42),
+		Validity:     attCertValidityPeriod{NotBefore: now.Add(-time.Hour), NotAfter: now.Add(24 * time.Hour)},
+		Attributes:   asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(tbs)
+	signature, err := ecdsa.SignASN1(rand.Reader, issuerKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(attributeCertificate{
+		Info:               asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: signatureAlgorithm,
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := ParsePlatformCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pc
+}
@@ -0,0 +1,108 @@
+package validate
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/tpm"
+)
+
+var (
+	ErrDisallowedSignatureAlgorithm = codes.New(codes.E014DisallowedSignatureAlgorithm, "certificate is signed with a disallowed signature algorithm")
+	ErrDisallowedKey                = codes.New(codes.E015DisallowedKey, "EK public key is not allowed by the key policy")
+)
+
+var (
+	// SHA1SignatureAlgorithms lists the signature algorithms relying on SHA-1 (or weaker digests).
+	SHA1SignatureAlgorithms = []x509.SignatureAlgorithm{
+		x509.MD2WithRSA,
+		x509.MD5WithRSA,
+		x509.SHA1WithRSA,
+		x509.DSAWithSHA1,
+		x509.ECDSAWithSHA1,
+	}
+)
+
+// checkSignatureAlgorithm enforces the signature algorithm policy on cert.
+//
+// A weak algorithm (see [SHA1SignatureAlgorithms]) is only reported as a warning
+// unless it belongs to the disallowed list, in which case an error is returned.
+func (c *ekchecker) checkSignatureAlgorithm(cert *x509.Certificate, disallowed []x509.SignatureAlgorithm) error {
+	alg := cert.SignatureAlgorithm
+	if slices.Contains(disallowed, alg) {
+		return fmt.Errorf("%w: %q uses %s", ErrDisallowedSignatureAlgorithm, cert.Subject.String(), alg)
+	}
+	if slices.Contains(SHA1SignatureAlgorithms, alg) {
+		c.warn(c.logger.WithField("subject", cert.Subject.String()).WithField("algorithm", alg.String()),
+			codes.W007WeakSignatureAlgorithm, "certificate is signed with a weak signature algorithm",
+			fmt.Sprintf("%q uses %s", cert.Subject.String(), alg))
+	}
+	return nil
+}
+
+// checkChainsSignatureAlgorithms enforces the signature algorithm policy on
+// every non self-signed certificate of the verified chains.
+func (c *ekchecker) checkChainsSignatureAlgorithms(chains [][]*x509.Certificate, disallowed []x509.SignatureAlgorithm) error {
+	var checked []*x509.Certificate
+	for _, chain := range chains {
+		if len(chain) < 3 {
+			continue
+		}
+		// the leaf is checked beforehand and the root's self-signature is irrelevant
+		for _, cert := range chain[1 : len(chain)-1] {
+			if slices.ContainsFunc(checked, cert.Equal) {
+				continue
+			}
+			checked = append(checked, cert)
+			if err := c.checkSignatureAlgorithm(cert, disallowed); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// KeyPolicy constrains the EK public key.
+type KeyPolicy struct {
+	// MinRSABits is the minimum accepted RSA modulus size.
+	//
+	// Optional. If zero, any RSA key size is accepted.
+	MinRSABits int
+	// AllowedCurves lists the accepted ECC key types (eg. [tpm.KeyTypeECCNistP256]).
+	//
+	// Optional. If empty, any curve is accepted.
+	AllowedCurves []tpm.KeyType
+}
+
+// check returns [ErrDisallowedKey] if the public key of cert doesn't comply with the policy.
+func (p KeyPolicy) check(cert *x509.Certificate) error {
+	kty := tpm.KeyTypeFromCert(cert)
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if p.MinRSABits > 0 && pub.N.BitLen() < p.MinRSABits {
+			return fmt.Errorf("%w: %s is below the minimum RSA key size (%d bits)", ErrDisallowedKey, kty, p.MinRSABits)
+		}
+	case *ecdsa.PublicKey:
+		if len(p.AllowedCurves) > 0 && !slices.Contains(p.AllowedCurves, kty) {
+			return fmt.Errorf("%w: %s is not an allowed curve (allowed: %s)", ErrDisallowedKey, kty, p.allowedCurves())
+		}
+	default:
+		if len(p.AllowedCurves) > 0 || p.MinRSABits > 0 {
+			return fmt.Errorf("%w: %s key cannot be evaluated against the policy", ErrDisallowedKey, kty)
+		}
+	}
+	return nil
+}
+
+func (p KeyPolicy) allowedCurves() string {
+	curves := make([]string, 0, len(p.AllowedCurves))
+	for _, c := range p.AllowedCurves {
+		curves = append(curves, c.String())
+	}
+	return strings.Join(curves, ", ")
+}
@@ -0,0 +1,155 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+)
+
+// RevocationChecker checks the revocation status of a certificate and of its
+// issuers. It allows embedders to plug a revocation backend other than CRLs
+// (eg. an internal revocation service) into the checker, see
+// [EKCheckerConfig.RevocationChecker].
+type RevocationChecker interface {
+	// IsRevoked reports whether cert or one of its issuers (ordered from the
+	// issuer of cert up to the root, which is never revoked) is revoked.
+	// An error is returned if the revocation status cannot be established,
+	// which fails the check unless the revocation check is soft.
+	IsRevoked(ctx context.Context, cert *x509.Certificate, issuers []*x509.Certificate) (bool, error)
+}
+
+// crlRevocationChecker is the default [RevocationChecker]: it downloads the
+// CRLs of the CRL distribution points of each certificate.
+type crlRevocationChecker struct {
+	c *ekchecker
+}
+
+func (r *crlRevocationChecker) IsRevoked(ctx context.Context, cert *x509.Certificate, issuers []*x509.Certificate) (bool, error) {
+	start := time.Now()
+	certs := append([]*x509.Certificate{cert}, issuers...)
+	checked, err := r.c.checkLDAPRevocation(ctx, certs)
+	if err == nil {
+		config := x509util.RevocationConfig{
+			Chain:     checked[1:],
+			FullChain: true,
+		}
+		err = r.c.verifier.Verify(ctx, checked[0], config)
+	}
+	if err == nil || errors.Is(err, x509util.ErrCertificateRevoked) {
+		// The verifier applies CRLs regardless of their scope
+		if scopeErr := r.c.checkDownloadedCRLScope(certs, start); scopeErr != nil {
+			err = scopeErr
+		}
+	}
+	if err != nil && !errors.Is(err, x509util.ErrCertificateRevoked) && !errors.Is(err, x509util.ErrCRLNotFound) {
+		// The verifier reports every CRL signature failure alike
+		if sigErr := r.c.checkDownloadedCRLSignatures(certs, start); sigErr != nil {
+			err = sigErr
+		}
+	}
+	failures := r.c.crlFailures(certs, start)
+	if errors.Is(err, x509util.ErrCRLNotFound) && len(failures) > 0 {
+		err = fmt.Errorf("%w: every CRL distribution point is unreachable (%s)", err, strings.Join(failures, "; "))
+	}
+	if errors.Is(err, x509util.ErrCertificateRevoked) {
+		return true, nil
+	}
+	return false, err
+}
+
+// checkLDAPRevocation checks the revocation status of the certificates of
+// certs (ordered from the leaf up to the root) whose only supported CRL
+// distribution points are LDAP ones, which the verifier doesn't download.
+// It returns certs where these certificates are replaced by copies without
+// distribution points, so that the verifier checks the other ones only.
+func (c *ekchecker) checkLDAPRevocation(ctx context.Context, certs []*x509.Certificate) ([]*x509.Certificate, error) {
+	checked := slices.Clone(certs)
+	for i, cert := range certs {
+		if x509util.IsRoot(cert) || !slices.ContainsFunc(cert.CRLDistributionPoints, isLDAPCRLDP) ||
+			slices.ContainsFunc(cert.CRLDistributionPoints, func(dp string) bool { return isSupportedCRLDP(dp) && !isLDAPCRLDP(dp) }) {
+			continue
+		}
+		if err := c.checkCertLDAPRevocation(ctx, cert, x509util.CertificatesAbove(cert, certs)); err != nil {
+			return nil, err
+		}
+		stripped := *cert
+		stripped.CRLDistributionPoints = nil
+		checked[i] = &stripped
+	}
+	return checked, nil
+}
+
+// checkCertLDAPRevocation checks the revocation status of cert with the CRL
+// of its first reachable LDAP distribution point, which must be signed by
+// one of issuers, like the verifier does for HTTP distribution points.
+func (c *ekchecker) checkCertLDAPRevocation(ctx context.Context, cert *x509.Certificate, issuers []*x509.Certificate) error {
+	for _, dp := range cert.CRLDistributionPoints {
+		if !isLDAPCRLDP(dp) {
+			continue
+		}
+		// Failures are reported by crlFailures
+		rl, err := c.recorder.fetchLDAP(ctx, dp)
+		if err != nil {
+			continue
+		}
+		c.logger.WithField("url", dp).Info("crl downloaded")
+		if err := checkCRLSignature(rl, issuers...); err != nil {
+			return fmt.Errorf("CRL signature verification failed: %w", err)
+		}
+		crl, err := x509util.NewCRL(rl)
+		if err != nil {
+			return err
+		}
+		if crl.IsRevoked(cert) {
+			return x509util.ErrCertificateRevoked
+		}
+		return nil
+	}
+	return x509util.ErrCRLNotFound
+}
+
+// revokedCertificate returns the first certificate of certs (roots aside)
+// listed by the CRL downloaded from one of its distribution points, if any.
+// As the verifier only reports that the chain is revoked, it tells which
+// certificate is (eg. an intermediate CA).
+func (c *ekchecker) revokedCertificate(certs []*x509.Certificate) *x509.Certificate {
+	for _, cert := range certs {
+		if x509util.IsRoot(cert) {
+			continue
+		}
+		for _, dp := range cert.CRLDistributionPoints {
+			if !isSupportedCRLDP(dp) {
+				continue
+			}
+			rl := c.crls.get(dp)
+			if rl != nil && bytes.Equal(rl.RawIssuer, cert.RawIssuer) && slices.ContainsFunc(rl.RevokedCertificateEntries, func(entry x509.RevocationListEntry) bool {
+				return entry.SerialNumber.Cmp(cert.SerialNumber) == 0
+			}) {
+				return cert
+			}
+		}
+	}
+	return nil
+}
+
+// revocationChecker returns the custom revocation checker, if any,
+// or the CRL-based one.
+func (c *ekchecker) revocationChecker() RevocationChecker {
+	if c.revocation != nil {
+		return c.revocation
+	}
+	return &crlRevocationChecker{c: c}
+}
+
+// crlBased reports whether the revocation status is checked with CRLs,
+// which requires the certificates to have CRL distribution points.
+func (c *ekchecker) crlBased() bool {
+	return c.revocation == nil
+}
@@ -0,0 +1,164 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/attest/endorsement"
+	"github.com/loicsikidi/go-utils/crypto/x509util"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+type mockRevocationChecker struct {
+	revoked bool
+	err     error
+	calls   int
+}
+
+func (m *mockRevocationChecker) IsRevoked(ctx context.Context, cert *x509.Certificate, issuers []*x509.Certificate) (bool, error) {
+	m.calls++
+	return m.revoked, m.err
+}
+
+func TestCustomRevocationChecker(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	// Without CRL distribution point
+	ekWithoutDP := createTestEKWithAIA(t, root, rootKey)
+
+	tests := []struct {
+		name        string
+		ek          *x509.Certificate
+		checker     *mockRevocationChecker
+		soft        bool
+		wantErr     error
+		wantWarning codes.Code
+	}{
+		{name: "valid", ek: ek, checker: &mockRevocationChecker{}},
+		{name: "valid/no-crl-dp", ek: ekWithoutDP, checker: &mockRevocationChecker{}},
+		{name: "revoked", ek: ek, checker: &mockRevocationChecker{revoked: true}, wantErr: x509util.ErrCertificateRevoked},
+		{name: "revoked/no-crl-dp", ek: ekWithoutDP, checker: &mockRevocationChecker{revoked: true}, wantErr: x509util.ErrCertificateRevoked},
+		{name: "unavailable", ek: ek, checker: &mockRevocationChecker{err: errUnavailable}, wantErr: errUnavailable},
+		{name: "unavailable/soft", ek: ek, checker: &mockRevocationChecker{err: errUnavailable}, soft: true, wantWarning: codes.W004RevocationCheckFailed},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					t.Errorf("unexpected download of %s", req.URL)
+					return nil, errors.New("unexpected download")
+				}},
+				RevocationChecker: tc.checker,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var warnings []Warning
+			err = checker.Check(CheckConfig{
+				EK:                  endorsement.EK{Certificate: tc.ek},
+				SoftRevocationCheck: tc.soft,
+				Warnings:            &warnings,
+			})
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Errorf("Check() error = %v, want %v", err, tc.wantErr)
+			}
+			if tc.checker.calls != 1 {
+				t.Errorf("IsRevoked() calls = %d, want 1", tc.checker.calls)
+			}
+			if tc.wantWarning != "" && !slices.ContainsFunc(warnings, func(w Warning) bool { return w.Code == tc.wantWarning }) {
+				t.Errorf("warnings = %v, want %s", warnings, tc.wantWarning)
+			}
+		})
+	}
+}
+
+func TestRevokedIntermediate(t *testing.T) {
+	t.Parallel()
+
+	const rootCRLDP = "http://crl.example.com/root.crl"
+	root, rootKey := createTestCA(t)
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate := createTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		CRLDistributionPoints: []string{rootCRLDP},
+		PublicKey:             &intermediateKey.PublicKey,
+	}, root, rootKey)
+	ek := createTestEK(t, intermediate, intermediateKey)
+	intermediateCRL := createTestCRL(t, intermediate, intermediateKey, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		revoked bool
+		wantErr error
+	}{
+		{name: "valid"},
+		{name: "revoked", revoked: true, wantErr: x509util.ErrCertificateRevoked},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var revoked []*big.Int
+			if tc.revoked {
+				revoked = append(revoked, intermediate.SerialNumber)
+			}
+			rootCRL := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour), revoked...)
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					rl := intermediateCRL
+					if req.URL.String() == rootCRLDP {
+						rl = rootCRL
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(rl.Raw))}, nil
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = checker.(*ekchecker).withCRLRecord().verifyChain(t.Context(), ek, []*x509.Certificate{intermediate}, false, false, ErrUntrustedCertificate)
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Fatalf("verifyChain() error = %v, want %v", err, tc.wantErr)
+			}
+			// The error must tell that the EK itself is not revoked
+			if err != nil && !strings.Contains(err.Error(), "Test Intermediate CA") {
+				t.Errorf("verifyChain() error = %v, want the revoked intermediate CA", err)
+			}
+		})
+	}
+}
+
+var errUnavailable = errors.New("revocation service unavailable")
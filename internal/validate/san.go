@@ -0,0 +1,121 @@
+package validate
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+
+	"github.com/loicsikidi/attest/info"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+var ErrManufacturerMismatch = codes.New(codes.E016ManufacturerMismatch, "EK certificate manufacturer does not match the TPM")
+
+var (
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+	// OIDs defined in TCG EK Credential Profile, version 2.6
+	// See section 3.1.2 "TPM Device Attributes"
+	oidTPMManufacturer = asn1.ObjectIdentifier{2, 23, 133, 2, 1}
+	oidTPMModel        = asn1.ObjectIdentifier{2, 23, 133, 2, 2}
+	oidTPMVersion      = asn1.ObjectIdentifier{2, 23, 133, 2, 3}
+)
+
+// tagDirectoryName is the GeneralName tag of a directoryName (RFC 5280, section 4.2.1.6).
+const tagDirectoryName = 4
+
+// TPMAttributes holds the TPM device attributes found in the
+// directory name of the EK certificate Subject Alternative Name.
+type TPMAttributes struct {
+	// Manufacturer is the TPM manufacturer ID (eg. "id:4E544300").
+	Manufacturer string
+	// Model is the TPM model (eg. "NPCT75x").
+	Model string
+	// Version is the TPM firmware version (eg. "id:00070002").
+	Version string
+}
+
+// ParseTPMAttributes extracts the TPM device attributes from the
+// Subject Alternative Name of cert. Attributes which are not present
+// are left empty.
+func ParseTPMAttributes(cert *x509.Certificate) (TPMAttributes, error) {
+	var attrs TPMAttributes
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var names asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &names); err != nil {
+			return attrs, fmt.Errorf("failed to parse subject alternative name: %w", err)
+		}
+		rest := names.Bytes
+		for len(rest) > 0 {
+			var (
+				name asn1.RawValue
+				err  error
+			)
+			rest, err = asn1.Unmarshal(rest, &name)
+			if err != nil {
+				return attrs, fmt.Errorf("failed to parse subject alternative name: %w", err)
+			}
+			if name.Class != asn1.ClassContextSpecific || name.Tag != tagDirectoryName {
+				continue
+			}
+			var rdns pkix.RDNSequence
+			if _, err := asn1.Unmarshal(name.Bytes, &rdns); err != nil {
+				return attrs, fmt.Errorf("failed to parse directory name: %w", err)
+			}
+			for _, rdn := range rdns {
+				for _, atv := range rdn {
+					value, _ := atv.Value.(string)
+					switch {
+					case atv.Type.Equal(oidTPMManufacturer):
+						attrs.Manufacturer = value
+					case atv.Type.Equal(oidTPMModel):
+						attrs.Model = value
+					case atv.Type.Equal(oidTPMVersion):
+						attrs.Version = value
+					}
+				}
+			}
+		}
+	}
+	return attrs, nil
+}
+
+// MatchesManufacturer reports whether the manufacturer attribute
+// designates m (compared using its hexadecimal encoding).
+func (a TPMAttributes) MatchesManufacturer(m info.Manufacturer) bool {
+	id := strings.TrimPrefix(strings.TrimSpace(a.Manufacturer), "id:")
+	return strings.EqualFold(id, m.Hex)
+}
+
+// checkManufacturer cross-checks the TPM manufacturer found in the EK
+// certificate with the one reported by the TPM. A mismatch only triggers
+// a warning unless strict is set.
+func (c *ekchecker) checkManufacturer(cert *x509.Certificate, m info.Manufacturer, strict bool) error {
+	attrs, err := ParseTPMAttributes(cert)
+	if err != nil {
+		return err
+	}
+	if attrs.Manufacturer == "" {
+		c.warn(c.logger, codes.W008MissingManufacturer, "EK certificate has no TPM manufacturer attribute", "")
+		return nil
+	}
+	if attrs.MatchesManufacturer(m) {
+		c.logger.WithField("id", attrs.Manufacturer).
+			WithField("model", attrs.Model).
+			Debug("EK certificate manufacturer matches the TPM")
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("%w: certificate has %q, TPM reports %q (%s)", ErrManufacturerMismatch, attrs.Manufacturer, "id:"+m.Hex, m.ASCII)
+	}
+	c.warn(c.logger.WithField("certificate", attrs.Manufacturer).WithField("tpm", "id:"+m.Hex),
+		codes.W009ManufacturerMismatch, "EK certificate manufacturer does not match the TPM",
+		fmt.Sprintf("certificate has %q, TPM reports %q", attrs.Manufacturer, "id:"+m.Hex))
+	return nil
+}
@@ -0,0 +1,129 @@
+package validate
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"testing"
+
+	"github.com/loicsikidi/attest/info"
+
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+func TestParseTPMAttributes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want TPMAttributes
+	}{
+		{
+			name: "tpm-attributes",
+			cert: newSANCertificate(t, "id:4E544300", "NPCT75x", "id:00070002"),
+			want: TPMAttributes{Manufacturer: "id:4E544300", Model: "NPCT75x", Version: "id:00070002"},
+		},
+		{
+			name: "no-san",
+			cert: &x509.Certificate{},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseTPMAttributes(tc.cert)
+			if err != nil {
+				t.Fatalf("ParseTPMAttributes() error = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("ParseTPMAttributes() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckManufacturer(t *testing.T) {
+	t.Parallel()
+
+	nuvoton := info.GetManufacturerByID(0x4E544300)
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		strict  bool
+		wantErr error
+	}{
+		{
+			name: "success/match",
+			cert: newSANCertificate(t, "id:4E544300", "NPCT75x", ""),
+		},
+		{
+			name: "success/lowercase-match",
+			cert: newSANCertificate(t, "id:4e544300", "", ""),
+		},
+		{
+			name:   "success/missing-attribute",
+			cert:   &x509.Certificate{},
+			strict: true,
+		},
+		{
+			name: "success/mismatch-is-only-a-warning",
+			cert: newSANCertificate(t, "id:49465800", "", ""),
+		},
+		{
+			name:    "error/mismatch-in-strict-mode",
+			cert:    newSANCertificate(t, "id:49465800", "", ""),
+			strict:  true,
+			wantErr: ErrManufacturerMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &ekchecker{logger: log.New(log.WithNoop())}
+			err := c.checkManufacturer(tc.cert, nuvoton, tc.strict)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("checkManufacturer() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// newSANCertificate returns a certificate whose Subject Alternative Name
+// holds a directory name with the provided (non-empty) TPM attributes.
+func newSANCertificate(t *testing.T, manufacturer, model, version string) *x509.Certificate {
+	t.Helper()
+	var rdn pkix.RelativeDistinguishedNameSET
+	for _, attr := range []struct {
+		oid   asn1.ObjectIdentifier
+		value string
+	}{
+		{oidTPMManufacturer, manufacturer},
+		{oidTPMModel, model},
+		{oidTPMVersion, version},
+	} {
+		if attr.value != "" {
+			rdn = append(rdn, pkix.AttributeTypeAndValue{Type: attr.oid, Value: attr.value})
+		}
+	}
+	name, err := asn1.Marshal(pkix.RDNSequence{rdn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirName, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tagDirectoryName, IsCompound: true, Bytes: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	san, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: dirName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &x509.Certificate{Extensions: []pkix.Extension{{Id: oidSubjectAltName, Critical: true, Value: san}}}
+}
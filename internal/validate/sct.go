@@ -0,0 +1,299 @@
+package validate
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+// ErrMissingSCT is returned when SCTs are required (see [CheckConfig.RequireSCT])
+// and the EK certificate has no valid SCT from a known Certificate Transparency log.
+var ErrMissingSCT = codes.New(codes.E029MissingSCT, "EK certificate has no valid SCT from a known CT log")
+
+// oidSCTList is the embedded SCT list extension (RFC 6962, section 3.3).
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// Signature algorithms of the digitally-signed struct (RFC 5246, section 7.4.1.4.1).
+const (
+	sctHashSHA256   = 4
+	sctSignatureRSA = 1
+	sctSignatureECD = 3
+)
+
+// CTLog is a Certificate Transparency log whose SCTs are trusted.
+type CTLog struct {
+	Description string
+	// ID is the SHA-256 hash of the DER encoded public key of the log.
+	ID [sha256.Size]byte
+	// PublicKey verifies the signature of the SCTs of the log.
+	PublicKey crypto.PublicKey
+}
+
+// ctLogList is the JSON log list published by CT programs (v3 schema),
+// of which only the logs keys are used.
+type ctLogList struct {
+	Operators []struct {
+		Logs []struct {
+			Description string `json:"description"`
+			Key         []byte `json:"key"`
+		} `json:"logs"`
+	} `json:"operators"`
+}
+
+// ParseCTLogList parses a CT log list in the JSON format (v3) published by
+// CT programs (eg. https://www.gstatic.com/ct/log_list/v3/log_list.json).
+func ParseCTLogList(data []byte) ([]CTLog, error) {
+	var list ctLogList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse CT log list: %w", err)
+	}
+	var logs []CTLog
+	for _, operator := range list.Operators {
+		for _, l := range operator.Logs {
+			pub, err := x509.ParsePKIXPublicKey(l.Key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid key of CT log %q: %w", l.Description, err)
+			}
+			logs = append(logs, CTLog{Description: l.Description, ID: sha256.Sum256(l.Key), PublicKey: pub})
+		}
+	}
+	if len(logs) == 0 {
+		return nil, errors.New("CT log list has no log")
+	}
+	return logs, nil
+}
+
+// sct is a Signed Certificate Timestamp (RFC 6962, section 3.2).
+type sct struct {
+	LogID      [sha256.Size]byte
+	Timestamp  uint64
+	Extensions []byte
+	HashAlg    uint8
+	SigAlg     uint8
+	Signature  []byte
+}
+
+// time returns the timestamp of the SCT (milliseconds since the epoch).
+func (s *sct) time() time.Time {
+	return time.UnixMilli(int64(s.Timestamp))
+}
+
+// parseSCTs returns the SCTs embedded in cert, if any.
+func parseSCTs(cert *x509.Certificate) ([]sct, error) {
+	var raw []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			if _, err := asn1.Unmarshal(ext.Value, &raw); err != nil {
+				return nil, fmt.Errorf("failed to parse SCT list: %w", err)
+			}
+			break
+		}
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	list, rest, err := readVector(raw, 2)
+	if err != nil || len(rest) > 0 {
+		return nil, errors.New("failed to parse SCT list: malformed list")
+	}
+	var scts []sct
+	for len(list) > 0 {
+		var data []byte
+		if data, list, err = readVector(list, 2); err != nil {
+			return nil, fmt.Errorf("failed to parse SCT list: %w", err)
+		}
+		s, err := parseSCT(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SCT: %w", err)
+		}
+		scts = append(scts, *s)
+	}
+	return scts, nil
+}
+
+func parseSCT(data []byte) (*sct, error) {
+	// version (1), log ID (32), timestamp (8)
+	if len(data) < 1+sha256.Size+8 {
+		return nil, errors.New("truncated SCT")
+	}
+	if data[0] != 0 {
+		return nil, fmt.Errorf("unsupported SCT version %d", data[0]+1)
+	}
+	s := &sct{}
+	copy(s.LogID[:], data[1:])
+	s.Timestamp = binary.BigEndian.Uint64(data[1+sha256.Size:])
+	rest := data[1+sha256.Size+8:]
+	var err error
+	if s.Extensions, rest, err = readVector(rest, 2); err != nil {
+		return nil, err
+	}
+	if len(rest) < 2 {
+		return nil, errors.New("truncated SCT signature")
+	}
+	s.HashAlg, s.SigAlg = rest[0], rest[1]
+	if s.Signature, rest, err = readVector(rest[2:], 2); err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after SCT")
+	}
+	return s, nil
+}
+
+// readVector reads a TLS vector whose length is encoded on size bytes.
+func readVector(data []byte, size int) ([]byte, []byte, error) {
+	if len(data) < size {
+		return nil, nil, errors.New("truncated vector length")
+	}
+	var n int
+	for _, b := range data[:size] {
+		n = n<<8 | int(b)
+	}
+	if len(data)-size < n {
+		return nil, nil, errors.New("truncated vector")
+	}
+	return data[size : size+n], data[size+n:], nil
+}
+
+// verify checks the signature of s by log over the precertificate entry of
+// cert issued by issuer (RFC 6962, section 3.2).
+func (s *sct) verify(log CTLog, cert, issuer *x509.Certificate) error {
+	tbs, err := precertTBS(cert)
+	if err != nil {
+		return err
+	}
+	var signed bytes.Buffer
+	signed.Write([]byte{0, 0}) // v1, certificate_timestamp
+	_ = binary.Write(&signed, binary.BigEndian, s.Timestamp)
+	signed.Write([]byte{0, 1}) // precert_entry
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	signed.Write(issuerKeyHash[:])
+	signed.Write([]byte{byte(len(tbs) >> 16), byte(len(tbs) >> 8), byte(len(tbs))})
+	signed.Write(tbs)
+	_ = binary.Write(&signed, binary.BigEndian, uint16(len(s.Extensions)))
+	signed.Write(s.Extensions)
+
+	if s.HashAlg != sctHashSHA256 {
+		return fmt.Errorf("unsupported SCT hash algorithm %d", s.HashAlg)
+	}
+	digest := sha256.Sum256(signed.Bytes())
+	switch pub := log.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if s.SigAlg != sctSignatureECD || !ecdsa.VerifyASN1(pub, digest[:], s.Signature) {
+			return errors.New("invalid SCT signature")
+		}
+	case *rsa.PublicKey:
+		if s.SigAlg != sctSignatureRSA {
+			return errors.New("invalid SCT signature")
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], s.Signature); err != nil {
+			return fmt.Errorf("invalid SCT signature: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported key of CT log %q", log.Description)
+	}
+	return nil
+}
+
+// precertTBS returns the TBSCertificate of cert without its SCT list
+// extension, which is what the CT log signed.
+func precertTBS(cert *x509.Certificate) ([]byte, error) {
+	var tbs asn1.RawValue
+	if _, err := asn1.Unmarshal(cert.RawTBSCertificate, &tbs); err != nil {
+		return nil, fmt.Errorf("failed to parse TBS certificate: %w", err)
+	}
+	var fields []byte
+	for rest := tbs.Bytes; len(rest) > 0; {
+		var field asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			return nil, fmt.Errorf("failed to parse TBS certificate: %w", err)
+		}
+		if field.Class != asn1.ClassContextSpecific || field.Tag != 3 {
+			fields = append(fields, field.FullBytes...)
+			continue
+		}
+		// Extensions: [3] EXPLICIT SEQUENCE OF Extension
+		var exts []pkix.Extension
+		if _, err := asn1.Unmarshal(field.Bytes, &exts); err != nil {
+			return nil, fmt.Errorf("failed to parse extensions: %w", err)
+		}
+		var kept []pkix.Extension
+		for _, ext := range exts {
+			if !ext.Id.Equal(oidSCTList) {
+				kept = append(kept, ext)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		seq, err := asn1.Marshal(kept)
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 3, IsCompound: true, Bytes: seq})
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, wrapped...)
+	}
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: fields})
+}
+
+// checkSCT verifies the SCTs embedded in cert, issued by issuer, against the
+// known CT logs. An error wrapping [ErrMissingSCT] is returned if none is
+// valid and required is set, otherwise it is only logged.
+func (c *ekchecker) checkSCT(cert, issuer *x509.Certificate, required bool) error {
+	err := c.verifySCTs(cert, issuer)
+	if err == nil {
+		return nil
+	}
+	if required {
+		return fmt.Errorf("%w: %v", ErrMissingSCT, err)
+	}
+	c.warn(c.logger.WithError(err), codes.W012SCTNotVerified, "no valid SCT from a known CT log", err.Error())
+	return nil
+}
+
+// verifySCTs returns nil if at least one SCT of cert is validly signed by a known CT log.
+func (c *ekchecker) verifySCTs(cert, issuer *x509.Certificate) error {
+	scts, err := parseSCTs(cert)
+	if err != nil {
+		return err
+	}
+	if len(scts) == 0 {
+		return errors.New("no embedded SCT")
+	}
+	var failures []string
+	for _, s := range scts {
+		i := slices.IndexFunc(c.ctLogs, func(l CTLog) bool { return l.ID == s.LogID })
+		if i < 0 {
+			failures = append(failures, "SCT of unknown log "+hex.EncodeToString(s.LogID[:]))
+			continue
+		}
+		if err := s.verify(c.ctLogs[i], cert, issuer); err != nil {
+			failures = append(failures, fmt.Sprintf("SCT of log %q: %v", c.ctLogs[i].Description, err))
+			continue
+		}
+		c.logger.WithField("log", c.ctLogs[i].Description).
+			WithField("timestamp", s.time().UTC()).
+			Info("valid SCT")
+		return nil
+	}
+	return errors.New(strings.Join(failures, "; "))
+}
@@ -0,0 +1,214 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+func TestParseCTLogList(t *testing.T) {
+	t.Parallel()
+
+	logKey := createTestCTLogKey(t)
+	der, err := x509.MarshalPKIXPublicKey(&logKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := fmt.Sprintf(`{"version":"1","operators":[{"name":"Example","logs":[{"description":"Example log","log_id":"ignored","key":%q,"url":"https://ct.example.com/"}]}]}`,
+		base64.StdEncoding.EncodeToString(der))
+
+	logs, err := ParseCTLogList([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Description != "Example log" || logs[0].ID != sha256.Sum256(der) {
+		t.Errorf("ParseCTLogList() = %+v, want the example log", logs)
+	}
+
+	for _, invalid := range []string{`{"operators":[]}`, `{"operators":[{"logs":[{"key":"AAAA"}]}]}`, `not json`} {
+		if _, err := ParseCTLogList([]byte(invalid)); err == nil {
+			t.Errorf("ParseCTLogList(%s) error = nil, want an error", invalid)
+		}
+	}
+}
+
+func TestPrecertTBS(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	tmpl := testSCTTemplate()
+	precert := createTestCert(t, tmpl, root, rootKey)
+	tmpl.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: []byte{0x04, 0x02, 0x00, 0x00}}}
+	cert := createTestCert(t, tmpl, root, rootKey)
+
+	tbs, err := precertTBS(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tbs, precert.RawTBSCertificate) {
+		t.Error("precertTBS() differs from the TBS certificate without SCT list")
+	}
+}
+
+func TestCheckSCT(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	logKey := createTestCTLogKey(t)
+	otherLogKey := createTestCTLogKey(t)
+	logs := []CTLog{newTestCTLog(t, logKey)}
+
+	withSCT := createTestSCTCert(t, root, rootKey, logKey, false)
+	tampered := createTestSCTCert(t, root, rootKey, logKey, true)
+	unknownLog := createTestSCTCert(t, root, rootKey, otherLogKey, false)
+	withoutSCT := createTestEK(t, root, rootKey)
+
+	tests := []struct {
+		name        string
+		cert        *x509.Certificate
+		required    bool
+		wantErr     bool
+		wantWarning bool
+	}{
+		{name: "valid", cert: withSCT},
+		{name: "valid/required", cert: withSCT, required: true},
+		{name: "invalid-signature", cert: tampered, wantWarning: true},
+		{name: "invalid-signature/required", cert: tampered, required: true, wantErr: true},
+		{name: "unknown-log/required", cert: unknownLog, required: true, wantErr: true},
+		{name: "no-sct", cert: withoutSCT, wantWarning: true},
+		{name: "no-sct/required", cert: withoutSCT, required: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var warnings []Warning
+			c := (&ekchecker{logger: log.New(log.WithNoop()), ctLogs: logs}).withWarnings(&warnings)
+			err := c.checkSCT(tc.cert, root, tc.required)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("checkSCT() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrMissingSCT) {
+				t.Errorf("checkSCT() error = %v, want %v", err, ErrMissingSCT)
+			}
+			if gotWarning := len(warnings) == 1 && warnings[0].Code == codes.W012SCTNotVerified; gotWarning != tc.wantWarning {
+				t.Errorf("checkSCT() warnings = %v, wantWarning %v", warnings, tc.wantWarning)
+			}
+		})
+	}
+}
+
+func createTestCTLogKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestCTLog(t *testing.T, key *ecdsa.PrivateKey) CTLog {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return CTLog{Description: "Test log", ID: sha256.Sum256(der), PublicKey: &key.PublicKey}
+}
+
+func testSCTTemplate() *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		NotBefore:    time.Now().Add(-time.Hour).Truncate(time.Second),
+		NotAfter:     time.Now().Add(time.Hour).Truncate(time.Second),
+		KeyUsage:     x509.KeyUsageKeyAgreement,
+	}
+}
+
+// createTestSCTCert creates an EK certificate issued by issuer embedding an
+// SCT of the log of logKey (whose signature is invalid if tampered is set).
+func createTestSCTCert(t *testing.T, issuer *x509.Certificate, issuerKey, logKey *ecdsa.PrivateKey, tampered bool) *x509.Certificate {
+	t.Helper()
+	tmpl := testSCTTemplate()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.PublicKey = &key.PublicKey
+	tbs := createTestCert(t, tmpl, issuer, issuerKey).RawTBSCertificate
+
+	// Signed data of the precertificate entry (RFC 6962, section 3.2)
+	timestamp := uint64(time.Now().UnixMilli())
+	var signed bytes.Buffer
+	signed.Write([]byte{0, 0})
+	_ = binary.Write(&signed, binary.BigEndian, timestamp)
+	signed.Write([]byte{0, 1})
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	signed.Write(issuerKeyHash[:])
+	signed.Write([]byte{byte(len(tbs) >> 16), byte(len(tbs) >> 8), byte(len(tbs))})
+	signed.Write(tbs)
+	signed.Write([]byte{0, 0})
+	if tampered {
+		signed.WriteByte(0)
+	}
+	digest := sha256.Sum256(signed.Bytes())
+	sig, err := ecdsa.SignASN1(rand.Reader, logKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logID := newTestCTLog(t, logKey).ID
+	var sct bytes.Buffer
+	sct.WriteByte(0)
+	sct.Write(logID[:])
+	_ = binary.Write(&sct, binary.BigEndian, timestamp)
+	sct.Write([]byte{0, 0}) // no extension
+	sct.Write([]byte{sctHashSHA256, sctSignatureECD})
+	_ = binary.Write(&sct, binary.BigEndian, uint16(len(sig)))
+	sct.Write(sig)
+
+	var list bytes.Buffer
+	_ = binary.Write(&list, binary.BigEndian, uint16(sct.Len()+2))
+	_ = binary.Write(&list, binary.BigEndian, uint16(sct.Len()))
+	list.Write(sct.Bytes())
+	value, err := asn1.Marshal(list.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: value}}
+	return createTestCert(t, tmpl, issuer, issuerKey)
+}
+
+func createTestCert(t testing.TB, tmpl, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	pub := tmpl.PublicKey
+	if pub == nil {
+		pub = &issuerKey.PublicKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, pub, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
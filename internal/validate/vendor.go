@@ -0,0 +1,86 @@
+package validate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+)
+
+// ErrUnknownVendor is returned when the trusted bundle holds no certificate for a vendor.
+var ErrUnknownVendor = errors.New("vendor not found in the trusted bundle")
+
+// ownerPrefix introduces the vendor owning the next certificate of a bundle.
+const ownerPrefix = "# Owner:"
+
+// VendorCertificates holds the certificates of the trusted bundle owned by a vendor.
+type VendorCertificates struct {
+	Vendor        apiv1beta.VendorID
+	Roots         []*x509.Certificate
+	Intermediates []*x509.Certificate
+}
+
+// GetVendorCertificates returns the root and intermediate certificates that
+// the trusted bundle holds for vendor. [apiv1beta.TrustedBundle] only exposes
+// pools merging every vendor, hence the certificates are read from the raw
+// bundles, where each one is preceded by its owner.
+func GetVendorCertificates(tb apiv1beta.TrustedBundle, vendor apiv1beta.VendorID) (*VendorCertificates, error) {
+	if !slices.Contains(tb.GetVendors(), vendor) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVendor, vendor)
+	}
+	roots, err := parseVendorCertificates(tb.GetRawRoot(), vendor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse root bundle: %w", err)
+	}
+	intermediates, err := parseVendorCertificates(tb.GetRawIntermediate(), vendor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse intermediate bundle: %w", err)
+	}
+	return &VendorCertificates{
+		Vendor:        vendor,
+		Roots:         roots,
+		Intermediates: intermediates,
+	}, nil
+}
+
+// parseVendorCertificates returns the certificates of the PEM bundle data owned by vendor.
+func parseVendorCertificates(data []byte, vendor apiv1beta.VendorID) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	var owner apiv1beta.VendorID
+	var block strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if after, ok := strings.CutPrefix(line, ownerPrefix); ok {
+			owner = apiv1beta.VendorID(strings.TrimSpace(after))
+			continue
+		}
+		if strings.HasPrefix(line, "-----BEGIN ") {
+			block.Reset()
+		}
+		block.WriteString(line)
+		block.WriteString("\n")
+		if !strings.HasPrefix(line, "-----END ") || owner != vendor {
+			continue
+		}
+		p, _ := pem.Decode([]byte(block.String()))
+		if p == nil || p.Type != "CERTIFICATE" {
+			return nil, errors.New("failed to decode PEM block")
+		}
+		cert, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	return certs, nil
+}
@@ -0,0 +1,97 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/loicsikidi/tpm-ca-certificates/pkg/apiv1beta"
+)
+
+// rawTrustedBundle is a trusted bundle made of raw PEM bundles.
+type rawTrustedBundle struct {
+	apiv1beta.TrustedBundle
+	vendors            []apiv1beta.VendorID
+	root, intermediate []byte
+}
+
+func (b *rawTrustedBundle) GetVendors() []apiv1beta.VendorID { return b.vendors }
+func (b *rawTrustedBundle) GetRawRoot() []byte               { return b.root }
+func (b *rawTrustedBundle) GetRawIntermediate() []byte       { return b.intermediate }
+
+func TestGetVendorCertificates(t *testing.T) {
+	t.Parallel()
+
+	ifxRoot, ifxKey := createTestCA(t)
+	ifxIntermediate, _ := createTestIntermediate(t, ifxRoot, ifxKey)
+	ntcRoot, _ := createTestCA(t)
+
+	tb := &rawTrustedBundle{
+		vendors:      []apiv1beta.VendorID{apiv1beta.IFX, apiv1beta.NTC},
+		root:         encodeTestBundle(t, apiv1beta.IFX, ifxRoot, apiv1beta.NTC, ntcRoot),
+		intermediate: encodeTestBundle(t, apiv1beta.IFX, ifxIntermediate),
+	}
+
+	tests := []struct {
+		name              string
+		vendor            apiv1beta.VendorID
+		wantRoots         []*x509.Certificate
+		wantIntermediates []*x509.Certificate
+		wantErr           error
+	}{
+		{name: "ifx", vendor: apiv1beta.IFX, wantRoots: []*x509.Certificate{ifxRoot}, wantIntermediates: []*x509.Certificate{ifxIntermediate}},
+		{name: "ntc", vendor: apiv1beta.NTC, wantRoots: []*x509.Certificate{ntcRoot}},
+		{name: "unknown", vendor: apiv1beta.STM, wantErr: ErrUnknownVendor},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := GetVendorCertificates(tb, tc.vendor)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("GetVendorCertificates() error = %v, want %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !equalCerts(got.Roots, tc.wantRoots) {
+				t.Errorf("GetVendorCertificates() roots = %d certificates, want %d", len(got.Roots), len(tc.wantRoots))
+			}
+			if !equalCerts(got.Intermediates, tc.wantIntermediates) {
+				t.Errorf("GetVendorCertificates() intermediates = %d certificates, want %d", len(got.Intermediates), len(tc.wantIntermediates))
+			}
+		})
+	}
+}
+
+func equalCerts(a, b []*x509.Certificate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeTestBundle encodes pairs of vendor and certificate as a PEM bundle.
+func encodeTestBundle(t *testing.T, entries ...any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("## tpm-ca-certificates.pem\n")
+	for i := 0; i < len(entries); i += 2 {
+		cert := entries[i+1].(*x509.Certificate)
+		fmt.Fprintf(&buf, "\n# Certificate: %s\n# Owner: %s\n", cert.Subject.CommonName, entries[i])
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
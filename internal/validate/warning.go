@@ -0,0 +1,41 @@
+package validate
+
+import (
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+	"github.com/loicsikidi/tpm-trust/internal/log"
+)
+
+// Warning is a soft issue found by a check, which doesn't change its outcome.
+// Its code is stable (see [codes]) unlike its message.
+type Warning struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+// withWarnings returns a copy of the checker collecting the warnings
+// of a check into warnings (if set).
+func (c *ekchecker) withWarnings(warnings *[]Warning) *ekchecker {
+	checker := *c
+	checker.warnings = warnings
+	return &checker
+}
+
+// warn logs msg with entry (eg. holding the URL of a CRL distribution point)
+// and collects it as a warning of the given code, detail (if any) completing
+// the message (eg. with the URL).
+func (c *ekchecker) warn(entry log.FieldLogger, code codes.Code, msg, detail string) {
+	entry.WithField("code", code).Warn(msg)
+	c.collect(code, msg, detail)
+}
+
+// collect collects msg as a warning of the given code without logging it,
+// detail (if any) completing the message.
+func (c *ekchecker) collect(code codes.Code, msg, detail string) {
+	if c.warnings == nil {
+		return
+	}
+	if detail != "" {
+		msg += " (" + detail + ")"
+	}
+	*c.warnings = append(*c.warnings, Warning{Code: code, Message: msg})
+}
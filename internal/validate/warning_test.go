@@ -0,0 +1,70 @@
+package validate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/loicsikidi/attest/endorsement"
+
+	"github.com/loicsikidi/tpm-trust/internal/codes"
+)
+
+func TestCheckWarnings(t *testing.T) {
+	t.Parallel()
+
+	root, rootKey := createTestCA(t)
+	ek := createTestEK(t, root, rootKey)
+	crl := createTestCRL(t, root, rootKey, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name      string
+		crl       []byte // nil: CRL DP unreachable
+		soft      bool
+		wantCodes []codes.Code
+	}{
+		// The test EK has no EK Extended Key Usage
+		{name: "reachable", crl: crl.Raw, wantCodes: []codes.Code{codes.W006MissingEKU}},
+		{name: "soft/unreachable", soft: true, wantCodes: []codes.Code{codes.W006MissingEKU, codes.W003UnreachableCRLDP, codes.W004RevocationCheckFailed}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker, err := NewEKChecker(EKCheckerConfig{
+				TrustedBundle: &certsTrustedBundle{certs: []*x509.Certificate{root}},
+				HttpClient: &mockHTTPClient{doFunc: func(req *http.Request) (*http.Response, error) {
+					if tc.crl == nil {
+						return nil, errors.New("network is unreachable")
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(tc.crl))}, nil
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var warnings []Warning
+			err = checker.Check(CheckConfig{EK: endorsement.EK{Certificate: ek}, SoftRevocationCheck: tc.soft, Warnings: &warnings})
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			var codes []codes.Code
+			for _, w := range warnings {
+				if !slices.Contains(codes, w.Code) {
+					codes = append(codes, w.Code)
+				}
+			}
+			if !slices.Equal(codes, tc.wantCodes) {
+				t.Errorf("Check() warnings = %v, want codes %v", warnings, tc.wantCodes)
+			}
+		})
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"github.com/caarlos0/log"
 	"github.com/loicsikidi/tpm-trust/cmd/audit"
 	"github.com/loicsikidi/tpm-trust/cmd/certificates"
+	"github.com/loicsikidi/tpm-trust/cmd/debug"
 	"github.com/loicsikidi/tpm-trust/cmd/info"
 	versionCmd "github.com/loicsikidi/tpm-trust/cmd/version"
 	"github.com/loicsikidi/tpm-trust/internal"
+	"github.com/loicsikidi/tpm-trust/internal/privilege"
 	"github.com/spf13/cobra"
 )
 
@@ -30,16 +32,27 @@ certificate against a trusted bundle of TPM manufacturer root certificates.`,
 		SilenceErrors: true,
 	}
 
-	rootCmd.AddCommand(audit.NewCommand())
+	elevation := privilege.Config{}
+	rootCmd.PersistentFlags().StringVar(&elevation.SudoCommand, "sudo-command", "sudo", "Command used to gain the privileges required to access the TPM (Linux)")
+	rootCmd.PersistentFlags().BoolVar(&elevation.NonInteractive, "non-interactive", false, "Fail instead of prompting for a password when privileges are required (sudo -n, only supported with sudo)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return privilege.Configure(elevation)
+	}
+
+	versionInfo := buildVersion(version, builtBy)
+
+	rootCmd.AddCommand(audit.NewCommand(versionInfo))
+	rootCmd.AddCommand(audit.NewServeCommand(versionInfo))
 	rootCmd.AddCommand(certificates.NewCommand())
 	rootCmd.AddCommand(info.NewCommand())
-	rootCmd.AddCommand(versionCmd.NewCommand(buildVersion(version, builtBy)))
+	rootCmd.AddCommand(debug.NewCommand())
+	rootCmd.AddCommand(versionCmd.NewCommand(versionInfo))
 
 	if err := rootCmd.Execute(); err != nil {
 		if !errors.Is(err, internal.ErrSilence) {
 			log.WithError(err).Error("command failed")
 		}
-		os.Exit(1)
+		os.Exit(internal.ExitCode(err))
 	}
 }
 